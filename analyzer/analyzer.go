@@ -1,37 +1,53 @@
 package analyzer
 
 import (
-	"bufio"
-	"fmt"
+	"context"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/parsabordbar/ctx3/filetree"
+	ignore "github.com/sabhiram/go-gitignore"
 )
 
 type FileInfo struct {
-	Name         string `json:"name" toon:"name"`
-	Type         string `json:"type" toon:"type"`
-	Path         string `json:"path" toon:"path"`
-	Size         int64  `json:"size" toon:"size"`
-	Lines        int    `json:"lines" toon:"lines"`
-	LastEdited   string `json:"lastEdited" toon:"last_edited"`
-	IsEntryPoint bool   `json:"isEntryPoint" toon:"is_entry_point"`
+	Name          string `json:"name" toon:"name"`
+	Type          string `json:"type" toon:"type"` // raw extension, "file" when there is none
+	Language      string `json:"language" toon:"language"`
+	LanguageType  string `json:"languageType,omitempty" toon:"language_type"`
+	Vendored      bool   `json:"vendored,omitempty" toon:"vendored"`
+	Generated     bool   `json:"generated,omitempty" toon:"generated"`
+	Documentation bool   `json:"documentation,omitempty" toon:"documentation"`
+	Path          string `json:"path" toon:"path"`
+	Size          int64  `json:"size" toon:"size"`
+	Lines         int    `json:"lines" toon:"lines"`
+	Code          int    `json:"code" toon:"code"`
+	Comment       int    `json:"comment" toon:"comment"`
+	Blank         int    `json:"blank" toon:"blank"`
+	Tokens        int    `json:"tokens" toon:"tokens"` // estimated, see TokenCounter
+	LastEdited    string `json:"lastEdited" toon:"last_edited"`
+	IsEntryPoint  bool   `json:"isEntryPoint" toon:"is_entry_point"`
+	EntryReason   string `json:"entryPointReason,omitempty" toon:"entry_point_reason"`
 }
 
 type ProjectContext struct {
-	Root         string     `json:"root" toon:"root"`
-	Files        []FileInfo `json:"files" toon:"files"`
-	TotalFiles   int        `json:"total_files" toon:"total_files"`
-	TotalDirs    int        `json:"total_dirs" toon:"total_dirs"`
-	Dependencies []string   `json:"dependencies" toon:"dependencies"`
-	Readme       string     `json:"readme" toon:"readme"`
-}
+	Root         string            `json:"root" toon:"root"`
+	Name         string            `json:"name,omitempty" toon:"name"`
+	Files        []FileInfo        `json:"files" toon:"files"`
+	TotalFiles   int               `json:"total_files" toon:"total_files"`
+	TotalDirs    int               `json:"total_dirs" toon:"total_dirs"`
+	Dependencies []Dependency      `json:"dependencies" toon:"dependencies"`
+	Lockfiles    []LockfileSummary `json:"lockfiles,omitempty" toon:"lockfiles"`
+	Languages    []LanguageStat    `json:"languages" toon:"languages"`
+	Readme       string            `json:"readme" toon:"readme"`
+	EntryPoints  []EntryPoint      `json:"entryPoints,omitempty" toon:"entry_points"`
 
-var entryFileNames [20]string = [20]string{
-	"main.go", "index.js", "app.py", "server.js", "main.py",
-	"app.js", "index.py", "server.go", "main.ts", "app.ts",
-	"index.ts", "server.ts", "main.rb", "app.rb", "index.rb",
-	"server.rb", "main.php", "app.php", "index.php", "server.php",
+	// Set on workspace roots: the detected workspace and one context per member.
+	Workspace *Workspace       `json:"workspace,omitempty" toon:"workspace"`
+	Packages  []ProjectContext `json:"packages,omitempty" toon:"packages"`
+	// Set on workspace members: the other members this package depends on.
+	LocalDependencies []string `json:"localDependencies,omitempty" toon:"local_dependencies"`
 }
 
 var OutputJSON bool
@@ -44,134 +60,234 @@ func min(a, b int) int {
 	return b
 }
 
-func countLines(path string) int {
-	file, err := os.Open(path)
-	if err != nil {
-		return 0
-	}
-	defer file.Close()
+// linkedInfo describes the file a symbolic link points to, under the
+// link's name.
+type linkedInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (i linkedInfo) Name() string { return i.name }
 
-	scanner := bufio.NewScanner(file)
-	lines := 0
-	for scanner.Scan() {
-		lines++
+func readHead(path string, n int) []byte {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
 	}
-	return lines
+	defer f.Close()
+	buf := make([]byte, n)
+	m, _ := io.ReadFull(f, buf)
+	return buf[:m]
 }
 
-func is_entry_point(name string) bool {
-	for _, entry := range entryFileNames {
-		if name == entry {
-			return true
-		}
-	}
-	return false
+// AnalyzeOptions tunes which files AnalyzeProjectWithOptions looks at.
+type AnalyzeOptions struct {
+	// RespectGitignore skips files matched by the root .gitignore.
+	RespectGitignore bool
+	// Ignores hides build outputs, caches and the like, as in print and
+	// pack. nil loads them from root, falling back to the built-in
+	// defaults if a config file is malformed.
+	Ignores *filetree.IgnoreRules
 }
 
-func CollectFileStats(ctx *ProjectContext) map[string]int64 {
-	counts := make(map[string]int64)
+// AnalyzeProject collects the context of root. When root is a monorepo
+// (go.work, npm/pnpm or Cargo workspaces) every member also gets its own
+// ProjectContext in Packages.
+func AnalyzeProject(root string) ProjectContext {
+	pc, _ := AnalyzeProjectWithOptions(context.Background(), root, AnalyzeOptions{})
+	return pc
+}
 
-	for _, file := range ctx.Files {
-		ext := file.Type
-		if ext == "" {
-			ext = "other"
+// AnalyzeProjectWithOptions is AnalyzeProject with explicit options. It
+// stops with ctx's error when ctx is done.
+func AnalyzeProjectWithOptions(ctx context.Context, root string, opts AnalyzeOptions) (ProjectContext, error) {
+	if opts.Ignores == nil {
+		rules, err := filetree.LoadIgnoreRules(root, false)
+		if err != nil {
+			rules, _ = filetree.DefaultIgnoreRules()
 		}
-		counts[ext] += file.Size
+		opts.Ignores = rules
 	}
-	return counts
-}
-
-func FilePercentage(counts map[string]int64) map[string]float64 {
-	var total int64
-	for _, v := range counts {
-		total += v
+	// links are followed to files inside root only
+	confine, err := filepath.Abs(root)
+	if err == nil {
+		if real, err := filepath.EvalSymlinks(confine); err == nil {
+			confine = real
+		}
 	}
-
-	percentages := make(map[string]float64)
-	for ext, v := range counts {
-		percentages[ext] = (float64(v) / float64(total)) * 100
+	pc, err := analyzeDir(ctx, root, confine, opts)
+	if err != nil {
+		return ProjectContext{}, err
 	}
-	return percentages
-}
 
-func PrettyPrintPercentage(percentages map[string]float64) {
-	keys := make([]string, 0, len(percentages))
-	println("┌── File Percentages:")
-	for k := range percentages {
-		keys = append(keys, k)
+	ws, err := DetectWorkspace(root)
+	if err != nil || ws == nil {
+		return pc, nil
 	}
-
-	for i, lang := range keys {
-		percent := percentages[lang]
-		isLast := i == len(keys)-1
-
-		branch := "├── "
-		if isLast {
-			branch = "└── "
+	pc.Workspace = ws
+	for _, m := range ws.Members {
+		dir := filepath.Join(root, filepath.FromSlash(m.Path))
+		if real, err := filepath.EvalSymlinks(dir); err != nil || !filetree.Within(confine, absOr(real)) {
+			continue
 		}
-		color := "\033[36m"
-		if i%2 == 0 {
-			color = "\033[34m"
+		member, err := analyzeDir(ctx, dir, confine, opts)
+		if err != nil {
+			return ProjectContext{}, err
 		}
-
-		bar := strings.Repeat("█", int(percent/2))
-		coloredBar := color + bar + "\033[0m"
-
-		fmt.Printf("%s%-10s %5.1f%% %s\n", branch, lang, percent, coloredBar)
+		member.Root = m.Path
+		member.Name = m.Name
+		member.LocalDependencies = m.LocalDeps
+		pc.Packages = append(pc.Packages, member)
 	}
+	return pc, nil
 }
 
-func AnalyzeProject(root string) ProjectContext {
-	ctx := ProjectContext{Root: root}
+// analyzeDir collects the context of root. Symbolic links are followed
+// to regular files inside confine, a real path; other links, devices,
+// pipes and sockets are left out.
+func analyzeDir(ctx context.Context, root, confine string, opts AnalyzeOptions) (ProjectContext, error) {
+	pc := ProjectContext{Root: root}
+	var gitIg *ignore.GitIgnore
+	if opts.RespectGitignore {
+		gitIg, _ = ignore.CompileIgnoreFile(filepath.Join(root, ".gitignore"))
+	}
+	attrs, _ := LoadAttributes(root)
+	readmeDepth := -1
+	var declared []EntryPoint // entry points named by manifests, resolved after the walk
 
-	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if err != nil {
 			return nil
 		}
 		rel, _ := filepath.Rel(root, path)
 
-		if rel == "node_modules" || rel == ".git" {
-			return filepath.SkipDir
+		if rel != "." && (rel == ".git" || opts.Ignores.Ignores(rel, info.IsDir())) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := filepath.EvalSymlinks(path)
+			if err != nil || !filetree.Within(confine, target) {
+				return nil
+			}
+			tinfo, err := os.Stat(target)
+			if err != nil || !tinfo.Mode().IsRegular() {
+				return nil
+			}
+			info = linkedInfo{tinfo, info.Name()}
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
 		}
 
 		if !info.IsDir() {
+			if gitIg != nil && gitIg.MatchesPath(filepath.ToSlash(rel)) {
+				return nil
+			}
 			ext := strings.TrimPrefix(filepath.Ext(info.Name()), ".")
-			CollectFileStats(&ctx)
 			fileType := "file"
 			if ext != "" {
 				fileType = ext
 			}
 
-			ctx.Files = append(ctx.Files, FileInfo{
-				Name:         info.Name(),
-				IsEntryPoint: is_entry_point(info.Name()),
-				Type:         fileType,
-				Path:         rel,
-				Size:         info.Size(),
-				Lines:        countLines(path),
-				LastEdited:   info.ModTime().String(),
+			reason := detectEntryPoint(filepath.ToSlash(rel), path)
+			head := readHead(path, OriginHeadBytes)
+			lang := DetectLanguage(rel, head)
+			origin := DetectOrigin(rel, head, attrs)
+			lines, tokens, _ := measureFile(path, lang.Language)
+			pc.Files = append(pc.Files, FileInfo{
+				Name:          info.Name(),
+				Language:      lang.Language,
+				LanguageType:  lang.Type,
+				Vendored:      origin.Vendored != "",
+				Generated:     origin.Generated != "",
+				Documentation: lang.Documentation,
+				IsEntryPoint:  reason != "",
+				EntryReason:   reason,
+				Type:          fileType,
+				Path:          rel,
+				Size:          info.Size(),
+				Lines:         lines.Total(),
+				Code:          lines.Code,
+				Comment:       lines.Comment,
+				Blank:         lines.Blank,
+				Tokens:        tokens,
+				LastEdited:    info.ModTime().String(),
 			})
-			ctx.TotalFiles++
+			pc.TotalFiles++
 
-			if strings.ToLower(info.Name()) == "readme.md" {
+			// the README closest to the root describes the project
+			if depth := strings.Count(filepath.ToSlash(rel), "/"); strings.ToLower(info.Name()) == "readme.md" && (readmeDepth < 0 || depth < readmeDepth) {
 				data, _ := os.ReadFile(path)
-				ctx.Readme = string(data[:min(300, len(data))]) + "..."
+				pc.Readme = string(data[:min(300, len(data))]) + "..."
+				readmeDepth = depth
 			}
 
-			if info.Name() == "go.mod" {
-				data, _ := os.ReadFile(path)
-				lines := strings.Split(string(data), "\n")
-				for _, line := range lines {
-					if strings.HasPrefix(line, "require ") {
-						ctx.Dependencies = append(ctx.Dependencies, strings.TrimSpace(line[8:]))
+			if IsLockfile(info.Name()) {
+				if data, err := os.ReadFile(path); err == nil {
+					if summary, err := SummarizeLockfile(path, data); err == nil {
+						summary.Lockfile = filepath.ToSlash(rel)
+						pc.Lockfiles = append(pc.Lockfiles, summary)
 					}
 				}
 			}
+
+			if name := info.Name(); name == "package.json" || name == "pyproject.toml" || name == "Cargo.toml" ||
+				name == "Procfile" || strings.HasPrefix(name, "Dockerfile") || strings.HasSuffix(name, ".Dockerfile") {
+				if data, err := os.ReadFile(path); err == nil {
+					declared = append(declared, manifestEntryPoints(rel, data)...)
+				}
+			}
+
+			if IsManifest(info.Name()) {
+				if data, err := os.ReadFile(path); err == nil {
+					pc.Dependencies = append(pc.Dependencies, ParseManifest(rel, data)...)
+				}
+			}
 		} else {
-			ctx.TotalDirs++
+			pc.TotalDirs++
 		}
 		return nil
 	})
+	if err != nil {
+		return ProjectContext{}, err
+	}
+
+	pc.Languages = CollectLanguageStats(pc.Files)
 
-	return ctx
-}
\ No newline at end of file
+	byPath := make(map[string]int, len(pc.Files))
+	for i, f := range pc.Files {
+		byPath[filepath.ToSlash(f.Path)] = i
+	}
+	for _, ep := range declared {
+		for _, p := range append([]string{ep.Path}, ep.alternates...) {
+			if i, ok := byPath[p]; ok {
+				ep.Path = p
+				f := &pc.Files[i]
+				f.IsEntryPoint = true
+				if f.EntryReason == "" {
+					f.EntryReason = ep.Reason
+				} else if !strings.Contains(f.EntryReason, ep.Reason) {
+					f.EntryReason += "; " + ep.Reason
+				}
+				break
+			}
+		}
+		if _, ok := byPath[ep.Path]; !ok {
+			// declared but not present in the tree (e.g. a build output)
+			pc.EntryPoints = append(pc.EntryPoints, EntryPoint{Path: ep.Path, Reason: ep.Reason + " (file not found)"})
+		}
+	}
+	for _, f := range pc.Files {
+		if f.IsEntryPoint {
+			pc.EntryPoints = append(pc.EntryPoints, EntryPoint{Path: filepath.ToSlash(f.Path), Reason: f.EntryReason})
+		}
+	}
+	return pc, nil
+}
@@ -0,0 +1,606 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Ecosystems reported in Dependency.Ecosystem.
+const (
+	EcosystemGo       = "go"
+	EcosystemNPM      = "npm"
+	EcosystemPyPI     = "pypi"
+	EcosystemCargo    = "cargo"
+	EcosystemRubyGems = "rubygems"
+	EcosystemComposer = "composer"
+	EcosystemMaven    = "maven"
+	EcosystemGradle   = "gradle"
+	EcosystemNuGet    = "nuget"
+)
+
+// Scopes reported in Dependency.Scope.
+const (
+	ScopeRuntime   = "runtime"
+	ScopeDev       = "dev"
+	ScopePeer      = "peer"
+	ScopeOptional  = "optional"
+	ScopeBuild     = "build"
+	ScopeTest      = "test"
+	ScopeIndirect  = "indirect"
+	ScopeWorkspace = "workspace"
+)
+
+type Dependency struct {
+	Ecosystem string `json:"ecosystem" toon:"ecosystem"`
+	Name      string `json:"name" toon:"name"`
+	Version   string `json:"version,omitempty" toon:"version"`
+	Scope     string `json:"scope" toon:"scope"`
+	Manifest  string `json:"manifest" toon:"manifest"`
+}
+
+type manifestParser func(data []byte, manifest string) []Dependency
+
+// manifestParserFor returns the parser for a manifest file name, if any.
+func manifestParserFor(name string) (manifestParser, bool) {
+	lower := strings.ToLower(name)
+	switch {
+	case name == "go.mod":
+		return parseGoMod, true
+	case name == "package.json":
+		return parsePackageJSON, true
+	case strings.HasPrefix(lower, "requirements") && strings.HasSuffix(lower, ".txt"):
+		return parseRequirementsTxt, true
+	case name == "pyproject.toml":
+		return parsePyproject, true
+	case name == "Pipfile":
+		return parsePipfile, true
+	case name == "Cargo.toml":
+		return parseCargoToml, true
+	case name == "Gemfile":
+		return parseGemfile, true
+	case name == "composer.json":
+		return parseComposerJSON, true
+	case name == "pom.xml":
+		return parsePomXML, true
+	case name == "build.gradle" || name == "build.gradle.kts":
+		return parseGradle, true
+	case strings.HasSuffix(lower, ".csproj"):
+		return parseCsproj, true
+	}
+	return nil, false
+}
+
+// IsManifest reports whether name is a dependency manifest ctx3 understands.
+func IsManifest(name string) bool {
+	_, ok := manifestParserFor(name)
+	return ok
+}
+
+// ParseManifest extracts dependencies from a manifest. manifest is the
+// path recorded on each Dependency; its base name selects the parser.
+func ParseManifest(manifest string, data []byte) []Dependency {
+	parse, ok := manifestParserFor(filepath.Base(manifest))
+	if !ok {
+		return nil
+	}
+	return parse(data, filepath.ToSlash(manifest))
+}
+
+// --- Go ---
+
+func parseGoMod(data []byte, manifest string) []Dependency {
+	var deps []Dependency
+	inBlock := false
+	for _, raw := range strings.Split(string(data), "\n") {
+		line := strings.TrimSpace(raw)
+		indirect := strings.Contains(line, "// indirect")
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		switch {
+		case line == "require (":
+			inBlock = true
+			continue
+		case inBlock && line == ")":
+			inBlock = false
+			continue
+		case strings.HasPrefix(line, "require "):
+			line = strings.TrimSpace(strings.TrimPrefix(line, "require "))
+		case !inBlock:
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		scope := ScopeRuntime
+		if indirect {
+			scope = ScopeIndirect
+		}
+		deps = append(deps, Dependency{Ecosystem: EcosystemGo, Name: fields[0], Version: fields[1], Scope: scope, Manifest: manifest})
+	}
+	return deps
+}
+
+// --- npm ---
+
+type packageJSON struct {
+	Name                 string            `json:"name"`
+	Dependencies         map[string]string `json:"dependencies"`
+	DevDependencies      map[string]string `json:"devDependencies"`
+	PeerDependencies     map[string]string `json:"peerDependencies"`
+	OptionalDependencies map[string]string `json:"optionalDependencies"`
+	Workspaces           json.RawMessage   `json:"workspaces"`
+}
+
+// workspaceGlobs handles both `"workspaces": [...]` and `"workspaces": {"packages": [...]}`.
+func (p packageJSON) workspaceGlobs() []string {
+	if len(p.Workspaces) == 0 {
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(p.Workspaces, &list); err == nil {
+		return list
+	}
+	var obj struct {
+		Packages []string `json:"packages"`
+	}
+	if err := json.Unmarshal(p.Workspaces, &obj); err == nil {
+		return obj.Packages
+	}
+	return nil
+}
+
+func parsePackageJSON(data []byte, manifest string) []Dependency {
+	var pkg packageJSON
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil
+	}
+	var deps []Dependency
+	deps = appendMapDeps(deps, EcosystemNPM, pkg.Dependencies, ScopeRuntime, manifest)
+	deps = appendMapDeps(deps, EcosystemNPM, pkg.DevDependencies, ScopeDev, manifest)
+	deps = appendMapDeps(deps, EcosystemNPM, pkg.PeerDependencies, ScopePeer, manifest)
+	deps = appendMapDeps(deps, EcosystemNPM, pkg.OptionalDependencies, ScopeOptional, manifest)
+	for _, glob := range pkg.workspaceGlobs() {
+		deps = append(deps, Dependency{Ecosystem: EcosystemNPM, Name: glob, Scope: ScopeWorkspace, Manifest: manifest})
+	}
+	return deps
+}
+
+// --- Python ---
+
+var pep508Name = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)\s*(\[[^\]]*\])?\s*(.*)$`)
+
+// parsePEP508 splits `requests[socks]>=2.0; python_version<"3.8"` into name and version spec.
+func parsePEP508(spec string) (string, string, bool) {
+	if i := strings.Index(spec, ";"); i >= 0 {
+		spec = spec[:i]
+	}
+	spec = strings.TrimSpace(spec)
+	m := pep508Name.FindStringSubmatch(spec)
+	if m == nil {
+		return "", "", false
+	}
+	version := strings.TrimSpace(m[3])
+	if strings.HasPrefix(version, "@") {
+		// direct reference: `pkg @ https://...`
+		version = strings.TrimSpace(version[1:])
+	}
+	version = strings.Trim(version, "()")
+	return m[1], strings.ReplaceAll(version, " ", ""), true
+}
+
+func parseRequirementsTxt(data []byte, manifest string) []Dependency {
+	scope := ScopeRuntime
+	base := strings.ToLower(filepath.Base(manifest))
+	if strings.Contains(base, "dev") || strings.Contains(base, "test") {
+		scope = ScopeDev
+	}
+	var deps []Dependency
+	for _, raw := range strings.Split(string(data), "\n") {
+		line := strings.TrimSpace(raw)
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		// skip comments, options (-r, -e, --index-url, ...) and bare URLs/paths
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "-") ||
+			strings.Contains(line, "://") && !strings.Contains(line, "@") {
+			continue
+		}
+		name, version, ok := parsePEP508(line)
+		if !ok {
+			continue
+		}
+		deps = append(deps, Dependency{Ecosystem: EcosystemPyPI, Name: name, Version: version, Scope: scope, Manifest: manifest})
+	}
+	return deps
+}
+
+func parsePyproject(data []byte, manifest string) []Dependency {
+	var deps []Dependency
+	for _, sec := range parseTOML(data) {
+		switch {
+		case sec.Name == "project":
+			if raw, ok := sec.get("dependencies"); ok {
+				deps = appendPEP508Deps(deps, tomlArray(raw), ScopeRuntime, manifest)
+			}
+		case sec.Name == "project.optional-dependencies":
+			for _, e := range sec.Entries {
+				deps = appendPEP508Deps(deps, tomlArray(e.Value), ScopeOptional, manifest)
+			}
+		case sec.Name == "dependency-groups":
+			for _, e := range sec.Entries {
+				deps = appendPEP508Deps(deps, tomlArray(e.Value), ScopeDev, manifest)
+			}
+		case sec.Name == "tool.poetry.dependencies":
+			deps = appendTOMLTableDeps(deps, EcosystemPyPI, sec, ScopeRuntime, manifest)
+		case sec.Name == "tool.poetry.dev-dependencies":
+			deps = appendTOMLTableDeps(deps, EcosystemPyPI, sec, ScopeDev, manifest)
+		case strings.HasPrefix(sec.Name, "tool.poetry.group.") && strings.HasSuffix(sec.Name, ".dependencies"):
+			scope := ScopeDev
+			if group := strings.TrimSuffix(strings.TrimPrefix(sec.Name, "tool.poetry.group."), ".dependencies"); group == "main" {
+				scope = ScopeRuntime
+			}
+			deps = appendTOMLTableDeps(deps, EcosystemPyPI, sec, scope, manifest)
+		}
+	}
+	// poetry lists the interpreter itself as a dependency
+	out := deps[:0]
+	for _, d := range deps {
+		if !strings.EqualFold(d.Name, "python") {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func parsePipfile(data []byte, manifest string) []Dependency {
+	var deps []Dependency
+	for _, sec := range parseTOML(data) {
+		switch sec.Name {
+		case "packages":
+			deps = appendTOMLTableDeps(deps, EcosystemPyPI, sec, ScopeRuntime, manifest)
+		case "dev-packages":
+			deps = appendTOMLTableDeps(deps, EcosystemPyPI, sec, ScopeDev, manifest)
+		}
+	}
+	return deps
+}
+
+func appendPEP508Deps(deps []Dependency, specs []string, scope, manifest string) []Dependency {
+	for _, raw := range specs {
+		name, version, ok := parsePEP508(tomlString(raw))
+		if !ok {
+			continue
+		}
+		deps = append(deps, Dependency{Ecosystem: EcosystemPyPI, Name: name, Version: version, Scope: scope, Manifest: manifest})
+	}
+	return deps
+}
+
+// --- Rust ---
+
+func parseCargoToml(data []byte, manifest string) []Dependency {
+	var deps []Dependency
+	for _, sec := range parseTOML(data) {
+		name := sec.Name
+		// [target.'cfg(unix)'.dependencies] behaves like [dependencies]
+		if strings.HasPrefix(name, "target.") {
+			if i := strings.LastIndex(name, "."); i >= 0 {
+				name = name[i+1:]
+			}
+		}
+		switch name {
+		case "dependencies", "workspace.dependencies":
+			deps = appendTOMLTableDeps(deps, EcosystemCargo, sec, ScopeRuntime, manifest)
+		case "dev-dependencies":
+			deps = appendTOMLTableDeps(deps, EcosystemCargo, sec, ScopeDev, manifest)
+		case "build-dependencies":
+			deps = appendTOMLTableDeps(deps, EcosystemCargo, sec, ScopeBuild, manifest)
+		default:
+			// [dependencies.serde] sub-table form
+			for prefix, scope := range map[string]string{
+				"dependencies.":       ScopeRuntime,
+				"dev-dependencies.":   ScopeDev,
+				"build-dependencies.": ScopeBuild,
+			} {
+				if strings.HasPrefix(sec.Name, prefix) {
+					version, _ := sec.get("version")
+					deps = append(deps, Dependency{
+						Ecosystem: EcosystemCargo,
+						Name:      strings.TrimPrefix(sec.Name, prefix),
+						Version:   tomlString(version),
+						Scope:     scope,
+						Manifest:  manifest,
+					})
+				}
+			}
+		}
+	}
+	return deps
+}
+
+// appendTOMLTableDeps reads `name = "1.0"` and `name = { version = "1.0", ... }` entries.
+func appendTOMLTableDeps(deps []Dependency, ecosystem string, sec tomlSection, scope, manifest string) []Dependency {
+	for _, e := range sec.Entries {
+		version := tomlString(e.Value)
+		s := scope
+		if table := tomlInlineTable(e.Value); table != nil {
+			version = ""
+			switch {
+			case table["version"] != "":
+				version = tomlString(table["version"])
+			case table["path"] != "":
+				version = "path:" + tomlString(table["path"])
+			case table["git"] != "":
+				version = "git:" + tomlString(table["git"])
+			}
+			if table["optional"] == "true" && scope == ScopeRuntime {
+				s = ScopeOptional
+			}
+		}
+		if version == "*" {
+			version = ""
+		}
+		deps = append(deps, Dependency{Ecosystem: ecosystem, Name: e.Key, Version: version, Scope: s, Manifest: manifest})
+	}
+	return deps
+}
+
+// --- Ruby ---
+
+var (
+	gemLine   = regexp.MustCompile(`^gem\s+['"]([^'"]+)['"]((?:\s*,\s*['"][^'"]*['"])*)`)
+	gemGroup  = regexp.MustCompile(`^group\s+(.+?)\s+do\b`)
+	gemString = regexp.MustCompile(`['"]([^'"]*)['"]`)
+)
+
+func parseGemfile(data []byte, manifest string) []Dependency {
+	var deps []Dependency
+	scope := ScopeRuntime
+	depth := 0 // nesting of `do ... end` blocks inside a group
+	for _, raw := range strings.Split(string(data), "\n") {
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if m := gemGroup.FindStringSubmatch(line); m != nil {
+			scope = gemScope(m[1])
+			depth = 1
+			continue
+		}
+		if depth > 0 {
+			if strings.HasSuffix(line, " do") || strings.Contains(line, " do |") {
+				depth++
+			} else if line == "end" {
+				depth--
+				if depth == 0 {
+					scope = ScopeRuntime
+				}
+				continue
+			}
+		}
+		m := gemLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		var versions []string
+		for _, v := range gemString.FindAllStringSubmatch(m[2], -1) {
+			versions = append(versions, v[1])
+		}
+		s := scope
+		if g := strings.Index(line, "group:"); g >= 0 {
+			s = gemScope(line[g+len("group:"):])
+		}
+		deps = append(deps, Dependency{Ecosystem: EcosystemRubyGems, Name: m[1], Version: strings.Join(versions, ", "), Scope: s, Manifest: manifest})
+	}
+	return deps
+}
+
+func gemScope(groups string) string {
+	switch {
+	case strings.Contains(groups, "development"):
+		return ScopeDev
+	case strings.Contains(groups, "test"):
+		return ScopeTest
+	}
+	return ScopeRuntime
+}
+
+// --- PHP ---
+
+func parseComposerJSON(data []byte, manifest string) []Dependency {
+	var pkg struct {
+		Require    map[string]string `json:"require"`
+		RequireDev map[string]string `json:"require-dev"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil
+	}
+	var deps []Dependency
+	deps = appendMapDeps(deps, EcosystemComposer, pkg.Require, ScopeRuntime, manifest)
+	deps = appendMapDeps(deps, EcosystemComposer, pkg.RequireDev, ScopeDev, manifest)
+	return deps
+}
+
+// --- JVM ---
+
+type pomDependency struct {
+	GroupID    string `xml:"groupId"`
+	ArtifactID string `xml:"artifactId"`
+	Version    string `xml:"version"`
+	Scope      string `xml:"scope"`
+	Optional   string `xml:"optional"`
+}
+
+type pomProject struct {
+	Version    string          `xml:"version"`
+	Parent     pomDependency   `xml:"parent"`
+	Properties pomProperties   `xml:"properties"`
+	Deps       []pomDependency `xml:"dependencies>dependency"`
+	Managed    []pomDependency `xml:"dependencyManagement>dependencies>dependency"`
+}
+
+type pomProperties map[string]string
+
+func (p *pomProperties) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*p = pomProperties{}
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			var v string
+			if err := d.DecodeElement(&v, &t); err != nil {
+				return err
+			}
+			(*p)[t.Name.Local] = strings.TrimSpace(v)
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+var pomProperty = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+func parsePomXML(data []byte, manifest string) []Dependency {
+	var pom pomProject
+	if err := xml.Unmarshal(data, &pom); err != nil {
+		return nil
+	}
+	resolve := func(v string) string {
+		return pomProperty.ReplaceAllStringFunc(strings.TrimSpace(v), func(ref string) string {
+			key := ref[2 : len(ref)-1]
+			switch key {
+			case "project.version", "version":
+				if pom.Version != "" {
+					return pom.Version
+				}
+				return pom.Parent.Version
+			}
+			if val, ok := pom.Properties[key]; ok {
+				return val
+			}
+			return ref
+		})
+	}
+	managed := map[string]string{}
+	for _, d := range pom.Managed {
+		managed[d.GroupID+":"+d.ArtifactID] = resolve(d.Version)
+	}
+
+	var deps []Dependency
+	for _, d := range pom.Deps {
+		name := strings.TrimSpace(d.GroupID) + ":" + strings.TrimSpace(d.ArtifactID)
+		version := resolve(d.Version)
+		if version == "" {
+			version = managed[name]
+		}
+		scope := ScopeRuntime
+		switch strings.TrimSpace(d.Scope) {
+		case "test":
+			scope = ScopeTest
+		case "provided", "system":
+			scope = ScopeBuild
+		}
+		if strings.TrimSpace(d.Optional) == "true" {
+			scope = ScopeOptional
+		}
+		deps = append(deps, Dependency{Ecosystem: EcosystemMaven, Name: name, Version: version, Scope: scope, Manifest: manifest})
+	}
+	return deps
+}
+
+var gradleDep = regexp.MustCompile(`^\s*(\w+)\s*\(?\s*(?:platform\s*\(\s*)?["']([^"':]+):([^"':]+)(?::([^"']+))?["']`)
+
+func parseGradle(data []byte, manifest string) []Dependency {
+	var deps []Dependency
+	for _, line := range strings.Split(string(data), "\n") {
+		m := gradleDep.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		scope, ok := gradleScope(m[1])
+		if !ok {
+			continue
+		}
+		deps = append(deps, Dependency{Ecosystem: EcosystemGradle, Name: m[2] + ":" + m[3], Version: m[4], Scope: scope, Manifest: manifest})
+	}
+	return deps
+}
+
+func gradleScope(configuration string) (string, bool) {
+	lower := strings.ToLower(configuration)
+	switch {
+	case strings.HasPrefix(lower, "test"), strings.HasPrefix(lower, "androidtest"):
+		return ScopeTest, true
+	case lower == "compileonly", lower == "annotationprocessor", lower == "kapt", lower == "ksp":
+		return ScopeBuild, true
+	case lower == "implementation", lower == "api", lower == "compile", lower == "runtimeonly", lower == "runtime":
+		return ScopeRuntime, true
+	case strings.HasSuffix(lower, "implementation"), strings.HasSuffix(lower, "api"):
+		return ScopeRuntime, true
+	}
+	return "", false
+}
+
+// --- .NET ---
+
+func parseCsproj(data []byte, manifest string) []Dependency {
+	var proj struct {
+		ItemGroups []struct {
+			Refs []struct {
+				Include       string `xml:"Include,attr"`
+				Update        string `xml:"Update,attr"`
+				VersionAttr   string `xml:"Version,attr"`
+				VersionElem   string `xml:"Version"`
+				PrivateAssets string `xml:"PrivateAssets,attr"`
+			} `xml:"PackageReference"`
+		} `xml:"ItemGroup"`
+	}
+	if err := xml.Unmarshal(data, &proj); err != nil {
+		return nil
+	}
+	var deps []Dependency
+	for _, g := range proj.ItemGroups {
+		for _, r := range g.Refs {
+			name := r.Include
+			if name == "" {
+				name = r.Update
+			}
+			if name == "" {
+				continue
+			}
+			version := r.VersionAttr
+			if version == "" {
+				version = strings.TrimSpace(r.VersionElem)
+			}
+			scope := ScopeRuntime
+			if strings.EqualFold(r.PrivateAssets, "all") {
+				scope = ScopeDev
+			}
+			deps = append(deps, Dependency{Ecosystem: EcosystemNuGet, Name: name, Version: version, Scope: scope, Manifest: manifest})
+		}
+	}
+	return deps
+}
+
+// --- helpers ---
+
+func appendMapDeps(deps []Dependency, ecosystem string, m map[string]string, scope, manifest string) []Dependency {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		deps = append(deps, Dependency{Ecosystem: ecosystem, Name: name, Version: m[name], Scope: scope, Manifest: manifest})
+	}
+	return deps
+}
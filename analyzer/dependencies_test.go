@@ -0,0 +1,195 @@
+package analyzer
+
+import (
+	"testing"
+)
+
+func findDep(deps []Dependency, name string) (Dependency, bool) {
+	for _, d := range deps {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return Dependency{}, false
+}
+
+func TestParseManifest_GoModBlocks(t *testing.T) {
+	gomod := `module example.com/x
+
+go 1.22
+
+require github.com/spf13/cobra v1.9.1
+
+require (
+	github.com/bmatcuk/doublestar/v4 v4.9.1
+	github.com/spf13/pflag v1.0.6 // indirect
+)
+`
+	deps := ParseManifest("go.mod", []byte(gomod))
+	if len(deps) != 3 {
+		t.Fatalf("expected 3 deps, got %d: %+v", len(deps), deps)
+	}
+	d, ok := findDep(deps, "github.com/spf13/pflag")
+	if !ok || d.Version != "v1.0.6" || d.Scope != ScopeIndirect || d.Ecosystem != EcosystemGo {
+		t.Fatalf("unexpected pflag dep: %+v", d)
+	}
+	if d, _ := findDep(deps, "github.com/spf13/cobra"); d.Scope != ScopeRuntime || d.Manifest != "go.mod" {
+		t.Fatalf("unexpected cobra dep: %+v", d)
+	}
+}
+
+func TestParseManifest_PackageJSON(t *testing.T) {
+	pkg := `{
+  "name": "web",
+  "dependencies": {"react": "^18.2.0"},
+  "devDependencies": {"typescript": "~5.4.0"},
+  "peerDependencies": {"react-dom": ">=18"},
+  "workspaces": {"packages": ["packages/*"]}
+}`
+	deps := ParseManifest("apps/web/package.json", []byte(pkg))
+	cases := map[string]string{
+		"react":      ScopeRuntime,
+		"typescript": ScopeDev,
+		"react-dom":  ScopePeer,
+		"packages/*": ScopeWorkspace,
+	}
+	for name, scope := range cases {
+		d, ok := findDep(deps, name)
+		if !ok || d.Scope != scope {
+			t.Fatalf("%s: expected scope %s, got %+v (found=%v)", name, scope, d, ok)
+		}
+		if d.Manifest != "apps/web/package.json" {
+			t.Fatalf("%s: unexpected manifest %q", name, d.Manifest)
+		}
+	}
+}
+
+func TestParseManifest_PythonFlavours(t *testing.T) {
+	req := "# pinned\nrequests[socks]>=2.31 ; python_version >= \"3.8\"\n-r base.txt\nflask==3.0.0\n"
+	deps := ParseManifest("requirements-dev.txt", []byte(req))
+	if d, ok := findDep(deps, "requests"); !ok || d.Version != ">=2.31" || d.Scope != ScopeDev {
+		t.Fatalf("unexpected requests dep: %+v", d)
+	}
+	if len(deps) != 2 {
+		t.Fatalf("expected 2 deps, got %+v", deps)
+	}
+
+	pyproject := `[project]
+name = "svc"
+dependencies = [
+  "httpx>=0.27",  # client
+  "pydantic",
+]
+
+[project.optional-dependencies]
+docs = ["mkdocs"]
+
+[tool.poetry.dependencies]
+python = "^3.11"
+fastapi = { version = "^0.110", extras = ["all"] }
+
+[tool.poetry.group.dev.dependencies]
+pytest = "^8"
+`
+	deps = ParseManifest("pyproject.toml", []byte(pyproject))
+	want := map[string][2]string{
+		"httpx":    {">=0.27", ScopeRuntime},
+		"pydantic": {"", ScopeRuntime},
+		"mkdocs":   {"", ScopeOptional},
+		"fastapi":  {"^0.110", ScopeRuntime},
+		"pytest":   {"^8", ScopeDev},
+	}
+	for name, w := range want {
+		d, ok := findDep(deps, name)
+		if !ok || d.Version != w[0] || d.Scope != w[1] {
+			t.Fatalf("%s: want %v, got %+v (found=%v)", name, w, d, ok)
+		}
+	}
+	if _, ok := findDep(deps, "python"); ok {
+		t.Fatalf("python interpreter should not be reported as a dependency")
+	}
+}
+
+func TestParseManifest_CargoToml(t *testing.T) {
+	cargo := `[package]
+name = "cli"
+
+[dependencies]
+serde = { version = "1.0", features = ["derive"] }
+core = { path = "../core" }
+anyhow = "1"
+
+[dev-dependencies.insta]
+version = "1.34"
+
+[target.'cfg(unix)'.dependencies]
+nix = "0.28"
+`
+	deps := ParseManifest("Cargo.toml", []byte(cargo))
+	want := map[string][2]string{
+		"serde":  {"1.0", ScopeRuntime},
+		"core":   {"path:../core", ScopeRuntime},
+		"anyhow": {"1", ScopeRuntime},
+		"insta":  {"1.34", ScopeDev},
+		"nix":    {"0.28", ScopeRuntime},
+	}
+	for name, w := range want {
+		d, ok := findDep(deps, name)
+		if !ok || d.Version != w[0] || d.Scope != w[1] || d.Ecosystem != EcosystemCargo {
+			t.Fatalf("%s: want %v, got %+v (found=%v)", name, w, d, ok)
+		}
+	}
+}
+
+func TestParseManifest_GemfileAndJVM(t *testing.T) {
+	gemfile := `source "https://rubygems.org"
+gem "rails", "~> 7.1"
+group :development, :test do
+  gem 'rspec-rails'
+end
+gem "pg", group: :production
+`
+	deps := ParseManifest("Gemfile", []byte(gemfile))
+	if d, _ := findDep(deps, "rails"); d.Version != "~> 7.1" || d.Scope != ScopeRuntime {
+		t.Fatalf("unexpected rails dep: %+v", d)
+	}
+	if d, _ := findDep(deps, "rspec-rails"); d.Scope != ScopeDev {
+		t.Fatalf("unexpected rspec dep: %+v", d)
+	}
+	if d, _ := findDep(deps, "pg"); d.Scope != ScopeRuntime {
+		t.Fatalf("unexpected pg dep: %+v", d)
+	}
+
+	pom := `<project>
+  <properties><junit.version>5.10.0</junit.version></properties>
+  <dependencies>
+    <dependency><groupId>org.junit</groupId><artifactId>junit</artifactId><version>${junit.version}</version><scope>test</scope></dependency>
+    <dependency><groupId>com.google</groupId><artifactId>guava</artifactId><version>33.0</version></dependency>
+  </dependencies>
+</project>`
+	deps = ParseManifest("pom.xml", []byte(pom))
+	if d, _ := findDep(deps, "org.junit:junit"); d.Version != "5.10.0" || d.Scope != ScopeTest {
+		t.Fatalf("unexpected junit dep: %+v", d)
+	}
+
+	gradle := "dependencies {\n  implementation(\"com.squareup.okhttp3:okhttp:4.12.0\")\n  testImplementation 'junit:junit:4.13'\n}\n"
+	deps = ParseManifest("build.gradle.kts", []byte(gradle))
+	if d, _ := findDep(deps, "com.squareup.okhttp3:okhttp"); d.Version != "4.12.0" || d.Scope != ScopeRuntime {
+		t.Fatalf("unexpected okhttp dep: %+v", d)
+	}
+	if d, _ := findDep(deps, "junit:junit"); d.Scope != ScopeTest {
+		t.Fatalf("unexpected junit dep: %+v", d)
+	}
+
+	csproj := `<Project Sdk="Microsoft.NET.Sdk"><ItemGroup>
+  <PackageReference Include="Newtonsoft.Json" Version="13.0.3" />
+  <PackageReference Include="coverlet.collector"><Version>6.0.0</Version><PrivateAssets>all</PrivateAssets></PackageReference>
+</ItemGroup></Project>`
+	deps = ParseManifest("src/App.csproj", []byte(csproj))
+	if d, _ := findDep(deps, "Newtonsoft.Json"); d.Version != "13.0.3" || d.Ecosystem != EcosystemNuGet {
+		t.Fatalf("unexpected nuget dep: %+v", d)
+	}
+	if d, _ := findDep(deps, "coverlet.collector"); d.Version != "6.0.0" {
+		t.Fatalf("unexpected coverlet dep: %+v", d)
+	}
+}
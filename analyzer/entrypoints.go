@@ -0,0 +1,201 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type EntryPoint struct {
+	Path   string `json:"path" toon:"path"`
+	Reason string `json:"reason" toon:"reason"`
+
+	alternates []string // other places the declared file may live (src layout, packages)
+}
+
+// maxEntrySniff bounds how much of a source file is read to look for a main function.
+const maxEntrySniff = 1 << 20
+
+var (
+	goPackageMain = regexp.MustCompile(`(?m)^package\s+main\b`)
+	goFuncMain    = regexp.MustCompile(`(?m)^func\s+main\s*\(\s*\)`)
+	pyDunderMain  = regexp.MustCompile(`(?m)^if\s+__name__\s*==\s*['"]__main__['"]\s*:`)
+	rustFnMain    = regexp.MustCompile(`(?m)^\s*(?:pub\s+)?(?:async\s+)?fn\s+main\s*\(`)
+	cMain         = regexp.MustCompile(`(?m)^\s*(?:int|void)\s+main\s*\(`)
+	javaMain      = regexp.MustCompile(`\bpublic\s+static\s+void\s+main\s*\(\s*String`)
+)
+
+// conventionalEntryNames covers languages whose entry points can't be
+// recognised from content alone (any JS file may be run with `node`).
+var conventionalEntryNames = map[string]bool{
+	"index.js": true, "server.js": true, "app.js": true, "main.js": true,
+	"index.ts": true, "server.ts": true, "app.ts": true, "main.ts": true,
+	"main.rb": true, "app.rb": true, "server.rb": true, "config.ru": true,
+	"index.php": true, "app.php": true, "server.php": true, "main.php": true,
+}
+
+// detectEntryPoint inspects a single file and explains why it is an entry
+// point, or returns "" if it isn't one.
+func detectEntryPoint(rel, abs string) string {
+	name := filepath.Base(rel)
+	ext := strings.ToLower(filepath.Ext(name))
+
+	var re []*regexp.Regexp
+	var reason string
+	switch ext {
+	case ".go":
+		if strings.HasSuffix(name, "_test.go") {
+			return ""
+		}
+		re, reason = []*regexp.Regexp{goPackageMain, goFuncMain}, "Go package main with func main()"
+	case ".py":
+		if name == "__main__.py" {
+			return "Python package __main__ module"
+		}
+		re, reason = []*regexp.Regexp{pyDunderMain}, `Python if __name__ == "__main__" block`
+	case ".rs":
+		re, reason = []*regexp.Regexp{rustFnMain}, "Rust fn main()"
+		if strings.HasPrefix(rel, "src/bin/") || strings.Contains(rel, "/src/bin/") {
+			return "Cargo binary target (src/bin)"
+		}
+	case ".c", ".cc", ".cpp", ".cxx":
+		re, reason = []*regexp.Regexp{cMain}, "C/C++ main() function"
+	case ".java", ".kt":
+		re, reason = []*regexp.Regexp{javaMain}, "JVM public static void main"
+	default:
+		if conventionalEntryNames[name] {
+			return "conventional entry file name " + name
+		}
+		return ""
+	}
+
+	f, err := os.Open(abs)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxEntrySniff))
+	if err != nil {
+		return ""
+	}
+	for _, r := range re {
+		if !r.Match(data) {
+			return ""
+		}
+	}
+	return reason
+}
+
+// manifestEntryPoints returns the entry points declared by a manifest or
+// process file. Paths are relative to the analysed root.
+func manifestEntryPoints(rel string, data []byte) []EntryPoint {
+	dir := path.Dir(filepath.ToSlash(rel))
+	join := func(p string) string {
+		return path.Clean(path.Join(dir, filepath.ToSlash(p)))
+	}
+	name := filepath.Base(rel)
+
+	var out []EntryPoint
+	switch {
+	case name == "package.json":
+		var pkg struct {
+			Main    string            `json:"main"`
+			Bin     json.RawMessage   `json:"bin"`
+			Scripts map[string]string `json:"scripts"`
+		}
+		if json.Unmarshal(data, &pkg) != nil {
+			return nil
+		}
+		if pkg.Main != "" {
+			out = append(out, EntryPoint{Path: join(pkg.Main), Reason: fmt.Sprintf("package.json \"main\": %q", pkg.Main)})
+		}
+		var single string
+		var bins map[string]string
+		if json.Unmarshal(pkg.Bin, &single) == nil && single != "" {
+			out = append(out, EntryPoint{Path: join(single), Reason: fmt.Sprintf("package.json \"bin\": %q", single)})
+		} else if json.Unmarshal(pkg.Bin, &bins) == nil {
+			for _, cmd := range sortedKeys(bins) {
+				out = append(out, EntryPoint{Path: join(bins[cmd]), Reason: fmt.Sprintf("package.json \"bin\" %s: %q", cmd, bins[cmd])})
+			}
+		}
+		if start := pkg.Scripts["start"]; start != "" {
+			for _, f := range scriptFiles(start) {
+				out = append(out, EntryPoint{Path: join(f), Reason: fmt.Sprintf("package.json scripts.start: %q", start)})
+			}
+		}
+
+	case name == "pyproject.toml":
+		for _, sec := range parseTOML(data) {
+			if sec.Name != "project.scripts" && sec.Name != "tool.poetry.scripts" && sec.Name != "project.gui-scripts" {
+				continue
+			}
+			for _, e := range sec.Entries {
+				target := tomlString(e.Value)
+				module := target
+				if i := strings.Index(module, ":"); i >= 0 {
+					module = module[:i]
+				}
+				p := strings.ReplaceAll(module, ".", "/")
+				out = append(out, EntryPoint{
+					Path:       join(p + ".py"),
+					Reason:     fmt.Sprintf("[%s] %s = %q", sec.Name, e.Key, target),
+					alternates: []string{join("src/" + p + ".py"), join(p + "/__init__.py"), join("src/" + p + "/__init__.py")},
+				})
+			}
+		}
+
+	case name == "Cargo.toml":
+		for _, sec := range parseTOML(data) {
+			if !sec.IsArray || sec.Name != "bin" {
+				continue
+			}
+			binName, _ := sec.get("name")
+			p := "src/bin/" + tomlString(binName) + ".rs"
+			if raw, ok := sec.get("path"); ok {
+				p = tomlString(raw)
+			}
+			out = append(out, EntryPoint{Path: join(p), Reason: fmt.Sprintf("Cargo [[bin]] %s", tomlString(binName))})
+		}
+
+	case name == "Dockerfile" || strings.HasSuffix(name, ".Dockerfile") || strings.HasPrefix(name, "Dockerfile."):
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			upper := strings.ToUpper(line)
+			if strings.HasPrefix(upper, "ENTRYPOINT ") || strings.HasPrefix(upper, "CMD ") {
+				out = append(out, EntryPoint{Path: filepath.ToSlash(rel), Reason: "Dockerfile " + line})
+			}
+		}
+
+	case name == "Procfile":
+		for _, line := range strings.Split(string(data), "\n") {
+			proc, cmd, ok := strings.Cut(strings.TrimSpace(line), ":")
+			if !ok || strings.HasPrefix(proc, "#") {
+				continue
+			}
+			out = append(out, EntryPoint{Path: filepath.ToSlash(rel), Reason: fmt.Sprintf("Procfile %s: %s", proc, strings.TrimSpace(cmd))})
+		}
+	}
+	return out
+}
+
+var scriptFile = regexp.MustCompile(`[\w./-]+\.(?:[cm]?js|[cm]?ts|jsx|tsx|py|rb)\b`)
+
+// scriptFiles pulls file arguments out of an npm script such as "node dist/server.js".
+func scriptFiles(script string) []string {
+	return scriptFile.FindAllString(script, -1)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
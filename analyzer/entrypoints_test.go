@@ -0,0 +1,50 @@
+package analyzer
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeProject_ContentBasedEntryPoints(t *testing.T) {
+	td := t.TempDir()
+	writeTestFile(t, filepath.Join(td, "cmd", "worker", "run.go"), "package main\n\nfunc main() {}\n")
+	writeTestFile(t, filepath.Join(td, "lib", "main.go"), "package lib\n\nfunc main() {}\n")
+	writeTestFile(t, filepath.Join(td, "tools", "gen.py"), "def go():\n    pass\n\nif __name__ == \"__main__\":\n    go()\n")
+	writeTestFile(t, filepath.Join(td, "pyproject.toml"), "[project]\nname = \"x\"\n\n[project.scripts]\nacme = \"acme.cli:main\"\n")
+	writeTestFile(t, filepath.Join(td, "src", "acme", "cli.py"), "def main(): ...\n")
+	writeTestFile(t, filepath.Join(td, "web", "package.json"), `{"main": "lib/index.js", "bin": {"acme": "bin/acme.js"}, "scripts": {"start": "node dist/server.js"}}`)
+	writeTestFile(t, filepath.Join(td, "web", "bin", "acme.js"), "#!/usr/bin/env node\n")
+	writeTestFile(t, filepath.Join(td, "Dockerfile"), "FROM alpine\nENTRYPOINT [\"/app/worker\"]\n")
+	writeTestFile(t, filepath.Join(td, "Procfile"), "web: gunicorn app:app\n")
+
+	ctx := AnalyzeProject(td)
+	reasons := map[string]string{}
+	for _, ep := range ctx.EntryPoints {
+		reasons[ep.Path] = ep.Reason
+	}
+
+	want := map[string]string{
+		"cmd/worker/run.go":  "Go package main",
+		"tools/gen.py":       "__main__",
+		"src/acme/cli.py":    "[project.scripts] acme",
+		"web/bin/acme.js":    `"bin" acme`,
+		"web/dist/server.js": "not found",
+		"web/lib/index.js":   `"main"`,
+		"Dockerfile":         "ENTRYPOINT",
+		"Procfile":           "Procfile web: gunicorn app:app",
+	}
+	for p, substr := range want {
+		if !strings.Contains(reasons[p], substr) {
+			t.Fatalf("%s: expected reason containing %q, got %q (all: %v)", p, substr, reasons[p], reasons)
+		}
+	}
+	if _, ok := reasons["lib/main.go"]; ok {
+		t.Fatalf("lib/main.go is not package main and must not be an entry point")
+	}
+	for _, f := range ctx.Files {
+		if f.Path == filepath.FromSlash("cmd/worker/run.go") && (!f.IsEntryPoint || f.EntryReason == "") {
+			t.Fatalf("expected run.go flagged with a reason, got %+v", f)
+		}
+	}
+}
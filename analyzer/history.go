@@ -0,0 +1,448 @@
+package analyzer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parsabordbar/ctx3/filetree"
+)
+
+// HistoryPoint is the language mix of the tree at one sampled commit.
+type HistoryPoint struct {
+	Commit string    `json:"commit" toon:"commit"`
+	Time   time.Time `json:"time" toon:"time"`
+	Shares []Share   `json:"shares" toon:"shares"`
+}
+
+type HistoryOptions struct {
+	Rev   string        // where history starts; "" means HEAD
+	Every time.Duration // minimum gap between samples; 0 samples every commit
+	Limit int           // maximum number of samples; 0 means no limit
+	Share ShareOptions  // Top is applied across all points
+	// Ignores hides paths as in the working-tree commands; nil hides nothing.
+	Ignores *filetree.IgnoreRules
+}
+
+// LanguageHistory samples the first-parent history of the repository at
+// root and computes the language mix at each sample, oldest first. File
+// contents come straight from the object database with `git cat-file
+// --batch`; nothing is checked out, and a blob shared by several samples
+// is only measured once. The git commands are killed when ctx is done.
+func LanguageHistory(ctx context.Context, root string, opts HistoryOptions) ([]HistoryPoint, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, errors.New("git not found in PATH")
+	}
+	rev := opts.Rev
+	if rev == "" {
+		rev = "HEAD"
+	}
+	// only the commit id reaches the commands below
+	id, err := ResolveCommit(ctx, root, rev)
+	if err != nil {
+		return nil, err
+	}
+	commits, err := sampleCommits(ctx, root, id, opts.Every, opts.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	blobs, err := newBlobReader(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	defer blobs.Close()
+
+	needContent := opts.Share.By == ShareLines || opts.Share.By == ShareCode || opts.Share.By == ShareTokens
+	measured := map[string]FileInfo{} // blob id + path -> measurements
+
+	share := opts.Share
+	share.Top = 0
+	points := make([]HistoryPoint, 0, len(commits))
+	for _, c := range commits {
+		entries, err := lsTree(ctx, root, c.id)
+		if err != nil {
+			return nil, err
+		}
+		files := make([]FileInfo, 0, len(entries))
+		for _, e := range entries {
+			if opts.Ignores.IgnoresPath(e.path) {
+				continue
+			}
+			key := e.blob + "\x00" + e.path
+			f, ok := measured[key]
+			if !ok {
+				f = FileInfo{Name: path.Base(e.path), Path: e.path, Size: e.size}
+				var data []byte
+				if needContent || path.Ext(e.path) == "" {
+					if data, err = blobs.Read(e.blob); err != nil {
+						if ctx.Err() != nil {
+							return nil, ctx.Err()
+						}
+						return nil, fmt.Errorf("reading %s at %s: %w", e.path, c.id[:7], err)
+					}
+				}
+				lang := DetectLanguage(e.path, data)
+				f.Language, f.LanguageType = lang.Language, lang.Type
+				f.Vendored, f.Generated, f.Documentation = lang.Vendored, lang.Generated, lang.Documentation
+				if needContent {
+					lines, tokens, _ := measure(bytes.NewReader(data), lang.Language)
+					f.Lines, f.Code, f.Comment, f.Blank, f.Tokens = lines.Total(), lines.Code, lines.Comment, lines.Blank, tokens
+				}
+				measured[key] = f
+			}
+			files = append(files, f)
+		}
+		points = append(points, HistoryPoint{Commit: c.id, Time: c.time, Shares: LanguageShares(files, share)})
+	}
+	foldHistory(points, opts.Share.Top)
+	return points, nil
+}
+
+// foldHistory keeps the top languages by average share over all points, so
+// every point reports the same set of rows, and folds the rest into
+// OtherShare.
+func foldHistory(points []HistoryPoint, top int) {
+	if top <= 0 {
+		return
+	}
+	weight := map[string]float64{}
+	for _, p := range points {
+		for _, s := range p.Shares {
+			weight[s.Language] += s.Percent
+		}
+	}
+	if len(weight) <= top {
+		return
+	}
+	names := make([]string, 0, len(weight))
+	for n := range weight {
+		names = append(names, n)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if weight[names[i]] != weight[names[j]] {
+			return weight[names[i]] > weight[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	keep := map[string]bool{}
+	for _, n := range names[:top] {
+		keep[n] = true
+	}
+
+	for i := range points {
+		other := Share{Language: OtherShare}
+		var kept []Share
+		for _, s := range points[i].Shares {
+			if keep[s.Language] {
+				kept = append(kept, s)
+				continue
+			}
+			other.Value += s.Value
+			other.Percent += s.Percent
+			other.Files += s.Files
+		}
+		if other.Files > 0 {
+			kept = append(kept, other)
+		}
+		points[i].Shares = kept
+	}
+}
+
+type historyCommit struct {
+	id   string
+	time time.Time
+}
+
+// ResolveCommit turns rev into the id of the commit it names in the
+// repository at root. A rev that starts with a dash is refused: git would
+// read it as an option. Pass only the id on to other git commands.
+func ResolveCommit(ctx context.Context, root, rev string) (string, error) {
+	if strings.HasPrefix(rev, "-") {
+		return "", fmt.Errorf("invalid revision: %s", rev)
+	}
+	out, err := git(ctx, root, "rev-parse", "--verify", "--quiet", "--end-of-options", rev+"^{commit}")
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	if err != nil {
+		return "", fmt.Errorf("unknown revision: %s", rev)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// sampleCommits walks first-parent history from the commit id, newest
+// first, and keeps a commit whenever at least every has passed since the
+// last one kept. The result is oldest first.
+func sampleCommits(ctx context.Context, root, id string, every time.Duration, limit int) ([]historyCommit, error) {
+	out, err := git(ctx, root, "log", "--first-parent", "--format=%H %ct", "--end-of-options", id, "--")
+	if err != nil {
+		return nil, err
+	}
+	var commits []historyCommit
+	var next time.Time
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		id, ts, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		sec, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			continue
+		}
+		t := time.Unix(sec, 0).UTC()
+		if len(commits) > 0 && t.After(next) {
+			continue
+		}
+		commits = append(commits, historyCommit{id: id, time: t})
+		next = t.Add(-every)
+		if limit > 0 && len(commits) == limit {
+			break
+		}
+	}
+	if len(commits) == 0 {
+		return nil, fmt.Errorf("no commits found at %s", id)
+	}
+	for i, j := 0, len(commits)-1; i < j; i, j = i+1, j-1 {
+		commits[i], commits[j] = commits[j], commits[i]
+	}
+	return commits, nil
+}
+
+type treeEntry struct {
+	path string
+	blob string
+	size int64
+}
+
+// lsTree lists the regular files of a commit with their blob ids and sizes.
+func lsTree(ctx context.Context, root, commit string) ([]treeEntry, error) {
+	out, err := git(ctx, root, "ls-tree", "-r", "-l", "-z", "--end-of-options", commit)
+	if err != nil {
+		return nil, err
+	}
+	var entries []treeEntry
+	for _, rec := range bytes.Split(out, []byte{0}) {
+		meta, p, ok := strings.Cut(string(rec), "\t")
+		if !ok {
+			continue
+		}
+		// <mode> <type> <object> <size>; symlinks and submodules are skipped
+		f := strings.Fields(meta)
+		if len(f) != 4 || f[1] != "blob" || f[0] == "120000" {
+			continue
+		}
+		size, _ := strconv.ParseInt(f[3], 10, 64)
+		entries = append(entries, treeEntry{path: p, blob: f[2], size: size})
+	}
+	return entries, nil
+}
+
+func git(ctx context.Context, root string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", root}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// blobReader reads objects through one long-running `git cat-file --batch`.
+type blobReader struct {
+	cmd *exec.Cmd
+	in  io.WriteCloser
+	out *bufio.Reader
+}
+
+func newBlobReader(ctx context.Context, root string) (*blobReader, error) {
+	cmd := exec.CommandContext(ctx, "git", "-C", root, "cat-file", "--batch")
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &blobReader{cmd: cmd, in: in, out: bufio.NewReaderSize(out, 64*1024)}, nil
+}
+
+func (b *blobReader) Read(id string) ([]byte, error) {
+	if _, err := io.WriteString(b.in, id+"\n"); err != nil {
+		return nil, err
+	}
+	header, err := b.out.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	// <id> <type> <size>, or "<id> missing"
+	f := strings.Fields(header)
+	if len(f) != 3 {
+		return nil, fmt.Errorf("cat-file: %s", strings.TrimSpace(header))
+	}
+	size, err := strconv.Atoi(f[2])
+	if err != nil {
+		return nil, fmt.Errorf("cat-file: bad header %q", strings.TrimSpace(header))
+	}
+	data := make([]byte, size+1) // content plus the trailing newline
+	if _, err := io.ReadFull(b.out, data); err != nil {
+		return nil, err
+	}
+	return data[:size], nil
+}
+
+func (b *blobReader) Close() error {
+	b.in.Close()
+	return b.cmd.Wait()
+}
+
+// ParseEvery parses a sampling interval. On top of time.ParseDuration it
+// accepts days and weeks: "3d", "1w", "2w12h".
+func ParseEvery(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	var total time.Duration
+	rest := s
+	for rest != "" {
+		i := strings.IndexAny(rest, "dw")
+		if i < 0 {
+			break
+		}
+		n, err := strconv.Atoi(rest[:i])
+		if err != nil {
+			return 0, fmt.Errorf("invalid --every: %s (expected e.g. 1d, 1w, 12h)", s)
+		}
+		unit := 24 * time.Hour
+		if rest[i] == 'w' {
+			unit *= 7
+		}
+		total += time.Duration(n) * unit
+		rest = rest[i+1:]
+	}
+	if rest != "" {
+		d, err := time.ParseDuration(rest)
+		if err != nil {
+			return 0, fmt.Errorf("invalid --every: %s (expected e.g. 1d, 1w, 12h)", s)
+		}
+		total += d
+	}
+	if total < 0 {
+		return 0, fmt.Errorf("invalid --every: %s (must not be negative)", s)
+	}
+	return total, nil
+}
+
+// WriteHistoryCSV writes one row per sample and language:
+// commit,time,language,value,percent.
+func WriteHistoryCSV(w io.Writer, points []HistoryPoint) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"commit", "time", "language", "value", "percent"})
+	for _, p := range points {
+		for _, s := range p.Shares {
+			cw.Write([]string{
+				p.Commit,
+				p.Time.Format(time.RFC3339),
+				s.Language,
+				strconv.FormatInt(s.Value, 10),
+				strconv.FormatFloat(s.Percent, 'f', 2, 64),
+			})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// historyGlyphs fill the stacked bars, one per language in legend order.
+const historyGlyphs = "#=+*%@o:x~-."
+
+// PrettyPrintHistory draws one stacked bar of the given width per sample,
+// followed by a legend.
+func PrettyPrintHistory(w io.Writer, points []HistoryPoint, width int) {
+	// legend order: average share over all points, largest first
+	weight := map[string]float64{}
+	for _, p := range points {
+		for _, s := range p.Shares {
+			weight[s.Language] += s.Percent
+		}
+	}
+	names := make([]string, 0, len(weight))
+	for n := range weight {
+		names = append(names, n)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if weight[names[i]] != weight[names[j]] {
+			return weight[names[i]] > weight[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	glyph := map[string]byte{}
+	for i, n := range names {
+		glyph[n] = historyGlyphs[min(i, len(historyGlyphs)-1)]
+	}
+
+	for _, p := range points {
+		fmt.Fprintf(w, "%s %s |%s|\n", p.Time.Format("2006-01-02"), p.Commit[:min(7, len(p.Commit))], stackedBar(p.Shares, names, glyph, width))
+	}
+	fmt.Fprintln(w)
+	for _, n := range names {
+		fmt.Fprintf(w, "  %c %s\n", glyph[n], n)
+	}
+}
+
+// stackedBar splits width cells between languages by largest remainder, so
+// the segments always add up to the full bar.
+func stackedBar(shares []Share, order []string, glyph map[string]byte, width int) string {
+	pct := map[string]float64{}
+	for _, s := range shares {
+		pct[s.Language] = s.Percent
+	}
+	cells := make([]int, len(order))
+	type rem struct {
+		i    int
+		frac float64
+	}
+	var rems []rem
+	used := 0
+	for i, n := range order {
+		exact := pct[n] / 100 * float64(width)
+		cells[i] = int(exact)
+		used += cells[i]
+		if pct[n] > 0 {
+			rems = append(rems, rem{i, exact - float64(cells[i])})
+		}
+	}
+	if len(shares) > 0 {
+		sort.SliceStable(rems, func(a, b int) bool { return rems[a].frac > rems[b].frac })
+		for k := 0; used < width && k < len(rems); k++ {
+			cells[rems[k].i]++
+			used++
+		}
+	}
+
+	var b strings.Builder
+	for i, n := range order {
+		b.WriteString(strings.Repeat(string(glyph[n]), cells[i]))
+	}
+	b.WriteString(strings.Repeat(" ", width-used))
+	return b.String()
+}
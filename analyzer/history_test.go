@@ -0,0 +1,119 @@
+package analyzer
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// commitAt commits the working tree of dir with the given date.
+func commitAt(t *testing.T, dir, date string) {
+	t.Helper()
+	for _, args := range [][]string{{"add", "-A"}, {"commit", "-q", "-m", date}} {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@example.com", "GIT_AUTHOR_DATE="+date,
+			"GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@example.com", "GIT_COMMITTER_DATE="+date)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+}
+
+func TestLanguageHistory_SamplesCommitsFromObjectDatabase(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	td := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", td).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+
+	writeTestFile(t, filepath.Join(td, "a.js"), "var a = 1;\nvar b = 2;\n")
+	writeTestFile(t, filepath.Join(td, "b.js"), "var c = 3;\n")
+	commitAt(t, td, "2024-01-01T10:00:00Z")
+	writeTestFile(t, filepath.Join(td, "c.js"), "var d = 4;\n")
+	commitAt(t, td, "2024-01-03T10:00:00Z") // within a week of the next sample
+	os.Remove(filepath.Join(td, "a.js"))
+	writeTestFile(t, filepath.Join(td, "a.ts"), "const a: number = 1;\n// migrated\n")
+	commitAt(t, td, "2024-01-09T10:00:00Z")
+	// uncommitted changes are not part of history
+	writeTestFile(t, filepath.Join(td, "d.ts"), "const d = 1;\n")
+
+	points, err := LanguageHistory(context.Background(), td, HistoryOptions{Every: 7 * 24 * time.Hour, Share: ShareOptions{By: ShareLines}})
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	if len(points) != 2 {
+		t.Fatalf("expected 2 samples, got %d: %+v", len(points), points)
+	}
+	if !points[0].Time.Before(points[1].Time) {
+		t.Fatalf("points are not oldest first")
+	}
+	first, last := points[0].Shares, points[1].Shares
+	if len(first) != 1 || first[0].Language != "JavaScript" || first[0].Value != 3 {
+		t.Fatalf("unexpected first sample: %+v", first)
+	}
+	if len(last) != 2 || last[0].Language != "JavaScript" || last[1].Language != "TypeScript" || last[1].Value != 2 || last[1].Files != 1 {
+		t.Fatalf("unexpected last sample: %+v", last)
+	}
+
+	var csv, chart bytes.Buffer
+	if err := WriteHistoryCSV(&csv, points); err != nil {
+		t.Fatalf("csv: %v", err)
+	}
+	if rows := strings.Split(strings.TrimSpace(csv.String()), "\n"); len(rows) != 4 || rows[0] != "commit,time,language,value,percent" {
+		t.Fatalf("unexpected csv:\n%s", csv.String())
+	}
+	PrettyPrintHistory(&chart, points, 10)
+	if !strings.Contains(chart.String(), "|##########|") || !strings.Contains(chart.String(), "|#####=====|") {
+		t.Fatalf("unexpected chart:\n%s", chart.String())
+	}
+}
+
+func TestParseEvery(t *testing.T) {
+	cases := map[string]time.Duration{
+		"1w":    7 * 24 * time.Hour,
+		"3d":    72 * time.Hour,
+		"2w12h": 14*24*time.Hour + 12*time.Hour,
+		"90m":   90 * time.Minute,
+	}
+	for in, want := range cases {
+		if got, err := ParseEvery(in); err != nil || got != want {
+			t.Errorf("ParseEvery(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseEvery("weekly"); err == nil {
+		t.Errorf("expected error for weekly")
+	}
+}
+
+func TestLanguageHistory_RevIsNotAnOption(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	td := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", td).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	writeTestFile(t, filepath.Join(td, "a.js"), "var a = 1;\n")
+	commitAt(t, td, "2024-01-01T10:00:00Z")
+
+	probe := filepath.Join(t.TempDir(), "probe")
+	for _, rev := range []string{"--output=" + probe, "-o" + probe, "nope"} {
+		if _, err := LanguageHistory(context.Background(), td, HistoryOptions{Rev: rev}); err == nil {
+			t.Errorf("%s: expected an error", rev)
+		}
+	}
+	if _, err := os.Stat(probe); err == nil {
+		t.Fatal("a rev was passed to git as an option")
+	}
+	if points, err := LanguageHistory(context.Background(), td, HistoryOptions{Rev: "HEAD"}); err != nil || len(points) != 1 {
+		t.Fatalf("HEAD: %v, %+v", err, points)
+	}
+}
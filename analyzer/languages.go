@@ -0,0 +1,197 @@
+package analyzer
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	doublestar "github.com/bmatcuk/doublestar/v4"
+)
+
+//go:embed languages.json
+var languagesFile []byte
+
+// Language types, following GitHub Linguist.
+const (
+	TypeProgramming = "programming"
+	TypeMarkup      = "markup"
+	TypeData        = "data"
+	TypeProse       = "prose"
+)
+
+// OtherLanguage is reported for files the table doesn't know.
+const OtherLanguage = "Other"
+
+type Language struct {
+	Name         string   `json:"name"`
+	Type         string   `json:"type"`
+	Fence        string   `json:"fence"` // Markdown code-fence tag
+	Extensions   []string `json:"extensions"`
+	Filenames    []string `json:"filenames"`
+	Interpreters []string `json:"interpreters"`
+
+	LineComments  []string    `json:"lineComments"`
+	BlockComments [][2]string `json:"blockComments"` // start/end pairs
+}
+
+// Classification is what DetectLanguage knows about a single file.
+type Classification struct {
+	Language      string `json:"language" toon:"language"`
+	Type          string `json:"type,omitempty" toon:"type"`
+	Fence         string `json:"fence,omitempty" toon:"fence"`
+	Vendored      bool   `json:"vendored,omitempty" toon:"vendored"`
+	Generated     bool   `json:"generated,omitempty" toon:"generated"`
+	Documentation bool   `json:"documentation,omitempty" toon:"documentation"`
+}
+
+type languageTable struct {
+	Languages     []Language `json:"languages"`
+	Vendored      []string   `json:"vendored"`
+	Generated     []string   `json:"generated"`
+	Documentation []string   `json:"documentation"`
+
+	byExt         map[string]*Language
+	byName        map[string]*Language
+	byInterpreter map[string]*Language
+	byLanguage    map[string]*Language
+}
+
+var (
+	langOnce  sync.Once
+	langTable *languageTable
+)
+
+// languages parses the embedded table once. The table ships with the
+// binary, so a parse failure is a programming error.
+func languages() *languageTable {
+	langOnce.Do(func() {
+		t := &languageTable{}
+		if err := json.Unmarshal(languagesFile, t); err != nil {
+			panic("analyzer: invalid languages.json: " + err.Error())
+		}
+		t.byExt = map[string]*Language{}
+		t.byName = map[string]*Language{}
+		t.byInterpreter = map[string]*Language{}
+		t.byLanguage = map[string]*Language{}
+		for i := range t.Languages {
+			l := &t.Languages[i]
+			t.byLanguage[l.Name] = l
+			for _, e := range l.Extensions {
+				if _, dup := t.byExt[e]; !dup {
+					t.byExt[e] = l
+				}
+				if lower := strings.ToLower(e); lower != e {
+					if _, dup := t.byExt[lower]; !dup {
+						t.byExt[lower] = l
+					}
+				}
+			}
+			for _, n := range l.Filenames {
+				t.byName[n] = l
+			}
+			for _, in := range l.Interpreters {
+				t.byInterpreter[in] = l
+			}
+		}
+		langTable = t
+	})
+	return langTable
+}
+
+// Languages returns the embedded language table.
+func Languages() []Language {
+	return languages().Languages
+}
+
+// LookupLanguage returns the table entry for a canonical language name.
+func LookupLanguage(name string) (Language, bool) {
+	l, ok := languages().byLanguage[name]
+	if !ok {
+		return Language{}, false
+	}
+	return *l, true
+}
+
+// DetectLanguage classifies a file by its path ('/' or OS separated,
+// relative to the project root) and, for extensionless scripts, by the
+// shebang in head. head may be nil.
+func DetectLanguage(relPath string, head []byte) Classification {
+	t := languages()
+	rel := filepath.ToSlash(relPath)
+	name := path.Base(rel)
+
+	var lang *Language
+	if l, ok := t.byName[name]; ok {
+		lang = l
+	} else if ext := path.Ext(name); ext != "" {
+		if l, ok := t.byExt[ext]; ok {
+			lang = l
+		} else if l, ok := t.byExt[strings.ToLower(ext)]; ok {
+			lang = l
+		}
+	}
+	if lang == nil {
+		lang = t.byInterpreter[shebangInterpreter(head)]
+	}
+	if lang == nil && strings.HasPrefix(name, "Dockerfile") {
+		lang = t.byName["Dockerfile"]
+	}
+
+	c := Classification{Language: OtherLanguage}
+	if lang != nil {
+		c.Language, c.Type, c.Fence = lang.Name, lang.Type, lang.Fence
+	}
+	c.Vendored = matchesAny(rel, t.Vendored)
+	c.Generated = matchesAny(rel, t.Generated)
+	c.Documentation = matchesAny(rel, t.Documentation)
+	return c
+}
+
+// shebangInterpreter returns "python3" for "#!/usr/bin/env -S python3 -u".
+func shebangInterpreter(head []byte) string {
+	if !bytes.HasPrefix(head, []byte("#!")) {
+		return ""
+	}
+	line := head[2:]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return ""
+	}
+	interp := path.Base(fields[0])
+	if interp == "env" {
+		interp = ""
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") && !strings.Contains(f, "=") {
+				interp = f
+				break
+			}
+		}
+	}
+	// python3.12 -> python3
+	if i := strings.IndexFunc(interp, func(r rune) bool { return r == '.' }); i > 0 {
+		interp = interp[:i]
+	}
+	return interp
+}
+
+func matchesAny(rel string, globs []string) bool {
+	_, ok := matchingGlob(rel, globs)
+	return ok
+}
+
+// matchingGlob returns the first of globs matching rel.
+func matchingGlob(rel string, globs []string) (string, bool) {
+	for _, g := range globs {
+		if ok, _ := doublestar.Match(g, rel); ok {
+			return g, true
+		}
+	}
+	return "", false
+}
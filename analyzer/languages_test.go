@@ -0,0 +1,43 @@
+package analyzer
+
+import "testing"
+
+func TestDetectLanguage(t *testing.T) {
+	cases := []struct {
+		path string
+		head string
+		want string
+		typ  string
+	}{
+		{"a/b.yml", "", "YAML", TypeData},
+		{"a/b.yaml", "", "YAML", TypeData},
+		{"Makefile", "", "Makefile", TypeProgramming},
+		{"include/x.h", "", "C", TypeProgramming},
+		{"README.md", "", "Markdown", TypeProse},
+		{"bin/tool", "#!/usr/bin/env -S python3.12 -u\n", "Python", TypeProgramming},
+		{"bin/run", "#!/bin/bash\n", "Shell", TypeProgramming},
+		{"Dockerfile.dev", "", "Dockerfile", TypeProgramming},
+		{"data.bin", "", OtherLanguage, ""},
+	}
+	for _, c := range cases {
+		got := DetectLanguage(c.path, []byte(c.head))
+		if got.Language != c.want || got.Type != c.typ {
+			t.Fatalf("%s: got %+v, want %s/%s", c.path, got, c.want, c.typ)
+		}
+	}
+}
+
+func TestDetectLanguage_Flags(t *testing.T) {
+	if c := DetectLanguage("vendor/github.com/x/y.go", nil); !c.Vendored || c.Language != "Go" {
+		t.Fatalf("expected vendored Go, got %+v", c)
+	}
+	if c := DetectLanguage("api/v1/api.pb.go", nil); !c.Generated {
+		t.Fatalf("expected generated, got %+v", c)
+	}
+	if c := DetectLanguage("docs/guide/intro.md", nil); !c.Documentation {
+		t.Fatalf("expected documentation, got %+v", c)
+	}
+	if c := DetectLanguage("cmd/root.go", nil); c.Vendored || c.Generated || c.Documentation {
+		t.Fatalf("expected plain source, got %+v", c)
+	}
+}
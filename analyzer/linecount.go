@@ -0,0 +1,223 @@
+package analyzer
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"sort"
+)
+
+// LineCounts splits a file's lines the way cloc does. A line holding both
+// code and a comment counts as code.
+type LineCounts struct {
+	Code    int `json:"code" toon:"code"`
+	Comment int `json:"comment" toon:"comment"`
+	Blank   int `json:"blank" toon:"blank"`
+}
+
+// Total is the number of physical lines.
+func (c LineCounts) Total() int { return c.Code + c.Comment + c.Blank }
+
+// LanguageStat aggregates LineCounts over every file of one language.
+type LanguageStat struct {
+	Language string `json:"language" toon:"language"`
+	Files    int    `json:"files" toon:"files"`
+	Code     int    `json:"code" toon:"code"`
+	Comment  int    `json:"comment" toon:"comment"`
+	Blank    int    `json:"blank" toon:"blank"`
+}
+
+// CountLines classifies every line read from r using the comment syntax of
+// language (a canonical name from the language table). Unlike a
+// bufio.Scanner it has no line-length limit, so minified files count
+// correctly.
+func CountLines(r io.Reader, language string) (LineCounts, error) {
+	var lineMarkers []string
+	var blocks [][2]string
+	if l, ok := LookupLanguage(language); ok {
+		lineMarkers, blocks = l.LineComments, l.BlockComments
+	}
+
+	var counts LineCounts
+	br := bufio.NewReaderSize(r, 64*1024)
+	inBlock := -1 // index into blocks while inside a block comment
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			var code, comment bool
+			code, comment, inBlock = classifyLine(bytes.TrimSpace(line), lineMarkers, blocks, inBlock)
+			switch {
+			case code:
+				counts.Code++
+			case comment:
+				counts.Comment++
+			default:
+				counts.Blank++
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return counts, nil
+		}
+		if err != nil {
+			return counts, err
+		}
+	}
+}
+
+// classifyLine scans one trimmed line, tracking block comments that span lines.
+func classifyLine(line []byte, lineMarkers []string, blocks [][2]string, inBlock int) (code, comment bool, state int) {
+	if len(line) == 0 {
+		return false, false, inBlock
+	}
+	i := 0
+	for i < len(line) {
+		if inBlock >= 0 {
+			comment = true
+			end := bytes.Index(line[i:], []byte(blocks[inBlock][1]))
+			if end < 0 {
+				return code, comment, inBlock
+			}
+			i += end + len(blocks[inBlock][1])
+			inBlock = -1
+			continue
+		}
+		if c := line[i]; c == ' ' || c == '\t' {
+			i++
+			continue
+		}
+		if hasAnyPrefix(line[i:], lineMarkers) {
+			return code, true, inBlock
+		}
+		if b := blockStart(line[i:], blocks); b >= 0 {
+			inBlock = b
+			i += len(blocks[b][0])
+			comment = true
+			continue
+		}
+		code = true
+		// markers inside a string literal are not comments
+		if n := stringLen(line[i:]); n > 0 {
+			i += n
+			continue
+		}
+		// jump to the next possible comment marker or string
+		next := nextMarker(line[i+1:], lineMarkers, blocks)
+		if next < 0 {
+			return code, comment, inBlock
+		}
+		i += 1 + next
+	}
+	return code, comment, inBlock
+}
+
+func hasAnyPrefix(b []byte, prefixes []string) bool {
+	for _, p := range prefixes {
+		if bytes.HasPrefix(b, []byte(p)) {
+			return true
+		}
+	}
+	return false
+}
+
+func blockStart(b []byte, blocks [][2]string) int {
+	for i, pair := range blocks {
+		if bytes.HasPrefix(b, []byte(pair[0])) {
+			return i
+		}
+	}
+	return -1
+}
+
+func nextMarker(b []byte, lineMarkers []string, blocks [][2]string) int {
+	best := bytes.IndexAny(b, "\"'`")
+	consider := func(m string) {
+		if idx := bytes.Index(b, []byte(m)); idx >= 0 && (best < 0 || idx < best) {
+			best = idx
+		}
+	}
+	for _, m := range lineMarkers {
+		consider(m)
+	}
+	for _, pair := range blocks {
+		consider(pair[0])
+	}
+	return best
+}
+
+// stringLen returns the length of the "…", '…' or `…` literal b starts
+// with, quotes included, or 0 when b doesn't start with one that ends on
+// the line. A backslash escapes the next byte except in `…`.
+func stringLen(b []byte) int {
+	if len(b) == 0 || (b[0] != '"' && b[0] != '\'' && b[0] != '`') {
+		return 0
+	}
+	q := b[0]
+	for i := 1; i < len(b); i++ {
+		switch {
+		case b[i] == '\\' && q != '`':
+			i++
+		case b[i] == q:
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// CountFileLines opens path and counts its lines. Binary files (a NUL byte
+// in the first 8 KB) have no lines.
+func CountFileLines(path, language string) (LineCounts, error) {
+	lines, _, err := measureFile(path, language)
+	return lines, err
+}
+
+// measureFile counts lines and estimates tokens in a single read of path.
+func measureFile(path, language string) (LineCounts, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return LineCounts{}, 0, err
+	}
+	defer f.Close()
+	return measure(f, language)
+}
+
+// measure counts lines and tokens of r. Binary content (a NUL byte in the
+// first 8 KB) has neither.
+func measure(r io.Reader, language string) (LineCounts, int, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+	if head, _ := br.Peek(8192); bytes.IndexByte(head, 0) >= 0 {
+		return LineCounts{}, 0, nil
+	}
+	var tokens TokenCounter
+	lines, err := CountLines(io.TeeReader(br, &tokens), language)
+	return lines, tokens.Tokens(), err
+}
+
+// CollectLanguageStats sums per-file line counts by language, largest code
+// base first.
+func CollectLanguageStats(files []FileInfo) []LanguageStat {
+	byLang := map[string]*LanguageStat{}
+	for _, f := range files {
+		s, ok := byLang[f.Language]
+		if !ok {
+			s = &LanguageStat{Language: f.Language}
+			byLang[f.Language] = s
+		}
+		s.Files++
+		s.Code += f.Code
+		s.Comment += f.Comment
+		s.Blank += f.Blank
+	}
+	out := make([]LanguageStat, 0, len(byLang))
+	for _, s := range byLang {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Code != out[j].Code {
+			return out[i].Code > out[j].Code
+		}
+		return out[i].Language < out[j].Language
+	})
+	return out
+}
@@ -0,0 +1,84 @@
+package analyzer
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCountLines_GoBlockComments(t *testing.T) {
+	src := `// Package x does things.
+package x
+
+/* a block
+   spanning lines */
+func f() int { /* inline */ return 1 } // trailing
+
+/* one-liner */
+
+var s = 1 /* starts here
+ends here */
+`
+	got, err := CountLines(strings.NewReader(src), "Go")
+	if err != nil {
+		t.Fatalf("CountLines: %v", err)
+	}
+	want := LineCounts{Code: 3, Comment: 5, Blank: 3}
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestCountLines_PythonAndUnknown(t *testing.T) {
+	src := "\"\"\"Module docstring.\n\nMore.\n\"\"\"\nimport os  # comment\n# full comment\n\nprint(os)\n"
+	got, _ := CountLines(strings.NewReader(src), "Python")
+	if want := (LineCounts{Code: 2, Comment: 4, Blank: 2}); got != want {
+		t.Fatalf("python: got %+v, want %+v", got, want)
+	}
+
+	got, _ = CountLines(strings.NewReader("a\n\n// not a comment here\n"), OtherLanguage)
+	if want := (LineCounts{Code: 2, Blank: 1}); got != want {
+		t.Fatalf("unknown: got %+v, want %+v", got, want)
+	}
+}
+
+func TestCountLines_MarkersInStrings(t *testing.T) {
+	src := "var g = \"dir/*.go\"\nvar u = `http://x` // real\nvar q = \"\\\"/*\"\n/* real */\nvar r = '/*'\n"
+	got, _ := CountLines(strings.NewReader(src), "Go")
+	if want := (LineCounts{Code: 4, Comment: 1}); got != want {
+		t.Fatalf("go: got %+v, want %+v", got, want)
+	}
+
+	src = "s = '#'\nt = \"# x\" # real\n# real\n"
+	got, _ = CountLines(strings.NewReader(src), "Python")
+	if want := (LineCounts{Code: 2, Comment: 1}); got != want {
+		t.Fatalf("python: got %+v, want %+v", got, want)
+	}
+}
+
+func TestCountLines_LongLinesAndNoTrailingNewline(t *testing.T) {
+	long := strings.Repeat("x", 200_000)
+	got, err := CountLines(strings.NewReader(long+"\n"+long), "JavaScript")
+	if err != nil {
+		t.Fatalf("CountLines: %v", err)
+	}
+	if got.Code != 2 || got.Total() != 2 {
+		t.Fatalf("expected 2 code lines for minified input, got %+v", got)
+	}
+}
+
+func TestCollectLanguageStats(t *testing.T) {
+	td := t.TempDir()
+	writeTestFile(t, filepath.Join(td, "a.go"), "package a\n\n// c\n")
+	writeTestFile(t, filepath.Join(td, "b.go"), "package a\n")
+	writeTestFile(t, filepath.Join(td, "c.yml"), "# c\nk: v\n")
+
+	ctx := AnalyzeProject(td)
+	if len(ctx.Languages) != 2 {
+		t.Fatalf("expected 2 languages, got %+v", ctx.Languages)
+	}
+	g := ctx.Languages[0]
+	if g.Language != "Go" || g.Files != 2 || g.Code != 2 || g.Comment != 1 || g.Blank != 1 {
+		t.Fatalf("unexpected Go stats: %+v", g)
+	}
+}
@@ -0,0 +1,374 @@
+package analyzer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LockedPackage is a direct dependency as resolved by a lockfile.
+type LockedPackage struct {
+	Name    string `json:"name" toon:"name"`
+	Version string `json:"version" toon:"version"`
+	Scope   string `json:"scope" toon:"scope"`
+}
+
+// LockfileSummary is a compact replacement for a lockfile's content.
+type LockfileSummary struct {
+	Lockfile  string          `json:"lockfile" toon:"lockfile"`
+	Ecosystem string          `json:"ecosystem" toon:"ecosystem"`
+	Resolved  int             `json:"resolved" toon:"resolved"` // every package the lockfile pins
+	Direct    []LockedPackage `json:"direct" toon:"direct"`
+}
+
+type lockfileSummarizer func(s *LockfileSummary, data []byte, dir string) error
+
+var lockfiles = map[string]struct {
+	ecosystem string
+	summarize lockfileSummarizer
+}{
+	"package-lock.json": {EcosystemNPM, summarizePackageLock},
+	"yarn.lock":         {EcosystemNPM, summarizeYarnLock},
+	"pnpm-lock.yaml":    {EcosystemNPM, summarizePnpmLock},
+	"go.sum":            {EcosystemGo, summarizeGoSum},
+	"Cargo.lock":        {EcosystemCargo, summarizeCargoLock},
+	"poetry.lock":       {EcosystemPyPI, summarizePoetryLock},
+}
+
+// IsLockfile reports whether name is a lockfile ctx3 can summarise.
+func IsLockfile(name string) bool {
+	_, ok := lockfiles[name]
+	return ok
+}
+
+// SummarizeLockfile condenses a lockfile into its resolved direct
+// dependencies. path is used to locate the sibling manifest (package.json,
+// go.mod, Cargo.toml, pyproject.toml) that tells direct and transitive
+// packages apart; data is the lockfile content.
+func SummarizeLockfile(path string, data []byte) (LockfileSummary, error) {
+	lf, ok := lockfiles[filepath.Base(path)]
+	if !ok {
+		return LockfileSummary{}, fmt.Errorf("not a known lockfile: %s", path)
+	}
+	s := LockfileSummary{Lockfile: filepath.ToSlash(path), Ecosystem: lf.ecosystem}
+	if err := lf.summarize(&s, data, filepath.Dir(path)); err != nil {
+		return s, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	sort.Slice(s.Direct, func(i, j int) bool {
+		if ri, rj := scopeRank(s.Direct[i].Scope), scopeRank(s.Direct[j].Scope); ri != rj {
+			return ri < rj
+		}
+		return s.Direct[i].Name < s.Direct[j].Name
+	})
+	return s, nil
+}
+
+// Render formats the summary as a small table that is clearly marked as
+// standing in for the original lockfile.
+func (s LockfileSummary) Render() []byte {
+	var buf bytes.Buffer
+	scopes := map[string]int{}
+	for _, p := range s.Direct {
+		scopes[p.Scope]++
+	}
+	names := make([]string, 0, len(scopes))
+	for k := range scopes {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var parts []string
+	for _, k := range names {
+		parts = append(parts, fmt.Sprintf("%s %d", k, scopes[k]))
+	}
+
+	fmt.Fprintf(&buf, "[ctx3 lockfile summary: original content of %s omitted]\n", filepath.Base(s.Lockfile))
+	fmt.Fprintf(&buf, "ecosystem: %s, resolved packages: %d, direct: %d", s.Ecosystem, s.Resolved, len(s.Direct))
+	if len(parts) > 0 {
+		fmt.Fprintf(&buf, " (%s)", strings.Join(parts, ", "))
+	}
+	buf.WriteString("\n")
+	if len(s.Direct) == 0 {
+		return buf.Bytes()
+	}
+	buf.WriteString("\n")
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "name\tversion\tscope")
+	for _, p := range s.Direct {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", p.Name, p.Version, p.Scope)
+	}
+	tw.Flush()
+	return buf.Bytes()
+}
+
+var scopeOrder = []string{ScopeRuntime, ScopePeer, ScopeOptional, ScopeBuild, ScopeDev, ScopeTest}
+
+func scopeRank(scope string) int {
+	for i, s := range scopeOrder {
+		if s == scope {
+			return i
+		}
+	}
+	return len(scopeOrder)
+}
+
+// siblingDeps parses a manifest next to the lockfile, if present.
+func siblingDeps(dir, manifest string) []Dependency {
+	data, err := os.ReadFile(filepath.Join(dir, manifest))
+	if err != nil {
+		return nil
+	}
+	return ParseManifest(manifest, data)
+}
+
+// directFrom resolves each manifest dependency against the versions found
+// in the lockfile.
+func directFrom(deps []Dependency, resolved map[string][]string) []LockedPackage {
+	var out []LockedPackage
+	seen := map[string]bool{}
+	for _, d := range deps {
+		if d.Scope == ScopeWorkspace || d.Scope == ScopeIndirect || seen[d.Name] {
+			continue
+		}
+		seen[d.Name] = true
+		versions := resolved[d.Name]
+		if len(versions) == 0 {
+			versions = resolved[normalizePyName(d.Name)]
+		}
+		version := strings.Join(versions, ", ")
+		if version == "" {
+			version = d.Version
+		}
+		out = append(out, LockedPackage{Name: d.Name, Version: version, Scope: d.Scope})
+	}
+	return out
+}
+
+func addResolved(m map[string][]string, name, version string) {
+	for _, v := range m[name] {
+		if v == version {
+			return
+		}
+	}
+	m[name] = append(m[name], version)
+}
+
+// --- npm ---
+
+func summarizePackageLock(s *LockfileSummary, data []byte, dir string) error {
+	var lock struct {
+		Packages map[string]struct {
+			Version              string            `json:"version"`
+			Dependencies         map[string]string `json:"dependencies"`
+			DevDependencies      map[string]string `json:"devDependencies"`
+			PeerDependencies     map[string]string `json:"peerDependencies"`
+			OptionalDependencies map[string]string `json:"optionalDependencies"`
+		} `json:"packages"`
+		Dependencies map[string]struct {
+			Version string `json:"version"`
+		} `json:"dependencies"`
+	}
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return err
+	}
+
+	resolved := map[string][]string{}
+	if len(lock.Packages) > 0 {
+		// lockfileVersion 2/3: keys are install paths, "" is the root project
+		for key, p := range lock.Packages {
+			if key == "" {
+				continue
+			}
+			s.Resolved++
+			if i := strings.LastIndex(key, "node_modules/"); i >= 0 && !strings.Contains(key[:i], "node_modules/") {
+				addResolved(resolved, key[i+len("node_modules/"):], p.Version)
+			}
+		}
+		if root, ok := lock.Packages[""]; ok {
+			var deps []Dependency
+			deps = appendMapDeps(deps, EcosystemNPM, root.Dependencies, ScopeRuntime, "")
+			deps = appendMapDeps(deps, EcosystemNPM, root.DevDependencies, ScopeDev, "")
+			deps = appendMapDeps(deps, EcosystemNPM, root.PeerDependencies, ScopePeer, "")
+			deps = appendMapDeps(deps, EcosystemNPM, root.OptionalDependencies, ScopeOptional, "")
+			s.Direct = directFrom(deps, resolved)
+			return nil
+		}
+	} else {
+		// lockfileVersion 1
+		for name, d := range lock.Dependencies {
+			s.Resolved++
+			addResolved(resolved, name, d.Version)
+		}
+	}
+	s.Direct = directFrom(siblingDeps(dir, "package.json"), resolved)
+	return nil
+}
+
+func summarizeYarnLock(s *LockfileSummary, data []byte, dir string) error {
+	// Both classic and berry lockfiles are blocks of
+	//   "name@range", name@other-range:
+	//     version "1.2.3"   (berry: version: 1.2.3)
+	resolved := map[string][]string{}
+	var names []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if line[0] != ' ' && strings.HasSuffix(line, ":") {
+			names = names[:0]
+			if strings.HasPrefix(line, "__metadata") {
+				continue
+			}
+			for _, desc := range strings.Split(strings.TrimSuffix(line, ":"), ",") {
+				desc = strings.Trim(strings.TrimSpace(desc), `"`)
+				if name := yarnDescriptorName(desc); name != "" {
+					names = append(names, name)
+				}
+			}
+			if len(names) > 0 {
+				s.Resolved++
+			}
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "version") || len(names) == 0 || strings.HasPrefix(line, "    ") {
+			continue
+		}
+		version := strings.Trim(strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(trimmed, "version"), ":")), `"`)
+		for _, n := range names {
+			addResolved(resolved, n, version)
+		}
+		names = names[:0]
+	}
+	s.Direct = directFrom(siblingDeps(dir, "package.json"), resolved)
+	return nil
+}
+
+// yarnDescriptorName extracts "@scope/pkg" from "@scope/pkg@npm:^1.0.0".
+func yarnDescriptorName(desc string) string {
+	start := 0
+	if strings.HasPrefix(desc, "@") {
+		start = 1
+	}
+	if i := strings.Index(desc[start:], "@"); i >= 0 {
+		return desc[:start+i]
+	}
+	return ""
+}
+
+func summarizePnpmLock(s *LockfileSummary, data []byte, dir string) error {
+	var lock struct {
+		Importers    map[string]pnpmImporter `yaml:"importers"`
+		Packages     map[string]yaml.Node    `yaml:"packages"`
+		pnpmImporter `yaml:",inline"`
+	}
+	if err := yaml.Unmarshal(data, &lock); err != nil {
+		return err
+	}
+	s.Resolved = len(lock.Packages)
+
+	root := lock.pnpmImporter
+	if imp, ok := lock.Importers["."]; ok {
+		root = imp
+	}
+	for _, group := range []struct {
+		deps  map[string]yaml.Node
+		scope string
+	}{
+		{root.Dependencies, ScopeRuntime},
+		{root.DevDependencies, ScopeDev},
+		{root.OptionalDependencies, ScopeOptional},
+	} {
+		for name, node := range group.deps {
+			// v5: `name: 1.2.3`; v6+: `name: {specifier: ^1, version: 1.2.3(peer@x)}`
+			version := node.Value
+			if node.Kind == yaml.MappingNode {
+				var v struct {
+					Version string `yaml:"version"`
+				}
+				_ = node.Decode(&v)
+				version = v.Version
+			}
+			if i := strings.Index(version, "("); i >= 0 {
+				version = version[:i]
+			}
+			s.Direct = append(s.Direct, LockedPackage{Name: name, Version: version, Scope: group.scope})
+		}
+	}
+	return nil
+}
+
+type pnpmImporter struct {
+	Dependencies         map[string]yaml.Node `yaml:"dependencies"`
+	DevDependencies      map[string]yaml.Node `yaml:"devDependencies"`
+	OptionalDependencies map[string]yaml.Node `yaml:"optionalDependencies"`
+}
+
+// --- Go ---
+
+func summarizeGoSum(s *LockfileSummary, data []byte, dir string) error {
+	modules := map[string]bool{}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		modules[fields[0]+"@"+strings.TrimSuffix(fields[1], "/go.mod")] = true
+	}
+	s.Resolved = len(modules)
+
+	resolved := map[string][]string{}
+	deps := siblingDeps(dir, "go.mod")
+	for _, d := range deps {
+		resolved[d.Name] = []string{d.Version}
+	}
+	s.Direct = directFrom(deps, resolved)
+	return nil
+}
+
+// --- Rust / Python ---
+
+func summarizeCargoLock(s *LockfileSummary, data []byte, dir string) error {
+	resolved := lockedTOMLPackages(s, data, func(n string) string { return n })
+	var deps []Dependency
+	for _, d := range siblingDeps(dir, "Cargo.toml") {
+		if !strings.HasPrefix(d.Version, "path:") {
+			deps = append(deps, d)
+		}
+	}
+	s.Direct = directFrom(deps, resolved)
+	return nil
+}
+
+func summarizePoetryLock(s *LockfileSummary, data []byte, dir string) error {
+	resolved := lockedTOMLPackages(s, data, normalizePyName)
+	s.Direct = directFrom(siblingDeps(dir, "pyproject.toml"), resolved)
+	return nil
+}
+
+// lockedTOMLPackages reads the [[package]] tables shared by Cargo.lock and poetry.lock.
+func lockedTOMLPackages(s *LockfileSummary, data []byte, normalize func(string) string) map[string][]string {
+	resolved := map[string][]string{}
+	for _, sec := range parseTOML(data) {
+		if !sec.IsArray || sec.Name != "package" {
+			continue
+		}
+		name, _ := sec.get("name")
+		version, _ := sec.get("version")
+		s.Resolved++
+		addResolved(resolved, normalize(tomlString(name)), tomlString(version))
+	}
+	return resolved
+}
+
+// normalizePyName applies PEP 503 normalisation so "Flask_Login" matches "flask-login".
+func normalizePyName(name string) string {
+	name = strings.ToLower(name)
+	return strings.NewReplacer("_", "-", ".", "-").Replace(name)
+}
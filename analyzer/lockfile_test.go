@@ -0,0 +1,123 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
+
+func TestSummarizeLockfile_PackageLockV3(t *testing.T) {
+	lock := `{
+  "lockfileVersion": 3,
+  "packages": {
+    "": {"dependencies": {"react": "^18.2.0"}, "devDependencies": {"vite": "^5.0.0"}},
+    "node_modules/react": {"version": "18.2.0"},
+    "node_modules/loose-envify": {"version": "1.4.0"},
+    "node_modules/vite": {"version": "5.1.4"},
+    "node_modules/vite/node_modules/esbuild": {"version": "0.19.0"}
+  }
+}`
+	s, err := SummarizeLockfile("package-lock.json", []byte(lock))
+	if err != nil {
+		t.Fatalf("summarize: %v", err)
+	}
+	if s.Resolved != 4 {
+		t.Fatalf("expected 4 resolved packages, got %d", s.Resolved)
+	}
+	if len(s.Direct) != 2 || s.Direct[0].Name != "react" || s.Direct[0].Version != "18.2.0" || s.Direct[1].Scope != ScopeDev {
+		t.Fatalf("unexpected direct deps: %+v", s.Direct)
+	}
+	out := string(s.Render())
+	if !strings.HasPrefix(out, "[ctx3 lockfile summary:") || !strings.Contains(out, "vite") {
+		t.Fatalf("unexpected render:\n%s", out)
+	}
+}
+
+func TestSummarizeLockfile_YarnUsesSiblingManifest(t *testing.T) {
+	td := t.TempDir()
+	writeTestFile(t, filepath.Join(td, "package.json"), `{"dependencies": {"@babel/core": "^7.0.0", "lodash": "^4.17.0"}}`)
+	yarn := `# yarn lockfile v1
+
+"@babel/core@^7.0.0", "@babel/core@^7.1.0":
+  version "7.24.0"
+  dependencies:
+    json5 "^2.2.3"
+
+json5@^2.2.3:
+  version "2.2.3"
+
+lodash@^4.17.0:
+  version "4.17.21"
+`
+	path := filepath.Join(td, "yarn.lock")
+	s, err := SummarizeLockfile(path, []byte(yarn))
+	if err != nil {
+		t.Fatalf("summarize: %v", err)
+	}
+	if s.Resolved != 3 || len(s.Direct) != 2 {
+		t.Fatalf("unexpected summary: %+v", s)
+	}
+	if s.Direct[0].Name != "@babel/core" || s.Direct[0].Version != "7.24.0" {
+		t.Fatalf("unexpected babel entry: %+v", s.Direct[0])
+	}
+}
+
+func TestSummarizeLockfile_PnpmAndCargo(t *testing.T) {
+	pnpm := `lockfileVersion: '9.0'
+importers:
+  .:
+    dependencies:
+      react:
+        specifier: ^18.2.0
+        version: 18.2.0
+    devDependencies:
+      vitest:
+        specifier: ^1.0.0
+        version: 1.3.1(@types/node@20.0.0)
+packages:
+  react@18.2.0:
+    resolution: {integrity: sha512-x}
+  vitest@1.3.1:
+    resolution: {integrity: sha512-y}
+  tinypool@0.8.0:
+    resolution: {integrity: sha512-z}
+`
+	s, err := SummarizeLockfile("pnpm-lock.yaml", []byte(pnpm))
+	if err != nil {
+		t.Fatalf("pnpm: %v", err)
+	}
+	if s.Resolved != 3 || len(s.Direct) != 2 || s.Direct[1].Version != "1.3.1" {
+		t.Fatalf("unexpected pnpm summary: %+v", s)
+	}
+
+	td := t.TempDir()
+	writeTestFile(t, filepath.Join(td, "Cargo.toml"), "[dependencies]\nserde = \"1\"\nlocal = { path = \"../local\" }\n")
+	cargo := "version = 3\n\n[[package]]\nname = \"serde\"\nversion = \"1.0.197\"\n\n[[package]]\nname = \"itoa\"\nversion = \"1.0.10\"\n"
+	s, err = SummarizeLockfile(filepath.Join(td, "Cargo.lock"), []byte(cargo))
+	if err != nil {
+		t.Fatalf("cargo: %v", err)
+	}
+	if s.Resolved != 2 || len(s.Direct) != 1 || s.Direct[0].Version != "1.0.197" {
+		t.Fatalf("unexpected cargo summary: %+v", s)
+	}
+}
+
+func TestSummarizeLockfile_Unknown(t *testing.T) {
+	if IsLockfile("Gemfile.lock.bak") {
+		t.Fatalf("unexpected lockfile detection")
+	}
+	if _, err := SummarizeLockfile("foo.lock", nil); err == nil {
+		t.Fatalf("expected error for unknown lockfile")
+	}
+}
@@ -0,0 +1,271 @@
+package analyzer
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	doublestar "github.com/bmatcuk/doublestar/v4"
+)
+
+// Origin says why a file is not hand-written source of the project. Each
+// field holds the reason, e.g. "path **/*.pb.go", and is empty when the
+// file isn't vendored or generated. Minified files count as generated.
+type Origin struct {
+	Vendored  string `json:"vendored,omitempty" toon:"vendored"`
+	Generated string `json:"generated,omitempty" toon:"generated"`
+}
+
+// Kind returns "vendored", "generated" or "" for hand-written files.
+// Vendored wins when both apply.
+func (o Origin) Kind() string {
+	switch {
+	case o.Vendored != "":
+		return "vendored"
+	case o.Generated != "":
+		return "generated"
+	}
+	return ""
+}
+
+// Reason returns the reason for Kind.
+func (o Origin) Reason() string {
+	if o.Vendored != "" {
+		return o.Vendored
+	}
+	return o.Generated
+}
+
+// DetectOriginByPath classifies a file by its path ('/'-separated,
+// relative to the project root) and attrs, which may be nil. An explicit
+// linguist attribute, set or unset, takes precedence over the path tables.
+func DetectOriginByPath(rel string, attrs *Attributes) Origin {
+	t := languages()
+	rel = filepath.ToSlash(rel)
+	var o Origin
+	vendored, vset := attrs.Vendored(rel)
+	switch {
+	case vset && vendored.Value:
+		o.Vendored = vendored.Source + " linguist-vendored"
+	case !vset:
+		if g, ok := matchingGlob(rel, t.Vendored); ok {
+			o.Vendored = "path " + g
+		}
+	}
+	generated, gset := attrs.Generated(rel)
+	switch {
+	case gset && generated.Value:
+		o.Generated = generated.Source + " linguist-generated"
+	case !gset:
+		if g, ok := matchingGlob(rel, t.Generated); ok {
+			o.Generated = "path " + g
+		}
+	}
+	return o
+}
+
+// DetectOrigin is DetectOriginByPath plus what the first bytes of the file
+// show: a generated-code header such as Go's "Code generated ... DO NOT
+// EDIT." line or "@generated", or minified code. head should be the first
+// few kilobytes of the file, OriginHeadBytes is enough; binary files are
+// only classified by path.
+func DetectOrigin(rel string, head []byte, attrs *Attributes) Origin {
+	o := DetectOriginByPath(rel, attrs)
+	if o.Generated != "" || bytes.IndexByte(head, 0) >= 0 {
+		return o
+	}
+	if _, set := attrs.Generated(rel); set {
+		return o // linguist-generated=false
+	}
+	if marker := generatedMarker(head); marker != "" {
+		o.Generated = "header " + marker
+	} else if avg, ok := minified(head); ok {
+		o.Generated = fmt.Sprintf("minified (%d bytes per line)", avg)
+	}
+	return o
+}
+
+// OriginHeadBytes is how much of a file DetectOrigin needs to see.
+const OriginHeadBytes = 8192
+
+// generatedHeader matches the comment lines generators put at the top of
+// their output: Go's convention, protoc, Thrift, T4, "@generated" (Meta),
+// "<auto-generated>" (.NET), ...
+var generatedHeader = regexp.MustCompile(`(?i)^\s*(?://+|#+|/\*+|\*+|--|;+|<!--|')\s*(?:` +
+	`(?:code generated|generated by|auto-?generated|this (?:file|code) (?:is|was) (?:auto(?:matically)?[- ]?)?generated).*do not edit` +
+	`|@generated\b` +
+	`|<auto-generated)`)
+
+// generatedMarker returns the generated-code header line among the first
+// lines of head, or "".
+func generatedMarker(head []byte) string {
+	sc := bufio.NewScanner(bytes.NewReader(head))
+	sc.Buffer(make([]byte, 0, 4096), len(head)+1)
+	for i := 0; i < 20 && sc.Scan(); i++ {
+		if line := sc.Text(); generatedHeader.MatchString(line) {
+			line = strings.TrimSpace(line)
+			if len(line) > 80 {
+				line = line[:77] + "..."
+			}
+			return line
+		}
+	}
+	return ""
+}
+
+// minified reports whether head looks like minified code or data: long
+// lines with hardly any whitespace, which prose and formatted source don't
+// have. It returns the average line length.
+func minified(head []byte) (int, bool) {
+	if len(head) < 512 {
+		return 0, false
+	}
+	lines := bytes.Count(head, []byte("\n"))
+	if len(head) > 0 && head[len(head)-1] != '\n' {
+		lines++
+	}
+	avg := len(head) / max(lines, 1)
+	spaces := 0
+	for _, b := range head {
+		if b == ' ' || b == '\t' {
+			spaces++
+		}
+	}
+	return avg, avg >= 200 && float64(spaces)/float64(len(head)) < 0.1
+}
+
+// Attributes holds the linguist-generated and linguist-vendored lines of
+// a .gitattributes file. A nil *Attributes sets nothing.
+type Attributes struct {
+	rules []attrRule
+	sum   string
+}
+
+// AttrValue is an attribute as set by the last matching line.
+type AttrValue struct {
+	Value  bool
+	Source string // ".gitattributes:3"
+}
+
+type attrRule struct {
+	pattern   string // doublestar glob relative to the root
+	generated *AttrValue
+	vendored  *AttrValue
+}
+
+// LoadAttributes reads the .gitattributes at root. It returns nil when
+// there is none.
+func LoadAttributes(root string) (*Attributes, error) {
+	data, err := os.ReadFile(filepath.Join(root, ".gitattributes"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ParseAttributes(data, ".gitattributes"), nil
+}
+
+// ParseAttributes parses .gitattributes content; source names the file in
+// AttrValue.Source. Patterns follow git: one without a slash matches the
+// file name at any depth, one with a slash matches from the root, and a
+// later line overrides an earlier one. "attr", "attr=true" set an
+// attribute, "-attr", "attr=false" unset it and "!attr" forgets it.
+func ParseAttributes(data []byte, source string) *Attributes {
+	sum := sha256.Sum256(data)
+	a := &Attributes{sum: hex.EncodeToString(sum[:])}
+	for i, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		pattern := fields[0]
+		if strings.HasSuffix(pattern, "/") {
+			continue // matches directories only, never a file
+		}
+		if strings.Contains(pattern, "/") {
+			pattern = strings.TrimPrefix(pattern, "/")
+		} else {
+			pattern = "**/" + pattern
+		}
+		r := attrRule{pattern: pattern}
+		src := fmt.Sprintf("%s:%d", source, i+1)
+		for _, f := range fields[1:] {
+			name, value := f, &AttrValue{Value: true, Source: src}
+			switch {
+			case strings.HasPrefix(f, "-"):
+				name, value.Value = f[1:], false
+			case strings.HasPrefix(f, "!"):
+				name, value = f[1:], nil
+			case strings.HasSuffix(f, "=false"):
+				name, value.Value = strings.TrimSuffix(f, "=false"), false
+			default:
+				name = strings.TrimSuffix(f, "=true")
+			}
+			switch name {
+			case "linguist-generated":
+				r.generated = value
+			case "linguist-vendored":
+				r.vendored = value
+			default:
+				continue
+			}
+			if value == nil {
+				// "!attr" still overrides earlier lines, as unspecified
+				if name == "linguist-generated" {
+					r.generated = &AttrValue{}
+				} else {
+					r.vendored = &AttrValue{}
+				}
+			}
+		}
+		if r.generated != nil || r.vendored != nil {
+			a.rules = append(a.rules, r)
+		}
+	}
+	return a
+}
+
+// Generated returns linguist-generated for rel and whether a line sets or
+// unsets it.
+func (a *Attributes) Generated(rel string) (AttrValue, bool) {
+	return a.lookup(rel, func(r attrRule) *AttrValue { return r.generated })
+}
+
+// Vendored returns linguist-vendored for rel and whether a line sets or
+// unsets it.
+func (a *Attributes) Vendored(rel string) (AttrValue, bool) {
+	return a.lookup(rel, func(r attrRule) *AttrValue { return r.vendored })
+}
+
+func (a *Attributes) lookup(rel string, attr func(attrRule) *AttrValue) (AttrValue, bool) {
+	if a == nil {
+		return AttrValue{}, false
+	}
+	rel = path.Clean(filepath.ToSlash(rel))
+	for i := len(a.rules) - 1; i >= 0; i-- {
+		v := attr(a.rules[i])
+		if v == nil {
+			continue
+		}
+		if ok, _ := doublestar.Match(a.rules[i].pattern, rel); ok {
+			return *v, v.Source != ""
+		}
+	}
+	return AttrValue{}, false
+}
+
+// Sum identifies the content the attributes were parsed from; "" for nil.
+func (a *Attributes) Sum() string {
+	if a == nil {
+		return ""
+	}
+	return a.sum
+}
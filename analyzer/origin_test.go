@@ -0,0 +1,105 @@
+package analyzer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectOrigin_Headers(t *testing.T) {
+	cases := []struct {
+		path, head string
+		generated  bool
+	}{
+		{"api/types.go", "// Code generated by protoc-gen-go. DO NOT EDIT.\n\npackage api\n", true},
+		{"x.py", "#!/usr/bin/env python\n# -*- coding: utf-8 -*-\n# Generated by the protocol buffer compiler.  DO NOT EDIT!\n", true},
+		{"Foo.java", "/**\n * Autogenerated by Thrift Compiler (0.9.3)\n *\n * DO NOT EDIT UNLESS YOU ARE SURE\n */\n", false},
+		{"Foo.java", "/**\n * Autogenerated by Thrift Compiler (0.9.3) DO NOT EDIT\n */\n", true},
+		{"www/schema.js", "/**\n * @generated SignedSource<<abc>>\n */\n", true},
+		{"Form.Designer.cs", "//------\n// <auto-generated>\n//     This code was generated by a tool.\n", true},
+		{"cmd/root.go", "package cmd\n\n// Run does not edit files; code generated elsewhere is read as is.\n", false},
+		{"doc.md", "Files marked @generated are skipped.\n", false},
+		// only the header tells these names apart
+		{"color_string.go", "// Code generated by \"stringer -type=Color\"; DO NOT EDIT.\n", true},
+		{"format_string.go", "package fmtx\n\nfunc formatString(s string) string { return s }\n", false},
+		{"mock_store.go", "package store\n\n// mockStore is a hand-written fake.\n", false},
+		{"app.js.map", `{"version":3,"sources":["app.ts"]}`, false},
+	}
+	for _, c := range cases {
+		o := DetectOrigin(c.path, []byte(c.head), nil)
+		if got := o.Generated != ""; got != c.generated {
+			t.Errorf("%s %q: generated=%v (%q), want %v", c.path, c.head, got, o.Generated, c.generated)
+		}
+	}
+}
+
+func TestDetectOrigin_Minified(t *testing.T) {
+	min := strings.Repeat("var a=function(b){return b+1};", 200)
+	if o := DetectOrigin("static/app.js", []byte(min), nil); !strings.HasPrefix(o.Generated, "minified") {
+		t.Fatalf("expected minified, got %+v", o)
+	}
+	prose := strings.Repeat("A long paragraph of prose that is written on a single line without breaks. ", 40)
+	if o := DetectOrigin("notes.txt", []byte(prose), nil); o.Generated != "" {
+		t.Fatalf("prose flagged: %+v", o)
+	}
+	code := strings.Repeat("func f() int {\n\treturn 1\n}\n\n", 100)
+	if o := DetectOrigin("f.go", []byte(code), nil); o.Generated != "" {
+		t.Fatalf("code flagged: %+v", o)
+	}
+	if o := DetectOrigin("img.bin", append([]byte(min), 0), nil); o.Generated != "" {
+		t.Fatalf("binary flagged: %+v", o)
+	}
+}
+
+func TestDetectOrigin_Paths(t *testing.T) {
+	if o := DetectOrigin("api/v1/api.pb.go", nil, nil); o.Generated != "path **/*.pb.go" || o.Kind() != "generated" {
+		t.Fatalf("got %+v", o)
+	}
+	if o := DetectOrigin("third_party/lib/x.c", nil, nil); o.Kind() != "vendored" {
+		t.Fatalf("got %+v", o)
+	}
+	if o := DetectOrigin("cmd/root.go", []byte("package cmd\n"), nil); o.Kind() != "" {
+		t.Fatalf("got %+v", o)
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	attrs := ParseAttributes([]byte(`# comment
+*.json linguist-generated
+/api/schema.json -linguist-generated
+gen/** linguist-generated=true
+vendor/patched/** linguist-vendored=false
+lib/ linguist-vendored
+web/dist/** linguist-vendored linguist-generated
+web/dist/keep.js !linguist-vendored
+`), ".gitattributes")
+
+	cases := []struct {
+		path                string
+		vendored, generated string
+	}{
+		{"data/x.json", "", ".gitattributes:2 linguist-generated"},
+		{"api/schema.json", "", ""},
+		{"sub/api/schema.json", "", ".gitattributes:2 linguist-generated"},
+		{"gen/a/b.go", "", ".gitattributes:4 linguist-generated"},
+		{"vendor/x/a.go", "path vendor/**", ""},
+		{"vendor/patched/a.go", "", ""},
+		{"lib/a.go", "", ""},
+		{"web/dist/app.js", ".gitattributes:7 linguist-vendored", ".gitattributes:7 linguist-generated"},
+		{"web/dist/keep.js", "", ".gitattributes:7 linguist-generated"},
+	}
+	for _, c := range cases {
+		o := DetectOriginByPath(c.path, attrs)
+		if o.Vendored != c.vendored || o.Generated != c.generated {
+			t.Errorf("%s: got %+v, want vendored=%q generated=%q", c.path, o, c.vendored, c.generated)
+		}
+	}
+
+	// an unset attribute also turns the content checks off
+	head := []byte("// Code generated by hand-rolled tool. DO NOT EDIT.\n")
+	if o := DetectOrigin("api/schema.json", head, attrs); o.Generated != "" {
+		t.Fatalf("expected -linguist-generated to win, got %+v", o)
+	}
+	if attrs.Sum() == "" || (*Attributes)(nil).Sum() != "" {
+		t.Fatal("unexpected Sum")
+	}
+}
@@ -0,0 +1,137 @@
+package analyzer
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ShareMetric is what a language's share of the project is measured in.
+type ShareMetric string
+
+const (
+	ShareBytes  ShareMetric = "bytes"
+	ShareLines  ShareMetric = "lines"
+	ShareCode   ShareMetric = "code"
+	ShareFiles  ShareMetric = "files"
+	ShareTokens ShareMetric = "tokens"
+)
+
+// OtherShare names the row that --top folds the remaining languages into.
+const OtherShare = "other"
+
+// ParseShareMetric validates a --by value.
+func ParseShareMetric(s string) (ShareMetric, error) {
+	switch m := ShareMetric(strings.ToLower(strings.TrimSpace(s))); m {
+	case ShareBytes, ShareLines, ShareCode, ShareFiles, ShareTokens:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid --by: %s (expected bytes|lines|code|files|tokens)", s)
+}
+
+type Share struct {
+	Language string  `json:"language" toon:"language"`
+	Value    int64   `json:"value" toon:"value"`
+	Percent  float64 `json:"percent" toon:"percent"`
+	Files    int     `json:"files" toon:"files"`
+}
+
+type ShareOptions struct {
+	By ShareMetric // "" means bytes
+	// Top keeps the N largest languages and folds the rest into OtherShare;
+	// 0 keeps all of them.
+	Top              int
+	IncludeVendored  bool
+	IncludeGenerated bool
+}
+
+// LanguageShares weighs every language by opts.By. Rows are sorted by
+// value, largest first, with ties broken by name, so the output is stable.
+func LanguageShares(files []FileInfo, opts ShareOptions) []Share {
+	byLang := map[string]*Share{}
+	var total int64
+	for _, f := range files {
+		if (f.Vendored && !opts.IncludeVendored) || (f.Generated && !opts.IncludeGenerated) {
+			continue
+		}
+		s, ok := byLang[f.Language]
+		if !ok {
+			s = &Share{Language: f.Language}
+			byLang[f.Language] = s
+		}
+		v := shareValue(f, opts.By)
+		s.Value += v
+		s.Files++
+		total += v
+	}
+
+	out := make([]Share, 0, len(byLang))
+	for _, s := range byLang {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Value != out[j].Value {
+			return out[i].Value > out[j].Value
+		}
+		return out[i].Language < out[j].Language
+	})
+
+	if opts.Top > 0 && len(out) > opts.Top {
+		other := Share{Language: OtherShare}
+		for _, s := range out[opts.Top:] {
+			other.Value += s.Value
+			other.Files += s.Files
+		}
+		out = append(out[:opts.Top], other)
+	}
+	for i := range out {
+		if total > 0 {
+			out[i].Percent = float64(out[i].Value) / float64(total) * 100
+		}
+	}
+	return out
+}
+
+func shareValue(f FileInfo, by ShareMetric) int64 {
+	switch by {
+	case ShareLines:
+		return int64(f.Lines)
+	case ShareCode:
+		return int64(f.Code)
+	case ShareFiles:
+		return 1
+	case ShareTokens:
+		return int64(f.Tokens)
+	default:
+		return f.Size
+	}
+}
+
+// PrettyPrintPercentage draws shares as a tree of coloured bars.
+func PrettyPrintPercentage(w io.Writer, shares []Share, by ShareMetric) {
+	if by == "" {
+		by = ShareBytes
+	}
+	width := 10
+	for _, s := range shares {
+		width = max(width, len(s.Language))
+	}
+
+	fmt.Fprintf(w, "┌── File Percentages (by %s):\n", by)
+	for i, s := range shares {
+		branch := "├── "
+		if i == len(shares)-1 {
+			branch = "└── "
+		}
+		color := "\033[36m"
+		if i%2 == 0 {
+			color = "\033[34m"
+		}
+
+		bar := strings.Repeat("█", int(s.Percent/2))
+		coloredBar := color + bar + "\033[0m"
+
+		fmt.Fprintf(w, "%s%-*s %5.1f%% %s\n", branch, width, s.Language, s.Percent, coloredBar)
+	}
+}
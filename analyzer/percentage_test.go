@@ -0,0 +1,135 @@
+package analyzer
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLanguageShares_ByMetricTopAndExclusions(t *testing.T) {
+	files := []FileInfo{
+		{Language: "Go", Size: 100, Lines: 10, Code: 8},
+		{Language: "Go", Size: 100, Lines: 10, Code: 8},
+		{Language: "Python", Size: 50, Lines: 40, Code: 30},
+		{Language: "Shell", Size: 25, Lines: 5, Code: 5},
+		{Language: "YAML", Size: 25, Lines: 5, Code: 5},
+		{Language: "JavaScript", Size: 1000, Lines: 1, Code: 1, Generated: true},
+		{Language: "C", Size: 1000, Lines: 1, Code: 1, Vendored: true},
+	}
+
+	shares := LanguageShares(files, ShareOptions{By: ShareBytes})
+	if len(shares) != 4 || shares[0].Language != "Go" || shares[0].Value != 200 {
+		t.Fatalf("unexpected byte shares: %+v", shares)
+	}
+	// ties are broken by name
+	if shares[2].Language != "Shell" || shares[3].Language != "YAML" {
+		t.Fatalf("unstable order: %+v", shares)
+	}
+
+	shares = LanguageShares(files, ShareOptions{By: ShareLines, Top: 1})
+	if len(shares) != 2 || shares[0].Language != "Python" || shares[1].Language != OtherShare || shares[1].Value != 30 || shares[1].Files != 4 {
+		t.Fatalf("unexpected top shares: %+v", shares)
+	}
+
+	shares = LanguageShares(files, ShareOptions{By: ShareFiles, IncludeVendored: true, IncludeGenerated: true})
+	if len(shares) != 6 || shares[0].Language != "Go" || shares[0].Value != 2 {
+		t.Fatalf("unexpected file shares: %+v", shares)
+	}
+
+	if _, err := ParseShareMetric("words"); err == nil {
+		t.Fatalf("expected error for unknown metric")
+	}
+}
+
+func TestPrettyPrintPercentage_Deterministic(t *testing.T) {
+	shares := LanguageShares([]FileInfo{
+		{Language: "Go", Size: 3}, {Language: "Rust", Size: 1}, {Language: "C", Size: 1},
+	}, ShareOptions{})
+	var a, b bytes.Buffer
+	PrettyPrintPercentage(&a, shares, ShareBytes)
+	PrettyPrintPercentage(&b, shares, ShareBytes)
+	if a.String() != b.String() {
+		t.Fatalf("output differs between runs")
+	}
+	lines := strings.Split(strings.TrimSpace(a.String()), "\n")
+	if len(lines) != 4 || !strings.Contains(lines[1], "Go") || !strings.Contains(lines[2], "C ") || !strings.HasPrefix(lines[3], "└── Rust") {
+		t.Fatalf("unexpected output:\n%s", a.String())
+	}
+}
+
+func TestAnalyzeProjectWithOptions_RespectsGitignore(t *testing.T) {
+	td := t.TempDir()
+	writeTestFile(t, filepath.Join(td, ".gitignore"), "public/\n*.log\n")
+	writeTestFile(t, filepath.Join(td, "main.go"), "package main\n\nfunc main() {}\n")
+	writeTestFile(t, filepath.Join(td, "public", "bundle.js"), "var a = 1;\n")
+	writeTestFile(t, filepath.Join(td, "debug.log"), "noise\n")
+
+	ctx, err := AnalyzeProjectWithOptions(context.Background(), td, AnalyzeOptions{RespectGitignore: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range ctx.Files {
+		if p := filepath.ToSlash(f.Path); p == "public/bundle.js" || p == "debug.log" {
+			t.Fatalf("ignored file analysed: %s", p)
+		}
+	}
+	if len(AnalyzeProject(td).Files) != 4 {
+		t.Fatalf("AnalyzeProject should see every file")
+	}
+}
+
+func TestAnalyzeProjectWithOptions_SymlinksStayInsideRoot(t *testing.T) {
+	outside := t.TempDir()
+	writeTestFile(t, filepath.Join(outside, "secret.md"), "top secret\n")
+	td := t.TempDir()
+	writeTestFile(t, filepath.Join(td, "docs", "guide.md"), "inside the project\n")
+	if err := os.Symlink(filepath.Join(outside, "secret.md"), filepath.Join(td, "README.md")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+	if err := os.Symlink(filepath.Join("docs", "guide.md"), filepath.Join(td, "GUIDE.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, err := AnalyzeProjectWithOptions(context.Background(), td, AnalyzeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(ctx.Readme, "secret") {
+		t.Fatalf("README outside the root was read: %q", ctx.Readme)
+	}
+	var paths []string
+	for _, f := range ctx.Files {
+		paths = append(paths, filepath.ToSlash(f.Path))
+	}
+	if len(paths) != 2 || paths[0] != "GUIDE.md" || paths[1] != "docs/guide.md" {
+		t.Fatalf("unexpected files: %v", paths)
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	cases := []struct {
+		in   string
+		want int
+	}{
+		{"", 0},
+		{"hello world", 2},
+		{"func main() {}", 6},
+		{"internationalization", 4},
+		{"a\n\n\nb", 3},
+	}
+	for _, c := range cases {
+		if got := EstimateTokens([]byte(c.in)); got != c.want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", c.in, got, c.want)
+		}
+	}
+
+	td := t.TempDir()
+	writeTestFile(t, filepath.Join(td, "a.go"), "package a\n")
+	ctx := AnalyzeProject(td)
+	if ctx.Files[0].Tokens != EstimateTokens([]byte("package a\n")) {
+		t.Fatalf("unexpected token estimate: %+v", ctx.Files[0])
+	}
+}
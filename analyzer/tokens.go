@@ -0,0 +1,75 @@
+package analyzer
+
+// TokenCounter estimates how many LLM tokens a text will use without
+// shipping a tokenizer. It follows the shape of BPE vocabularies: short
+// words and identifiers are one token, longer ones split every ~6 bytes,
+// punctuation is a token per character, and non-ASCII text costs roughly a
+// token per character. It is an io.Writer so it can sit behind a TeeReader.
+type TokenCounter struct {
+	tokens   int
+	word     int // length of the current ASCII word
+	nonASCII int // bytes in the current non-ASCII run
+	newline  bool
+}
+
+func (t *TokenCounter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		switch {
+		case b >= 0x80:
+			t.flushWord()
+			t.nonASCII++
+		case b >= 'a' && b <= 'z', b >= 'A' && b <= 'Z', b >= '0' && b <= '9', b == '_':
+			t.flushNonASCII()
+			t.word++
+		case b == ' ' || b == '\t' || b == '\r':
+			t.flush()
+		case b == '\n':
+			t.flush()
+			// a run of newlines (plus the indentation after it) is one token
+			if !t.newline {
+				t.tokens++
+			}
+			t.newline = true
+			continue
+		default:
+			t.flush()
+			t.tokens++
+		}
+		t.newline = false
+	}
+	return len(p), nil
+}
+
+// Tokens returns the estimate for everything written so far.
+func (t *TokenCounter) Tokens() int {
+	return t.tokens + wordTokens(t.word) + (t.nonASCII+2)/3
+}
+
+func (t *TokenCounter) flush() {
+	t.flushWord()
+	t.flushNonASCII()
+}
+
+func (t *TokenCounter) flushWord() {
+	t.tokens += wordTokens(t.word)
+	t.word = 0
+}
+
+func (t *TokenCounter) flushNonASCII() {
+	t.tokens += (t.nonASCII + 2) / 3
+	t.nonASCII = 0
+}
+
+func wordTokens(n int) int {
+	if n == 0 {
+		return 0
+	}
+	return 1 + (n-1)/6
+}
+
+// EstimateTokens returns the TokenCounter estimate for data.
+func EstimateTokens(data []byte) int {
+	var t TokenCounter
+	t.Write(data)
+	return t.Tokens()
+}
@@ -0,0 +1,233 @@
+package analyzer
+
+import (
+	"strings"
+)
+
+// tomlSection is one table of a TOML document. Keys keep their raw
+// (still quoted) values; the helpers below decode the shapes manifests use.
+type tomlSection struct {
+	Name    string
+	IsArray bool // [[name]]
+	Entries []tomlEntry
+}
+
+type tomlEntry struct {
+	Key   string
+	Value string
+}
+
+// parseTOML is a deliberately small TOML reader. It understands tables,
+// arrays of tables, basic/literal strings, inline tables and (multi-line)
+// arrays, which is all the manifests we look at need. The root table is
+// returned as a section with an empty name.
+func parseTOML(data []byte) []tomlSection {
+	sections := []tomlSection{{}}
+	cur := &sections[0]
+
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(stripTOMLComment(lines[i]))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[[") && strings.HasSuffix(line, "]]") {
+			sections = append(sections, tomlSection{Name: tomlKey(line[2 : len(line)-2]), IsArray: true})
+			cur = &sections[len(sections)-1]
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			sections = append(sections, tomlSection{Name: tomlKey(line[1 : len(line)-1])})
+			cur = &sections[len(sections)-1]
+			continue
+		}
+
+		eq := indexOutsideQuotes(line, '=')
+		if eq < 0 {
+			continue
+		}
+		key := tomlKey(line[:eq])
+		value := strings.TrimSpace(line[eq+1:])
+
+		// multi-line arrays and inline tables: keep reading until brackets balance
+		for bracketDepth(value) > 0 && i+1 < len(lines) {
+			i++
+			value += " " + strings.TrimSpace(stripTOMLComment(lines[i]))
+		}
+		// multi-line basic strings
+		if strings.HasPrefix(value, `"""`) && (len(value) < 6 || !strings.HasSuffix(value, `"""`)) {
+			for i+1 < len(lines) {
+				i++
+				value += "\n" + lines[i]
+				if strings.HasSuffix(strings.TrimSpace(lines[i]), `"""`) {
+					break
+				}
+			}
+		}
+		cur.Entries = append(cur.Entries, tomlEntry{Key: key, Value: value})
+	}
+	return sections
+}
+
+// get returns the raw value for key in the section.
+func (s tomlSection) get(key string) (string, bool) {
+	for _, e := range s.Entries {
+		if e.Key == key {
+			return e.Value, true
+		}
+	}
+	return "", false
+}
+
+// tomlKey normalises a (possibly dotted and quoted) key: `target.'cfg(unix)'.dependencies`
+// becomes `target.cfg(unix).dependencies`.
+func tomlKey(raw string) string {
+	raw = strings.TrimSpace(raw)
+	var b strings.Builder
+	var quote byte
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+				continue
+			}
+		case c == '"' || c == '\'':
+			quote = c
+			continue
+		case c == ' ' || c == '\t':
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// tomlString decodes a quoted scalar. Unquoted values are returned trimmed.
+func tomlString(raw string) string {
+	raw = strings.TrimSpace(raw)
+	for _, q := range []string{`"""`, `'''`} {
+		if strings.HasPrefix(raw, q) && strings.HasSuffix(raw, q) && len(raw) >= 6 {
+			return strings.TrimSpace(raw[3 : len(raw)-3])
+		}
+	}
+	if len(raw) >= 2 && (raw[0] == '"' || raw[0] == '\'') && raw[len(raw)-1] == raw[0] {
+		s := raw[1 : len(raw)-1]
+		if raw[0] == '"' {
+			s = strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(s)
+		}
+		return s
+	}
+	return raw
+}
+
+// tomlArray splits `["a", "b", { x = 1 }]` into its raw elements.
+func tomlArray(raw string) []string {
+	raw = strings.TrimSpace(raw)
+	if !strings.HasPrefix(raw, "[") || !strings.HasSuffix(raw, "]") {
+		return nil
+	}
+	return splitTopLevel(raw[1:len(raw)-1], ',')
+}
+
+// tomlInlineTable decodes `{ version = "1", path = "../x" }` into raw values.
+func tomlInlineTable(raw string) map[string]string {
+	raw = strings.TrimSpace(raw)
+	if !strings.HasPrefix(raw, "{") || !strings.HasSuffix(raw, "}") {
+		return nil
+	}
+	out := map[string]string{}
+	for _, part := range splitTopLevel(raw[1:len(raw)-1], ',') {
+		eq := indexOutsideQuotes(part, '=')
+		if eq < 0 {
+			continue
+		}
+		out[tomlKey(part[:eq])] = strings.TrimSpace(part[eq+1:])
+	}
+	return out
+}
+
+func splitTopLevel(s string, sep byte) []string {
+	var out []string
+	depth := 0
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		case c == sep && depth == 0:
+			if p := strings.TrimSpace(s[start:i]); p != "" {
+				out = append(out, p)
+			}
+			start = i + 1
+		}
+	}
+	if p := strings.TrimSpace(s[start:]); p != "" {
+		out = append(out, p)
+	}
+	return out
+}
+
+func stripTOMLComment(line string) string {
+	if i := indexOutsideQuotes(line, '#'); i >= 0 {
+		return line[:i]
+	}
+	return line
+}
+
+func indexOutsideQuotes(s string, target byte) int {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == target:
+			return i
+		}
+	}
+	return -1
+}
+
+func bracketDepth(s string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth
+}
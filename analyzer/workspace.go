@@ -0,0 +1,303 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	doublestar "github.com/bmatcuk/doublestar/v4"
+	"github.com/parsabordbar/ctx3/filetree"
+	"gopkg.in/yaml.v3"
+)
+
+// Workspace kinds reported in Workspace.Kinds.
+const (
+	WorkspaceGo    = "go.work"
+	WorkspaceNPM   = "npm"
+	WorkspacePNPM  = "pnpm"
+	WorkspaceCargo = "cargo"
+)
+
+type WorkspaceMember struct {
+	Name      string   `json:"name" toon:"name"`
+	Path      string   `json:"path" toon:"path"` // relative to the workspace root, '/'-separated
+	Ecosystem string   `json:"ecosystem" toon:"ecosystem"`
+	LocalDeps []string `json:"localDependencies,omitempty" toon:"local_dependencies"` // names of other members
+}
+
+type Workspace struct {
+	Kinds   []string          `json:"kinds" toon:"kinds"`
+	Members []WorkspaceMember `json:"members" toon:"members"`
+}
+
+// DetectWorkspace looks for go.work, npm/pnpm and Cargo workspace
+// definitions at root. It returns nil when root is not a monorepo.
+func DetectWorkspace(root string) (*Workspace, error) {
+	ws := &Workspace{}
+	seen := map[string]bool{}
+	add := func(kind string, members []WorkspaceMember) {
+		if len(members) == 0 {
+			return
+		}
+		ws.Kinds = append(ws.Kinds, kind)
+		for _, m := range members {
+			if !seen[m.Path] {
+				seen[m.Path] = true
+				ws.Members = append(ws.Members, m)
+			}
+		}
+	}
+
+	if data, err := os.ReadFile(filepath.Join(root, "go.work")); err == nil {
+		add(WorkspaceGo, goWorkMembers(root, data))
+	}
+	if data, err := os.ReadFile(filepath.Join(root, "pnpm-workspace.yaml")); err == nil {
+		var cfg struct {
+			Packages []string `yaml:"packages"`
+		}
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("pnpm-workspace.yaml: %w", err)
+		}
+		add(WorkspacePNPM, globMembers(root, cfg.Packages, "package.json", EcosystemNPM))
+	}
+	if data, err := os.ReadFile(filepath.Join(root, "package.json")); err == nil {
+		var pkg packageJSON
+		if err := json.Unmarshal(data, &pkg); err != nil {
+			return nil, fmt.Errorf("package.json: %w", err)
+		}
+		add(WorkspaceNPM, globMembers(root, pkg.workspaceGlobs(), "package.json", EcosystemNPM))
+	}
+	if data, err := os.ReadFile(filepath.Join(root, "Cargo.toml")); err == nil {
+		for _, sec := range parseTOML(data) {
+			if sec.Name != "workspace" {
+				continue
+			}
+			var globs []string
+			raw, _ := sec.get("members")
+			for _, g := range tomlArray(raw) {
+				globs = append(globs, tomlString(g))
+			}
+			raw, _ = sec.get("exclude")
+			for _, g := range tomlArray(raw) {
+				globs = append(globs, "!"+tomlString(g))
+			}
+			add(WorkspaceCargo, globMembers(root, globs, "Cargo.toml", EcosystemCargo))
+		}
+	}
+
+	if len(ws.Members) == 0 {
+		return nil, nil
+	}
+	sort.Slice(ws.Members, func(i, j int) bool { return ws.Members[i].Path < ws.Members[j].Path })
+	linkLocalDeps(root, ws.Members)
+	return ws, nil
+}
+
+// Member finds a member by name or by path.
+func (w *Workspace) Member(nameOrPath string) (WorkspaceMember, bool) {
+	p := strings.TrimSuffix(filepath.ToSlash(filepath.Clean(nameOrPath)), "/")
+	for _, m := range w.Members {
+		if m.Name == nameOrPath || m.Path == p {
+			return m, true
+		}
+	}
+	return WorkspaceMember{}, false
+}
+
+// WithLocalDeps returns the named member followed by every member it
+// depends on, directly or transitively.
+func (w *Workspace) WithLocalDeps(nameOrPath string) ([]WorkspaceMember, error) {
+	start, ok := w.Member(nameOrPath)
+	if !ok {
+		names := make([]string, 0, len(w.Members))
+		for _, m := range w.Members {
+			names = append(names, m.Name)
+		}
+		return nil, fmt.Errorf("unknown workspace package %q (available: %s)", nameOrPath, strings.Join(names, ", "))
+	}
+	out := []WorkspaceMember{start}
+	visited := map[string]bool{start.Name: true}
+	for i := 0; i < len(out); i++ {
+		for _, dep := range out[i].LocalDeps {
+			if visited[dep] {
+				continue
+			}
+			visited[dep] = true
+			if m, ok := w.Member(dep); ok {
+				out = append(out, m)
+			}
+		}
+	}
+	return out, nil
+}
+
+func goWorkMembers(root string, data []byte) []WorkspaceMember {
+	var dirs []string
+	inBlock := false
+	for _, raw := range strings.Split(string(data), "\n") {
+		line := raw
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "use (":
+			inBlock = true
+		case inBlock && line == ")":
+			inBlock = false
+		case strings.HasPrefix(line, "use "):
+			dirs = append(dirs, strings.TrimSpace(strings.TrimPrefix(line, "use ")))
+		case inBlock && line != "":
+			dirs = append(dirs, line)
+		}
+	}
+
+	var members []WorkspaceMember
+	for _, d := range dirs {
+		rel := path.Clean(filepath.ToSlash(strings.Trim(d, `"`)))
+		if m, ok := readMember(root, rel, "go.mod", EcosystemGo); ok {
+			members = append(members, m)
+		}
+	}
+	return members
+}
+
+// globMembers expands workspace globs ("packages/*", "!packages/legacy")
+// into directories that contain the given manifest.
+func globMembers(root string, globs []string, manifest, ecosystem string) []WorkspaceMember {
+	fsys := os.DirFS(root)
+	include := map[string]bool{}
+	for _, g := range globs {
+		if strings.HasPrefix(g, "!") {
+			continue
+		}
+		matches, err := doublestar.Glob(fsys, strings.TrimPrefix(path.Clean(g), "./"))
+		if err != nil {
+			continue
+		}
+		for _, m := range matches {
+			if !strings.Contains("/"+m+"/", "/node_modules/") {
+				include[m] = true
+			}
+		}
+	}
+	for _, g := range globs {
+		if !strings.HasPrefix(g, "!") {
+			continue
+		}
+		pat := strings.TrimPrefix(path.Clean(strings.TrimPrefix(g, "!")), "./")
+		for m := range include {
+			if ok, _ := doublestar.Match(pat, m); ok {
+				delete(include, m)
+			}
+		}
+	}
+
+	dirs := make([]string, 0, len(include))
+	for d := range include {
+		dirs = append(dirs, d)
+	}
+	sort.Strings(dirs)
+	var members []WorkspaceMember
+	for _, d := range dirs {
+		if m, ok := readMember(root, d, manifest, ecosystem); ok {
+			members = append(members, m)
+		}
+	}
+	return members
+}
+
+// readMember names a member after its manifest (module path, package
+// name, crate name), falling back to the directory name.
+func readMember(root, rel, manifest, ecosystem string) (WorkspaceMember, bool) {
+	if !insideRoot(root, rel) {
+		return WorkspaceMember{}, false
+	}
+	data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(rel), manifest))
+	if err != nil {
+		return WorkspaceMember{}, false
+	}
+	m := WorkspaceMember{Name: path.Base(rel), Path: rel, Ecosystem: ecosystem}
+	if rel == "." {
+		m.Name = filepath.Base(absOr(root))
+	}
+	switch manifest {
+	case "go.mod":
+		for _, line := range strings.Split(string(data), "\n") {
+			if f := strings.Fields(line); len(f) >= 2 && f[0] == "module" {
+				m.Name = strings.Trim(f[1], `"`)
+				break
+			}
+		}
+	case "package.json":
+		var pkg packageJSON
+		if json.Unmarshal(data, &pkg) == nil && pkg.Name != "" {
+			m.Name = pkg.Name
+		}
+	case "Cargo.toml":
+		for _, sec := range parseTOML(data) {
+			if sec.Name == "package" {
+				if name, ok := sec.get("name"); ok {
+					m.Name = tomlString(name)
+				}
+			}
+		}
+	}
+	return m, true
+}
+
+// insideRoot reports whether the directory at rel, a '/'-separated path
+// from a workspace file, is root or below it, symbolic links resolved. A
+// member such as `use ../elsewhere` would take the analysis out of root.
+func insideRoot(root, rel string) bool {
+	if !filepath.IsLocal(filepath.FromSlash(rel)) {
+		return false
+	}
+	realRoot, err := filepath.EvalSymlinks(absOr(root))
+	if err != nil {
+		return false
+	}
+	real, err := filepath.EvalSymlinks(filepath.Join(realRoot, filepath.FromSlash(rel)))
+	return err == nil && filetree.Within(realRoot, real)
+}
+
+// linkLocalDeps records, for every member, which other members it depends on.
+func linkLocalDeps(root string, members []WorkspaceMember) {
+	byName := map[string]string{}
+	byPath := map[string]string{}
+	for _, m := range members {
+		byName[m.Name] = m.Name
+		byPath[m.Path] = m.Name
+	}
+	for i := range members {
+		m := &members[i]
+		manifest := map[string]string{EcosystemGo: "go.mod", EcosystemNPM: "package.json", EcosystemCargo: "Cargo.toml"}[m.Ecosystem]
+		data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(m.Path), manifest))
+		if err != nil {
+			continue
+		}
+		seen := map[string]bool{}
+		for _, d := range ParseManifest(manifest, data) {
+			target, ok := byName[d.Name]
+			if strings.HasPrefix(d.Version, "path:") {
+				target, ok = byPath[path.Clean(path.Join(m.Path, strings.TrimPrefix(d.Version, "path:")))]
+			}
+			if ok && target != m.Name && !seen[target] {
+				seen[target] = true
+				m.LocalDeps = append(m.LocalDeps, target)
+			}
+		}
+		sort.Strings(m.LocalDeps)
+	}
+}
+
+func absOr(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return p
+}
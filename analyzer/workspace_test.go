@@ -0,0 +1,118 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDetectWorkspace_NPMWithLocalDeps(t *testing.T) {
+	td := t.TempDir()
+	writeTestFile(t, filepath.Join(td, "package.json"), `{"name": "root", "workspaces": ["apps/*", "packages/*", "!packages/legacy"]}`)
+	writeTestFile(t, filepath.Join(td, "apps", "web-api", "package.json"), `{"name": "web-api", "dependencies": {"@acme/db": "workspace:*", "express": "^4"}}`)
+	writeTestFile(t, filepath.Join(td, "apps", "web-api", "src", "index.js"), "require('express')\n")
+	writeTestFile(t, filepath.Join(td, "apps", "web-api", "README.md"), "# web api\n")
+	writeTestFile(t, filepath.Join(td, "packages", "db", "package.json"), `{"name": "@acme/db", "dependencies": {"@acme/util": "*"}}`)
+	writeTestFile(t, filepath.Join(td, "packages", "util", "package.json"), `{"name": "@acme/util"}`)
+	writeTestFile(t, filepath.Join(td, "packages", "legacy", "package.json"), `{"name": "legacy"}`)
+
+	ws, err := DetectWorkspace(td)
+	if err != nil || ws == nil {
+		t.Fatalf("expected workspace, got %v / %v", ws, err)
+	}
+	if len(ws.Members) != 3 {
+		t.Fatalf("expected 3 members (legacy excluded), got %+v", ws.Members)
+	}
+	members, err := ws.WithLocalDeps("web-api")
+	if err != nil {
+		t.Fatalf("WithLocalDeps: %v", err)
+	}
+	var paths []string
+	for _, m := range members {
+		paths = append(paths, m.Path)
+	}
+	if strings.Join(paths, ",") != "apps/web-api,packages/db,packages/util" {
+		t.Fatalf("unexpected closure: %v", paths)
+	}
+	if _, err := ws.WithLocalDeps("nope"); err == nil {
+		t.Fatalf("expected error for unknown package")
+	}
+
+	ctx := AnalyzeProject(td)
+	if len(ctx.Packages) != 3 {
+		t.Fatalf("expected per-package contexts, got %d", len(ctx.Packages))
+	}
+	api := ctx.Packages[0]
+	if api.Name != "web-api" || api.Root != "apps/web-api" || api.Readme == "" || len(api.Dependencies) != 2 {
+		t.Fatalf("unexpected web-api context: %+v", api)
+	}
+}
+
+func TestDetectWorkspace_GoWorkAndCargo(t *testing.T) {
+	td := t.TempDir()
+	writeTestFile(t, filepath.Join(td, "go.work"), "go 1.22\n\nuse (\n\t./svc\n\t./lib // shared\n)\n")
+	writeTestFile(t, filepath.Join(td, "svc", "go.mod"), "module example.com/svc\n\nrequire example.com/lib v0.0.0\n")
+	writeTestFile(t, filepath.Join(td, "lib", "go.mod"), "module example.com/lib\n")
+
+	ws, err := DetectWorkspace(td)
+	if err != nil || ws == nil || len(ws.Members) != 2 {
+		t.Fatalf("unexpected go workspace: %+v / %v", ws, err)
+	}
+	svc, _ := ws.Member("svc")
+	if svc.Name != "example.com/svc" || strings.Join(svc.LocalDeps, ",") != "example.com/lib" {
+		t.Fatalf("unexpected svc member: %+v", svc)
+	}
+
+	cd := t.TempDir()
+	writeTestFile(t, filepath.Join(cd, "Cargo.toml"), "[workspace]\nmembers = [\"crates/*\"]\n")
+	writeTestFile(t, filepath.Join(cd, "crates", "cli", "Cargo.toml"), "[package]\nname = \"acme-cli\"\n\n[dependencies]\ncore = { path = \"../core\" }\n")
+	writeTestFile(t, filepath.Join(cd, "crates", "core", "Cargo.toml"), "[package]\nname = \"acme-core\"\n")
+	ws, err = DetectWorkspace(cd)
+	if err != nil || ws == nil {
+		t.Fatalf("unexpected cargo workspace: %v", err)
+	}
+	cli, _ := ws.Member("acme-cli")
+	if strings.Join(cli.LocalDeps, ",") != "acme-core" {
+		t.Fatalf("expected path dependency to link members, got %+v", cli)
+	}
+}
+
+func TestDetectWorkspace_MembersStayInsideRoot(t *testing.T) {
+	base := t.TempDir()
+	writeTestFile(t, filepath.Join(base, "secret", "go.mod"), "module example.com/secret\n")
+	writeTestFile(t, filepath.Join(base, "secret", "README.md"), "TOP SECRET readme\n")
+	writeTestFile(t, filepath.Join(base, "secret", "package.json"), `{"name": "secret"}`)
+	td := filepath.Join(base, "root")
+	writeTestFile(t, filepath.Join(td, "go.work"), "go 1.22\n\nuse (\n\t./svc\n\t../secret\n)\n")
+	writeTestFile(t, filepath.Join(td, "svc", "go.mod"), "module example.com/svc\n")
+	writeTestFile(t, filepath.Join(td, "package.json"), `{"name": "root", "workspaces": ["packages/*"]}`)
+	if err := os.MkdirAll(filepath.Join(td, "packages"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(base, "secret"), filepath.Join(td, "packages", "leak")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+
+	ws, err := DetectWorkspace(td)
+	if err != nil || ws == nil {
+		t.Fatalf("expected workspace, got %v / %v", ws, err)
+	}
+	if len(ws.Members) != 1 || ws.Members[0].Path != "svc" {
+		t.Fatalf("members outside the root: %+v", ws.Members)
+	}
+	ctx := AnalyzeProject(td)
+	for _, pc := range ctx.Packages {
+		if strings.Contains(pc.Readme, "TOP SECRET") || pc.Root != "svc" {
+			t.Fatalf("analyzed a member outside the root: %+v", pc)
+		}
+	}
+}
+
+func TestDetectWorkspace_None(t *testing.T) {
+	td := t.TempDir()
+	writeTestFile(t, filepath.Join(td, "go.mod"), "module x\n")
+	if ws, err := DetectWorkspace(td); ws != nil || err != nil {
+		t.Fatalf("expected no workspace, got %+v / %v", ws, err)
+	}
+}
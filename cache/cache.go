@@ -0,0 +1,188 @@
+// Package cache is ctx3's on-disk cache of per-file results, such as
+// packed content with its token count, or the functions a file declares.
+//
+// Results are content-addressed: a Store keeps them by a key derived from
+// the SHA-256 of the file content plus whatever else shaped them (options,
+// file name, a format version). An Index per root remembers the content
+// hash of every path at a given size and modification time, so files that
+// haven't changed aren't even read.
+//
+// The cache lives in $XDG_CACHE_HOME/ctx3 (~/.cache/ctx3 on Linux).
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Store is a cache directory. Methods are safe for concurrent use, also
+// across processes: every file is written to a temporary name and renamed.
+type Store struct {
+	dir string
+}
+
+// DefaultDir is where ctx3 keeps its cache.
+func DefaultDir() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "ctx3"), nil
+}
+
+// Open returns the store in dir, creating it if needed.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir}, nil
+}
+
+// OpenDefault opens the store in DefaultDir.
+func OpenDefault() (*Store, error) {
+	dir, err := DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+	return Open(dir)
+}
+
+func (s *Store) Dir() string { return s.dir }
+
+// Key hashes parts into a key for Get and Put.
+func Key(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Sum is the content hash used in keys and by Index.
+func Sum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// SumFile is Sum of the file at path, read as a stream so a large file
+// is never held in memory.
+func SumFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (s *Store) objectPath(kind, key string) string {
+	return filepath.Join(s.dir, "objects", kind, key[:2], key)
+}
+
+// Get decodes the value stored under kind and key into v. It reports
+// false when there is none or it can't be decoded.
+func (s *Store) Get(kind, key string, v any) bool {
+	data, err := os.ReadFile(s.objectPath(kind, key))
+	if err != nil {
+		return false
+	}
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v) == nil
+}
+
+// Put stores v under kind and key.
+func (s *Store) Put(kind, key string, v any) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return err
+	}
+	return writeAtomic(s.objectPath(kind, key), buf.Bytes())
+}
+
+// Stats describes what a store holds.
+type Stats struct {
+	Dir   string               `json:"dir"`
+	Kinds map[string]KindStats `json:"kinds"`
+	// Indexes counts the roots with an index.
+	Indexes    int   `json:"indexes"`
+	TotalBytes int64 `json:"totalBytes"`
+}
+
+type KindStats struct {
+	Entries int   `json:"entries"`
+	Bytes   int64 `json:"bytes"`
+}
+
+func (s *Store) Stats() (Stats, error) {
+	st := Stats{Dir: s.dir, Kinds: map[string]KindStats{}}
+	err := filepath.WalkDir(s.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.Contains(d.Name(), ".tmp") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		st.TotalBytes += info.Size()
+		rel, _ := filepath.Rel(s.dir, p)
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		switch {
+		case parts[0] == "objects" && len(parts) == 4:
+			ks := st.Kinds[parts[1]]
+			ks.Entries++
+			ks.Bytes += info.Size()
+			st.Kinds[parts[1]] = ks
+		case parts[0] == "index":
+			st.Indexes++
+		}
+		return nil
+	})
+	return st, err
+}
+
+// Clear deletes everything in the store.
+func (s *Store) Clear() error {
+	for _, sub := range []string{"objects", "index"} {
+		if err := os.RemoveAll(filepath.Join(s.dir, sub)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStore_PutGetStatsClear(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "ctx3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	type rec struct {
+		Content []byte
+		Tokens  int
+	}
+	key := Key(Sum([]byte("package a\n")), "a.go")
+	if key == Key(Sum([]byte("package a\n")), "b.go") {
+		t.Fatalf("keys of different parts collide")
+	}
+
+	var got rec
+	if s.Get("pack", key, &got) {
+		t.Fatalf("Get on an empty store succeeded")
+	}
+	if err := s.Put("pack", key, rec{Content: []byte("package a\n"), Tokens: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if !s.Get("pack", key, &got) || string(got.Content) != "package a\n" || got.Tokens != 3 {
+		t.Fatalf("Get = %+v", got)
+	}
+
+	ix, err := s.Index(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := fakeInfo{size: 10, mod: time.Now().Add(-time.Hour)}
+	ix.Record("a.go", info, "sum")
+	if err := ix.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := s.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Kinds["pack"].Entries != 1 || st.Indexes != 1 || st.TotalBytes == 0 {
+		t.Errorf("Stats = %+v", st)
+	}
+	if err := s.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Get("pack", key, &got) {
+		t.Errorf("Get after Clear succeeded")
+	}
+	if st, _ := s.Stats(); st.TotalBytes != 0 {
+		t.Errorf("Stats after Clear = %+v", st)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := t.TempDir()
+	ix, err := s.Index(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := fakeInfo{size: 10, mod: time.Now().Add(-time.Hour)}
+	ix.Record("a.go", old, "sum-a")
+	if sum, ok := ix.Lookup("a.go", old); !ok || sum != "sum-a" {
+		t.Errorf("Lookup = %q, %v", sum, ok)
+	}
+	if _, ok := ix.Lookup("a.go", fakeInfo{size: 11, mod: old.mod}); ok {
+		t.Errorf("Lookup matched another size")
+	}
+	if _, ok := ix.Lookup("a.go", fakeInfo{size: 10, mod: old.mod.Add(time.Second)}); ok {
+		t.Errorf("Lookup matched another mtime")
+	}
+
+	// a file hashed right after it was written may change again unnoticed
+	fresh := fakeInfo{size: 5, mod: time.Now()}
+	ix.Record("b.go", fresh, "sum-b")
+	if _, ok := ix.Lookup("b.go", fresh); ok {
+		t.Errorf("Lookup trusted a racy entry")
+	}
+
+	if err := ix.Save(); err != nil {
+		t.Fatal(err)
+	}
+	again, err := s.Index(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum, ok := again.Lookup("a.go", old); !ok || sum != "sum-a" {
+		t.Errorf("reloaded Lookup = %q, %v", sum, ok)
+	}
+	again.Forget("a.go")
+	if _, ok := again.Lookup("a.go", old); ok {
+		t.Errorf("Lookup after Forget succeeded")
+	}
+
+	// a corrupt index starts empty
+	if err := os.WriteFile(again.path, []byte("garbage"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if broken, err := s.Index(root); err != nil || len(broken.entries) != 0 {
+		t.Errorf("corrupt index: %v, %d entries", err, len(broken.entries))
+	}
+}
+
+type fakeInfo struct {
+	os.FileInfo
+	size int64
+	mod  time.Time
+}
+
+func (f fakeInfo) Size() int64        { return f.size }
+func (f fakeInfo) ModTime() time.Time { return f.mod }
+
+func TestSumFile(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "a.go")
+	if err := os.WriteFile(p, []byte("package a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if sum, err := SumFile(p); err != nil || sum != Sum([]byte("package a\n")) {
+		t.Errorf("SumFile = %q, %v; want Sum of the content", sum, err)
+	}
+}
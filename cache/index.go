@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Index maps the files of one root to their content hash, valid while
+// their size and modification time stay the same.
+type Index struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]indexEntry
+	dirty   bool
+}
+
+type indexEntry struct {
+	Size     int64
+	ModTime  int64 // UnixNano
+	Sum      string
+	HashedAt int64 // UnixNano
+}
+
+// racyWindow guards against edits that keep size and mtime: a hash taken
+// within this long of the file's mtime isn't trusted, since the file may
+// have changed again within the same mtime tick.
+const racyWindow = 2 * time.Second
+
+// Index loads the index of root (any path form; it is made absolute). A
+// missing or unreadable index starts empty.
+func (s *Store) Index(root string) (*Index, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	ix := &Index{
+		path:    filepath.Join(s.dir, "index", Key(abs)[:32]),
+		entries: map[string]indexEntry{},
+	}
+	if data, err := os.ReadFile(ix.path); err == nil {
+		if gob.NewDecoder(bytes.NewReader(data)).Decode(&ix.entries) != nil {
+			ix.entries = map[string]indexEntry{}
+		}
+	}
+	return ix, nil
+}
+
+// Lookup returns the content hash recorded for rel if info still matches.
+func (ix *Index) Lookup(rel string, info fs.FileInfo) (string, bool) {
+	ix.mu.Lock()
+	e, ok := ix.entries[rel]
+	ix.mu.Unlock()
+	if !ok || e.Size != info.Size() || e.ModTime != info.ModTime().UnixNano() {
+		return "", false
+	}
+	if time.Duration(e.HashedAt-e.ModTime) < racyWindow {
+		return "", false
+	}
+	return e.Sum, true
+}
+
+// Record remembers that rel, as described by info, hashes to sum.
+func (ix *Index) Record(rel string, info fs.FileInfo, sum string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.entries[rel] = indexEntry{
+		Size:     info.Size(),
+		ModTime:  info.ModTime().UnixNano(),
+		Sum:      sum,
+		HashedAt: time.Now().UnixNano(),
+	}
+	ix.dirty = true
+}
+
+// Forget drops rel, e.g. a file that no longer exists.
+func (ix *Index) Forget(rel string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if _, ok := ix.entries[rel]; ok {
+		delete(ix.entries, rel)
+		ix.dirty = true
+	}
+}
+
+// Save writes the index back if it changed.
+func (ix *Index) Save() error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if !ix.dirty {
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(ix.entries); err != nil {
+		return err
+	}
+	if err := writeAtomic(ix.path, buf.Bytes()); err != nil {
+		return err
+	}
+	ix.dirty = false
+	return nil
+}
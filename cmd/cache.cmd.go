@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/parsabordbar/ctx3/cache"
+	"github.com/parsabordbar/ctx3/filetree"
+	"github.com/spf13/cobra"
+)
+
+var cacheJSON bool
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache stats|clear",
+	Short: "Inspect or clear the on-disk cache",
+	Long: `pack, mcp and serve keep the packed form of every file (after redaction,
+encoding and token counting) and the functions it declares in a cache,
+so unchanged files aren't read or parsed again. Entries are keyed by file
+content and by the options that shaped them, so changing --redact or
+--binary never serves stale results.
+
+The cache lives in $XDG_CACHE_HOME/ctx3 (~/.cache/ctx3 on Linux). Pass
+--no-cache to pack, mcp or serve to bypass it.`,
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show what the cache holds",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := cache.OpenDefault()
+		if err != nil {
+			return err
+		}
+		st, err := store.Stats()
+		if err != nil {
+			return err
+		}
+		if cacheJSON {
+			data, err := json.MarshalIndent(st, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+		fmt.Printf("Cache: %s\n", st.Dir)
+		kinds := make([]string, 0, len(st.Kinds))
+		for k := range st.Kinds {
+			kinds = append(kinds, k)
+		}
+		sort.Strings(kinds)
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "KIND\tENTRIES\tSIZE")
+		for _, k := range kinds {
+			fmt.Fprintf(tw, "%s\t%d\t%s\n", k, st.Kinds[k].Entries, filetree.HumanSize(st.Kinds[k].Bytes))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Printf("%d indexed roots, %s in all\n", st.Indexes, filetree.HumanSize(st.TotalBytes))
+		return nil
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Delete everything in the cache",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := cache.OpenDefault()
+		if err != nil {
+			return err
+		}
+		st, err := store.Stats()
+		if err != nil {
+			return err
+		}
+		if err := store.Clear(); err != nil {
+			return err
+		}
+		fmt.Printf("Cleared %s (%s)\n", st.Dir, filetree.HumanSize(st.TotalBytes))
+		return nil
+	},
+}
+
+func init() {
+	cacheStatsCmd.Flags().BoolVar(&cacheJSON, "json", false, "Output as JSON")
+	cacheCmd.AddCommand(cacheStatsCmd, cacheClearCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
+
+// openCache opens the default cache, or returns nil when disabled or
+// unavailable; ctx3 then works without one.
+func openCache(disabled bool) *cache.Store {
+	if disabled {
+		return nil
+	}
+	store, err := cache.OpenDefault()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warn: cache disabled: %v\n", err)
+		return nil
+	}
+	return store
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestMain keeps the commands under test away from the user's cache.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "ctx3-cache")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("XDG_CACHE_HOME", dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestCacheCommand(t *testing.T) {
+	td := t.TempDir()
+	mustWrite(t, filepath.Join(td, "x.go"), []byte("package x\n"))
+
+	t.Cleanup(func() { packOutputPath = "" }) // flags outlive Execute
+	rootCmd.SetArgs([]string{"pack", td, "-o", filepath.Join(t.TempDir(), "out.xml")})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("pack: %v", err)
+	}
+	objects := filepath.Join(os.Getenv("XDG_CACHE_HOME"), "ctx3", "objects")
+	if _, err := os.Stat(objects); err != nil {
+		t.Fatalf("pack left no cache entries: %v", err)
+	}
+
+	rootCmd.SetArgs([]string{"cache", "clear"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("cache clear: %v", err)
+	}
+	if _, err := os.Stat(objects); !os.IsNotExist(err) {
+		t.Errorf("cache clear left %s: %v", objects, err)
+	}
+}
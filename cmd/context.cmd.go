@@ -1,15 +1,26 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"sort"
 	"strings"
+
 	"github.com/parsabordbar/ctx3/analyzer"
+	"github.com/parsabordbar/ctx3/filetree"
 	"github.com/toon-format/toon-go"
 
 	"github.com/spf13/cobra"
 )
 
+var (
+	contextShowHidden bool
+	contextWatch      bool
+)
+
 var contextCmd = &cobra.Command{
 	Use:   "context [directory]",
 	Short: "Analyze project context for LLMs",
@@ -19,38 +30,116 @@ Output formats:
   - Default: Human-readable text format
   - JSON (-j): Machine-readable JSON format
   - TOON (-t): Token-Oriented Object Notation (compact, LLM-optimized)`,
-	Args:  cobra.MaximumNArgs(1),
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		dir := "."
 		if len(args) > 0 {
 			dir = args[0]
 		}
 
-		ctx := analyzer.AnalyzeProject(dir)
-
-		// Handle different output formats
-		if analyzer.OutputTOON {
-			// Output as TOON format
-			encoded, err := toon.Marshal(ctx, toon.WithLengthMarkers(true))
+		if contextWatch {
+			err := watchLoop("context", dir, contextShowHidden, nil, func(context.Context, []string) error {
+				return writeContext(os.Stdout, dir)
+			})
 			if err != nil {
-				fmt.Printf("Error encoding TOON: %v\n", err)
-				return
+				fmt.Println(err)
 			}
-			fmt.Println(string(encoded))
-		} else if analyzer.OutputJSON {
-			// Output as JSON
-			data, _ := json.MarshalIndent(ctx, "", "  ")
-			fmt.Println(string(data))
-		} else {
-			// Output as human-readable format
-			fmt.Printf("📂 Project: %s\n", ctx.Root)
-			fmt.Printf("Files: %d, Dirs: %d\n", ctx.TotalFiles, ctx.TotalDirs)
-			if len(ctx.Dependencies) > 0 {
-				fmt.Println("Dependencies:", strings.Join(ctx.Dependencies, ", "))
+			return
+		}
+		if err := writeContext(os.Stdout, dir); err != nil {
+			fmt.Println(err)
+		}
+	},
+}
+
+// writeContext analyzes dir and writes the result to w in the format the
+// flags ask for.
+func writeContext(w io.Writer, dir string) error {
+	rules, err := filetree.LoadIgnoreRules(dir, contextShowHidden)
+	if err != nil {
+		return fmt.Errorf("Error loading ignore rules: %v", err)
+	}
+	ctx, err := analyzer.AnalyzeProjectWithOptions(context.Background(), dir, analyzer.AnalyzeOptions{Ignores: rules})
+	if err != nil {
+		return err
+	}
+
+	// Handle different output formats
+	if analyzer.OutputTOON {
+		// Output as TOON format
+		encoded, err := toon.Marshal(ctx, toon.WithLengthMarkers(true))
+		if err != nil {
+			return fmt.Errorf("Error encoding TOON: %v", err)
+		}
+		fmt.Fprintln(w, string(encoded))
+	} else if analyzer.OutputJSON {
+		// Output as JSON
+		data, _ := json.MarshalIndent(ctx, "", "  ")
+		fmt.Fprintln(w, string(data))
+	} else {
+		// Output as human-readable format
+		fmt.Fprintf(w, "📂 Project: %s\n", ctx.Root)
+		fmt.Fprintf(w, "Files: %d, Dirs: %d\n", ctx.TotalFiles, ctx.TotalDirs)
+		if langs := languageSummary(ctx.Files); langs != "" {
+			fmt.Fprintln(w, "Languages:", langs)
+		}
+		if len(ctx.Dependencies) > 0 {
+			fmt.Fprintln(w, "Dependencies:")
+			for _, d := range ctx.Dependencies {
+				name := d.Name
+				if d.Version != "" {
+					name += " " + d.Version
+				}
+				fmt.Fprintf(w, "  [%s] %s (%s, %s)\n", d.Ecosystem, name, d.Scope, d.Manifest)
 			}
-			if ctx.Readme != "" {
-				fmt.Println("\nREADME Preview:\n", ctx.Readme)
+		}
+		if len(ctx.EntryPoints) > 0 {
+			fmt.Fprintln(w, "Entry points:")
+			for _, ep := range ctx.EntryPoints {
+				fmt.Fprintf(w, "  %s — %s\n", ep.Path, ep.Reason)
 			}
 		}
-	},
-}
\ No newline at end of file
+		if ctx.Readme != "" {
+			fmt.Fprintln(w, "\nREADME Preview:\n", ctx.Readme)
+		}
+		if ctx.Workspace != nil {
+			fmt.Fprintf(w, "\n📦 Workspace (%s): %d packages\n", strings.Join(ctx.Workspace.Kinds, ", "), len(ctx.Packages))
+			for _, pc := range ctx.Packages {
+				fmt.Fprintf(w, "├── %s (%s): %d files, %d dependencies\n", pc.Name, pc.Root, pc.TotalFiles, len(pc.Dependencies))
+				if len(pc.LocalDependencies) > 0 {
+					fmt.Fprintf(w, "│     uses: %s\n", strings.Join(pc.LocalDependencies, ", "))
+				}
+				for _, ep := range pc.EntryPoints {
+					fmt.Fprintf(w, "│     entry point: %s — %s\n", ep.Path, ep.Reason)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// languageSummary lists programming/markup languages by file count, e.g. "Go (12), Markdown (2)".
+func languageSummary(files []analyzer.FileInfo) string {
+	counts := map[string]int{}
+	for _, f := range files {
+		if f.Vendored || f.Generated || f.Language == analyzer.OtherLanguage {
+			continue
+		}
+		counts[f.Language]++
+	}
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s (%d)", name, counts[name])
+	}
+	return strings.Join(parts, ", ")
+}
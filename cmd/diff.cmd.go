@@ -0,0 +1,288 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/parsabordbar/ctx3/analyzer"
+	"github.com/parsabordbar/ctx3/pack"
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffRevs    []string
+	diffJSON    bool
+	diffStat    bool
+	diffContext int
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <old-pack> <new-pack> | --rev <rev> [--rev <rev>] [directory]",
+	Short: "Show what changed between two packs",
+	Long: `Show the files added, removed or modified between two packs, with a
+unified diff and the change in estimated tokens for each, so a model that
+already has the old pack can be sent just the difference.
+
+The packs are either two pack files (XML or Markdown, as written by pack),
+or the directory packed at two git revisions with --rev A --rev B. A single
+--rev compares that revision with the working tree. Revisions are read with
+git archive, nothing is checked out, and the pack flags (--include,
+--ignore, --lockfiles, ...) shape both packs.
+
+The default output is a table of changed files followed by the unified
+diffs; --stat prints only the table and --json everything as JSON.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(diffRevs) > 0 {
+			if len(diffRevs) > 2 {
+				return fmt.Errorf("--rev can be given at most twice")
+			}
+			return cobra.MaximumNArgs(1)(cmd, args)
+		}
+		if len(args) != 2 {
+			return fmt.Errorf("expected two pack files, or --rev")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if diffJSON && diffStat {
+			return fmt.Errorf("--json and --stat can't be combined")
+		}
+		var old, new []pack.FileEntry
+		var err error
+		if len(diffRevs) > 0 {
+			dir := "."
+			if len(args) > 0 {
+				dir = args[0]
+			}
+			old, new, err = packRevs(cmd.Context(), dir, diffRevs)
+		} else {
+			old, new, err = parsePackFiles(args[0], args[1])
+		}
+		if err != nil {
+			return err
+		}
+		diffs := pack.Diff(old, new, diffContext)
+
+		var out bytes.Buffer
+		if err := writeDiff(&out, diffs); err != nil {
+			return err
+		}
+		if packOutputPath != "" {
+			return os.WriteFile(packOutputPath, out.Bytes(), 0o644)
+		}
+		_, err = os.Stdout.Write(out.Bytes())
+		return err
+	},
+}
+
+func parsePackFiles(oldPath, newPath string) (old, new []pack.FileEntry, err error) {
+	read := func(path string) ([]pack.FileEntry, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		doc, err := pack.Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return doc.Files, nil
+	}
+	if old, err = read(oldPath); err != nil {
+		return nil, nil, err
+	}
+	if new, err = read(newPath); err != nil {
+		return nil, nil, err
+	}
+	return old, new, nil
+}
+
+// packRevs packs dir at the first revision and at the second, or as it is
+// on disk when only one is given.
+func packRevs(ctx context.Context, dir string, revs []string) (old, new []pack.FileEntry, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, nil, errors.New("--rev needs git in PATH")
+	}
+	if old, err = packRev(ctx, dir, revs[0]); err != nil {
+		return nil, nil, err
+	}
+	if len(revs) == 2 {
+		new, err = packRev(ctx, dir, revs[1])
+	} else {
+		new, err = packDir(ctx, dir)
+	}
+	return old, new, err
+}
+
+// packRev packs dir as it was at rev: the tree is exported with git archive
+// into a temporary directory and packed from there.
+func packRev(ctx context.Context, dir, rev string) ([]pack.FileEntry, error) {
+	// archived from the top, since git archive run in a subdirectory
+	// limits itself to it
+	out, err := gitOutput(dir, "rev-parse", "--show-toplevel", "--show-prefix")
+	if err != nil {
+		return nil, err
+	}
+	top, prefix, _ := strings.Cut(strings.TrimSuffix(out, "\n"), "\n")
+	// only the commit id reaches git archive
+	id, err := analyzer.ResolveCommit(ctx, dir, rev)
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := os.MkdirTemp("", "ctx3-diff-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	archive := exec.CommandContext(ctx, "git", "-C", top, "archive", "--format=tar", id+":"+prefix)
+	var stderr bytes.Buffer
+	archive.Stderr = &stderr
+	stdout, err := archive.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := archive.Start(); err != nil {
+		return nil, err
+	}
+	xerr := extractTar(stdout, tmp)
+	io.Copy(io.Discard, stdout)
+	if err := archive.Wait(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git archive %s: %s", rev, msg)
+		}
+		return nil, fmt.Errorf("git archive %s: %w", rev, err)
+	}
+	if xerr != nil {
+		return nil, fmt.Errorf("extracting %s: %w", rev, xerr)
+	}
+	return packDir(ctx, tmp)
+}
+
+func packDir(ctx context.Context, dir string) ([]pack.FileEntry, error) {
+	cfg, err := collectPackConfigFromFlags(dir)
+	if err != nil {
+		return nil, err
+	}
+	files, _, _, err := pack.WalkAndCollect(ctx, cfg)
+	return files, err
+}
+
+// extractTar writes the regular files and directories of a tar stream
+// below dir. Links and special files are left out.
+func extractTar(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !filepath.IsLocal(filepath.FromSlash(h.Name)) {
+			continue
+		}
+		dst := filepath.Join(dir, filepath.FromSlash(h.Name))
+		switch h.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(dst, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func gitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return string(out), nil
+}
+
+func writeDiff(w io.Writer, diffs []pack.FileDiff) error {
+	if diffJSON {
+		if diffs == nil {
+			diffs = []pack.FileDiff{}
+		}
+		data, err := json.MarshalIndent(diffs, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	}
+
+	if len(diffs) == 0 {
+		_, err := fmt.Fprintln(w, "No changes")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHANGE\tTOKENS\tPATH")
+	counts := map[pack.ChangeKind]int{}
+	delta := 0
+	for _, d := range diffs {
+		fmt.Fprintf(tw, "%s\t%+d\t%s\n", d.Change, d.TokenDelta, d.Path)
+		counts[d.Change]++
+		delta += d.TokenDelta
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%d files changed (%d added, %d removed, %d modified), %+d tokens\n",
+		len(diffs), counts[pack.ChangeAdded], counts[pack.ChangeRemoved], counts[pack.ChangeModified], delta)
+	if diffStat {
+		return nil
+	}
+	for _, d := range diffs {
+		fmt.Fprintln(w)
+		if _, err := io.WriteString(w, d.Unified); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	addPackFlags(diffCmd)
+	diffCmd.Flags().StringArrayVar(&diffRevs, "rev", nil, "Git revision to pack and compare (twice: old and new; once: against the working tree)")
+	diffCmd.Flags().BoolVar(&diffJSON, "json", false, "Output the changed files with their diffs as JSON")
+	diffCmd.Flags().BoolVar(&diffStat, "stat", false, "List the changed files without their diffs")
+	diffCmd.Flags().IntVarP(&diffContext, "unified", "U", pack.DefaultDiffContext, "Lines of context around each change")
+
+	rootCmd.AddCommand(diffCmd)
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestDiffCommand_PackFiles(t *testing.T) {
+	td := t.TempDir()
+	oldPack := filepath.Join(td, "old.xml")
+	newPack := filepath.Join(td, "new.md")
+	mustWrite(t, oldPack, []byte("<files>\n<file path=\"a.go\">\npackage a\n</file>\n\n<file path=\"b.go\">\npackage b\n</file>\n\n</files>\n"))
+	mustWrite(t, newPack, []byte("# Files\n\n## File: a.go\n\n```go\npackage a\n\nfunc A() {}\n```\n\n"))
+
+	t.Cleanup(func() { packOutputPath, diffStat = "", false })
+	out := filepath.Join(td, "diff.txt")
+	rootCmd.SetArgs([]string{"diff", oldPack, newPack, "-o", out})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"2 files changed (0 added, 1 removed, 1 modified)",
+		"--- a/a.go\n+++ b/a.go\n@@ -1 +1,3 @@\n package a\n+\n+func A() {}\n",
+		"--- a/b.go\n+++ /dev/null\n",
+	} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("output lacks %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestDiffCommand_Revs(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	td := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", td, "-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	mustWrite(t, filepath.Join(td, "sub", "x.go"), []byte("package x\n"))
+	mustWrite(t, filepath.Join(td, "top.txt"), []byte("top\n"))
+	git("add", ".")
+	git("commit", "-qm", "one")
+	mustWrite(t, filepath.Join(td, "sub", "y.go"), []byte("package x\n\nvar Y = 1\n"))
+	git("add", ".")
+	git("commit", "-qm", "two")
+	mustWrite(t, filepath.Join(td, "sub", "x.go"), []byte("package x // edited\n"))
+
+	// --rev keeps appending across Execute calls unless emptied
+	resetRevs := func() { diffCmd.Flags().Lookup("rev").Value.(pflag.SliceValue).Replace(nil) }
+	t.Cleanup(func() { packOutputPath, diffStat = "", false; resetRevs() })
+	out := filepath.Join(td, "diff.txt")
+	rootCmd.SetArgs([]string{"diff", "--rev", "HEAD~1", "--rev", "HEAD", filepath.Join(td, "sub"), "--stat", "-o", out})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	got, _ := os.ReadFile(out)
+	if !strings.Contains(string(got), "added") || !strings.Contains(string(got), "y.go") || strings.Contains(string(got), "top.txt") || strings.Contains(string(got), "x.go") {
+		t.Errorf("HEAD~1..HEAD of sub:\n%s", got)
+	}
+
+	// one --rev compares with the working tree
+	resetRevs()
+	rootCmd.SetArgs([]string{"diff", "--rev", "HEAD", td, "--stat=false", "-o", out})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	got, _ = os.ReadFile(out)
+	if !strings.Contains(string(got), "+package x // edited\n") {
+		t.Errorf("HEAD..working tree:\n%s", got)
+	}
+
+	// a rev is never handed to git archive as an option
+	resetRevs()
+	leak := filepath.Join(td, "leak.tar")
+	rootCmd.SetArgs([]string{"diff", "--rev=--output=" + leak, td, "-o", out})
+	if err := rootCmd.Execute(); err == nil || !strings.Contains(err.Error(), "invalid revision") {
+		t.Errorf("expected an invalid revision error, got %v", err)
+	}
+	if _, err := os.Stat(leak); err == nil {
+		t.Errorf("git archive wrote %s", leak)
+	}
+}
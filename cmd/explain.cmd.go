@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/parsabordbar/ctx3/pack"
+	"github.com/spf13/cobra"
+)
+
+var (
+	explainRoot string
+	explainJSON bool
+)
+
+var explainCmd = &cobra.Command{
+	Use:   "explain <path> [pack flags]",
+	Short: "Show why pack includes or excludes a path",
+	Long: `Show the chain of rules pack applies to a path, in order, with the
+rule, pattern and source (flag, .gitignore line, ignore list file) of each
+one, and whether the path ends up in the pack.
+
+Pass the same flags as to pack (--include, --ignore, --max-file-bytes, ...)
+to see their effect. The path is resolved against the current directory
+and must lie inside --root.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rootAbs, err := filepath.Abs(explainRoot)
+		if err != nil {
+			return err
+		}
+		target, err := filepath.Abs(args[0])
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(rootAbs, target)
+		if err != nil {
+			return err
+		}
+
+		cfg, err := collectPackConfigFromFlags(explainRoot)
+		if err != nil {
+			return err
+		}
+		d, err := pack.Explain(context.Background(), cfg, rel)
+		if err != nil {
+			return err
+		}
+
+		if explainJSON {
+			data, err := json.MarshalIndent(d, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+
+		fmt.Printf("%s (%d bytes)\n", d.Path, d.Size)
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "EFFECT\tRULE\tPATTERN\tSOURCE\tDETAIL")
+		for _, s := range d.Steps {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", s.Effect, s.Rule, dash(s.Pattern), dash(s.Source), dash(s.Detail))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		if d.Included {
+			fmt.Println("=> included")
+		} else {
+			r := d.Reason()
+			fmt.Printf("=> excluded by %s\n", r.Rule)
+		}
+		return nil
+	},
+}
+
+func init() {
+	addPackFlags(explainCmd)
+	explainCmd.Flags().StringVar(&explainRoot, "root", ".", "Directory that would be packed")
+	explainCmd.Flags().BoolVar(&explainJSON, "json", false, "Output the decision as JSON")
+
+	rootCmd.AddCommand(explainCmd)
+}
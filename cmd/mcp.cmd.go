@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/parsabordbar/ctx3/mcp"
+	"github.com/spf13/cobra"
+)
+
+var mcpNoCache bool
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp [directory]",
+	Short: "Run a Model Context Protocol server over stdio",
+	Long: `Run a Model Context Protocol (MCP) server on stdin/stdout so editors and
+agents can pull project context on demand.
+
+Tools:
+  get_tree        File tree, optionally of a subdirectory and to a depth
+  get_context     The output of ctx3 context, as JSON
+  pack_paths      Pack the files matching globs, within a token budget
+  read_file       Numbered lines of a file, optionally a line range
+  search          Lines matching a regular expression
+  list_functions  Functions and methods, optionally below a path
+
+Every path is relative to the directory (default: current) and can't
+leave it. Logs go to stderr; stdout carries only protocol messages.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := "."
+		if len(args) > 0 {
+			dir = args[0]
+		}
+		if info, err := os.Stat(dir); err != nil {
+			return err
+		} else if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", dir)
+		}
+		fmt.Fprintf(os.Stderr, "ctx3 mcp: serving %s on stdio\n", dir)
+		srv := mcp.NewServer(dir)
+		srv.Cache = openCache(mcpNoCache)
+		return srv.Serve(cmd.Context(), os.Stdin, os.Stdout)
+	},
+}
+
+func init() {
+	mcpCmd.Flags().BoolVar(&mcpNoCache, "no-cache", false, "Don't use the on-disk cache (see ctx3 cache)")
+	rootCmd.AddCommand(mcpCmd)
+}
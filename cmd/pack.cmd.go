@@ -2,29 +2,54 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"slices"
 	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/parsabordbar/ctx3/filetree"
 	"github.com/parsabordbar/ctx3/pack"
 	"github.com/spf13/cobra"
 )
 
 var (
-	packOutputPath    string
-	packFormat        string // xml|md|txt
-	packRespectGit    bool
-	packInclude       []string
-	packIgnore        []string
-	packMaxFileBytes  int64
-	packMaxTotalBytes int64
-	packBinary        string // skip|hex|base64
-	packSort          string // paths|ext
-	packSection       string // all|structure|files
-	packRedact        []string
-	packConcurrency   int
-	packCompact       bool // NEW
+	packOutputPath       string
+	packFormat           string // xml|md|txt
+	packRespectGit       bool
+	packInclude          []string
+	packIgnore           []string
+	packMaxFileBytes     int64
+	packMaxTotalBytes    int64
+	packBinary           string // skip|hex|base64
+	packSort             string // paths|ext
+	packSection          string // all|structure|files
+	packRedact           []string
+	packConcurrency      int
+	packCompact          bool   // NEW
+	packLockfiles        string // full|summarize|skip
+	packPackage          string // workspace member name or path
+	packShowHidden       bool
+	packDryRun           bool
+	packJSON             bool
+	packReportJSON       string
+	packOnError          string // skip|fail
+	packTimeout          time.Duration
+	packWatch            bool
+	packNoCache          bool
+	packLargeFiles       string // skip|truncate|sample
+	packHeadLines        int
+	packTailLines        int
+	packSampleRecords    int
+	packGenerated        string // include|skip|stub
+	packEncodingFallback string // none|latin1
+	packConvert          []string
+	packNotebookOutput   int
+	packSymlinks         string // skip|record|follow-within-root|follow
 )
 
 var packCmd = &cobra.Command{
@@ -36,16 +61,32 @@ var packCmd = &cobra.Command{
 		if len(args) > 0 {
 			root = args[0]
 		}
+		if packJSON && !packDryRun {
+			return fmt.Errorf("--json requires --dry-run")
+		}
 		cfg, err := collectPackConfigFromFlags(root)
 		if err != nil {
 			return err
 		}
+		if packDryRun {
+			if packWatch {
+				return fmt.Errorf("--watch can't be combined with --dry-run")
+			}
+			return runPackDryRun(cfg)
+		}
+		if packWatch {
+			return runPackWatch(root)
+		}
 
-		out, report, err := pack.Pack(context.Background(), cfg)
+		if store := openCache(packNoCache); store != nil {
+			if cfg.Cache, err = pack.NewDiskReadCache(store, root); err != nil {
+				return err
+			}
+		}
+		out, report, err := packWithTimeout(context.Background(), cfg)
 		if err != nil {
 			return err
 		}
-
 		if cfg.OutputPath != "" {
 			if err := os.WriteFile(cfg.OutputPath, out, 0o644); err != nil {
 				return err
@@ -53,72 +94,208 @@ var packCmd = &cobra.Command{
 		} else {
 			fmt.Print(string(out))
 		}
+		printPackReport(os.Stderr, report)
+		return writeReportJSON(report)
+	},
+}
 
-		fmt.Fprintf(os.Stderr, "Packed %d files (%d skipped), %d bytes\n",
-			report.FilesIncluded, report.FilesSkipped, report.TotalBytes)
-		for _, w := range report.Warnings {
-			fmt.Fprintf(os.Stderr, "warn: %s\n", w)
-		}
+// packWithTimeout packs cfg, giving up after --timeout.
+func packWithTimeout(ctx context.Context, cfg pack.Config) ([]byte, pack.Report, error) {
+	if packTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, packTimeout)
+		defer cancel()
+	}
+	out, report, err := pack.Pack(ctx, cfg)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, report, fmt.Errorf("pack timed out after %s: %w", packTimeout, err)
+	}
+	return out, report, err
+}
+
+// writeReportJSON writes report to --report-json, if set.
+func writeReportJSON(report pack.Report) error {
+	if packReportJSON == "" {
 		return nil
-	},
+	}
+	// empty lists rather than null, for scripts reading the file
+	if report.Skipped == nil {
+		report.Skipped = []pack.SkipRecord{}
+	}
+	if report.SkippedByReason == nil {
+		report.SkippedByReason = map[pack.Rule]int{}
+	}
+	if report.Warnings == nil {
+		report.Warnings = []string{}
+	}
+	if report.Errors == nil {
+		report.Errors = []string{}
+	}
+	if report.Truncated == nil {
+		report.Truncated = []string{}
+	}
+	if report.Stubbed == nil {
+		report.Stubbed = []string{}
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(packReportJSON, append(data, '\n'), 0o644)
+}
+
+// runPackWatch packs root, then packs it again whenever something below
+// it changes. Unchanged files are served from a cache rather than read
+// again, and the config is rebuilt every time so ignore files apply live.
+// The output file is replaced atomically.
+func runPackWatch(root string) error {
+	store := openCache(packNoCache)
+	newCache := func() *pack.ReadCache {
+		if store != nil {
+			if c, err := pack.NewDiskReadCache(store, root); err == nil {
+				return c
+			}
+		}
+		return pack.NewReadCache()
+	}
+	cache := newCache()
+	return watchLoop("pack", root, packShowHidden, []string{packOutputPath, packReportJSON}, func(ctx context.Context, changed []string) error {
+		cfg, err := collectPackConfigFromFlags(root)
+		if err != nil {
+			return err
+		}
+		if slices.Contains(changed, ".") {
+			cache = newCache() // events were lost
+		}
+		cache.Invalidate(changed...)
+		cfg.Cache = cache
+		// the previous output must not end up in the next one, whatever
+		// --include says
+		for _, o := range []string{packOutputPath, packReportJSON} {
+			if rel, ok := relInside(root, o); ok {
+				cfg.Ignores.Rules = append(cfg.Ignores.Rules, filetree.IgnoreRule{Pattern: globEscape(rel), Source: "--watch output"})
+			}
+		}
+
+		start, reads := time.Now(), cache.Reads()
+		out, report, err := packWithTimeout(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		if cfg.OutputPath != "" {
+			if err := writeFileAtomic(cfg.OutputPath, out); err != nil {
+				return err
+			}
+		} else {
+			fmt.Print(string(out))
+		}
+		fmt.Fprintf(os.Stderr, "ctx3 pack: rebuilt in %s, %d files read from disk\n",
+			time.Since(start).Round(time.Millisecond), cache.Reads()-reads)
+		printPackReport(os.Stderr, report)
+		return writeReportJSON(report)
+	})
 }
 
 func init() {
-	packRespectGit = true
-
-	packCmd.Flags().StringVarP(&packOutputPath, "output", "o", "", "Write output to file (default: stdout)")
-	packCmd.Flags().StringVarP(&packFormat, "format", "f", "xml", "Output format: xml|md|txt")
-	packCmd.Flags().BoolVar(&packRespectGit, "respect-gitignore", true, "Respect .gitignore rules")
-	packCmd.Flags().StringSliceVar(&packInclude, "include", nil, "Comma-separated globs to include (applied after ignores)")
-	packCmd.Flags().StringSliceVar(&packIgnore, "ignore", nil, "Comma-separated globs to ignore (higher precedence than .gitignore)")
-	packCmd.Flags().Int64Var(&packMaxFileBytes, "max-file-bytes", 0, "Skip any single file larger than this many bytes (0 = unlimited)")
-	packCmd.Flags().Int64Var(&packMaxTotalBytes, "max-total-bytes", 0, "Stop once accumulated content exceeds this many bytes (0 = unlimited)")
-	packCmd.Flags().StringVar(&packBinary, "binary", "skip", "How to handle binary files: skip|hex|base64")
-	packCmd.Flags().StringVar(&packSort, "sort", "paths", "Sort order for files: paths|ext")
-	packCmd.Flags().StringVar(&packSection, "section", "all", "Which sections to output: all|structure|files")
-	packCmd.Flags().StringSliceVar(&packRedact, "redact", nil, "Comma-separated regex patterns to redact from file contents")
-	packCmd.Flags().IntVar(&packConcurrency, "concurrency", 0, "Number of concurrent file reads (0 = auto)")
-	packCmd.Flags().BoolVar(&packCompact, "compact", false, "Remove extra blank lines between sections and files") // NEW
+	addPackFlags(packCmd)
+	packCmd.Flags().BoolVar(&packDryRun, "dry-run", false, "List every candidate path with its include/exclude decision instead of packing")
+	packCmd.Flags().BoolVar(&packJSON, "json", false, "Output --dry-run as JSON")
+	packCmd.Flags().DurationVar(&packTimeout, "timeout", 0, "Give up after this long, e.g. 30s (0 = no limit)")
+	packCmd.Flags().BoolVar(&packNoCache, "no-cache", false, "Don't use the on-disk cache of packed files (see ctx3 cache)")
+	packCmd.Flags().BoolVar(&packWatch, "watch", false, "Keep packing as files change; -o is rewritten atomically")
+	packCmd.Flags().StringVar(&packReportJSON, "report-json", "", "Write the pack report (skipped files with reasons, warnings, errors) as JSON to this file")
 
 	rootCmd.AddCommand(packCmd)
 }
 
+// addPackFlags registers the flags that shape a pack on cmd; pack and
+// explain share them.
+func addPackFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&packOutputPath, "output", "o", "", "Write output to file (default: stdout)")
+	cmd.Flags().StringVarP(&packFormat, "format", "f", "xml", "Output format: xml|md|txt")
+	cmd.Flags().BoolVar(&packRespectGit, "respect-gitignore", true, "Respect .gitignore rules")
+	cmd.Flags().StringSliceVar(&packInclude, "include", nil, "Comma-separated globs to include (applied after ignores)")
+	cmd.Flags().StringSliceVar(&packIgnore, "ignore", nil, "Comma-separated globs to ignore (higher precedence than .gitignore)")
+	cmd.Flags().Int64Var(&packMaxFileBytes, "max-file-bytes", 0, "Skip any single file larger than this many bytes (0 = unlimited)")
+	cmd.Flags().Int64Var(&packMaxTotalBytes, "max-total-bytes", 0, "Stop once accumulated content exceeds this many bytes (0 = unlimited)")
+	cmd.Flags().StringVar(&packBinary, "binary", "skip", "How to handle binary files: skip|hex|base64")
+	cmd.Flags().StringVar(&packSort, "sort", "paths", "Sort order for files: paths|ext")
+	cmd.Flags().StringVar(&packSection, "section", "all", "Which sections to output: all|structure|files")
+	cmd.Flags().StringSliceVar(&packRedact, "redact", nil, "Comma-separated regex patterns to redact from file contents")
+	cmd.Flags().IntVar(&packConcurrency, "concurrency", 0, "Number of concurrent file reads (0 = auto)")
+	cmd.Flags().BoolVar(&packCompact, "compact", false, "Remove extra blank lines between sections and files") // NEW
+	cmd.Flags().StringVar(&packPackage, "package", "", "Pack only this workspace member (name or path) plus the local packages it depends on")
+	cmd.Flags().StringVar(&packLockfiles, "lockfiles", "full", "How to handle lockfiles (package-lock.json, go.sum, ...): full|summarize|skip")
+	cmd.Flags().StringVar(&packLargeFiles, "large-files", "skip", "What to do with files over --max-file-bytes: skip|truncate (keep head and tail lines)|sample (keys or header and first records of JSON/YAML/CSV)")
+	cmd.Flags().IntVar(&packHeadLines, "head-lines", pack.DefaultHeadLines, "Lines kept from the start of a truncated file")
+	cmd.Flags().IntVar(&packTailLines, "tail-lines", pack.DefaultTailLines, "Lines kept from the end of a truncated file")
+	cmd.Flags().IntVar(&packSampleRecords, "sample-records", pack.DefaultSampleRecords, "Records kept per list by --large-files sample")
+	cmd.Flags().StringVar(&packGenerated, "generated", "include", "What to do with generated, minified and vendored files: include|skip|stub (a one-line placeholder)")
+	cmd.Flags().StringVar(&packEncodingFallback, "encoding-fallback", "none", "How to read text whose encoding isn't detected: none (as is)|latin1")
+	cmd.Flags().StringSliceVar(&packConvert, "convert", []string{"ipynb", "docx", "odt", "pdf"}, "Extensions packed as the text their converter extracts: ipynb, docx, odt, pdf, csv, tsv, or none")
+	cmd.Flags().IntVar(&packNotebookOutput, "notebook-output-lines", 0, "Lines of text output kept per notebook cell (0 = drop outputs; images are always dropped)")
+	cmd.Flags().StringVar(&packSymlinks, "symlinks", "follow-within-root", "What to do with symbolic links: skip|record (list them as name -> target)|follow-within-root|follow")
+	cmd.Flags().StringVar(&packOnError, "on-error", "skip", "What to do when a file can't be read: skip (report it and go on)|fail")
+
+	cmd.Flags().BoolVar(&packShowHidden, "show-hidden-defaults", false, "Don't apply the built-in ignore list (build outputs, caches, ...)")
+}
+
 func collectPackConfigFromFlags(root string) (pack.Config, error) {
 	var cfg pack.Config
+	var err error
 	cfg.RootDir = root
 	cfg.OutputPath = packOutputPath
 
-	switch strings.ToLower(packFormat) {
-	case "xml":
-		cfg.OutputFormat = pack.FormatXML
-	case "md":
-		cfg.OutputFormat = pack.FormatMD
-	case "txt":
-		cfg.OutputFormat = pack.FormatTXT
-	default:
-		return cfg, fmt.Errorf("invalid --format: %s (expected xml|md|txt)", packFormat)
-	}
-
-	switch strings.ToLower(packBinary) {
-	case "skip":
-		cfg.BinaryHandling = pack.BinarySkip
-	case "hex":
-		cfg.BinaryHandling = pack.BinaryHex
-	case "base64":
-		cfg.BinaryHandling = pack.BinaryBase64
-	default:
-		return cfg, fmt.Errorf("invalid --binary: %s (expected skip|hex|base64)", packBinary)
-	}
-
-	switch strings.ToLower(packSort) {
-	case "paths":
-		cfg.SortByExt = false
-	case "ext":
-		cfg.SortByExt = true
-	default:
-		return cfg, fmt.Errorf("invalid --sort: %s (expected paths|ext)", packSort)
+	if cfg.OutputFormat, err = pack.ParseOutputFormat(packFormat); err != nil {
+		return cfg, err
+	}
+	if cfg.BinaryHandling, err = pack.ParseBinaryStrategy(packBinary); err != nil {
+		return cfg, err
+	}
+	if cfg.SortByExt, err = pack.ParseSort(packSort); err != nil {
+		return cfg, err
+	}
+	if cfg.Lockfiles, err = pack.ParseLockfileMode(packLockfiles); err != nil {
+		return cfg, err
+	}
+	if cfg.OnError, err = pack.ParseErrorPolicy(packOnError); err != nil {
+		return cfg, err
+	}
+	if cfg.LargeFiles, err = pack.ParseLargeFileMode(packLargeFiles); err != nil {
+		return cfg, err
+	}
+	if cfg.LargeFiles != pack.LargeFilesSkip && packMaxFileBytes <= 0 {
+		return cfg, fmt.Errorf("--large-files %s requires --max-file-bytes", cfg.LargeFiles)
+	}
+	if packHeadLines < 1 || packTailLines < 1 || packSampleRecords < 1 {
+		return cfg, fmt.Errorf("--head-lines, --tail-lines and --sample-records must be at least 1")
+	}
+	cfg.HeadLines, cfg.TailLines, cfg.SampleRecords = packHeadLines, packTailLines, packSampleRecords
+	if cfg.Generated, err = pack.ParseGeneratedPolicy(packGenerated); err != nil {
+		return cfg, err
+	}
+	if cfg.EncodingFallback, err = pack.ParseEncodingFallback(packEncodingFallback); err != nil {
+		return cfg, err
+	}
+	if cfg.Convert, err = pack.ParseConvert(packConvert); err != nil {
+		return cfg, err
+	}
+	if packNotebookOutput < 0 {
+		return cfg, fmt.Errorf("--notebook-output-lines must not be negative")
+	}
+	cfg.NotebookOutputLines = packNotebookOutput
+	if cfg.Symlinks, err = pack.ParseSymlinkPolicy(packSymlinks); err != nil {
+		return cfg, err
+	}
+	if packPackage != "" {
+		if cfg.Subdirs, err = pack.PackageSubdirs(root, packPackage); err != nil {
+			return cfg, err
+		}
 	}
 
+	if cfg.Ignores, err = filetree.LoadIgnoreRules(root, packShowHidden); err != nil {
+		return cfg, err
+	}
 	cfg.RespectGitignore = packRespectGit
 	cfg.IncludeGlobs = normalizeSlice(packInclude)
 	cfg.IgnoreGlobs = normalizeSlice(packIgnore)
@@ -128,23 +305,117 @@ func collectPackConfigFromFlags(root string) (pack.Config, error) {
 	cfg.Concurrency = packConcurrency
 	cfg.Compact = packCompact
 
-	switch strings.ToLower(packSection) {
-	case "all":
-		cfg.Sections.Structure = true
-		cfg.Sections.Files = true
-	case "structure":
-		cfg.Sections.Structure = true
-		cfg.Sections.Files = false
-	case "files":
-		cfg.Sections.Structure = false
-		cfg.Sections.Files = true
-	default:
-		return cfg, errors.New("invalid --section: expected all|structure|files")
+	if cfg.Sections, err = pack.ParseSections(packSection); err != nil {
+		return cfg, err
 	}
-
 	return cfg, nil
 }
 
+// skipReasonOrder is the order printPackReport groups skipped files in.
+var skipReasonOrder = []pack.Rule{
+	pack.RuleMaxFileBytes,
+	pack.RuleBinary,
+	pack.RuleLockfile,
+	pack.RuleGenerated,
+	pack.RuleSymlink,
+	pack.RuleSpecial,
+	pack.RuleMaxTotalBytes,
+	pack.RuleReadError,
+}
+
+// printPackReport prints the pack summary with skipped files grouped by
+// reason, then warnings and errors.
+func printPackReport(w io.Writer, report pack.Report) {
+	fmt.Fprintf(w, "Packed %d files (%d skipped), %d bytes\n",
+		report.FilesIncluded, report.FilesSkipped, report.TotalBytes)
+	for _, reason := range skipReasonOrder {
+		var paths []string
+		for _, s := range report.Skipped {
+			if s.Reason == reason {
+				paths = append(paths, s.Path)
+			}
+		}
+		if len(paths) == 0 {
+			continue
+		}
+		more := ""
+		if len(paths) > 5 {
+			more = fmt.Sprintf(" and %d more", len(paths)-5)
+			paths = paths[:5]
+		}
+		fmt.Fprintf(w, "  %s (%d): %s%s\n", reason, report.SkippedByReason[reason], strings.Join(paths, ", "), more)
+	}
+	if n := len(report.Truncated); n > 0 {
+		paths, more := report.Truncated, ""
+		if n > 5 {
+			paths, more = paths[:5], fmt.Sprintf(" and %d more", n-5)
+		}
+		fmt.Fprintf(w, "  truncated (%d): %s%s\n", n, strings.Join(paths, ", "), more)
+	}
+	if n := len(report.Stubbed); n > 0 {
+		paths, more := report.Stubbed, ""
+		if n > 5 {
+			paths, more = paths[:5], fmt.Sprintf(" and %d more", n-5)
+		}
+		fmt.Fprintf(w, "  stubbed (%d): %s%s\n", n, strings.Join(paths, ", "), more)
+	}
+	for _, warn := range report.Warnings {
+		fmt.Fprintf(w, "warn: %s\n", warn)
+	}
+	for _, e := range report.Errors {
+		fmt.Fprintf(w, "error: %s\n", e)
+	}
+}
+
+// runPackDryRun prints the decision for every candidate path of cfg,
+// without reading any file content.
+func runPackDryRun(cfg pack.Config) error {
+	decisions, err := pack.DryRun(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+	if packJSON {
+		if decisions == nil {
+			decisions = []pack.Decision{}
+		}
+		data, err := json.MarshalIndent(decisions, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DECISION\tRULE\tPATTERN\tSOURCE\tSIZE\tPATH")
+	included := 0
+	for _, d := range decisions {
+		decision, r := "exclude", d.Reason()
+		if d.Included {
+			decision, r = "include", pack.Step{}
+			included++
+		}
+		p := d.Path
+		if d.IsDir {
+			p += "/"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\n", decision, dash(string(r.Rule)), dash(r.Pattern), dash(r.Source), d.Size, p)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%d included, %d excluded (binary files are only detected when packing)\n",
+		included, len(decisions)-included)
+	return nil
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
 func normalizeSlice(in []string) []string {
 	var out []string
 	for _, s := range in {
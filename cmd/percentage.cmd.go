@@ -1,22 +1,178 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
 	"github.com/parsabordbar/ctx3/analyzer"
+	"github.com/parsabordbar/ctx3/filetree"
 	"github.com/spf13/cobra"
+	"github.com/toon-format/toon-go"
+)
+
+var (
+	percentageBy               string
+	percentageTop              int
+	percentageJSON             bool
+	percentageTOON             bool
+	percentageRespectGit       bool
+	percentageIncludeVendored  bool
+	percentageIncludeGenerated bool
+	percentageHistory          bool
+	percentageEvery            string
+	percentageRev              string
+	percentageLimit            int
+	percentageCSV              bool
+	percentageShowHidden       bool
 )
 
+type percentageOutput struct {
+	By     analyzer.ShareMetric `json:"by" toon:"by"`
+	Total  int64                `json:"total" toon:"total"`
+	Shares []analyzer.Share     `json:"shares" toon:"shares"`
+}
+
 var percentageCmd = &cobra.Command{
-	Use:   "percentage",
+	Use:   "percentage [directory]",
 	Short: "Show file format percentages in the project",
-	Args:  cobra.MaximumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	Long: `Show how much of the project each language makes up.
+
+Languages are weighed by --by: bytes (default), lines, code (non-blank,
+non-comment lines), files or tokens (an LLM token estimate). Vendored and
+generated files are left out unless asked for.
+
+With --history the language mix is computed at commits sampled from the
+first-parent git history (one per --every interval, read from the object
+database without checking anything out), oldest first.
+
+Output formats:
+  - Default: Bar chart (a stacked chart per commit with --history)
+  - JSON (-j): Machine-readable JSON format
+  - TOON (-t): Token-Oriented Object Notation (compact, LLM-optimized)
+  - CSV (--csv): commit,time,language,value,percent rows (--history only)`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
 		dir := "."
 		if len(args) > 0 {
 			dir = args[0]
 		}
-		ctx := analyzer.AnalyzeProject(dir)
-		counts := analyzer.CollectFileStats(&ctx)
-		filePercentages := analyzer.FilePercentage(counts)
-		analyzer.PrettyPrintPercentage(filePercentages)
+		by, err := analyzer.ParseShareMetric(percentageBy)
+		if err != nil {
+			return err
+		}
+		if percentageTop < 0 {
+			return fmt.Errorf("invalid --top: %d (expected >= 0)", percentageTop)
+		}
+
+		rules, err := filetree.LoadIgnoreRules(dir, percentageShowHidden)
+		if err != nil {
+			return err
+		}
+		if percentageHistory {
+			return runPercentageHistory(dir, by, rules)
+		}
+		if percentageCSV {
+			return fmt.Errorf("--csv requires --history")
+		}
+
+		ctx, err := analyzer.AnalyzeProjectWithOptions(context.Background(), dir, analyzer.AnalyzeOptions{RespectGitignore: percentageRespectGit, Ignores: rules})
+		if err != nil {
+			return err
+		}
+		shares := analyzer.LanguageShares(ctx.Files, analyzer.ShareOptions{
+			By:               by,
+			Top:              percentageTop,
+			IncludeVendored:  percentageIncludeVendored,
+			IncludeGenerated: percentageIncludeGenerated,
+		})
+
+		out := percentageOutput{By: by, Shares: shares}
+		for _, s := range shares {
+			out.Total += s.Value
+		}
+		switch {
+		case percentageTOON:
+			encoded, err := toon.Marshal(out, toon.WithLengthMarkers(true))
+			if err != nil {
+				return fmt.Errorf("encoding TOON: %w", err)
+			}
+			fmt.Println(string(encoded))
+		case percentageJSON:
+			data, err := json.MarshalIndent(out, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+		default:
+			analyzer.PrettyPrintPercentage(os.Stdout, shares, by)
+		}
+		return nil
 	},
 }
+
+func runPercentageHistory(dir string, by analyzer.ShareMetric, rules *filetree.IgnoreRules) error {
+	every, err := analyzer.ParseEvery(percentageEvery)
+	if err != nil {
+		return err
+	}
+	if percentageLimit < 0 {
+		return fmt.Errorf("invalid --limit: %d (expected >= 0)", percentageLimit)
+	}
+	points, err := analyzer.LanguageHistory(context.Background(), dir, analyzer.HistoryOptions{
+		Rev:     percentageRev,
+		Every:   every,
+		Limit:   percentageLimit,
+		Ignores: rules,
+		Share: analyzer.ShareOptions{
+			By:               by,
+			Top:              percentageTop,
+			IncludeVendored:  percentageIncludeVendored,
+			IncludeGenerated: percentageIncludeGenerated,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	out := struct {
+		By     analyzer.ShareMetric    `json:"by" toon:"by"`
+		Points []analyzer.HistoryPoint `json:"points" toon:"points"`
+	}{by, points}
+	switch {
+	case percentageCSV:
+		return analyzer.WriteHistoryCSV(os.Stdout, points)
+	case percentageTOON:
+		encoded, err := toon.Marshal(out, toon.WithLengthMarkers(true))
+		if err != nil {
+			return fmt.Errorf("encoding TOON: %w", err)
+		}
+		fmt.Println(string(encoded))
+	case percentageJSON:
+		data, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	default:
+		analyzer.PrettyPrintHistory(os.Stdout, points, 60)
+	}
+	return nil
+}
+
+func init() {
+	percentageCmd.Flags().StringVar(&percentageBy, "by", "bytes", "Weigh languages by bytes|lines|code|files|tokens")
+	percentageCmd.Flags().IntVar(&percentageTop, "top", 0, "Show the N largest languages and fold the rest into \"other\" (0 = all)")
+	percentageCmd.Flags().BoolVarP(&percentageJSON, "json", "j", false, "Output as JSON")
+	percentageCmd.Flags().BoolVarP(&percentageTOON, "toon", "t", false, "Output as TOON")
+	percentageCmd.Flags().BoolVar(&percentageRespectGit, "respect-gitignore", true, "Respect .gitignore rules")
+	percentageCmd.Flags().BoolVar(&percentageIncludeVendored, "include-vendored", false, "Count vendored files (vendor/, third_party/, ...)")
+	percentageCmd.Flags().BoolVar(&percentageIncludeGenerated, "include-generated", false, "Count generated files (lockfiles, *.pb.go, minified JS, ...)")
+	percentageCmd.Flags().BoolVar(&percentageShowHidden, "show-hidden-defaults", false, "Don't apply the built-in ignore list (build outputs, caches, ...)")
+	percentageCmd.Flags().BoolVar(&percentageHistory, "history", false, "Show the language mix over the git history")
+	percentageCmd.Flags().StringVar(&percentageEvery, "every", "1w", "Sampling interval for --history (e.g. 12h, 1d, 2w)")
+	percentageCmd.Flags().StringVar(&percentageRev, "rev", "HEAD", "Revision --history starts from")
+	percentageCmd.Flags().IntVar(&percentageLimit, "limit", 0, "Maximum number of --history samples (0 = all)")
+	percentageCmd.Flags().BoolVar(&percentageCSV, "csv", false, "Output --history as CSV")
+}
@@ -1,26 +1,114 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/parsabordbar/ctx3/filetree"
 	"github.com/spf13/cobra"
 )
 
+var (
+	printDepth      int
+	printDirsFirst  bool
+	printHuman      bool
+	printRespectGit bool
+	printInclude    []string
+	printIgnore     []string
+	printFilesOnly  bool
+	printDirsOnly   bool
+	printCollapse   bool
+	printFormat     string
+	printShowHidden bool
+	printWatch      bool
+)
+
 var printCmd = &cobra.Command{
 	Use:   "print [directory]",
 	Short: "Print a directory tree",
-	Args:  cobra.MaximumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	Long: `Print a directory tree with file sizes and aggregated directory totals.
+
+--include and --ignore take the same globs as pack. Directories that end up
+with no included files are dropped from the tree.
+
+Output formats (--format):
+  - text: box-drawing tree (default)
+  - json: the tree as nested objects, for tooling
+  - md: nested Markdown list, for docs
+  - mermaid, dot: diagrams (Mermaid flowchart, Graphviz)
+  - html: self-contained page with a collapsible tree`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
 		dir := "."
 		if len(args) > 0 {
 			dir = args[0]
 		}
-		fmt.Println("┌── 📂 Project structure:")
-		filetree.PrintTree(dir, "")
+		if printDepth < 0 {
+			return fmt.Errorf("invalid --depth: %d (expected >= 0)", printDepth)
+		}
+		if printFilesOnly && printDirsOnly {
+			return fmt.Errorf("--files-only and --dirs-only are mutually exclusive")
+		}
+		switch printFormat {
+		case filetree.FormatText, filetree.FormatJSON, filetree.FormatMD, filetree.FormatMermaid, filetree.FormatDOT, filetree.FormatHTML:
+		default:
+			return fmt.Errorf("invalid --format: %s (expected text|json|md|mermaid|dot|html)", printFormat)
+		}
+		if printFilesOnly && printFormat != filetree.FormatText {
+			return fmt.Errorf("--files-only only applies to --format text")
+		}
+
+		if printWatch {
+			return watchLoop("print", dir, printShowHidden, nil, func(context.Context, []string) error {
+				return printTree(os.Stdout, dir)
+			})
+		}
+		return printTree(os.Stdout, dir)
 	},
 }
 
+// printTree builds the tree of dir and renders it to w as the flags say.
+func printTree(w io.Writer, dir string) error {
+	rules, err := filetree.LoadIgnoreRules(dir, printShowHidden)
+	if err != nil {
+		return err
+	}
+	tree, err := filetree.Build(context.Background(), dir, filetree.Options{
+		Rules:            rules,
+		IncludeGlobs:     normalizeSlice(printInclude),
+		IgnoreGlobs:      normalizeSlice(printIgnore),
+		RespectGitignore: printRespectGit,
+	})
+	if err != nil {
+		return err
+	}
+	if printFormat == filetree.FormatText && !printFilesOnly {
+		fmt.Fprintln(w, "┌── 📂 Project structure:")
+	}
+	return filetree.Render(w, tree, printFormat, filetree.RenderOptions{
+		Depth:     printDepth,
+		DirsFirst: printDirsFirst,
+		Human:     printHuman,
+		DirsOnly:  printDirsOnly,
+		FilesOnly: printFilesOnly,
+		Collapse:  printCollapse,
+	})
+}
+
 func init() {
+	printCmd.Flags().IntVarP(&printDepth, "depth", "L", 0, "Descend at most N levels (0 = unlimited)")
+	printCmd.Flags().BoolVar(&printDirsFirst, "dirs-first", false, "List directories before files")
+	printCmd.Flags().BoolVarP(&printHuman, "human", "H", false, "Print sizes in human-readable units (KiB, MiB, ...)")
+	printCmd.Flags().BoolVar(&printRespectGit, "respect-gitignore", false, "Respect .gitignore rules")
+	printCmd.Flags().StringSliceVar(&printInclude, "include", nil, "Comma-separated globs of files to show")
+	printCmd.Flags().StringSliceVar(&printIgnore, "ignore", nil, "Comma-separated globs to hide")
+	printCmd.Flags().BoolVar(&printFilesOnly, "files-only", false, "Print a flat list of file paths instead of a tree")
+	printCmd.Flags().BoolVar(&printDirsOnly, "dirs-only", false, "Show directories only")
+	printCmd.Flags().BoolVar(&printCollapse, "collapse", false, "Join chains of single-directory folders on one line (a/b/c)")
+	printCmd.Flags().StringVarP(&printFormat, "format", "f", "text", "Output format: text|json|md|mermaid|dot|html")
+	printCmd.Flags().BoolVar(&printWatch, "watch", false, "Print the tree again whenever files change")
+	printCmd.Flags().BoolVar(&printShowHidden, "show-hidden-defaults", false, "Don't apply the built-in ignore list (node_modules, build outputs, caches, ...)")
 	rootCmd.AddCommand(printCmd)
 }
@@ -22,10 +22,16 @@ var rootCmd = &cobra.Command{
 		fmt.Println(icon)
 		fmt.Println("ctx3 is a CLI tool to analyze project structure.")
 		fmt.Println("┌── Available commands:")
+		fmt.Println("├── cache stats|clear        Inspect or clear the on-disk cache")
 		fmt.Println("├── context [directory]      Analyze project context for LLMs")
+		fmt.Println("├── diff <old> <new>         Show what changed between two packs (or --rev A --rev B)")
+		fmt.Println("├── explain <path>           Show why pack includes or excludes a path")
+		fmt.Println("├── mcp [directory]          Serve project context to editors and agents over MCP (stdio)")
 		fmt.Println("├── print [directory]        Print the file tree of the specified directory")	
 		fmt.Println("├── pack [directory]     	  Pack a repository into a single AI-friendly file")	
-		fmt.Println("└── percentage [directory]   Percentage of file types present in the specified directory")
+		fmt.Println("├── percentage [directory]   Percentage of file types present in the specified directory")
+		fmt.Println("├── serve                    Serve tree, context, pack and more over a local HTTP API")
+		fmt.Println("└── stats [directory]        Code, comment and blank lines per language")
 		fmt.Println()
 	},
 }
@@ -33,6 +39,8 @@ var rootCmd = &cobra.Command{
 func init() {
 	contextCmd.Flags().BoolVarP(&analyzer.OutputJSON, "json", "j", false, "Output as JSON")
 	contextCmd.Flags().BoolVarP(&analyzer.OutputTOON, "toon", "t", false, "Output as TOON")
+	contextCmd.Flags().BoolVar(&contextWatch, "watch", false, "Analyze again whenever files change")
+	contextCmd.Flags().BoolVar(&contextShowHidden, "show-hidden-defaults", false, "Don't apply the built-in ignore list (build outputs, caches, ...)")
 	rootCmd.AddCommand(contextCmd)
 	rootCmd.AddCommand(percentageCmd)
 }
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/parsabordbar/ctx3/serve"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveAddr        string
+	serveRoots       []string
+	serveConcurrency int
+	serveMaxInFlight int
+	serveNoCache     bool
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve ctx3 over a local HTTP API",
+	Long: `Serve ctx3 over HTTP. Every endpoint is a GET taking the parameters of
+the matching command, with underscores for dashes (?max_file_bytes=...).
+
+Endpoints:
+  /roots       The served roots and their names
+  /tree        The file tree (JSON, or ?format=text|md|mermaid|dot|html)
+  /context     The output of ctx3 context, as JSON
+  /pack        The packed document, streamed; totals follow in trailers
+               (?dry_run returns the include/exclude decisions as JSON)
+  /percentage  Language shares, or their history with ?history
+  /functions   Functions and methods, optionally filtered by ?name=
+
+Only the directories given with --root (default: current) are served.
+A request picks one with ?root=<name> (default: the first) and a
+directory inside it with ?path=; paths leaving the root are refused.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		srv, err := serve.New(serve.Options{
+			Roots:       serveRoots,
+			Concurrency: serveConcurrency,
+			MaxInFlight: serveMaxInFlight,
+			Cache:       openCache(serveNoCache),
+		})
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		hs := &http.Server{
+			Addr:              serveAddr,
+			Handler:           srv,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		for _, r := range srv.Roots() {
+			fmt.Fprintf(os.Stderr, "ctx3 serve: root %s = %s\n", r.Name, r.Path)
+		}
+		fmt.Fprintf(os.Stderr, "ctx3 serve: listening on %s\n", serveAddr)
+
+		errc := make(chan error, 1)
+		go func() { errc <- hs.ListenAndServe() }()
+		select {
+		case err := <-errc:
+			return err
+		case <-ctx.Done():
+		}
+		fmt.Fprintln(os.Stderr, "ctx3 serve: shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := hs.Shutdown(shutdownCtx); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveAddr, "addr", ":7077", "Address to listen on")
+	serveCmd.Flags().StringArrayVar(&serveRoots, "root", []string{"."}, "Directory that may be served (repeatable)")
+	serveCmd.Flags().IntVar(&serveConcurrency, "concurrency", 0, "Max parallel file reads per pack request (0 = number of CPUs)")
+	serveCmd.Flags().IntVar(&serveMaxInFlight, "max-in-flight", 0, "Max requests doing work at once (0 = number of CPUs)")
+	serveCmd.Flags().BoolVar(&serveNoCache, "no-cache", false, "Don't use the on-disk cache (see ctx3 cache)")
+	rootCmd.AddCommand(serveCmd)
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/parsabordbar/ctx3/analyzer"
+	"github.com/spf13/cobra"
+	"github.com/toon-format/toon-go"
+)
+
+var (
+	statsJSON bool
+	statsTOON bool
+)
+
+type statsOutput struct {
+	Languages []analyzer.LanguageStat `json:"languages" toon:"languages"`
+	Total     analyzer.LanguageStat   `json:"total" toon:"total"`
+}
+
+var statsCmd = &cobra.Command{
+	Use:   "stats [directory]",
+	Short: "Count code, comment and blank lines per language (cloc-style)",
+	Long: `Count code, comment and blank lines per language using language-aware
+comment syntax, including block comments.
+
+Output formats:
+  - Default: cloc-like table
+  - JSON (-j): Machine-readable JSON format
+  - TOON (-t): Token-Oriented Object Notation (compact, LLM-optimized)`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := "."
+		if len(args) > 0 {
+			dir = args[0]
+		}
+		ctx := analyzer.AnalyzeProject(dir)
+
+		out := statsOutput{Languages: ctx.Languages, Total: analyzer.LanguageStat{Language: "SUM"}}
+		for _, l := range ctx.Languages {
+			out.Total.Files += l.Files
+			out.Total.Code += l.Code
+			out.Total.Comment += l.Comment
+			out.Total.Blank += l.Blank
+		}
+
+		switch {
+		case statsTOON:
+			encoded, err := toon.Marshal(out, toon.WithLengthMarkers(true))
+			if err != nil {
+				return fmt.Errorf("encoding TOON: %w", err)
+			}
+			fmt.Println(string(encoded))
+		case statsJSON:
+			data, err := json.MarshalIndent(out, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+		default:
+			// numbers are right-aligned, the language column is padded by hand
+			width := len("Language")
+			for _, l := range out.Languages {
+				width = max(width, len(l.Language))
+			}
+			rule := strings.Repeat("-", width)
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+			fmt.Fprintf(tw, "%-*s\tfiles\tblank\tcomment\tcode\t\n", width, "Language")
+			fmt.Fprintf(tw, "%s\t-----\t-----\t-------\t----\t\n", rule)
+			for _, l := range append(out.Languages, out.Total) {
+				if l.Language == out.Total.Language {
+					fmt.Fprintf(tw, "%s\t-----\t-----\t-------\t----\t\n", rule)
+				}
+				fmt.Fprintf(tw, "%-*s\t%d\t%d\t%d\t%d\t\n", width, l.Language, l.Files, l.Blank, l.Comment, l.Code)
+			}
+			tw.Flush()
+		}
+		return nil
+	},
+}
+
+func init() {
+	statsCmd.Flags().BoolVarP(&statsJSON, "json", "j", false, "Output as JSON")
+	statsCmd.Flags().BoolVarP(&statsTOON, "toon", "t", false, "Output as TOON")
+	rootCmd.AddCommand(statsCmd)
+}
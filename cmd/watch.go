@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/parsabordbar/ctx3/filetree"
+	"github.com/parsabordbar/ctx3/watch"
+)
+
+// watchLoop calls build once, then again after every debounced batch of
+// changes below root, until interrupted. Directories hidden by the ignore
+// list aren't watched; the list is reloaded after every build, so edits to
+// .ctx3.yaml and .ctx3ignore apply live. A failing first build is returned; later build
+// errors are logged and watching goes on.
+// Changes to the files in outputs (and their temporary siblings from
+// writeFileAtomic) are ignored.
+func watchLoop(name, root string, showHidden bool, outputs []string, build func(ctx context.Context, changed []string) error) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var rules atomic.Pointer[filetree.IgnoreRules]
+	reload := func() {
+		if r, err := filetree.LoadIgnoreRules(root, showHidden); err == nil {
+			rules.Store(r)
+		} else {
+			fmt.Fprintf(os.Stderr, "ctx3 %s: %v\n", name, err)
+		}
+	}
+	reload()
+
+	ignored := map[string]bool{}
+	for _, o := range outputs {
+		if rel, ok := relInside(root, o); ok {
+			ignored[rel] = true
+		}
+	}
+
+	w, err := watch.New(root, watch.Options{
+		SkipDir: func(rel string) bool {
+			_, skip := rules.Load().Match(rel, true)
+			return skip || filepath.Base(rel) == "node_modules"
+		},
+		Ignore: func(rel string) bool {
+			for out := range ignored {
+				dir, base := filepath.Split(out)
+				if rel == out || strings.HasPrefix(rel, dir+"."+base+".tmp") {
+					return true
+				}
+			}
+			return false
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := build(ctx, nil); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "ctx3 %s: watching %s (%s), Ctrl-C to stop\n", name, root, w.Backend())
+	return w.Run(ctx, func(changed []string) error {
+		reload()
+		if err := build(ctx, changed); err != nil && ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "ctx3 %s: %v\n", name, err)
+		}
+		return nil
+	})
+}
+
+// writeFileAtomic replaces path with data through a rename, so readers
+// never see a half-written file.
+func writeFileAtomic(path string, data []byte) error {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := os.CreateTemp(dir, "."+base+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// relInside returns p relative to root, '/'-separated, when p lies inside
+// root.
+func relInside(root, p string) (string, bool) {
+	if p == "" {
+		return "", false
+	}
+	rootAbs, err := filepath.Abs(root)
+	if err != nil {
+		return "", false
+	}
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(rootAbs, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// globEscape quotes the glob metacharacters of a literal path.
+func globEscape(p string) string {
+	var b strings.Builder
+	for _, r := range p {
+		if strings.ContainsRune(`*?[]{}\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,200 @@
+package filetree
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// Tree output formats for Render.
+const (
+	FormatText    = "text"
+	FormatJSON    = "json"
+	FormatMD      = "md"
+	FormatMermaid = "mermaid"
+	FormatDOT     = "dot"
+	FormatHTML    = "html"
+)
+
+// Render writes n in one of the Format* formats. Depth, DirsFirst,
+// DirsOnly and Collapse apply to every format; FilesOnly only to text.
+func Render(w io.Writer, n *Node, format string, opts RenderOptions) error {
+	switch format {
+	case FormatText, "":
+		return RenderText(w, n, opts)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(view(n, opts, 0))
+	case FormatMD:
+		return renderMD(w, view(n, opts, 0), "", opts)
+	case FormatMermaid:
+		return renderMermaid(w, view(n, opts, 0), opts)
+	case FormatDOT:
+		return renderDOT(w, view(n, opts, 0), opts)
+	case FormatHTML:
+		return renderHTML(w, view(n, opts, 0), opts)
+	}
+	return fmt.Errorf("unsupported format: %s (expected text|json|md|mermaid|dot|html)", format)
+}
+
+// view copies n with the structural render options applied, so the
+// exporters only have to walk Children.
+func view(n *Node, opts RenderOptions, depth int) *Node {
+	cp := *n
+	cp.Children = nil
+	cp.index = nil
+	if opts.Depth > 0 && depth >= opts.Depth {
+		return &cp
+	}
+	for _, c := range visibleChildren(n, opts) {
+		c, name, level := collapse(c, depth+1, opts)
+		child := view(c, opts, level)
+		child.Name = name
+		cp.Children = append(cp.Children, child)
+	}
+	return &cp
+}
+
+// displayName is n's name as the exporters show it: directories end in
+// a slash and links point at their target, e.g. "docs -> ../shared/docs".
+func displayName(n *Node) string {
+	name := n.Name
+	if n.IsDir {
+		name += "/"
+	}
+	if n.Target != "" {
+		name += " -> " + n.Target
+	}
+	return name
+}
+
+func renderMD(w io.Writer, n *Node, indent string, opts RenderOptions) error {
+	for _, c := range n.Children {
+		name := "`" + displayName(c) + "`"
+		if c.IsDir {
+			name = "**" + name + "**"
+		}
+		if _, err := fmt.Fprintf(w, "%s- %s (%s)\n", indent, name, sizeLabel(c.Size, opts.Human)); err != nil {
+			return err
+		}
+		if err := renderMD(w, c, indent+"  ", opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkIDs numbers the nodes of a tree depth-first, root first, and calls
+// fn with every node, its id and its parent's id (-1 for the root).
+func walkIDs(n *Node, fn func(n *Node, id, parent int) error) error {
+	next := 0
+	var walk func(n *Node, parent int) error
+	walk = func(n *Node, parent int) error {
+		id := next
+		next++
+		if err := fn(n, id, parent); err != nil {
+			return err
+		}
+		for _, c := range n.Children {
+			if err := walk(c, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(n, -1)
+}
+
+func renderMermaid(w io.Writer, n *Node, opts RenderOptions) error {
+	if _, err := fmt.Fprintln(w, "graph LR"); err != nil {
+		return err
+	}
+	return walkIDs(n, func(c *Node, id, parent int) error {
+		label := strings.ReplaceAll(displayName(c), `"`, "#quot;") + "<br/>" + sizeLabel(c.Size, opts.Human)
+		shape := `n%d["%s"]`
+		if c.IsDir {
+			shape = `n%d[/"%s"/]`
+		}
+		node := fmt.Sprintf(shape, id, label)
+		if parent < 0 {
+			_, err := fmt.Fprintf(w, "  %s\n", node)
+			return err
+		}
+		_, err := fmt.Fprintf(w, "  n%d --> %s\n", parent, node)
+		return err
+	})
+}
+
+func renderDOT(w io.Writer, n *Node, opts RenderOptions) error {
+	if _, err := fmt.Fprint(w, "digraph tree {\n  rankdir=LR;\n  node [fontname=\"monospace\"];\n"); err != nil {
+		return err
+	}
+	err := walkIDs(n, func(c *Node, id, parent int) error {
+		shape := "note"
+		if c.IsDir {
+			shape = "folder"
+		}
+		label := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(displayName(c)) + `\n` + sizeLabel(c.Size, opts.Human)
+		if _, err := fmt.Fprintf(w, "  n%d [shape=%s, label=\"%s\"];\n", id, shape, label); err != nil {
+			return err
+		}
+		if parent >= 0 {
+			_, err := fmt.Fprintf(w, "  n%d -> n%d;\n", parent, id)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, "}")
+	return err
+}
+
+const htmlHead = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+<style>
+body { font-family: ui-monospace, SFMono-Regular, Menlo, monospace; font-size: 14px; }
+ul { list-style: none; margin: 0; padding-left: 1.4em; }
+summary { cursor: pointer; font-weight: bold; }
+.size { color: #888; margin-left: .6em; }
+</style>
+</head>
+<body>
+`
+
+// renderHTML writes a self-contained page: directories are <details>
+// elements, so the tree folds without any script.
+func renderHTML(w io.Writer, n *Node, opts RenderOptions) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, htmlHead, html.EscapeString(n.Name))
+	var walk func(n *Node, open bool)
+	walk = func(n *Node, open bool) {
+		attr := ""
+		if open {
+			attr = " open"
+		}
+		fmt.Fprintf(&b, "<details%s><summary>%s<span class=\"size\">%s</span></summary>\n<ul>\n",
+			attr, html.EscapeString(displayName(n)), html.EscapeString(sizeLabel(n.Size, opts.Human)))
+		for _, c := range n.Children {
+			b.WriteString("<li>")
+			if c.IsDir {
+				walk(c, false)
+			} else {
+				fmt.Fprintf(&b, "%s<span class=\"size\">%s</span>", html.EscapeString(displayName(c)), html.EscapeString(sizeLabel(c.Size, opts.Human)))
+			}
+			b.WriteString("</li>\n")
+		}
+		b.WriteString("</ul>\n</details>\n")
+	}
+	walk(n, true)
+	b.WriteString("</body>\n</html>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
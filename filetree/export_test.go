@@ -0,0 +1,63 @@
+package filetree
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func exportTree() *Node {
+	root := NewRoot()
+	root.Name = "proj"
+	root.Insert("cmd/main.go", false, 100)
+	root.Insert(`docs/"quoted".md`, false, 5)
+	root.Insert("go.mod", false, 20)
+	root.Sort()
+	root.Summarize()
+	return root
+}
+
+func TestRender_Formats(t *testing.T) {
+	render := func(format string, opts RenderOptions) string {
+		t.Helper()
+		var buf bytes.Buffer
+		if err := Render(&buf, exportTree(), format, opts); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		return buf.String()
+	}
+
+	var decoded Node
+	if err := json.Unmarshal([]byte(render(FormatJSON, RenderOptions{Depth: 1})), &decoded); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if decoded.Name != "proj" || decoded.Size != 125 || len(decoded.Children) != 3 || decoded.Children[0].Children != nil {
+		t.Fatalf("unexpected json tree: %+v", decoded)
+	}
+
+	md := render(FormatMD, RenderOptions{})
+	if !strings.Contains(md, "- **`cmd/`** (100 bytes)\n  - `main.go` (100 bytes)\n") {
+		t.Fatalf("unexpected markdown:\n%s", md)
+	}
+
+	mermaid := render(FormatMermaid, RenderOptions{})
+	if !strings.HasPrefix(mermaid, "graph LR\n") || !strings.Contains(mermaid, "n1 --> n2[\"main.go<br/>100 bytes\"]") || !strings.Contains(mermaid, "#quot;quoted#quot;") {
+		t.Fatalf("unexpected mermaid:\n%s", mermaid)
+	}
+
+	dot := render(FormatDOT, RenderOptions{Human: true})
+	if !strings.Contains(dot, `n0 [shape=folder, label="proj/\n125 B"];`) || !strings.Contains(dot, `\"quoted\".md`) || !strings.HasSuffix(dot, "}\n") {
+		t.Fatalf("unexpected dot:\n%s", dot)
+	}
+
+	page := render(FormatHTML, RenderOptions{DirsOnly: true})
+	if !strings.Contains(page, "<details open><summary>proj/") || strings.Contains(page, "go.mod") || !strings.Contains(page, "</html>") {
+		t.Fatalf("unexpected html:\n%s", page)
+	}
+
+	var buf bytes.Buffer
+	if err := Render(&buf, exportTree(), "svg", RenderOptions{}); err == nil {
+		t.Fatalf("expected error for unknown format")
+	}
+}
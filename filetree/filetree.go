@@ -1,58 +1,241 @@
 package filetree
 
 import (
-	_ "embed"
-	"encoding/json"
+	"context"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
-	"slices"
+	"sort"
+	"strings"
+
+	ignore "github.com/sabhiram/go-gitignore"
 )
 
-//go:embed ignore.json
-var ignoreFile []byte
+// Node is a file or directory in a tree built by Build or assembled with
+// Insert. The root of a built tree is named after the directory.
+type Node struct {
+	Name  string `json:"name"`
+	Path  string `json:"path"` // relative to the root, '/'-separated; "." for the root
+	IsDir bool   `json:"isDir"`
+	// Size is the file size; for directories, the total size of every file below.
+	Size int64 `json:"size"`
+	// FileCount is the number of files below a directory, recursively.
+	FileCount int     `json:"fileCount,omitempty"`
+	Children  []*Node `json:"children,omitempty"`
+	// Err is set on entries that could not be read (e.g. a directory
+	// without permission); they are kept in the tree rather than aborting it.
+	Err string `json:"error,omitempty"`
+	// Note annotates an entry for whoever assembled the tree, e.g.
+	// "truncated" for a file pack only excerpts.
+	Note string `json:"note,omitempty"`
+	// Target is where a symbolic link points, as written in the link.
+	Target string `json:"target,omitempty"`
+
+	index map[string]*Node // children by name, built lazily by Insert
+}
 
-type DirIgnores struct {
-	Dirs []string `json:"dirs"`
+type Options struct {
+	// Rules hides entries by name or path. nil loads them with
+	// LoadIgnoreRules(root, false).
+	Rules *IgnoreRules
+	// IncludeGlobs keeps only the files matching one of the globs;
+	// directories left empty are dropped.
+	IncludeGlobs []string
+	// IgnoreGlobs leaves out files and directories matching one of the globs.
+	IgnoreGlobs []string
+	// RespectGitignore leaves out what the root .gitignore ignores.
+	RespectGitignore bool
 }
 
-func readIgnoreJson() []string {
-	var fields DirIgnores
-	err := json.Unmarshal(ignoreFile, &fields)
+// walker carries the filters of one Build.
+type walker struct {
+	ctx   context.Context
+	opts  Options
+	rules *IgnoreRules
+	gitIg *ignore.GitIgnore
+}
+
+// Build walks root and returns its tree with children sorted by name and
+// directory sizes aggregated. Symlinks are not followed: a link, broken or
+// not, is listed like a file with its Target set. It stops with ctx's error when ctx is done.
+func Build(ctx context.Context, root string, opts Options) (*Node, error) {
+	rules := opts.Rules
+	if rules == nil {
+		var err error
+		if rules, err = LoadIgnoreRules(root, false); err != nil {
+			return nil, err
+		}
+	}
+	info, err := os.Stat(root)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	return fields.Dirs
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+
+	w := walker{ctx: ctx, opts: opts, rules: rules}
+	tree := NewRoot()
+	if abs, err := filepath.Abs(root); err == nil {
+		tree.Name = filepath.Base(abs)
+		if opts.RespectGitignore {
+			w.gitIg = LoadGitignore(abs)
+		}
+	}
+	if err := w.build(tree, root); err != nil {
+		return nil, err
+	}
+	tree.Summarize()
+	return tree, nil
 }
 
-func PrintTree(root string, prefix string) {
-	entries, err := os.ReadDir(root)
-	if err != nil {
-		fmt.Println("Error reading directory:", err)
-		return
+// skip applies the Options filters to one entry.
+func (w *walker) skip(rel string, isDir bool) bool {
+	if w.rules.Ignores(rel, isDir) || gitignored(w.gitIg, rel, isDir) || MatchAny(rel, w.opts.IgnoreGlobs) {
+		return true
 	}
+	return !isDir && len(w.opts.IncludeGlobs) > 0 && !MatchAny(rel, w.opts.IncludeGlobs)
+}
 
-	for i, entry := range entries {
-		if slices.Contains(readIgnoreJson(), entry.Name()) {
+func (w *walker) build(dir *Node, abs string) error {
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(abs)
+	if err != nil {
+		dir.Err = err.Error()
+	}
+	for _, entry := range entries {
+		rel := join(dir.Path, entry.Name())
+		if w.skip(rel, entry.IsDir()) {
 			continue
 		}
+		child := &Node{Name: entry.Name(), Path: rel, IsDir: entry.IsDir()}
+		// Info is an Lstat, so it succeeds on broken symlinks too
+		if info, err := entry.Info(); err == nil {
+			if !child.IsDir {
+				child.Size = info.Size()
+			}
+			if info.Mode()&os.ModeSymlink != 0 {
+				child.Target, _ = os.Readlink(filepath.Join(abs, entry.Name()))
+			}
+		} else {
+			child.Err = err.Error()
+		}
+		if child.IsDir {
+			if err := w.build(child, filepath.Join(abs, entry.Name())); err != nil {
+				return err
+			}
+			if len(w.opts.IncludeGlobs) > 0 && len(child.Children) == 0 {
+				continue
+			}
+		}
+		dir.Children = append(dir.Children, child)
+	}
+	return nil
+}
 
-		path := filepath.Join(root, entry.Name())
-		info, _ := os.Stat(path)
+// NewRoot returns an empty root directory for assembling a tree with Insert.
+func NewRoot() *Node {
+	return &Node{Name: ".", Path: ".", IsDir: true}
+}
 
-		isLast := i == len(entries)-1
+// Insert adds the file or directory at rel ('/'-separated) below n,
+// creating missing parent directories, and returns its node. Inserting an
+// existing path returns the existing node. Call Summarize afterwards to
+// update directory totals.
+func (n *Node) Insert(rel string, isDir bool, size int64) *Node {
+	rel = path.Clean(rel)
+	if rel == "." {
+		return n
+	}
+	cur := n
+	parts := strings.Split(rel, "/")
+	for i, part := range parts {
+		last := i == len(parts)-1
+		if cur.index == nil {
+			cur.index = make(map[string]*Node, len(cur.Children))
+			for _, c := range cur.Children {
+				cur.index[c.Name] = c
+			}
+		}
+		child, ok := cur.index[part]
+		if !ok {
+			child = &Node{Name: part, Path: join(cur.Path, part), IsDir: !last || isDir}
+			if last && !isDir {
+				child.Size = size
+			}
+			cur.Children = append(cur.Children, child)
+			cur.index[part] = child
+		}
+		cur = child
+	}
+	return cur
+}
 
-		branch := "├── "
-		newPrefix := prefix + "│   "
-		if isLast {
-			branch = "└── "
-			newPrefix = prefix + "    "
+// Summarize recomputes Size and FileCount of every directory from its
+// files.
+func (n *Node) Summarize() {
+	if !n.IsDir {
+		return
+	}
+	n.Size, n.FileCount = 0, 0
+	for _, c := range n.Children {
+		c.Summarize()
+		n.Size += c.Size
+		if c.IsDir {
+			n.FileCount += c.FileCount
+		} else {
+			n.FileCount++
 		}
+	}
+}
 
-		fmt.Println(prefix + branch + entry.Name() + fmt.Sprintf(" (%d bytes)", info.Size()))
+// Sort orders children by name, recursively.
+func (n *Node) Sort() {
+	sort.Slice(n.Children, func(i, j int) bool { return n.Children[i].Name < n.Children[j].Name })
+	for _, c := range n.Children {
+		c.Sort()
+	}
+}
 
-		if entry.IsDir() {
-			PrintTree(path, newPrefix)
+// Dirs returns the directory children of n in their current order.
+func (n *Node) Dirs() []*Node {
+	var out []*Node
+	for _, c := range n.Children {
+		if c.IsDir {
+			out = append(out, c)
 		}
 	}
-}
\ No newline at end of file
+	return out
+}
+
+// Files returns the file children of n in their current order.
+func (n *Node) Files() []*Node {
+	var out []*Node
+	for _, c := range n.Children {
+		if !c.IsDir {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func join(dir, name string) string {
+	if dir == "." || dir == "" {
+		return name
+	}
+	return dir + "/" + name
+}
+
+// PrintTree prints the tree of root to stdout, indenting every line with
+// prefix.
+func PrintTree(root string, prefix string) {
+	tree, err := Build(context.Background(), root, Options{})
+	if err != nil {
+		fmt.Println("Error reading directory:", err)
+		return
+	}
+	renderText(os.Stdout, tree, prefix, 1, RenderOptions{})
+}
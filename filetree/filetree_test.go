@@ -0,0 +1,92 @@
+package filetree
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
+
+func TestBuild_AggregatesSizesAndSkipsIgnored(t *testing.T) {
+	td := t.TempDir()
+	writeFile(t, filepath.Join(td, "src", "a.go"), "12345")
+	writeFile(t, filepath.Join(td, "src", "lib", "b.go"), "123")
+	writeFile(t, filepath.Join(td, "README.md"), "12")
+	writeFile(t, filepath.Join(td, "node_modules", "x", "index.js"), "ignored")
+	if err := os.Symlink(filepath.Join(td, "missing"), filepath.Join(td, "dangling")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+
+	tree, err := Build(context.Background(), td, Options{})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	var names []string
+	for _, c := range tree.Children {
+		names = append(names, c.Name)
+	}
+	if got := strings.Join(names, ","); got != "README.md,dangling,src" {
+		t.Fatalf("unexpected children: %s", got)
+	}
+	src := tree.Children[2]
+	if src.Size != 8 || src.FileCount != 2 || src.Path != "src" {
+		t.Fatalf("unexpected src totals: %+v", src)
+	}
+	if lib := src.Children[1]; lib.Path != "src/lib" || lib.Size != 3 {
+		t.Fatalf("unexpected src/lib: %+v", lib)
+	}
+	if tree.FileCount != 4 {
+		t.Fatalf("expected 4 files, got %d", tree.FileCount)
+	}
+
+	var buf bytes.Buffer
+	if err := RenderText(&buf, tree, RenderOptions{}); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if lines[len(lines)-1] != "        └── b.go (3 bytes)" || lines[2] != "└── src (8 bytes)" {
+		t.Fatalf("unexpected text tree:\n%s", buf.String())
+	}
+}
+
+func TestInsert_CreatesParentsOnce(t *testing.T) {
+	root := NewRoot()
+	root.Insert("a/b/c.txt", false, 4)
+	root.Insert("a/b/d.txt", false, 6)
+	root.Insert("a/e", true, 0)
+	root.Summarize()
+
+	a := root.Children[0]
+	if len(root.Children) != 1 || !a.IsDir || a.Size != 10 || a.FileCount != 2 {
+		t.Fatalf("unexpected a: %+v", a)
+	}
+	if got := len(a.Dirs()); got != 2 {
+		t.Fatalf("expected 2 dirs under a, got %d", got)
+	}
+	if b := a.Children[0]; b.Path != "a/b" || len(b.Files()) != 2 {
+		t.Fatalf("unexpected a/b: %+v", b)
+	}
+}
+
+func TestBuild_StopsWhenCancelled(t *testing.T) {
+	td := t.TempDir()
+	writeFile(t, filepath.Join(td, "src", "a.go"), "package a\n")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Build(ctx, td, Options{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Build = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,249 @@
+package filetree
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	doublestar "github.com/bmatcuk/doublestar/v4"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed ignore.json
+var ignoreFile []byte
+
+// Sources reported in IgnoreRule.Source besides config file paths.
+const SourceDefaults = "built-in defaults"
+
+// ProjectConfigFile is read from the root of every tree.
+const ProjectConfigFile = ".ctx3.yaml"
+
+// ProjectIgnoreFile is read from the root of every tree after
+// ProjectConfigFile: one pattern per line, see parseIgnoreFile.
+const ProjectIgnoreFile = ".ctx3ignore"
+
+// IgnoreRule is one pattern of an IgnoreRules list. A pattern without a
+// '/' is matched against the entry name, otherwise against the whole
+// '/'-separated path; both may be doublestar globs.
+type IgnoreRule struct {
+	Pattern string `json:"pattern"`
+	Dir     bool   `json:"dir"` // applies to directories; otherwise to files
+	Source  string `json:"source"`
+}
+
+// IgnoreRules hides entries from every command: print, pack, context and
+// percentage all use the same list.
+type IgnoreRules struct {
+	Rules []IgnoreRule
+}
+
+// ignoreConfig is the shape of ignore.json, ~/.config/ctx3/ignore.json and
+// the "ignore" key of .ctx3.yaml.
+type ignoreConfig struct {
+	// Replace drops every rule loaded before this file (including the
+	// defaults) instead of extending them.
+	Replace bool     `json:"replace" yaml:"replace"`
+	Dirs    []string `json:"dirs" yaml:"dirs"`
+	Files   []string `json:"files" yaml:"files"`
+	// Unignore removes earlier rules with exactly these patterns.
+	Unignore []string `json:"unignore" yaml:"unignore"`
+}
+
+var (
+	defaultsOnce  sync.Once
+	defaultsRules []IgnoreRule
+	defaultsErr   error
+)
+
+// DefaultIgnoreRules returns the built-in rules. The embedded ignore.json
+// is parsed once.
+func DefaultIgnoreRules() (*IgnoreRules, error) {
+	defaultsOnce.Do(func() {
+		var cfg ignoreConfig
+		if err := json.Unmarshal(ignoreFile, &cfg); err != nil {
+			defaultsErr = fmt.Errorf("filetree: invalid ignore.json: %w", err)
+			return
+		}
+		r := &IgnoreRules{}
+		r.apply(cfg, SourceDefaults)
+		defaultsRules = r.Rules
+	})
+	if defaultsErr != nil {
+		return nil, defaultsErr
+	}
+	return &IgnoreRules{Rules: append([]IgnoreRule(nil), defaultsRules...)}, nil
+}
+
+// LoadIgnoreRules returns the rules for the tree at root: the built-in
+// defaults (unless showHiddenDefaults is set), extended or replaced by
+// ~/.config/ctx3/ignore.json, then by the "ignore" key of root/.ctx3.yaml
+// and then by root/.ctx3ignore. Missing files are fine; malformed ones are
+// an error.
+func LoadIgnoreRules(root string, showHiddenDefaults bool) (*IgnoreRules, error) {
+	rules := &IgnoreRules{}
+	if !showHiddenDefaults {
+		defaults, err := DefaultIgnoreRules()
+		if err != nil {
+			return nil, err
+		}
+		rules = defaults
+	}
+
+	if p := UserIgnoreFile(); p != "" {
+		data, err := os.ReadFile(p)
+		switch {
+		case err == nil:
+			var cfg ignoreConfig
+			if err := json.Unmarshal(data, &cfg); err != nil {
+				return nil, fmt.Errorf("%s: %w", p, err)
+			}
+			rules.apply(cfg, p)
+		case !errors.Is(err, fs.ErrNotExist):
+			return nil, err
+		}
+	}
+
+	p := filepath.Join(root, ProjectConfigFile)
+	data, err := os.ReadFile(p)
+	switch {
+	case err == nil:
+		var cfg struct {
+			Ignore ignoreConfig `yaml:"ignore"`
+		}
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		rules.apply(cfg.Ignore, p)
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, err
+	}
+
+	p = filepath.Join(root, ProjectIgnoreFile)
+	data, err = os.ReadFile(p)
+	switch {
+	case err == nil:
+		rules.apply(parseIgnoreFile(data), p)
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, err
+	}
+	return rules, nil
+}
+
+// parseIgnoreFile reads a .ctx3ignore: one pattern per line, matched like
+// the other lists. A pattern ending in '/' hides directories only, any
+// other both files and directories, as in .gitignore; "!pattern" removes
+// earlier rules with exactly that pattern. A leading '/' is dropped. Blank
+// lines and lines starting with '#' are skipped.
+func parseIgnoreFile(data []byte) ignoreConfig {
+	var cfg ignoreConfig
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if pat, ok := strings.CutPrefix(line, "!"); ok {
+			cfg.Unignore = append(cfg.Unignore, strings.TrimSuffix(strings.TrimPrefix(pat, "/"), "/"))
+			continue
+		}
+		line = strings.TrimPrefix(line, "/")
+		if dir, ok := strings.CutSuffix(line, "/"); ok {
+			cfg.Dirs = append(cfg.Dirs, dir)
+			continue
+		}
+		cfg.Dirs = append(cfg.Dirs, line)
+		cfg.Files = append(cfg.Files, line)
+	}
+	return cfg
+}
+
+// UserIgnoreFile returns the path of the per-user ignore file,
+// $XDG_CONFIG_HOME/ctx3/ignore.json or ~/.config/ctx3/ignore.json.
+func UserIgnoreFile() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "ctx3", "ignore.json")
+}
+
+func (r *IgnoreRules) apply(cfg ignoreConfig, source string) {
+	if cfg.Replace {
+		r.Rules = nil
+	}
+	if len(cfg.Unignore) > 0 {
+		kept := r.Rules[:0]
+		for _, rule := range r.Rules {
+			if !contains(cfg.Unignore, rule.Pattern) {
+				kept = append(kept, rule)
+			}
+		}
+		r.Rules = kept
+	}
+	for _, p := range cfg.Dirs {
+		r.Rules = append(r.Rules, IgnoreRule{Pattern: strings.TrimSuffix(p, "/"), Dir: true, Source: source})
+	}
+	for _, p := range cfg.Files {
+		r.Rules = append(r.Rules, IgnoreRule{Pattern: p, Source: source})
+	}
+}
+
+// Match returns the first rule hiding the entry at rel. A nil receiver
+// hides nothing.
+func (r *IgnoreRules) Match(rel string, isDir bool) (IgnoreRule, bool) {
+	if r == nil {
+		return IgnoreRule{}, false
+	}
+	rel = filepath.ToSlash(rel)
+	name := path.Base(rel)
+	for _, rule := range r.Rules {
+		if rule.Dir != isDir {
+			continue
+		}
+		target := name
+		if strings.Contains(rule.Pattern, "/") {
+			target = rel
+		}
+		if ok, _ := doublestar.Match(rule.Pattern, target); ok {
+			return rule, true
+		}
+	}
+	return IgnoreRule{}, false
+}
+
+// Ignores reports whether any rule hides the entry at rel.
+func (r *IgnoreRules) Ignores(rel string, isDir bool) bool {
+	_, ok := r.Match(rel, isDir)
+	return ok
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// IgnoresPath reports whether the file at rel, or any directory above it,
+// is hidden. It is for callers that see paths without walking the tree.
+func (r *IgnoreRules) IgnoresPath(rel string) bool {
+	rel = filepath.ToSlash(rel)
+	for dir := path.Dir(rel); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if r.Ignores(dir, true) {
+			return true
+		}
+	}
+	return r.Ignores(rel, false)
+}
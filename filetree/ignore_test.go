@@ -0,0 +1,79 @@
+package filetree
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadIgnoreRules_Layers(t *testing.T) {
+	cfgHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", cfgHome)
+	writeFile(t, filepath.Join(cfgHome, "ctx3", "ignore.json"), `{"dirs": ["tmp*"], "unignore": ["build"]}`)
+
+	td := t.TempDir()
+	writeFile(t, filepath.Join(td, ProjectConfigFile), "ignore:\n  dirs: [\"docs/generated\"]\n  files: [\"*.snap\"]\n  unignore: [\".DS_Store\"]\n")
+	writeFile(t, filepath.Join(td, ProjectIgnoreFile), "# scratch space\n/scratch/\n*.bak\n\n!vendor\n")
+
+	rules, err := LoadIgnoreRules(td, false)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	cases := []struct {
+		rel    string
+		isDir  bool
+		hidden bool
+		source string
+	}{
+		{"node_modules", true, true, SourceDefaults},
+		{"web/node_modules", true, true, SourceDefaults},
+		{"node_modules", false, false, ""}, // dir rules don't hide files
+		{"build", true, false, ""},         // unignored by the user file
+		{"tmp-cache", true, true, filepath.Join(cfgHome, "ctx3", "ignore.json")},
+		{"docs/generated", true, true, filepath.Join(td, ProjectConfigFile)},
+		{"src/docs/generated", true, false, ""}, // path patterns are anchored
+		{"ui/__snapshots__/a.snap", false, true, filepath.Join(td, ProjectConfigFile)},
+		{".DS_Store", false, false, ""},
+		{"vendor", true, false, ""}, // unignored by .ctx3ignore
+		{"scratch", true, true, filepath.Join(td, ProjectIgnoreFile)},
+		{"scratch", false, false, ""},
+		{"src/old.bak", false, true, filepath.Join(td, ProjectIgnoreFile)},
+		{"old.bak", true, true, filepath.Join(td, ProjectIgnoreFile)},
+	}
+	for _, c := range cases {
+		rule, hidden := rules.Match(c.rel, c.isDir)
+		if hidden != c.hidden || rule.Source != c.source {
+			t.Errorf("Match(%q, dir=%v) = %+v, %v; want hidden=%v from %q", c.rel, c.isDir, rule, hidden, c.hidden, c.source)
+		}
+	}
+	if !rules.IgnoresPath("tmp1/x/y.go") || !rules.IgnoresPath("scratch/x/y.go") || rules.IgnoresPath("src/main.go") {
+		t.Errorf("IgnoresPath should check parent directories")
+	}
+}
+
+func TestLoadIgnoreRules_ReplaceShowHiddenAndErrors(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	td := t.TempDir()
+
+	hidden, err := LoadIgnoreRules(td, true)
+	if err != nil || hidden.Ignores("node_modules", true) {
+		t.Fatalf("--show-hidden-defaults should drop the defaults: %v", err)
+	}
+
+	writeFile(t, filepath.Join(td, ProjectConfigFile), "ignore:\n  replace: true\n  dirs: [\"fixtures\"]\n")
+	rules, err := LoadIgnoreRules(td, false)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(rules.Rules) != 1 || rules.Ignores("dist", true) || !rules.Ignores("fixtures", true) {
+		t.Fatalf("replace should drop earlier rules: %+v", rules.Rules)
+	}
+
+	writeFile(t, filepath.Join(td, ProjectConfigFile), "ignore: [unclosed\n")
+	if _, err := LoadIgnoreRules(td, false); err == nil {
+		t.Fatalf("expected error for malformed %s", ProjectConfigFile)
+	}
+	if _, err := Build(context.Background(), td, Options{}); err == nil {
+		t.Fatalf("Build should surface the config error")
+	}
+}
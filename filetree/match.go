@@ -0,0 +1,52 @@
+package filetree
+
+import (
+	"os"
+	"path/filepath"
+
+	doublestar "github.com/bmatcuk/doublestar/v4"
+	ignore "github.com/sabhiram/go-gitignore"
+)
+
+// MatchAny reports whether the '/'-separated path rel matches one of the
+// doublestar globs. pack's --include/--ignore and print's use it alike.
+func MatchAny(rel string, globs []string) bool {
+	_, ok := MatchingGlob(rel, globs)
+	return ok
+}
+
+// MatchingGlob is MatchAny that also returns the first glob that matched.
+func MatchingGlob(rel string, globs []string) (string, bool) {
+	name := filepath.FromSlash(rel)
+	for _, g := range globs {
+		pat := filepath.FromSlash(g)
+		if ok, _ := doublestar.PathMatch(pat, name); ok {
+			return g, true
+		}
+	}
+	return "", false
+}
+
+// LoadGitignore compiles the .gitignore at the root of a tree. It returns
+// nil when there is none or it can't be read.
+func LoadGitignore(rootAbs string) *ignore.GitIgnore {
+	p := filepath.Join(rootAbs, ".gitignore")
+	if _, err := os.Stat(p); err == nil {
+		if gi, err := ignore.CompileIgnoreFile(p); err == nil {
+			return gi
+		}
+	}
+	return nil
+}
+
+// gitignored checks rel against gi. Directories are matched with a
+// trailing slash so that "dist/" patterns apply to them.
+func gitignored(gi *ignore.GitIgnore, rel string, isDir bool) bool {
+	if gi == nil {
+		return false
+	}
+	if isDir {
+		return gi.MatchesPath(rel + "/")
+	}
+	return gi.MatchesPath(rel)
+}
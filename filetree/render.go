@@ -0,0 +1,140 @@
+package filetree
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+type RenderOptions struct {
+	// Depth limits how many levels below the root are shown; 0 shows all.
+	Depth int
+	// DirsFirst lists directories before files at every level.
+	DirsFirst bool
+	// Human prints sizes as "1.2 KiB" instead of a byte count.
+	Human bool
+	// DirsOnly leaves files out of the drawing (their sizes still count).
+	DirsOnly bool
+	// FilesOnly prints a flat list of file paths instead of a tree.
+	FilesOnly bool
+	// Collapse joins chains of directories that hold a single directory
+	// into one line: "a/b/c".
+	Collapse bool
+}
+
+// RenderText draws the children of n with box-drawing branches, one entry
+// per line with its size.
+func RenderText(w io.Writer, n *Node, opts RenderOptions) error {
+	if opts.FilesOnly {
+		return renderFileList(w, n, 1, opts)
+	}
+	return renderText(w, n, "", 1, opts)
+}
+
+func renderText(w io.Writer, n *Node, prefix string, depth int, opts RenderOptions) error {
+	if opts.Depth > 0 && depth > opts.Depth {
+		return nil
+	}
+	children := visibleChildren(n, opts)
+	for i, c := range children {
+		c, name, level := collapse(c, depth, opts)
+		if c.Target != "" {
+			name += " -> " + c.Target
+		}
+
+		branch, next := "├── ", prefix+"│   "
+		if i == len(children)-1 {
+			branch, next = "└── ", prefix+"    "
+		}
+		if _, err := fmt.Fprintf(w, "%s%s%s (%s)\n", prefix, branch, name, sizeLabel(c.Size, opts.Human)); err != nil {
+			return err
+		}
+		if c.IsDir {
+			if err := renderText(w, c, next, level+1, opts); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func renderFileList(w io.Writer, n *Node, depth int, opts RenderOptions) error {
+	if opts.Depth > 0 && depth > opts.Depth {
+		return nil
+	}
+	for _, c := range n.Children {
+		if c.IsDir {
+			if err := renderFileList(w, c, depth+1, opts); err != nil {
+				return err
+			}
+			continue
+		}
+		name := c.Path
+		if c.Target != "" {
+			name += " -> " + c.Target
+		}
+		if _, err := fmt.Fprintf(w, "%s (%s)\n", name, sizeLabel(c.Size, opts.Human)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collapse follows the chain of single directories starting at c, which
+// is level levels below the root, when opts.Collapse is set. It returns
+// the last directory of the chain, the chain's joined name and that
+// directory's level; every joined directory counts against opts.Depth.
+func collapse(c *Node, level int, opts RenderOptions) (*Node, string, int) {
+	name := c.Name
+	if !opts.Collapse {
+		return c, name, level
+	}
+	for c.IsDir && (opts.Depth == 0 || level < opts.Depth) {
+		next := visibleChildren(c, opts)
+		if len(next) != 1 || !next[0].IsDir {
+			break
+		}
+		c = next[0]
+		name += "/" + c.Name
+		level++
+	}
+	return c, name, level
+}
+
+// visibleChildren applies DirsOnly and DirsFirst without touching n.
+func visibleChildren(n *Node, opts RenderOptions) []*Node {
+	if !opts.DirsOnly && !opts.DirsFirst {
+		return n.Children
+	}
+	out := make([]*Node, 0, len(n.Children))
+	for _, c := range n.Children {
+		if c.IsDir || !opts.DirsOnly {
+			out = append(out, c)
+		}
+	}
+	if opts.DirsFirst {
+		sort.SliceStable(out, func(i, j int) bool { return out[i].IsDir && !out[j].IsDir })
+	}
+	return out
+}
+
+func sizeLabel(n int64, human bool) string {
+	if human {
+		return HumanSize(n)
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
+
+// HumanSize formats a byte count with binary units: "512 B", "1.2 KiB".
+func HumanSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,147 @@
+package filetree
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func renderString(t *testing.T, tree *Node, opts RenderOptions) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := RenderText(&buf, tree, opts); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	return buf.String()
+}
+
+func TestRenderText_LastGlyphAfterIgnoredEntries(t *testing.T) {
+	td := t.TempDir()
+	writeFile(t, filepath.Join(td, "a.txt"), "a")
+	writeFile(t, filepath.Join(td, "vendor", "x.go"), "x") // default-ignored, sorts last
+	tree, err := Build(context.Background(), td, Options{})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if got := renderString(t, tree, RenderOptions{}); got != "└── a.txt (1 bytes)\n" {
+		t.Fatalf("unexpected tree:\n%s", got)
+	}
+}
+
+func TestBuild_SymlinkTargets(t *testing.T) {
+	td := t.TempDir()
+	writeFile(t, filepath.Join(td, "real.txt"), "real")
+	if err := os.Symlink("real.txt", filepath.Join(td, "link.txt")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+	if err := os.Symlink("missing", filepath.Join(td, "broken")); err != nil {
+		t.Fatal(err)
+	}
+	tree, err := Build(context.Background(), td, Options{})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	got := renderString(t, tree, RenderOptions{})
+	for _, want := range []string{"broken -> missing (", "link.txt -> real.txt (", "real.txt (4 bytes)"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("text lacks %q:\n%s", want, got)
+		}
+	}
+	for format, want := range map[string]string{
+		FormatJSON: `"target": "real.txt"`,
+		FormatMD:   "`link.txt -> real.txt`",
+		FormatHTML: "link.txt -&gt; real.txt",
+	} {
+		var buf bytes.Buffer
+		if err := Render(&buf, tree, format, RenderOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("%s lacks %q:\n%s", format, want, buf.String())
+		}
+	}
+}
+
+func TestRenderText_Options(t *testing.T) {
+	root := NewRoot()
+	root.Insert("b.txt", false, 2048)
+	root.Insert("a/b/c/deep.go", false, 10)
+	root.Insert("z/one.go", false, 1)
+	root.Insert("z/two.go", false, 1)
+	root.Sort()
+	root.Summarize()
+
+	got := renderString(t, root, RenderOptions{DirsFirst: true, Collapse: true, Human: true})
+	want := "" +
+		"├── a/b/c (10 B)\n" +
+		"│   └── deep.go (10 B)\n" +
+		"├── z (2 B)\n" +
+		"│   ├── one.go (1 B)\n" +
+		"│   └── two.go (1 B)\n" +
+		"└── b.txt (2.0 KiB)\n"
+	if got != want {
+		t.Fatalf("unexpected tree:\n%s\nwant:\n%s", got, want)
+	}
+
+	// every joined directory counts as a level
+	got = renderString(t, root, RenderOptions{Depth: 2, DirsFirst: true, Collapse: true})
+	want = "" +
+		"├── a/b (10 bytes)\n" +
+		"├── z (2 bytes)\n" +
+		"│   ├── one.go (1 bytes)\n" +
+		"│   └── two.go (1 bytes)\n" +
+		"└── b.txt (2048 bytes)\n"
+	if got != want {
+		t.Fatalf("unexpected collapsed tree at depth 2:\n%s\nwant:\n%s", got, want)
+	}
+	var buf bytes.Buffer
+	if err := Render(&buf, root, FormatMD, RenderOptions{Depth: 2, Collapse: true}); err != nil {
+		t.Fatal(err)
+	}
+	if md := buf.String(); !strings.Contains(md, "`a/b/`") || strings.Contains(md, "c/") || strings.Contains(md, "deep.go") {
+		t.Fatalf("unexpected collapsed md at depth 2:\n%s", md)
+	}
+
+	got = renderString(t, root, RenderOptions{Depth: 1, DirsOnly: true})
+	if got != "├── a (10 bytes)\n└── z (2 bytes)\n" {
+		t.Fatalf("unexpected dirs-only tree:\n%s", got)
+	}
+
+	got = renderString(t, root, RenderOptions{FilesOnly: true})
+	if got != "a/b/c/deep.go (10 bytes)\nb.txt (2048 bytes)\nz/one.go (1 bytes)\nz/two.go (1 bytes)\n" {
+		t.Fatalf("unexpected file list:\n%s", got)
+	}
+}
+
+func TestBuild_GlobsAndGitignore(t *testing.T) {
+	td := t.TempDir()
+	writeFile(t, filepath.Join(td, ".gitignore"), "tmp/\n*.log\n")
+	writeFile(t, filepath.Join(td, "src", "main.go"), "package main\n")
+	writeFile(t, filepath.Join(td, "src", "main_test.go"), "package main\n")
+	writeFile(t, filepath.Join(td, "docs", "intro.md"), "# hi\n")
+	writeFile(t, filepath.Join(td, "tmp", "scratch.go"), "package tmp\n")
+	writeFile(t, filepath.Join(td, "run.log"), "log\n")
+
+	tree, err := Build(context.Background(), td, Options{RespectGitignore: true, IncludeGlobs: []string{"**/*.go"}, IgnoreGlobs: []string{"**/*_test.go"}})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	got := renderString(t, tree, RenderOptions{FilesOnly: true})
+	if got != "src/main.go (13 bytes)\n" {
+		t.Fatalf("unexpected files:\n%s", got)
+	}
+	if strings.Contains(renderString(t, tree, RenderOptions{}), "docs") {
+		t.Fatalf("empty directory kept with --include")
+	}
+}
+
+func TestHumanSize(t *testing.T) {
+	for n, want := range map[int64]string{0: "0 B", 1023: "1023 B", 1024: "1.0 KiB", 1536: "1.5 KiB", 5 << 20: "5.0 MiB"} {
+		if got := HumanSize(n); got != want {
+			t.Errorf("HumanSize(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
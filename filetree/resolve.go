@@ -0,0 +1,67 @@
+package filetree
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ErrOutsideRoot is returned by Resolve for paths that leave the root.
+var ErrOutsideRoot = errors.New("path is outside the root")
+
+// Resolve turns p, relative to root or absolute, into an absolute path
+// inside root with symlinks followed, and its '/'-separated form relative
+// to root. Paths that leave root, lexically or through a link, fail with
+// ErrOutsideRoot, even when they don't exist.
+func Resolve(root, p string) (abs, rel string, err error) {
+	if root, err = filepath.Abs(root); err != nil {
+		return "", "", err
+	}
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return "", "", err
+	}
+	orig := p
+	p = filepath.FromSlash(p)
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(root, p)
+	}
+	if !Within(root, filepath.Clean(p)) {
+		return "", "", fmt.Errorf("%s: %w", orig, ErrOutsideRoot)
+	}
+	if abs, err = filepath.EvalSymlinks(p); err != nil {
+		// a missing path below a link out of root is still outside; don't
+		// tell whether it exists there
+		if dir := existingParent(p); dir != "" && !Within(root, dir) {
+			return "", "", fmt.Errorf("%s: %w", orig, ErrOutsideRoot)
+		}
+		return "", "", err
+	}
+	if !Within(root, abs) {
+		return "", "", fmt.Errorf("%s: %w", orig, ErrOutsideRoot)
+	}
+	rel, _ = filepath.Rel(root, abs)
+	return abs, filepath.ToSlash(rel), nil
+}
+
+// Within reports whether p is root or below it; both are cleaned absolute
+// paths.
+func Within(root, p string) bool {
+	rel, err := filepath.Rel(root, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// existingParent returns the nearest ancestor of p that exists, with
+// symlinks followed, or "" if there is none.
+func existingParent(p string) string {
+	for {
+		parent := filepath.Dir(p)
+		if parent == p {
+			return ""
+		}
+		p = parent
+		if dir, err := filepath.EvalSymlinks(p); err == nil {
+			return dir
+		}
+	}
+}
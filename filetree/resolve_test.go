@@ -0,0 +1,43 @@
+package filetree
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	writeFile(t, filepath.Join(root, "src", "a.go"), "package a\n")
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "src"), filepath.Join(root, "alias")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct{ in, rel string }{
+		{".", "."},
+		{"src/a.go", "src/a.go"},
+		{"src/../src", "src"},
+		{"alias/a.go", "src/a.go"},
+		{filepath.Join(root, "src"), "src"},
+	} {
+		_, rel, err := Resolve(root, tc.in)
+		if err != nil || rel != tc.rel {
+			t.Errorf("Resolve(%q) = %q, %v; want %q", tc.in, rel, err, tc.rel)
+		}
+	}
+
+	for _, in := range []string{"..", "../x", "src/../../x", outside, "escape", "escape/missing"} {
+		if _, _, err := Resolve(root, in); !errors.Is(err, ErrOutsideRoot) {
+			t.Errorf("Resolve(%q) = %v, want ErrOutsideRoot", in, err)
+		}
+	}
+	if _, _, err := Resolve(root, "missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Resolve(missing) = %v, want not exist", err)
+	}
+}
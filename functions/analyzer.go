@@ -1,9 +1,13 @@
 package functions
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/parsabordbar/ctx3/cache"
+	"github.com/parsabordbar/ctx3/filetree"
 )
 
 type ParameterType string
@@ -19,103 +23,224 @@ type Parameter struct {
 }
 
 type ReturnType struct {
-	Type        ParameterType `json:"type"`
-	IsArray     bool          `json:"is_array,omitempty"`
-	IsOptional  bool          `json:"is_optional,omitempty"`
-	IsNullable  bool          `json:"is_nullable,omitempty"`
+	Type       ParameterType `json:"type"`
+	IsArray    bool          `json:"is_array,omitempty"`
+	IsOptional bool          `json:"is_optional,omitempty"`
+	IsNullable bool          `json:"is_nullable,omitempty"`
 }
 
 type Function struct {
-	Name        string        `json:"name"`
-	Language    string        `json:"language"`
-	Path        string        `json:"path"`
-	LineNumber  int           `json:"line_number"`
-	Parameters  []Parameter   `json:"parameters"`
-	ReturnTypes []ReturnType  `json:"return_types"`
-	IsAsync     bool          `json:"is_async,omitempty"`
-	IsExported  bool          `json:"is_exported,omitempty"`
-	Decorators  []string      `json:"decorators,omitempty"`
-	DocString   string        `json:"doc_string,omitempty"`
-	Signature   string        `json:"signature"`
+	Name        string       `json:"name"`
+	Language    string       `json:"language"`
+	Path        string       `json:"path"`
+	LineNumber  int          `json:"line_number"`
+	Parameters  []Parameter  `json:"parameters"`
+	ReturnTypes []ReturnType `json:"return_types"`
+	IsAsync     bool         `json:"is_async,omitempty"`
+	IsExported  bool         `json:"is_exported,omitempty"`
+	Decorators  []string     `json:"decorators,omitempty"`
+	DocString   string       `json:"doc_string,omitempty"`
+	Signature   string       `json:"signature"`
 }
 
 type FunctionContext struct {
-	Functions    []Function `json:"functions"`
-	TotalFunctions int       `json:"total_functions"`
-	LanguageStats map[string]int `json:"language_stats"`
+	Functions      []Function     `json:"functions"`
+	TotalFunctions int            `json:"total_functions"`
+	LanguageStats  map[string]int `json:"language_stats"`
 }
 
+// AnalyzeFunctions lists the functions and methods declared in the Go,
+// Python, JavaScript, TypeScript, Rust and C files under rootDir. Go is
+// parsed properly; the other languages are scanned line by line, so
+// unusual formatting can hide a declaration. Entries hidden by the shared
+// ignore rules (filetree.LoadIgnoreRules) are skipped.
 func AnalyzeFunctions(rootDir string) FunctionContext {
-	ctx := FunctionContext{
-		Functions:    []Function{},
+	rules, err := filetree.LoadIgnoreRules(rootDir, false)
+	if err != nil {
+		rules, _ = filetree.DefaultIgnoreRules()
+	}
+	return AnalyzeFunctionsWithRules(rootDir, rules)
+}
+
+// AnalyzeFunctionsWithRules is AnalyzeFunctions with the ignore rules
+// given explicitly.
+func AnalyzeFunctionsWithRules(rootDir string, rules *filetree.IgnoreRules) FunctionContext {
+	fc, _ := AnalyzeFunctionsWithOptions(context.Background(), rootDir, Options{Rules: rules})
+	return fc
+}
+
+type Options struct {
+	// Rules hides files and directories; nil hides nothing.
+	Rules *filetree.IgnoreRules
+	// Cache, when set, keeps the functions of every file by content hash,
+	// so unchanged files aren't parsed again.
+	Cache *cache.Store
+}
+
+// cacheKind is the kind of cache entries holding the functions of a file.
+// Bump the version when an analyzer changes what it finds.
+const cacheKind = "functions-v1"
+
+// AnalyzeFunctionsWithOptions is AnalyzeFunctions with the options given
+// explicitly. It stops with ctx's error when ctx is done.
+func AnalyzeFunctionsWithOptions(ctx context.Context, rootDir string, opts Options) (FunctionContext, error) {
+	fc := FunctionContext{
+		Functions:     []Function{},
 		LanguageStats: make(map[string]int),
 	}
+	rules := opts.Rules
+	var index *cache.Index
+	if opts.Cache != nil {
+		index, _ = opts.Cache.Index(rootDir)
+	}
+	// links are followed to files inside rootDir only
+	confine, err := filepath.Abs(rootDir)
+	if err == nil {
+		if real, err := filepath.EvalSymlinks(confine); err == nil {
+			confine = real
+		}
+	}
 
-	filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil || info.IsDir() {
+	err = filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err != nil {
+			return nil
+		}
+		rel := GetRlativePath(rootDir, path)
+		if info.IsDir() {
+			if rel != "." && (info.Name() == ".git" || rules.Ignores(rel, true)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := filepath.EvalSymlinks(path)
+			if err != nil || !filetree.Within(confine, target) {
+				return nil
+			}
+			tinfo, err := os.Stat(target)
+			if err != nil {
+				return nil
+			}
+			info = linkedInfo{tinfo, info.Name()}
+		}
+		// devices and named pipes could block a reader
+		if !info.Mode().IsRegular() {
 			return nil
 		}
 
-		if shouldSkipFile(path) {
+		if shouldSkipFile(rel, rules) {
 			return nil
 		}
 
 		ext := strings.ToLower(filepath.Ext(path))
+		if _, ok := analyzers[ext]; !ok {
+			return nil
+		}
 		var functions []Function
-
-		switch ext {
-		case ".go":
-			functions = analyzeGoFile(path)
-		case ".py":
-			functions = analyzePythonFile(path)
-		case ".js", ".jsx":
-			functions = analyzeJavaScriptFile(path)
-		case ".ts", ".tsx":
-			functions = analyzeTypeScriptFile(path)
-		case ".rs":
-			functions = analyzeRustFile(path)
-		case ".c", ".h":
-			functions = analyzeCFile(path)
+		if index != nil {
+			functions = analyzeCached(opts.Cache, index, rel, path, ext, info)
+		} else {
+			functions = analyzers[ext](path)
 		}
 
 		for i := range functions {
-			functions[i].Path = GetRlativePath(rootDir, path)
-			ctx.Functions = append(ctx.Functions, functions[i])
-			ctx.LanguageStats[functions[i].Language]++
+			functions[i].Path = rel
+			fc.Functions = append(fc.Functions, functions[i])
+			fc.LanguageStats[functions[i].Language]++
 		}
 
 		return nil
 	})
+	if index != nil {
+		index.Save()
+	}
+	if err != nil {
+		return FunctionContext{}, err
+	}
 
-	ctx.TotalFunctions = len(ctx.Functions)
-	return ctx
+	fc.TotalFunctions = len(fc.Functions)
+	return fc, nil
 }
 
-//TODO 
-func analyzeCFile(path string) []Function {
-	panic("unimplemented")
+// analyzers parse a file by extension.
+var analyzers = map[string]func(path string) []Function{
+	".go":  analyzeGoFile,
+	".py":  analyzePythonFile,
+	".js":  analyzeJavaScriptFile,
+	".jsx": analyzeJavaScriptFile,
+	".mjs": analyzeJavaScriptFile,
+	".cjs": analyzeJavaScriptFile,
+	".ts":  analyzeTypeScriptFile,
+	".tsx": analyzeTypeScriptFile,
+	".mts": analyzeTypeScriptFile,
+	".cts": analyzeTypeScriptFile,
+	".rs":  analyzeRustFile,
+	".c":   analyzeCFile,
+	".h":   analyzeCFile,
 }
 
-func analyzeRustFile(path string) []Function {
-	panic("unimplemented")
+// linkedInfo describes the file a symbolic link points to, under the
+// link's name.
+type linkedInfo struct {
+	os.FileInfo
+	name string
 }
 
-func analyzeTypeScriptFile(path string) []Function {
-	panic("unimplemented")
-}
+func (i linkedInfo) Name() string { return i.name }
 
-func analyzeJavaScriptFile(path string) []Function {
-	panic("unimplemented")
+// analyzeCached returns the functions of a file from the cache when its
+// content was analyzed before, and analyzes and stores them otherwise.
+func analyzeCached(store *cache.Store, index *cache.Index, rel, path, ext string, info os.FileInfo) []Function {
+	sum, ok := index.Lookup(rel, info)
+	if !ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		sum = cache.Sum(data)
+		index.Record(rel, info, sum)
+	}
+	key := cache.Key(sum, ext)
+	var functions []Function
+	if store.Get(cacheKind, key, &functions) {
+		return functions
+	}
+	functions = analyzers[ext](path)
+	store.Put(cacheKind, key, functions)
+	return functions
 }
 
-func analyzePythonFile(path string) []Function {
-	panic("unimplemented")
+// shouldSkipFile leaves out ignored, minified and bundled files, whose
+// "functions" are not worth listing.
+func shouldSkipFile(rel string, rules *filetree.IgnoreRules) bool {
+	if rules.Ignores(rel, false) {
+		return true
+	}
+	name := strings.ToLower(filepath.Base(rel))
+	return strings.HasSuffix(name, ".min.js") || strings.HasSuffix(name, ".bundle.js") || strings.HasSuffix(name, ".d.ts")
 }
 
-func analyzeGoFile(path string) []Function {
-	panic("unimplemented")
+// Filter returns the functions in the file or below the directory at
+// path ('/'-separated, "" or "." for all) whose name contains name.
+func (c FunctionContext) Filter(path, name string) FunctionContext {
+	path = strings.Trim(path, "/")
+	if path == "." {
+		path = ""
+	}
+	out := FunctionContext{Functions: []Function{}, LanguageStats: map[string]int{}}
+	for _, f := range c.Functions {
+		if path != "" && f.Path != path && !strings.HasPrefix(f.Path, path+"/") {
+			continue
+		}
+		if name != "" && !strings.Contains(f.Name, name) {
+			continue
+		}
+		out.Functions = append(out.Functions, f)
+		out.LanguageStats[f.Language]++
+	}
+	out.TotalFunctions = len(out.Functions)
+	return out
 }
-
-func shouldSkipFile(path string) bool {
-	panic("unimplemented")
-}
\ No newline at end of file
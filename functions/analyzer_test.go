@@ -0,0 +1,192 @@
+package functions
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/parsabordbar/ctx3/cache"
+)
+
+func writeTestFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
+
+func byName(fns []Function) map[string]Function {
+	m := map[string]Function{}
+	for _, f := range fns {
+		m[f.Path+":"+f.Name] = f
+	}
+	return m
+}
+
+func TestAnalyzeFunctions(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "store.go"), `package x
+
+// Get returns the value for key.
+func (s *Store[T]) Get(key string) (*T, error) {
+	return nil, nil
+}
+
+func helper(a, b int) []int { return nil }
+`)
+	writeTestFile(t, filepath.Join(root, "app.py"), `@app.route("/")
+async def index(request, limit: int = 10) -> Optional[str]:
+    """Serve the index."""
+    return None
+
+class Repo:
+    def _files(self,
+               pattern: str) -> List[str]:
+        pass
+`)
+	writeTestFile(t, filepath.Join(root, "web", "svc.ts"), `/** Greets someone. */
+export async function greet(name: string, opts?: Opts): Promise<string[]> {
+  return [];
+}
+export const add = (a: number, b = 2): number => a + b;
+const total = (a + b) * 2;
+export function over(a: string): void;
+export class Svc {
+  @Input()
+  load(id: string) {
+    if (id) {
+      run(id);
+    }
+  }
+}
+`)
+	writeTestFile(t, filepath.Join(root, "lib.rs"), `/// Parses it.
+#[inline]
+pub fn parse(s: &str) -> Option<Vec<u8>> {
+    None
+}
+impl Display for Wrapper {
+    fn fmt(&self, f: &mut Formatter) -> fmt::Result { Ok(()) }
+}
+`)
+	writeTestFile(t, filepath.Join(root, "main.c"), `/* Entry point. */
+int main(int argc, char **argv)
+{
+    if (argc) { return 1; }
+    return 0;
+}
+static const char *name(void);
+`)
+	writeTestFile(t, filepath.Join(root, "node_modules", "dep", "index.js"), "function hidden() {}\n")
+
+	ctx := AnalyzeFunctions(root)
+	got := byName(ctx.Functions)
+
+	want := []string{
+		"store.go:Store.Get", "store.go:helper",
+		"app.py:index", "app.py:Repo._files",
+		"web/svc.ts:greet", "web/svc.ts:add", "web/svc.ts:Svc.load",
+		"lib.rs:parse", "lib.rs:Wrapper.fmt",
+		"main.c:main",
+	}
+	for _, w := range want {
+		if _, ok := got[w]; !ok {
+			t.Errorf("missing %s", w)
+		}
+	}
+	if len(ctx.Functions) != len(want) || ctx.TotalFunctions != len(want) {
+		var names []string
+		for k := range got {
+			names = append(names, k)
+		}
+		t.Errorf("got %d functions, want %d: %s", len(ctx.Functions), len(want), strings.Join(names, ", "))
+	}
+
+	if f := got["store.go:Store.Get"]; !f.IsExported || f.DocString != "Get returns the value for key." ||
+		len(f.ReturnTypes) != 2 || f.ReturnTypes[0].Type != "*T" || f.LineNumber != 4 {
+		t.Errorf("Store.Get = %+v", f)
+	}
+	if f := got["store.go:helper"]; f.IsExported || len(f.Parameters) != 2 || !f.ReturnTypes[0].IsArray {
+		t.Errorf("helper = %+v", f)
+	}
+	if f := got["app.py:index"]; !f.IsAsync || f.DocString != "Serve the index." || len(f.Decorators) != 1 ||
+		len(f.Parameters) != 2 || f.Parameters[1].Type != "int" || !f.ReturnTypes[0].IsOptional {
+		t.Errorf("index = %+v", f)
+	}
+	if f := got["app.py:Repo._files"]; f.IsExported || f.Signature != "def _files(self, pattern: str) -> List[str]" {
+		t.Errorf("Repo._files = %+v", f)
+	}
+	if f := got["web/svc.ts:greet"]; !f.IsAsync || !f.IsExported || f.DocString != "Greets someone." || f.ReturnTypes[0].Type != "Promise<string[]>" {
+		t.Errorf("greet = %+v", f)
+	}
+	if f := got["web/svc.ts:Svc.load"]; len(f.Decorators) != 1 || f.Decorators[0] != "Input()" {
+		t.Errorf("Svc.load = %+v", f)
+	}
+	if f := got["lib.rs:parse"]; !f.IsExported || f.DocString != "Parses it." || !f.ReturnTypes[0].IsOptional {
+		t.Errorf("parse = %+v", f)
+	}
+	if f := got["lib.rs:Wrapper.fmt"]; len(f.Parameters) != 1 || f.Parameters[0].Name != "f" {
+		t.Errorf("Wrapper.fmt = %+v", f)
+	}
+	if f := got["main.c:main"]; f.DocString != "Entry point." || len(f.Parameters) != 2 || f.Parameters[1].Type != "char**" {
+		t.Errorf("main = %+v", f)
+	}
+	if ctx.LanguageStats["Go"] != 2 || ctx.LanguageStats["TypeScript"] != 3 {
+		t.Errorf("LanguageStats = %v", ctx.LanguageStats)
+	}
+}
+
+func TestAnalyzeFunctions_Cache(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "a.go"), "package a\n\nfunc A(n int) string { return \"\" }\n")
+	writeTestFile(t, filepath.Join(root, "b.py"), "def b(x):\n    pass\n")
+	store, err := cache.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plain := AnalyzeFunctionsWithRules(root, nil)
+	first, _ := AnalyzeFunctionsWithOptions(context.Background(), root, Options{Cache: store})
+	st, _ := store.Stats()
+	if st.Kinds[cacheKind].Entries != 2 {
+		t.Fatalf("cached %d files, want 2", st.Kinds[cacheKind].Entries)
+	}
+	second, _ := AnalyzeFunctionsWithOptions(context.Background(), root, Options{Cache: store})
+	if !reflect.DeepEqual(plain, first) || !reflect.DeepEqual(first, second) {
+		t.Errorf("cached results differ:\n%+v\n%+v\n%+v", plain, first, second)
+	}
+
+	writeTestFile(t, filepath.Join(root, "a.go"), "package a\n\nfunc Renamed() {}\n")
+	third, _ := AnalyzeFunctionsWithOptions(context.Background(), root, Options{Cache: store})
+	if got := byName(third.Functions); got["a.go:Renamed"].Name == "" || got["a.go:A"].Name != "" {
+		t.Errorf("edit not picked up: %+v", got)
+	}
+}
+
+func TestAnalyzeFunctions_SymlinksStayInsideRoot(t *testing.T) {
+	outside := t.TempDir()
+	writeTestFile(t, filepath.Join(outside, "s.go"), "package s\n\nfunc SecretFunc(password string) {}\n")
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "lib", "a.go"), "package lib\n\nfunc A() {}\n")
+	if err := os.Symlink(filepath.Join(outside, "s.go"), filepath.Join(root, "s.go")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+	if err := os.Symlink(filepath.Join("lib", "a.go"), filepath.Join(root, "b.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	got := byName(AnalyzeFunctions(root).Functions)
+	if got["s.go:SecretFunc"].Name != "" {
+		t.Errorf("function read through a link out of the root: %+v", got)
+	}
+	if got["b.go:A"].Name == "" || got["lib/a.go:A"].Name == "" {
+		t.Errorf("link inside the root not followed: %+v", got)
+	}
+}
@@ -0,0 +1,117 @@
+package functions
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// cFunc matches a declaration starting in the first column: a return type
+// then a name and "(".
+var cFunc = regexp.MustCompile(`^([A-Za-z_][\w \t*]*?[\s*])([A-Za-z_]\w*)\s*\(`)
+
+var cNotTypes = map[string]bool{
+	"return": true, "else": true, "if": true, "while": true, "for": true,
+	"switch": true, "do": true, "case": true, "typedef": true, "goto": true,
+}
+
+// analyzeCFile lists the function definitions of a C file, and in a
+// header also the prototypes. Static functions are not exported.
+func analyzeCFile(path string) []Function {
+	lines := readLines(path)
+	header := strings.EqualFold(filepath.Ext(path), ".h")
+	var out []Function
+	var sc scopes
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		m := cFunc.FindStringSubmatch(line)
+		if m == nil || sc.depth > 0 || cNotTypes[strings.Fields(m[1])[0]] {
+			sc.advance(line)
+			continue
+		}
+		sig, term, end := signatureAt(lines, i, []string{"{", ";"})
+		for j := i; j <= end; j++ {
+			sc.advance(lines[j])
+		}
+		if term != "{" && !(term == ";" && header) {
+			i = end
+			continue
+		}
+
+		_, inner, _ := parenContent(sig)
+		ret, static := cReturnType(m[1])
+		fn := Function{
+			Name:        m[2],
+			Language:    "C",
+			LineNumber:  i + 1,
+			Parameters:  cParams(inner),
+			ReturnTypes: []ReturnType{{Type: ParameterType(ret), IsNullable: strings.HasSuffix(ret, "*")}},
+			IsExported:  !static,
+			DocString:   blockCommentBefore(lines, i, nil),
+			Signature:   strings.TrimSpace(strings.TrimRight(compact(sig), "{;")),
+		}
+		if fn.DocString == "" {
+			fn.DocString = lineCommentsBefore(lines, i, "//", nil)
+		}
+		if ret == "void" {
+			fn.ReturnTypes = nil
+		}
+		out = append(out, fn)
+		i = end
+	}
+	return out
+}
+
+// cReturnType strips storage and inline specifiers from the text before a
+// function name.
+func cReturnType(prefix string) (string, bool) {
+	var kept []string
+	static := false
+	for _, f := range strings.Fields(prefix) {
+		switch f {
+		case "static":
+			static = true
+		case "inline", "extern", "__inline", "__inline__":
+		default:
+			kept = append(kept, f)
+		}
+	}
+	ret := strings.Join(kept, " ")
+	return strings.ReplaceAll(ret, " *", "*"), static
+}
+
+func cParams(inner string) []Parameter {
+	params := []Parameter{}
+	inner = strings.TrimSpace(inner)
+	if inner == "" || inner == "void" {
+		return params
+	}
+	for _, p := range splitTopLevel(inner, ',') {
+		if p == "..." {
+			params = append(params, Parameter{Name: "...", Type: Any})
+			continue
+		}
+		// function pointer: int (*cb)(int)
+		if k := strings.Index(p, "(*"); k >= 0 {
+			name := p[k+2:]
+			if e := strings.IndexByte(name, ')'); e >= 0 {
+				name = name[:e]
+			}
+			params = append(params, Parameter{Name: strings.TrimSpace(name), Type: ParameterType(compact(p))})
+			continue
+		}
+		typ, name := p, ""
+		suffix := ""
+		if k := strings.IndexByte(p, '['); k >= 0 {
+			typ, suffix = strings.TrimSpace(p[:k]), "[]"
+		}
+		if k := strings.LastIndexAny(typ, " \t*"); k >= 0 {
+			name = typ[k+1:]
+			typ = typ[:k+1]
+		}
+		typ = strings.ReplaceAll(compact(typ), " *", "*")
+		params = append(params, Parameter{Name: name, Type: ParameterType(typ + suffix)})
+	}
+	return params
+}
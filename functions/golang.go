@@ -0,0 +1,109 @@
+package functions
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"strings"
+)
+
+// analyzeGoFile lists the functions and methods of a Go file. Methods are
+// named Receiver.Method. Files that don't parse yield what the parser
+// recovered.
+func analyzeGoFile(path string) []Function {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	fset := token.NewFileSet()
+	file, _ := parser.ParseFile(fset, path, src, parser.ParseComments|parser.SkipObjectResolution)
+	if file == nil {
+		return nil
+	}
+
+	var out []Function
+	for _, decl := range file.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		fn := Function{
+			Name:       fd.Name.Name,
+			Language:   "Go",
+			LineNumber: fset.Position(fd.Pos()).Line,
+			Parameters: goFields(fd.Type.Params),
+			IsExported: ast.IsExported(fd.Name.Name),
+		}
+		if fd.Recv != nil && len(fd.Recv.List) > 0 {
+			fn.Name = goReceiverName(fd.Recv.List[0].Type) + "." + fn.Name
+		}
+		if fd.Type.Results != nil {
+			for _, f := range fd.Type.Results.List {
+				rt := goReturnType(f.Type)
+				for i := 1; i < len(f.Names); i++ {
+					fn.ReturnTypes = append(fn.ReturnTypes, rt)
+				}
+				fn.ReturnTypes = append(fn.ReturnTypes, rt)
+			}
+		}
+		if fd.Doc != nil {
+			fn.DocString = strings.TrimSpace(fd.Doc.Text())
+		}
+		end := fd.End()
+		if fd.Body != nil {
+			end = fd.Body.Lbrace
+		}
+		start, stop := fset.Position(fd.Pos()).Offset, fset.Position(end).Offset
+		if start >= 0 && stop <= len(src) && start < stop {
+			fn.Signature = strings.Join(strings.Fields(string(src[start:stop])), " ")
+		}
+		out = append(out, fn)
+	}
+	return out
+}
+
+func goFields(list *ast.FieldList) []Parameter {
+	params := []Parameter{}
+	if list == nil {
+		return params
+	}
+	for _, f := range list.List {
+		typ := ParameterType(types.ExprString(f.Type))
+		if len(f.Names) == 0 {
+			params = append(params, Parameter{Type: typ})
+		}
+		for _, n := range f.Names {
+			params = append(params, Parameter{Name: n.Name, Type: typ})
+		}
+	}
+	return params
+}
+
+func goReturnType(expr ast.Expr) ReturnType {
+	rt := ReturnType{Type: ParameterType(types.ExprString(expr))}
+	switch t := expr.(type) {
+	case *ast.ArrayType:
+		rt.IsArray = true
+	case *ast.StarExpr, *ast.MapType, *ast.ChanType, *ast.FuncType, *ast.InterfaceType:
+		rt.IsNullable = true
+	case *ast.Ident:
+		rt.IsNullable = t.Name == "error" || t.Name == "any"
+	}
+	return rt
+}
+
+func goReceiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return goReceiverName(t.X)
+	case *ast.IndexExpr:
+		return goReceiverName(t.X)
+	case *ast.IndexListExpr:
+		return goReceiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return types.ExprString(expr)
+}
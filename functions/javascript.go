@@ -0,0 +1,134 @@
+package functions
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	jsFunction = regexp.MustCompile(`^\s*(export\s+)?(?:default\s+)?(?:declare\s+)?(async\s+)?function\s*\*?\s*([\w$]+)\s*(?:<[^(]*>)?\s*\(`)
+	jsArrow    = regexp.MustCompile(`^\s*(export\s+)?(?:const|let|var)\s+([\w$]+)\s*(?::[^=]+)?=\s*(async\s+)?(?:function\b[^(]*)?(?:<[^(]*>)?\(`)
+	jsArrowOne = regexp.MustCompile(`^\s*(export\s+)?(?:const|let|var)\s+([\w$]+)\s*=\s*(async\s+)?([\w$]+)\s*=>`)
+	jsClass    = regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:abstract\s+)?class\s+([\w$]+)`)
+	jsMethod   = regexp.MustCompile(`^\s*((?:(?:public|private|protected|static|readonly|override|abstract|async|get|set)\s+)*)(#?[\w$]+)\s*\??\s*(?:<[^(]*>)?\s*\(`)
+	jsDecorate = regexp.MustCompile(`^\s*@([\w$.]+(?:\(.*\))?)\s*$`)
+)
+
+var jsKeywords = map[string]bool{
+	"if": true, "for": true, "while": true, "switch": true, "catch": true,
+	"return": true, "function": true, "new": true, "typeof": true, "super": true,
+}
+
+func analyzeJavaScriptFile(path string) []Function {
+	return analyzeScriptFile(path, "JavaScript")
+}
+
+func analyzeTypeScriptFile(path string) []Function {
+	return analyzeScriptFile(path, "TypeScript")
+}
+
+// analyzeScriptFile lists function declarations, functions assigned to
+// const/let/var and class methods (named Class.method) of a JavaScript or
+// TypeScript file.
+func analyzeScriptFile(path, lang string) []Function {
+	lines := readLines(path)
+	var out []Function
+	var sc scopes
+	var decorators []string
+	isDecorator := func(l string) bool { return jsDecorate.MatchString(l) }
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		fn, matched := Function{Language: lang, LineNumber: i + 1}, false
+		want := "{" // what must follow the parameters for this to be a definition
+
+		if m := jsFunction.FindStringSubmatch(line); m != nil {
+			fn.Name, fn.IsExported, fn.IsAsync, matched = m[3], m[1] != "", m[2] != "", true
+		} else if m := jsArrow.FindStringSubmatch(line); m != nil {
+			fn.Name, fn.IsExported, fn.IsAsync, matched = m[2], m[1] != "", m[3] != "", true
+			if !strings.Contains(line, "function") {
+				want = "=>"
+			}
+		} else if m := jsArrowOne.FindStringSubmatch(line); m != nil {
+			fn.Name, fn.IsExported, fn.IsAsync = m[2], m[1] != "", m[3] != ""
+			fn.Parameters = []Parameter{{Name: m[4]}}
+			fn.Signature = compact(line[:strings.Index(line, "=>")+2])
+			fn.DocString = blockCommentBefore(lines, i, isDecorator)
+			out = append(out, fn)
+			sc.advance(line)
+			continue
+		} else if m := jsClass.FindStringSubmatch(line); m != nil {
+			sc.open = m[1]
+		} else if m := jsDecorate.FindStringSubmatch(line); m != nil {
+			decorators = append(decorators, m[1])
+			sc.advance(line)
+			continue
+		} else if class, inBody := sc.current(); inBody {
+			if m := jsMethod.FindStringSubmatch(line); m != nil && !jsKeywords[m[2]] {
+				fn.Name, matched = class+"."+m[2], true
+				fn.IsAsync = strings.Contains(m[1], "async")
+				fn.IsExported = !strings.HasPrefix(m[2], "#") && !strings.Contains(m[1], "private")
+			}
+		}
+		if !matched {
+			decorators = nil
+			sc.advance(line)
+			continue
+		}
+
+		sig, term, end := signatureAt(lines, i, []string{"=>", "{", ";"})
+		for j := i; j <= end; j++ {
+			sc.advance(lines[j])
+		}
+		if term != want {
+			// an overload or ambient declaration without a body, or a
+			// parenthesised expression rather than an arrow function
+			decorators = nil
+			i = end
+			continue
+		}
+		_, inner, after := parenContent(sig)
+		fn.Parameters = jsParams(inner)
+		fn.Decorators = decorators
+		fn.DocString = blockCommentBefore(lines, i, isDecorator)
+		fn.Signature = strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(compact(sig), "{"), "=>"))
+		ret := strings.TrimSpace(after)
+		ret = strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(ret, "{"), "=>"))
+		if t, ok := strings.CutPrefix(ret, ":"); ok {
+			fn.ReturnTypes = []ReturnType{jsReturnType(strings.TrimSpace(t))}
+		}
+		out = append(out, fn)
+		decorators = nil
+		i = end
+	}
+	return out
+}
+
+func jsParams(inner string) []Parameter {
+	params := []Parameter{}
+	for _, p := range splitTopLevel(inner, ',') {
+		p, _, _ = cutTopLevel(p, '=')
+		name, typ, _ := cutTopLevel(p, ':')
+		name = strings.TrimSuffix(name, "?")
+		for _, mod := range []string{"public ", "private ", "protected ", "readonly "} {
+			name = strings.TrimPrefix(name, mod)
+		}
+		if name == "this" {
+			continue
+		}
+		params = append(params, Parameter{Name: name, Type: ParameterType(typ)})
+	}
+	return params
+}
+
+func jsReturnType(t string) ReturnType {
+	inner := t
+	if s, ok := strings.CutPrefix(t, "Promise<"); ok {
+		inner = strings.TrimSuffix(s, ">")
+	}
+	return ReturnType{
+		Type:       ParameterType(t),
+		IsArray:    isArrayType(inner),
+		IsNullable: strings.Contains(inner, "null") || strings.Contains(inner, "undefined"),
+	}
+}
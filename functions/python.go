@@ -0,0 +1,133 @@
+package functions
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	pyDef   = regexp.MustCompile(`^(\s*)(async\s+)?def\s+(\w+)\s*\(`)
+	pyClass = regexp.MustCompile(`^(\s*)class\s+(\w+)`)
+)
+
+// analyzePythonFile lists the functions of a Python file. Methods are
+// named Class.method.
+func analyzePythonFile(path string) []Function {
+	lines := readLines(path)
+	var out []Function
+	var decorators []string
+	type class struct {
+		indent int
+		name   string
+	}
+	var classes []class
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		for len(classes) > 0 && indent <= classes[len(classes)-1].indent {
+			classes = classes[:len(classes)-1]
+		}
+		if strings.HasPrefix(trimmed, "@") {
+			decorators = append(decorators, strings.TrimPrefix(trimmed, "@"))
+			continue
+		}
+		if m := pyClass.FindStringSubmatch(line); m != nil {
+			classes = append(classes, class{indent: len(m[1]), name: m[2]})
+			decorators = nil
+			continue
+		}
+		m := pyDef.FindStringSubmatch(line)
+		if m == nil {
+			decorators = nil
+			continue
+		}
+
+		sig, _, end := signatureAt(lines, i, []string{":"})
+		_, inner, after := parenContent(sig)
+		fn := Function{
+			Name:       m[3],
+			Language:   "Python",
+			LineNumber: i + 1,
+			Parameters: pyParams(inner),
+			IsAsync:    m[2] != "",
+			IsExported: !strings.HasPrefix(m[3], "_"),
+			Decorators: decorators,
+			DocString:  pyDocstring(lines, end+1),
+			Signature:  strings.TrimSuffix(compact(sig), ":"),
+		}
+		if len(classes) > 0 && len(m[1]) > classes[len(classes)-1].indent {
+			fn.Name = classes[len(classes)-1].name + "." + fn.Name
+		}
+		ret := strings.TrimSuffix(strings.TrimSpace(after), ":")
+		if ret = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(ret), "->")); ret != "" {
+			fn.ReturnTypes = []ReturnType{pyReturnType(ret)}
+		}
+		out = append(out, fn)
+		decorators = nil
+		i = end
+	}
+	return out
+}
+
+func pyParams(inner string) []Parameter {
+	params := []Parameter{}
+	for _, p := range splitTopLevel(inner, ',') {
+		p, _, _ = cutTopLevel(p, '=')
+		name, typ, _ := cutTopLevel(p, ':')
+		if name == "self" || name == "cls" || name == "/" || name == "*" {
+			continue
+		}
+		params = append(params, Parameter{Name: name, Type: ParameterType(typ)})
+	}
+	return params
+}
+
+func pyReturnType(t string) ReturnType {
+	rt := ReturnType{Type: ParameterType(t), IsArray: isArrayType(t)}
+	if strings.HasPrefix(t, "Optional[") {
+		rt.IsOptional = true
+	}
+	if strings.Contains(t, "None") && t != "None" {
+		rt.IsNullable = true
+	}
+	return rt
+}
+
+// pyDocstring returns the docstring starting at line i, if any.
+func pyDocstring(lines []string, i int) string {
+	for i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+		i++
+	}
+	if i >= len(lines) {
+		return ""
+	}
+	first := strings.TrimSpace(lines[i])
+	var quote string
+	for _, q := range []string{`"""`, `'''`} {
+		if strings.HasPrefix(first, q) {
+			quote = q
+		}
+	}
+	if quote == "" {
+		return ""
+	}
+	rest := first[len(quote):]
+	if end := strings.Index(rest, quote); end >= 0 {
+		return strings.TrimSpace(rest[:end])
+	}
+	doc := []string{rest}
+	for j := i + 1; j < len(lines); j++ {
+		l := strings.TrimSpace(lines[j])
+		if end := strings.Index(l, quote); end >= 0 {
+			doc = append(doc, l[:end])
+			break
+		}
+		doc = append(doc, l)
+	}
+	return strings.TrimSpace(strings.Join(doc, "\n"))
+}
@@ -0,0 +1,100 @@
+package functions
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	rustFn    = regexp.MustCompile(`^\s*(pub(?:\([^)]*\))?\s+)?(?:default\s+)?(?:const\s+)?(async\s+)?(?:unsafe\s+)?(?:extern\s+"[^"]*"\s+)?fn\s+(\w+)`)
+	rustImpl  = regexp.MustCompile(`^\s*(?:unsafe\s+)?impl\b(?:<[^>]*>)?\s+(?:[\w:]+(?:<[^>]*>)?\s+for\s+)?([\w:]+)`)
+	rustTrait = regexp.MustCompile(`^\s*(?:pub(?:\([^)]*\))?\s+)?(?:unsafe\s+)?trait\s+(\w+)`)
+)
+
+// analyzeRustFile lists the functions of a Rust file, including trait
+// method declarations. Functions in impl and trait blocks are named
+// Type.method.
+func analyzeRustFile(path string) []Function {
+	lines := readLines(path)
+	var out []Function
+	var sc scopes
+	isAttr := func(l string) bool { return strings.HasPrefix(l, "#[") }
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if m := rustImpl.FindStringSubmatch(line); m != nil {
+			name := m[1]
+			if k := strings.LastIndex(name, "::"); k >= 0 {
+				name = name[k+2:]
+			}
+			sc.open = name
+		} else if m := rustTrait.FindStringSubmatch(line); m != nil {
+			sc.open = m[1]
+		}
+		m := rustFn.FindStringSubmatch(line)
+		if m == nil {
+			sc.advance(line)
+			continue
+		}
+
+		sig, _, end := signatureAt(lines, i, []string{"{", ";"})
+		owner, inBody := sc.current()
+		for j := i; j <= end; j++ {
+			sc.advance(lines[j])
+		}
+		_, inner, after := parenContent(sig)
+		fn := Function{
+			Name:       m[3],
+			Language:   "Rust",
+			LineNumber: i + 1,
+			Parameters: rustParams(inner),
+			IsAsync:    m[2] != "",
+			IsExported: m[1] != "",
+			DocString:  lineCommentsBefore(lines, i, "///", isAttr),
+			Signature:  strings.TrimSpace(strings.TrimRight(compact(sig), "{;")),
+		}
+		if inBody {
+			fn.Name = owner + "." + fn.Name
+		}
+		for j := i - 1; j >= 0; j-- {
+			t := strings.TrimSpace(lines[j])
+			if !isAttr(t) {
+				break
+			}
+			fn.Decorators = append([]string{t}, fn.Decorators...)
+		}
+		ret := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(after), "{;"))
+		if k := strings.Index(ret, " where "); k >= 0 {
+			ret = ret[:k]
+		}
+		if t, ok := strings.CutPrefix(ret, "->"); ok {
+			fn.ReturnTypes = []ReturnType{rustReturnType(strings.TrimSpace(t))}
+		}
+		out = append(out, fn)
+		i = end
+	}
+	return out
+}
+
+func rustParams(inner string) []Parameter {
+	params := []Parameter{}
+	for _, p := range splitTopLevel(inner, ',') {
+		name, typ, ok := cutTopLevel(p, ':')
+		if !ok && strings.HasSuffix(name, "self") {
+			continue // self, &self, &mut self, &'a self
+		}
+		if name == "self" || name == "mut self" {
+			continue
+		}
+		params = append(params, Parameter{Name: strings.TrimPrefix(name, "mut "), Type: ParameterType(typ)})
+	}
+	return params
+}
+
+func rustReturnType(t string) ReturnType {
+	return ReturnType{
+		Type:       ParameterType(t),
+		IsArray:    isArrayType(t),
+		IsOptional: strings.HasPrefix(t, "Option<"),
+	}
+}
@@ -0,0 +1,240 @@
+package functions
+
+import (
+	"os"
+	"strings"
+)
+
+// The non-Go languages are scanned line by line: a regexp finds the line a
+// declaration starts on, and signatureAt gathers the rest of it, which may
+// span several lines.
+
+const maxSignatureLines = 30
+
+func readLines(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+}
+
+// signatureAt joins lines from i on until the first parenthesis on line i
+// is closed and one of terms follows it. It returns the text up to and
+// including the terminator, the terminator, and the index of the last line
+// used; term is "" when none was found.
+func signatureAt(lines []string, i int, terms []string) (sig, term string, end int) {
+	var b strings.Builder
+	depth, opened := 0, false
+	for j := i; j < len(lines) && j < i+maxSignatureLines; j++ {
+		line := lines[j]
+		for k := 0; k < len(line); k++ {
+			c := line[k]
+			switch {
+			case c == '(':
+				depth++
+				opened = true
+			case c == ')':
+				depth--
+			case opened && depth == 0:
+				for _, t := range terms {
+					if strings.HasPrefix(line[k:], t) {
+						b.WriteString(line[:k+len(t)])
+						return b.String(), t, j
+					}
+				}
+			}
+		}
+		b.WriteString(line)
+		b.WriteByte(' ')
+	}
+	return b.String(), "", i
+}
+
+// parenContent splits a signature into the text before its first
+// parenthesis, what's inside it and what follows the matching close.
+func parenContent(sig string) (before, inner, after string) {
+	open := strings.IndexByte(sig, '(')
+	if open < 0 {
+		return sig, "", ""
+	}
+	depth := 0
+	for k := open; k < len(sig); k++ {
+		switch sig[k] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return sig[:open], sig[open+1 : k], sig[k+1:]
+			}
+		}
+	}
+	return sig[:open], sig[open+1:], ""
+}
+
+// splitTopLevel splits s at sep where it isn't nested in brackets. An
+// arrow ("->", "=>") doesn't close an angle bracket.
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	depth, start := 0, 0
+	for k := 0; k < len(s); k++ {
+		switch c := s[k]; {
+		case c == '(' || c == '[' || c == '{' || c == '<':
+			depth++
+		case c == '>' && k > 0 && (s[k-1] == '-' || s[k-1] == '='):
+		case c == ')' || c == ']' || c == '}' || c == '>':
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, s[start:k])
+			start = k + 1
+		}
+	}
+	parts = append(parts, s[start:])
+	var out []string
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// cutTopLevel splits s at the first top-level sep, like strings.Cut.
+func cutTopLevel(s string, sep byte) (string, string, bool) {
+	depth := 0
+	for k := 0; k < len(s); k++ {
+		switch c := s[k]; {
+		case c == '(' || c == '[' || c == '{' || c == '<':
+			depth++
+		case c == '>' && k > 0 && (s[k-1] == '-' || s[k-1] == '='):
+		case c == ')' || c == ']' || c == '}' || c == '>':
+			depth--
+		case c == sep && depth == 0:
+			return strings.TrimSpace(s[:k]), strings.TrimSpace(s[k+1:]), true
+		}
+	}
+	return strings.TrimSpace(s), "", false
+}
+
+// lineCommentsBefore returns the comment lines starting with prefix right
+// above line i, with the prefix removed. Lines for which skip returns true
+// (attributes, decorators) may sit between the comment and line i.
+func lineCommentsBefore(lines []string, i int, prefix string, skip func(string) bool) string {
+	var doc []string
+	for j := i - 1; j >= 0; j-- {
+		t := strings.TrimSpace(lines[j])
+		if strings.HasPrefix(t, prefix) {
+			doc = append([]string{strings.TrimSpace(strings.TrimPrefix(t, prefix))}, doc...)
+			continue
+		}
+		if len(doc) == 0 && skip != nil && skip(t) {
+			continue
+		}
+		break
+	}
+	return strings.Join(doc, "\n")
+}
+
+// blockCommentBefore returns the /* ... */ (or /** ... */) comment ending
+// right above line i, without its markers and leading asterisks.
+func blockCommentBefore(lines []string, i int, skip func(string) bool) string {
+	j := i - 1
+	for j >= 0 && skip != nil && skip(strings.TrimSpace(lines[j])) {
+		j--
+	}
+	if j < 0 || !strings.HasSuffix(strings.TrimSpace(lines[j]), "*/") {
+		return ""
+	}
+	end := j
+	for j >= 0 && !strings.Contains(lines[j], "/*") {
+		j--
+	}
+	if j < 0 {
+		return ""
+	}
+	var doc []string
+	for _, l := range lines[j : end+1] {
+		l = strings.TrimSpace(l)
+		l = strings.TrimPrefix(l, "/**")
+		l = strings.TrimPrefix(l, "/*")
+		l = strings.TrimSuffix(l, "*/")
+		l = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(l), "*"))
+		if l != "" {
+			doc = append(doc, l)
+		}
+	}
+	return strings.Join(doc, "\n")
+}
+
+// braceDelta counts opening minus closing braces on a line, ignoring
+// strings and line comments well enough for scanning declarations.
+func braceDelta(line string) int {
+	d := 0
+	var quote byte
+	for k := 0; k < len(line); k++ {
+		c := line[k]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				k++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '/' && k+1 < len(line) && line[k+1] == '/':
+			return d
+		case c == '{':
+			d++
+		case c == '}':
+			d--
+		}
+	}
+	return d
+}
+
+// scope is a class, impl or trait body a scanner is inside of.
+type scope struct {
+	name  string
+	depth int // brace depth of the body
+}
+
+// scopes tracks nested bodies by brace depth for the C-like scanners.
+type scopes struct {
+	depth int
+	stack []scope
+	open  string // a scope declared on this line, pushed once its brace opens
+}
+
+// advance accounts for line; call it after the line was scanned.
+func (s *scopes) advance(line string) {
+	before := s.depth
+	s.depth += braceDelta(line)
+	if s.open != "" && s.depth > before {
+		s.stack = append(s.stack, scope{name: s.open, depth: before + 1})
+		s.open = ""
+	}
+	for len(s.stack) > 0 && s.depth < s.stack[len(s.stack)-1].depth {
+		s.stack = s.stack[:len(s.stack)-1]
+	}
+}
+
+// current returns the innermost scope if line sits directly in its body.
+func (s *scopes) current() (string, bool) {
+	if len(s.stack) == 0 {
+		return "", false
+	}
+	top := s.stack[len(s.stack)-1]
+	return top.name, s.depth == top.depth
+}
+
+func compact(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func isArrayType(t string) bool {
+	return strings.HasSuffix(t, "[]") || strings.HasPrefix(t, "[") || strings.HasPrefix(t, "&[") ||
+		strings.HasPrefix(t, "Vec<") || strings.HasPrefix(t, "Array<") ||
+		strings.HasPrefix(strings.ToLower(t), "list[")
+}
@@ -6,10 +6,11 @@ require (
 	github.com/bmatcuk/doublestar/v4 v4.9.1
 	github.com/sabhiram/go-gitignore v0.0.0-20210923224102-525f6e181f06
 	github.com/spf13/cobra v1.9.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	github.com/spf13/pflag v1.0.6 // indirect
+	github.com/spf13/pflag v1.0.6
 	github.com/toon-format/toon-go v0.0.0-20251202084852-7ca0e27c4e8c
 )
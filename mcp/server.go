@@ -0,0 +1,223 @@
+// Package mcp serves ctx3 as a Model Context Protocol server: JSON-RPC 2.0
+// messages, one per line, over a reader/writer pair (stdin and stdout for
+// `ctx3 mcp`). Only the tools capability is offered.
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/parsabordbar/ctx3/cache"
+	"github.com/parsabordbar/ctx3/pack"
+)
+
+// ProtocolVersion is the newest MCP revision the server speaks. A client
+// asking for one of supportedVersions gets that version back.
+const ProtocolVersion = "2025-06-18"
+
+var supportedVersions = map[string]bool{
+	"2024-11-05": true,
+	"2025-03-26": true,
+	"2025-06-18": true,
+}
+
+// JSON-RPC error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// maxMessageBytes bounds one incoming line.
+const maxMessageBytes = 16 << 20
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"` // absent for notifications
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string { return fmt.Sprintf("%s (%d)", e.Message, e.Code) }
+
+// Server answers MCP requests about the project at Root.
+type Server struct {
+	Root    string
+	Version string // reported in serverInfo
+	// Cache, when set before Serve, keeps packed files and function lists
+	// across calls and runs.
+	Cache *cache.Store
+	tools []Tool
+
+	readCacheOnce sync.Once
+	readCache     *pack.ReadCache
+}
+
+// NewServer returns a server exposing the ctx3 tools for root.
+func NewServer(root string) *Server {
+	s := &Server{Root: root, Version: "dev"}
+	s.tools = s.defaultTools()
+	return s
+}
+
+// packCache returns the read cache for pack_paths, nil without Cache.
+func (s *Server) packCache() *pack.ReadCache {
+	s.readCacheOnce.Do(func() {
+		if s.Cache != nil {
+			s.readCache, _ = pack.NewDiskReadCache(s.Cache, s.Root)
+		}
+	})
+	return s.readCache
+}
+
+// Tools returns the tools the server offers.
+func (s *Server) Tools() []Tool { return s.tools }
+
+// Serve reads requests from r and writes responses to w until r is
+// exhausted or ctx is done. Requests are handled one at a time, in order.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), maxMessageBytes)
+	bw := bufio.NewWriter(w)
+	for sc.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line := sc.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		resp := s.handle(ctx, line)
+		if resp == nil {
+			continue
+		}
+		data, err := json.Marshal(resp)
+		if err != nil {
+			return err
+		}
+		bw.Write(data)
+		bw.WriteByte('\n')
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// handle answers one message; it returns nil for notifications.
+func (s *Server) handle(ctx context.Context, msg []byte) *response {
+	var req request
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return &response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{codeParseError, "parse error: " + err.Error()}}
+	}
+	notification := len(req.ID) == 0
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		if notification {
+			return nil
+		}
+		return &response{JSONRPC: "2.0", ID: req.ID, Error: &rpcError{codeInvalidRequest, "invalid request"}}
+	}
+
+	result, rerr := s.dispatch(ctx, req)
+	if notification {
+		return nil
+	}
+	resp := &response{JSONRPC: "2.0", ID: req.ID, Result: result}
+	if rerr != nil {
+		resp.Result, resp.Error = nil, rerr
+	}
+	return resp
+}
+
+func (s *Server) dispatch(ctx context.Context, req request) (any, *rpcError) {
+	switch req.Method {
+	case "initialize":
+		var p struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		if len(req.Params) > 0 {
+			if err := json.Unmarshal(req.Params, &p); err != nil {
+				return nil, &rpcError{codeInvalidParams, err.Error()}
+			}
+		}
+		version := ProtocolVersion
+		if supportedVersions[p.ProtocolVersion] {
+			version = p.ProtocolVersion
+		}
+		return map[string]any{
+			"protocolVersion": version,
+			"capabilities":    map[string]any{"tools": map[string]any{"listChanged": false}},
+			"serverInfo":      map[string]any{"name": "ctx3", "version": s.Version},
+			"instructions":    "Tools for exploring the project at the server root: start with get_tree or get_context, then read_file, search or list_functions, and pack_paths to pull several files at once.",
+		}, nil
+	case "ping":
+		return map[string]any{}, nil
+	case "tools/list":
+		return map[string]any{"tools": s.tools}, nil
+	case "tools/call":
+		var p struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return nil, &rpcError{codeInvalidParams, err.Error()}
+		}
+		return s.callTool(ctx, p.Name, p.Arguments)
+	case "notifications/initialized", "notifications/cancelled":
+		return nil, nil
+	}
+	return nil, &rpcError{codeMethodNotFound, "method not found: " + req.Method}
+}
+
+// content is one block of a tool result.
+type content struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type toolResult struct {
+	Content []content `json:"content"`
+	IsError bool      `json:"isError,omitempty"`
+}
+
+// callTool runs a tool. A failing tool is reported in the result, with
+// isError set, so the model can see what went wrong; an unknown tool or
+// malformed arguments are protocol errors.
+func (s *Server) callTool(ctx context.Context, name string, args json.RawMessage) (any, *rpcError) {
+	for _, t := range s.tools {
+		if t.Name != name {
+			continue
+		}
+		if len(args) == 0 || string(args) == "null" {
+			args = json.RawMessage("{}")
+		}
+		text, err := t.run(ctx, args)
+		if err != nil {
+			var ae argError
+			if errors.As(err, &ae) {
+				return nil, &rpcError{codeInvalidParams, ae.Error()}
+			}
+			return toolResult{Content: []content{{Type: "text", Text: err.Error()}}, IsError: true}, nil
+		}
+		return toolResult{Content: []content{{Type: "text", Text: text}}}, nil
+	}
+	return nil, &rpcError{codeInvalidParams, "unknown tool: " + name}
+}
@@ -0,0 +1,292 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
+
+// client is a minimal JSON-RPC client talking to a Server over pipes.
+type client struct {
+	t    *testing.T
+	in   *io.PipeWriter
+	out  *bufio.Scanner
+	next int
+}
+
+func newClient(t *testing.T, root string) *client {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := NewServer(root).Serve(context.Background(), inR, outW)
+		outW.Close()
+		done <- err
+	}()
+	t.Cleanup(func() {
+		inW.Close()
+		if err := <-done; err != nil {
+			t.Errorf("Serve: %v", err)
+		}
+	})
+	sc := bufio.NewScanner(outR)
+	sc.Buffer(make([]byte, 64*1024), maxMessageBytes)
+	return &client{t: t, in: inW, out: sc}
+}
+
+func (c *client) send(msg any) {
+	c.t.Helper()
+	data, err := json.Marshal(msg)
+	if err != nil {
+		c.t.Fatalf("marshal: %v", err)
+	}
+	if _, err := c.in.Write(append(data, '\n')); err != nil {
+		c.t.Fatalf("write: %v", err)
+	}
+}
+
+func (c *client) receive() response {
+	c.t.Helper()
+	if !c.out.Scan() {
+		c.t.Fatalf("no response: %v", c.out.Err())
+	}
+	var resp struct {
+		response
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(c.out.Bytes(), &resp); err != nil {
+		c.t.Fatalf("bad response %s: %v", c.out.Bytes(), err)
+	}
+	resp.response.Result = resp.Result
+	return resp.response
+}
+
+// call sends a request and returns its raw result or error.
+func (c *client) call(method string, params any) (json.RawMessage, *rpcError) {
+	c.t.Helper()
+	c.next++
+	c.send(map[string]any{"jsonrpc": "2.0", "id": c.next, "method": method, "params": params})
+	resp := c.receive()
+	if string(resp.ID) != strings.TrimSpace(string(mustJSON(c.t, c.next))) {
+		c.t.Fatalf("response id %s, want %d", resp.ID, c.next)
+	}
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	return resp.Result.(json.RawMessage), nil
+}
+
+// tool calls a tool and returns its text and isError flag.
+func (c *client) tool(name string, args any) (string, bool) {
+	c.t.Helper()
+	raw, rerr := c.call("tools/call", map[string]any{"name": name, "arguments": args})
+	if rerr != nil {
+		c.t.Fatalf("%s: %v", name, rerr)
+	}
+	var res toolResult
+	if err := json.Unmarshal(raw, &res); err != nil {
+		c.t.Fatalf("%s: %v", name, err)
+	}
+	if len(res.Content) != 1 || res.Content[0].Type != "text" {
+		c.t.Fatalf("%s: unexpected content %+v", name, res.Content)
+	}
+	return res.Content[0].Text, res.IsError
+}
+
+func mustJSON(t *testing.T, v any) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func testProject(t *testing.T) string {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "go.mod"), "module example.com/demo\n\ngo 1.22\n")
+	writeTestFile(t, filepath.Join(root, "main.go"), "package main\n\n// main starts the demo.\nfunc main() {\n\tgreet(\"world\")\n}\n")
+	writeTestFile(t, filepath.Join(root, "greet", "greet.go"), "package greet\n\nfunc Hello(name string) string {\n\treturn \"hello \" + name // TODO: i18n\n}\n")
+	writeTestFile(t, filepath.Join(root, "README.md"), "# demo\n")
+	writeTestFile(t, filepath.Join(root, "dist", "bundle.js"), "function built() {}\n")
+	return root
+}
+
+func TestServer_InitializeAndListTools(t *testing.T) {
+	c := newClient(t, testProject(t))
+
+	raw, rerr := c.call("initialize", map[string]any{
+		"protocolVersion": "2025-03-26",
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]any{"name": "test", "version": "0"},
+	})
+	if rerr != nil {
+		t.Fatalf("initialize: %v", rerr)
+	}
+	var init struct {
+		ProtocolVersion string         `json:"protocolVersion"`
+		Capabilities    map[string]any `json:"capabilities"`
+		ServerInfo      struct{ Name string }
+	}
+	if err := json.Unmarshal(raw, &init); err != nil {
+		t.Fatal(err)
+	}
+	if init.ProtocolVersion != "2025-03-26" || init.Capabilities["tools"] == nil || init.ServerInfo.Name != "ctx3" {
+		t.Errorf("initialize = %s", raw)
+	}
+
+	// a notification gets no response: the next line answers the ping
+	c.send(map[string]any{"jsonrpc": "2.0", "method": "notifications/initialized"})
+	if _, rerr := c.call("ping", nil); rerr != nil {
+		t.Fatalf("ping: %v", rerr)
+	}
+
+	raw, rerr = c.call("tools/list", nil)
+	if rerr != nil {
+		t.Fatalf("tools/list: %v", rerr)
+	}
+	var list struct{ Tools []Tool }
+	if err := json.Unmarshal(raw, &list); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tool := range list.Tools {
+		names = append(names, tool.Name)
+		if tool.InputSchema["type"] != "object" {
+			t.Errorf("%s: schema type %v", tool.Name, tool.InputSchema["type"])
+		}
+	}
+	if got := strings.Join(names, ","); got != "get_tree,get_context,pack_paths,read_file,search,list_functions" {
+		t.Errorf("tools = %s", got)
+	}
+}
+
+func TestServer_Errors(t *testing.T) {
+	c := newClient(t, testProject(t))
+
+	if _, rerr := c.call("resources/list", nil); rerr == nil || rerr.Code != codeMethodNotFound {
+		t.Errorf("unknown method: %+v", rerr)
+	}
+	if _, rerr := c.call("tools/call", map[string]any{"name": "nope"}); rerr == nil || rerr.Code != codeInvalidParams {
+		t.Errorf("unknown tool: %+v", rerr)
+	}
+	if _, rerr := c.call("tools/call", map[string]any{"name": "search", "arguments": map[string]any{}}); rerr == nil || rerr.Code != codeInvalidParams {
+		t.Errorf("missing regex: %+v", rerr)
+	}
+
+	c.in.Write([]byte("{not json\n"))
+	if resp := c.receive(); resp.Error == nil || resp.Error.Code != codeParseError {
+		t.Errorf("parse error: %+v", resp)
+	}
+
+	if _, rerr := c.call("tools/call", map[string]any{"name": "read_file", "arguments": map[string]any{"path": "../outside"}}); rerr == nil {
+		t.Errorf("expected an error reading outside the root")
+	}
+	if text, isErr := c.tool("read_file", map[string]any{"path": "missing.go"}); !isErr || !strings.Contains(text, "missing.go") {
+		t.Errorf("missing file: %q (isError=%v)", text, isErr)
+	}
+}
+
+func TestServer_Tools(t *testing.T) {
+	c := newClient(t, testProject(t))
+
+	tree, _ := c.tool("get_tree", nil)
+	if !strings.Contains(tree, "greet.go") || strings.Contains(tree, "bundle.js") {
+		t.Errorf("get_tree:\n%s", tree)
+	}
+	if sub, _ := c.tool("get_tree", map[string]any{"path": "greet"}); strings.Contains(sub, "main.go") || !strings.Contains(sub, "greet.go") {
+		t.Errorf("get_tree greet:\n%s", sub)
+	}
+
+	ctxText, _ := c.tool("get_context", nil)
+	var pc struct {
+		Files []struct{ Path string }
+	}
+	if err := json.Unmarshal([]byte(ctxText), &pc); err != nil || len(pc.Files) == 0 {
+		t.Errorf("get_context: %v\n%s", err, ctxText)
+	}
+
+	packed, _ := c.tool("pack_paths", map[string]any{"globs": []string{"**/*.go"}})
+	if !strings.Contains(packed, `<file path="greet/greet.go">`) || strings.Contains(packed, "README.md") {
+		t.Errorf("pack_paths:\n%s", packed)
+	}
+	capped, _ := c.tool("pack_paths", map[string]any{"globs": []string{"**/*.go"}, "max_tokens": 20})
+	if !strings.Contains(capped, "(max-total-tokens)") {
+		t.Errorf("pack_paths with max_tokens should list left-out files:\n%s", capped)
+	}
+
+	lines, _ := c.tool("read_file", map[string]any{"path": "main.go", "start_line": 3, "end_line": 4})
+	want := "main.go (lines 3-4 of 6)\n     3\t// main starts the demo.\n     4\tfunc main() {\n"
+	if lines != want {
+		t.Errorf("read_file = %q, want %q", lines, want)
+	}
+
+	found, _ := c.tool("search", map[string]any{"regex": "todo", "ignore_case": true})
+	if found != "greet/greet.go:4: \treturn \"hello \" + name // TODO: i18n\n" {
+		t.Errorf("search = %q", found)
+	}
+	if none, _ := c.tool("search", map[string]any{"regex": "TODO", "globs": []string{"*.md"}}); none != "no matches" {
+		t.Errorf("search with globs = %q", none)
+	}
+
+	fnText, _ := c.tool("list_functions", map[string]any{"path": "greet"})
+	var fns struct {
+		Functions []struct{ Name, Path string }
+	}
+	if err := json.Unmarshal([]byte(fnText), &fns); err != nil {
+		t.Fatal(err)
+	}
+	if len(fns.Functions) != 1 || fns.Functions[0].Name != "Hello" || fns.Functions[0].Path != "greet/greet.go" {
+		t.Errorf("list_functions = %+v", fns.Functions)
+	}
+}
+
+func TestServer_GetContextStaysInsideRoot(t *testing.T) {
+	root := testProject(t)
+	outside := t.TempDir()
+	writeTestFile(t, filepath.Join(outside, "notes.md"), "top secret\n")
+	readme := filepath.Join(root, "README.md")
+	if err := os.Remove(readme); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "notes.md"), readme); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+	c := newClient(t, root)
+
+	ctxText, _ := c.tool("get_context", nil)
+	if strings.Contains(ctxText, "top secret") {
+		t.Errorf("get_context read outside the root:\n%s", ctxText)
+	}
+}
+
+func TestServer_SearchStaysInsideRoot(t *testing.T) {
+	root := testProject(t)
+	outside := t.TempDir()
+	writeTestFile(t, filepath.Join(outside, "creds"), "SECRET_TOKEN=abc\n")
+	if err := os.Symlink(filepath.Join(outside, "creds"), filepath.Join(root, "link.txt")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+	c := newClient(t, root)
+
+	if found, _ := c.tool("search", map[string]any{"regex": "SECRET_TOKEN"}); found != "no matches" {
+		t.Errorf("search read outside the root: %q", found)
+	}
+}
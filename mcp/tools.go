@@ -0,0 +1,423 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/parsabordbar/ctx3/analyzer"
+	"github.com/parsabordbar/ctx3/filetree"
+	"github.com/parsabordbar/ctx3/functions"
+	"github.com/parsabordbar/ctx3/pack"
+)
+
+// Tool is one tool the server offers. InputSchema is the JSON Schema of
+// its arguments.
+type Tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+
+	run func(ctx context.Context, args json.RawMessage) (string, error)
+}
+
+// argError marks arguments a tool can't work with; the client gets an
+// invalid-params error instead of a tool result.
+type argError struct{ msg string }
+
+func (e argError) Error() string { return "invalid arguments: " + e.msg }
+
+// Limits that keep a single answer a reasonable size.
+const (
+	defaultSearchResults = 100
+	maxSearchLine        = 300
+	maxSearchFileBytes   = 1 << 20
+	maxReadLines         = 2000
+)
+
+func decodeArgs(args json.RawMessage, v any) error {
+	if err := json.Unmarshal(args, v); err != nil {
+		return argError{err.Error()}
+	}
+	return nil
+}
+
+func schema(required []string, props map[string]any) map[string]any {
+	s := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+func prop(typ, desc string) map[string]any {
+	return map[string]any{"type": typ, "description": desc}
+}
+
+func (s *Server) defaultTools() []Tool {
+	return []Tool{
+		{
+			Name:        "get_tree",
+			Description: "File tree of the project (or of a directory in it), with the shared ignore rules and .gitignore applied.",
+			InputSchema: schema(nil, map[string]any{
+				"path":   prop("string", "Directory relative to the project root (default: the root)"),
+				"depth":  prop("integer", "Maximum depth to show (0 = unlimited)"),
+				"format": map[string]any{"type": "string", "enum": []string{"text", "json", "md"}, "description": "Output format (default: text)"},
+			}),
+			run: s.getTree,
+		},
+		{
+			Name:        "get_context",
+			Description: "Project overview as JSON: languages, entry points, dependencies and per-file line and token counts.",
+			InputSchema: schema(nil, map[string]any{}),
+			run:         s.getContext,
+		},
+		{
+			Name:        "pack_paths",
+			Description: "Contents of the files matching the globs in one document, in the format of `ctx3 pack`. Files beyond max_tokens are left out and listed at the end.",
+			InputSchema: schema([]string{"globs"}, map[string]any{
+				"globs":      map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Doublestar globs relative to the root, e.g. **/*.go"},
+				"max_tokens": prop("integer", "Estimated token budget for the file contents (0 = unlimited)"),
+				"format":     map[string]any{"type": "string", "enum": []string{"xml", "md"}, "description": "Output format (default: xml)"},
+			}),
+			run: s.packPaths,
+		},
+		{
+			Name:        "read_file",
+			Description: "Lines of a text file, numbered. Ranges are 1-based and inclusive.",
+			InputSchema: schema([]string{"path"}, map[string]any{
+				"path":       prop("string", "File path relative to the project root"),
+				"start_line": prop("integer", "First line (default 1)"),
+				"end_line":   prop("integer", fmt.Sprintf("Last line (default: start_line + %d)", maxReadLines-1)),
+			}),
+			run: s.readFile,
+		},
+		{
+			Name:        "search",
+			Description: "Lines matching a regular expression (Go RE2 syntax), as path:line: text.",
+			InputSchema: schema([]string{"regex"}, map[string]any{
+				"regex":       prop("string", "Regular expression"),
+				"globs":       map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Only search files matching one of these globs"},
+				"ignore_case": prop("boolean", "Match case-insensitively"),
+				"max_results": prop("integer", fmt.Sprintf("Stop after this many matches (default %d)", defaultSearchResults)),
+			}),
+			run: s.search,
+		},
+		{
+			Name:        "list_functions",
+			Description: "Functions and methods declared in Go, Python, JavaScript, TypeScript, Rust and C files, as JSON.",
+			InputSchema: schema(nil, map[string]any{
+				"path": prop("string", "Only list functions in this file or below this directory"),
+				"name": prop("string", "Only list functions whose name contains this text"),
+			}),
+			run: s.listFunctions,
+		},
+	}
+}
+
+func (s *Server) rules() (*filetree.IgnoreRules, error) {
+	return filetree.LoadIgnoreRules(s.Root, false)
+}
+
+// resolve is filetree.Resolve against Root; leaving the root is an
+// argument error.
+func (s *Server) resolve(p string) (string, string, error) {
+	abs, rel, err := filetree.Resolve(s.Root, p)
+	if errors.Is(err, filetree.ErrOutsideRoot) {
+		return "", "", argError{fmt.Sprintf("%s is outside the project", p)}
+	}
+	return abs, rel, err
+}
+
+func (s *Server) getTree(ctx context.Context, args json.RawMessage) (string, error) {
+	var a struct {
+		Path   string `json:"path"`
+		Depth  int    `json:"depth"`
+		Format string `json:"format"`
+	}
+	if err := decodeArgs(args, &a); err != nil {
+		return "", err
+	}
+	format := a.Format
+	if format == "" {
+		format = filetree.FormatText
+	}
+	if format != filetree.FormatText && format != filetree.FormatJSON && format != filetree.FormatMD {
+		return "", argError{"format must be text, json or md"}
+	}
+	dir := s.Root
+	if a.Path != "" {
+		abs, _, err := s.resolve(a.Path)
+		if err != nil {
+			return "", err
+		}
+		dir = abs
+	}
+	rules, err := s.rules()
+	if err != nil {
+		return "", err
+	}
+	tree, err := filetree.Build(ctx, dir, filetree.Options{Rules: rules, RespectGitignore: true})
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := filetree.Render(&buf, tree, format, filetree.RenderOptions{Depth: a.Depth}); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func (s *Server) getContext(ctx context.Context, _ json.RawMessage) (string, error) {
+	rules, err := s.rules()
+	if err != nil {
+		return "", err
+	}
+	pc, err := analyzer.AnalyzeProjectWithOptions(ctx, s.Root, analyzer.AnalyzeOptions{RespectGitignore: true, Ignores: rules})
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(pc, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (s *Server) packPaths(ctx context.Context, args json.RawMessage) (string, error) {
+	var a struct {
+		Globs     []string `json:"globs"`
+		MaxTokens int      `json:"max_tokens"`
+		Format    string   `json:"format"`
+	}
+	if err := decodeArgs(args, &a); err != nil {
+		return "", err
+	}
+	if len(a.Globs) == 0 {
+		return "", argError{"globs is required"}
+	}
+	if a.MaxTokens < 0 {
+		return "", argError{"max_tokens must be >= 0"}
+	}
+	format := pack.FormatXML
+	switch a.Format {
+	case "", "xml":
+	case "md":
+		format = pack.FormatMD
+	default:
+		return "", argError{"format must be xml or md"}
+	}
+	rules, err := s.rules()
+	if err != nil {
+		return "", err
+	}
+
+	out, report, err := pack.Pack(ctx, pack.Config{
+		RootDir:          s.Root,
+		OutputFormat:     format,
+		RespectGitignore: true,
+		IncludeGlobs:     a.Globs,
+		MaxTotalTokens:   a.MaxTokens,
+		BinaryHandling:   pack.BinarySkip,
+		Sections:         pack.Sections{Structure: true, Files: true},
+		Ignores:          rules,
+		Cache:            s.packCache(),
+	})
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	b.Write(out)
+	fmt.Fprintf(&b, "\nPacked %d files, ~%d tokens.", report.FilesIncluded, report.TotalTokens)
+	if len(report.Skipped) > 0 {
+		b.WriteString(" Left out:")
+		for _, sk := range report.Skipped {
+			fmt.Fprintf(&b, "\n- %s (%s)", sk.Path, sk.Reason)
+		}
+	}
+	b.WriteByte('\n')
+	return b.String(), nil
+}
+
+func (s *Server) readFile(_ context.Context, args json.RawMessage) (string, error) {
+	var a struct {
+		Path      string `json:"path"`
+		StartLine int    `json:"start_line"`
+		EndLine   int    `json:"end_line"`
+	}
+	if err := decodeArgs(args, &a); err != nil {
+		return "", err
+	}
+	if a.Path == "" {
+		return "", argError{"path is required"}
+	}
+	start := max(a.StartLine, 1)
+	end := a.EndLine
+	if end == 0 {
+		end = start + maxReadLines - 1
+	}
+	if end < start {
+		return "", argError{"end_line is before start_line"}
+	}
+	abs, rel, err := s.resolve(a.Path)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return "", err
+	}
+	if bytes.IndexByte(data[:min(len(data), 8192)], 0) >= 0 {
+		return "", fmt.Errorf("%s is a binary file", rel)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if start > len(lines) {
+		return "", fmt.Errorf("%s has %d lines", rel, len(lines))
+	}
+	end = min(end, len(lines))
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (lines %d-%d of %d)\n", rel, start, end, len(lines))
+	for i := start; i <= end; i++ {
+		fmt.Fprintf(&b, "%6d\t%s\n", i, lines[i-1])
+	}
+	return b.String(), nil
+}
+
+func (s *Server) search(ctx context.Context, args json.RawMessage) (string, error) {
+	var a struct {
+		Regex      string   `json:"regex"`
+		Globs      []string `json:"globs"`
+		IgnoreCase bool     `json:"ignore_case"`
+		MaxResults int      `json:"max_results"`
+	}
+	if err := decodeArgs(args, &a); err != nil {
+		return "", err
+	}
+	if a.Regex == "" {
+		return "", argError{"regex is required"}
+	}
+	expr := a.Regex
+	if a.IgnoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return "", argError{err.Error()}
+	}
+	limit := a.MaxResults
+	if limit <= 0 {
+		limit = defaultSearchResults
+	}
+	rules, err := s.rules()
+	if err != nil {
+		return "", err
+	}
+	tree, err := filetree.Build(ctx, s.Root, filetree.Options{Rules: rules, RespectGitignore: true, IncludeGlobs: a.Globs})
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	matches := 0
+	errDone := errors.New("done")
+	err = walkFiles(tree, func(n *filetree.Node) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if n.Size > maxSearchFileBytes {
+			return nil
+		}
+		// like read_file: links are followed inside the project only, and
+		// pipes and devices, which could block, are not read
+		abs, _, err := s.resolve(n.Path)
+		if err != nil {
+			return nil
+		}
+		if info, err := os.Stat(abs); err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+		data, err := os.ReadFile(abs)
+		if err != nil || bytes.IndexByte(data[:min(len(data), 8192)], 0) >= 0 {
+			return nil
+		}
+		sc := bufio.NewScanner(bytes.NewReader(data))
+		sc.Buffer(make([]byte, 64*1024), maxSearchFileBytes)
+		for line := 1; sc.Scan(); line++ {
+			text := sc.Text()
+			if !re.MatchString(text) {
+				continue
+			}
+			if len(text) > maxSearchLine {
+				text = text[:maxSearchLine] + "…"
+			}
+			fmt.Fprintf(&b, "%s:%d: %s\n", n.Path, line, strings.TrimRight(text, "\r"))
+			if matches++; matches >= limit {
+				return errDone
+			}
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errDone):
+		fmt.Fprintf(&b, "(stopped after %d matches)\n", limit)
+	case err != nil:
+		return "", err
+	case matches == 0:
+		return "no matches", nil
+	}
+	return b.String(), nil
+}
+
+// walkFiles calls fn for every file below n, in tree order.
+func walkFiles(n *filetree.Node, fn func(*filetree.Node) error) error {
+	for _, c := range n.Children {
+		var err error
+		if c.IsDir {
+			err = walkFiles(c, fn)
+		} else {
+			err = fn(c)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) listFunctions(ctx context.Context, args json.RawMessage) (string, error) {
+	var a struct {
+		Path string `json:"path"`
+		Name string `json:"name"`
+	}
+	if err := decodeArgs(args, &a); err != nil {
+		return "", err
+	}
+	rel := ""
+	if a.Path != "" {
+		var err error
+		if _, rel, err = s.resolve(a.Path); err != nil {
+			return "", err
+		}
+	}
+	rules, err := s.rules()
+	if err != nil {
+		return "", err
+	}
+
+	fc, err := functions.AnalyzeFunctionsWithOptions(ctx, s.Root, functions.Options{Rules: rules, Cache: s.Cache})
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(fc.Filter(rel, a.Name), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,281 @@
+package pack
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/parsabordbar/ctx3/analyzer"
+	"github.com/parsabordbar/ctx3/cache"
+)
+
+// ReadCache remembers the files a pack read, so the next pack re-reads
+// only the files whose size or modification time changed. It is safe for
+// concurrent use; `ctx3 pack --watch` keeps one across rebuilds.
+//
+// A cache belongs to one root. What it holds is keyed by the options that
+// shape file content (binary, lockfile, redact, ...), so packs with other
+// options read again rather than get stale results.
+type ReadCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedRead
+	reads   int
+
+	store *cache.Store // nil for memory only
+	index *cache.Index
+}
+
+type cachedRead struct {
+	size    int64
+	modTime time.Time
+	options string // optionsKey of the pack that read it
+	res     readResult
+}
+
+// readResult is what packing a file produced. A non-empty skipped means
+// the file is left out.
+type readResult struct {
+	entry   FileEntry
+	size    int64 // bytes of packed content
+	skipped Rule
+	tokens  int
+}
+
+// storedRead is a readResult on disk, without the path: equal content
+// under an equal file name packs the same anywhere.
+type storedRead struct {
+	Size      int64
+	IsBinary  bool
+	Content   []byte
+	Summary   string
+	N         int64
+	Skipped   Rule
+	Tokens    int
+	Truncated string
+	Encoding  string
+}
+
+// cacheKind is the kind of Store entries holding packed files. Bump the
+// version when transform changes what it produces.
+const cacheKind = "pack-v5"
+
+func NewReadCache() *ReadCache {
+	return &ReadCache{entries: map[string]cachedRead{}}
+}
+
+// NewDiskReadCache returns a cache for packs of root that also keeps the
+// packed files in store, for later runs.
+func NewDiskReadCache(store *cache.Store, root string) (*ReadCache, error) {
+	index, err := store.Index(root)
+	if err != nil {
+		return nil, err
+	}
+	c := NewReadCache()
+	c.store, c.index = store, index
+	return c, nil
+}
+
+// Invalidate forgets rels ('/'-separated, relative to the pack root), so
+// they are re-read even if their size and modification time look the same.
+func (c *ReadCache) Invalidate(rels ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, rel := range rels {
+		delete(c.entries, rel)
+		if c.index != nil {
+			c.index.Forget(rel)
+		}
+	}
+}
+
+// Reads returns how many files were read and packed through the cache
+// rather than answered by it.
+func (c *ReadCache) Reads() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reads
+}
+
+// read packs one file, answered from the cache when the file hasn't
+// changed. A nil cache always reads.
+func (c *ReadCache) read(rel, abs string, cfg Config) (readResult, error) {
+	if c == nil {
+		return readUncached(rel, abs, cfg)
+	}
+	// stat before reading: a write during the read leaves a newer
+	// modification time behind, so the next pack reads the file again
+	info, err := os.Stat(abs)
+	if err != nil {
+		c.forget(rel)
+		return readResult{entry: FileEntry{RelPath: rel}, skipped: RuleReadError}, err
+	}
+	if !info.Mode().IsRegular() {
+		// replaced by a pipe or device since the walk
+		return readResult{entry: FileEntry{RelPath: rel}, skipped: RuleSpecial}, nil
+	}
+	options := optionsKey(cfg)
+	c.mu.Lock()
+	cr, ok := c.entries[rel]
+	c.mu.Unlock()
+	if ok && cr.size == info.Size() && cr.modTime.Equal(info.ModTime()) && cr.options == options {
+		return cr.res, nil
+	}
+
+	var res readResult
+	var rerr error
+	if c.store != nil {
+		res, rerr = c.readStored(rel, abs, info, cfg, options)
+	} else {
+		res, rerr = readUncached(rel, abs, cfg)
+		c.mu.Lock()
+		c.reads++
+		c.mu.Unlock()
+	}
+	if rerr != nil {
+		c.forget(rel)
+		return res, rerr
+	}
+	c.mu.Lock()
+	c.entries[rel] = cachedRead{size: info.Size(), modTime: info.ModTime(), options: options, res: res}
+	c.mu.Unlock()
+	return res, nil
+}
+
+// readStored answers from the store by content hash, reading the file
+// only when the index doesn't know its hash already.
+func (c *ReadCache) readStored(rel, abs string, info os.FileInfo, cfg Config, options string) (readResult, error) {
+	fail := func(err error) (readResult, error) {
+		return readResult{entry: FileEntry{RelPath: rel, Size: info.Size()}, skipped: RuleReadError}, err
+	}
+	sum, ok := c.index.Lookup(rel, info)
+	if !ok {
+		var err error
+		if sum, err = cache.SumFile(abs); err != nil {
+			return fail(err)
+		}
+		c.index.Record(rel, info, sum)
+	}
+
+	// a lockfile summary also depends on the manifest next to it
+	storable := !(cfg.Lockfiles == LockfilesSummarize && analyzer.IsLockfile(path.Base(rel)))
+	// whether a file is generated or vendored may depend on its whole path
+	name := path.Base(rel)
+	if cfg.judgesOrigin(rel) {
+		name = rel
+	}
+	key := cache.Key(sum, name, options)
+	var sr storedRead
+	if storable && c.store.Get(cacheKind, key, &sr) {
+		return readResult{
+			entry:   FileEntry{RelPath: rel, Size: sr.Size, IsBinary: sr.IsBinary, Content: sr.Content, Summary: sr.Summary, Truncated: sr.Truncated, Encoding: sr.Encoding},
+			size:    sr.N,
+			skipped: sr.Skipped,
+			tokens:  sr.Tokens,
+		}, nil
+	}
+
+	// as in readOne, files left out for what their first bytes show are
+	// not read further
+	head, err := readHead(abs)
+	if err != nil {
+		return fail(err)
+	}
+	entry, n, skipped, byHead := skipByHead(rel, info.Size(), head, cfg)
+	if byHead {
+		// the hash from the index wasn't checked against the content
+		storable = storable && !ok
+	} else {
+		all, err := os.ReadFile(abs)
+		if err != nil {
+			return fail(err)
+		}
+		if cache.Sum(all) != sum {
+			// changed since it was indexed, without touching size or mtime
+			sum = cache.Sum(all)
+			c.index.Record(rel, info, sum)
+			key = cache.Key(sum, name, options)
+		}
+		entry, n, skipped = transform(rel, abs, int64(len(all)), all, cfg)
+	}
+	res := readResult{entry: entry, size: n, skipped: skipped, tokens: analyzer.EstimateTokens(entry.Content)}
+	c.mu.Lock()
+	c.reads++
+	c.mu.Unlock()
+	if storable {
+		// a failed write only costs the next run a read
+		c.store.Put(cacheKind, key, storedRead{
+			Size: entry.Size, IsBinary: entry.IsBinary, Content: entry.Content, Summary: entry.Summary,
+			N: n, Skipped: skipped, Tokens: res.tokens, Truncated: entry.Truncated, Encoding: entry.Encoding,
+		})
+	}
+	return res, nil
+}
+
+func (c *ReadCache) forget(rel string) {
+	c.mu.Lock()
+	delete(c.entries, rel)
+	c.mu.Unlock()
+	if c.index != nil {
+		c.index.Forget(rel)
+	}
+}
+
+// save writes the index of a disk-backed cache back.
+func (c *ReadCache) save() error {
+	if c == nil || c.index == nil {
+		return nil
+	}
+	return c.index.Save()
+}
+
+// retain drops the files not in rels, e.g. deleted since the last pack.
+func (c *ReadCache) retain(rels []string) {
+	if c == nil {
+		return
+	}
+	keep := make(map[string]bool, len(rels))
+	for _, rel := range rels {
+		keep[rel] = true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for rel := range c.entries {
+		if !keep[rel] {
+			delete(c.entries, rel)
+		}
+	}
+}
+
+// optionsKey sums up the options that change what transform produces.
+func optionsKey(cfg Config) string {
+	excerpt := ""
+	if cfg.excerpts() {
+		excerpt = fmt.Sprint(cfg.LargeFiles, cfg.MaxFileBytes, cfg.HeadLines, cfg.TailLines, cfg.SampleRecords)
+	}
+	generated := ""
+	if cfg.Generated == GeneratedSkip || cfg.Generated == GeneratedStub {
+		generated = string(cfg.Generated) + cfg.Attributes.Sum()
+	}
+	// converted text is capped and excerpted whatever the mode
+	exts := cfg.Convert
+	if exts == nil {
+		exts = DefaultConvert
+	}
+	var convert []string
+	for _, ext := range exts {
+		if conv := ConverterFor(ext); conv != nil {
+			convert = append(convert, ext+"="+conv.Name())
+		}
+	}
+	convert = append(convert, fmt.Sprint(cfg.NotebookOutputLines, cfg.SampleRecords, cfg.MaxFileBytes, cfg.LargeFiles))
+	return cache.Key(string(cfg.BinaryHandling), string(cfg.Lockfiles), strings.Join(cfg.RedactPatterns, "\x00"), excerpt, generated, string(cfg.EncodingFallback), strings.Join(convert, ","))
+}
+
+// readUncached is readOne with the token estimate.
+func readUncached(rel, abs string, cfg Config) (readResult, error) {
+	entry, n, skipped, err := readOne(rel, abs, cfg)
+	return readResult{entry: entry, size: n, skipped: skipped, tokens: analyzer.EstimateTokens(entry.Content)}, err
+}
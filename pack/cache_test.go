@@ -0,0 +1,149 @@
+package pack
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parsabordbar/ctx3/cache"
+)
+
+func TestReadCache_RereadsOnlyChangedFiles(t *testing.T) {
+	td := t.TempDir()
+	writeFile(t, filepath.Join(td, "a.go"), []byte("package a\n"))
+	writeFile(t, filepath.Join(td, "b.go"), []byte("package b\n"))
+	writeFile(t, filepath.Join(td, "c.go"), []byte("package c\n"))
+
+	cache := NewReadCache()
+	cfg := Config{RootDir: td, OutputFormat: FormatXML, Sections: Sections{Files: true}, Cache: cache}
+	pack := func() string {
+		t.Helper()
+		out, _, err := Pack(context.Background(), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(out)
+	}
+
+	pack()
+	if got := cache.Reads(); got != 3 {
+		t.Fatalf("first pack read %d files, want 3", got)
+	}
+	pack()
+	if got := cache.Reads(); got != 3 {
+		t.Fatalf("unchanged pack read %d more files, want 0", got-3)
+	}
+
+	writeFile(t, filepath.Join(td, "b.go"), []byte("package b // edited\n"))
+	if out := pack(); !strings.Contains(out, "// edited") {
+		t.Errorf("edit not packed:\n%s", out)
+	}
+	if got := cache.Reads(); got != 4 {
+		t.Errorf("after an edit, %d files read in all, want 4", got)
+	}
+
+	// same size and time: only Invalidate makes it read again
+	info, _ := os.Stat(filepath.Join(td, "c.go"))
+	writeFile(t, filepath.Join(td, "c.go"), []byte("package C\n"))
+	os.Chtimes(filepath.Join(td, "c.go"), time.Time{}, info.ModTime())
+	if out := pack(); strings.Contains(out, "package C") {
+		t.Errorf("expected the stale cached c.go:\n%s", out)
+	}
+	cache.Invalidate("c.go")
+	if out := pack(); !strings.Contains(out, "package C") {
+		t.Errorf("invalidated c.go not re-read:\n%s", out)
+	}
+
+	os.Remove(filepath.Join(td, "a.go"))
+	if out := pack(); strings.Contains(out, "a.go") {
+		t.Errorf("deleted a.go still packed:\n%s", out)
+	}
+	if _, ok := cache.entries["a.go"]; ok {
+		t.Errorf("deleted a.go still cached")
+	}
+}
+
+func TestDiskReadCache(t *testing.T) {
+	td := t.TempDir()
+	writeFile(t, filepath.Join(td, "a.go"), []byte("package a // secret=hunter2\n"))
+	writeFile(t, filepath.Join(td, "b.go"), []byte("package b\n"))
+	// old enough for the index to trust size and mtime
+	past := time.Now().Add(-time.Hour)
+	for _, name := range []string{"a.go", "b.go"} {
+		os.Chtimes(filepath.Join(td, name), past, past)
+	}
+	store, err := cache.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every run gets a fresh ReadCache, as separate ctx3 invocations do
+	run := func(redact ...string) (string, Report, int) {
+		t.Helper()
+		rc, err := NewDiskReadCache(store, td)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg := Config{RootDir: td, OutputFormat: FormatXML, Sections: Sections{Files: true}, RedactPatterns: redact, Cache: rc}
+		out, rep, err := Pack(context.Background(), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(out), rep, rc.Reads()
+	}
+
+	first, rep1, reads := run(`hunter\d`)
+	if reads != 2 || !strings.Contains(first, "secret=***") {
+		t.Fatalf("first run: %d reads\n%s", reads, first)
+	}
+	second, rep2, reads := run(`hunter\d`)
+	if reads != 0 || second != first || rep2.TotalTokens != rep1.TotalTokens {
+		t.Errorf("second run: %d reads, tokens %d vs %d\n%s", reads, rep2.TotalTokens, rep1.TotalTokens, second)
+	}
+
+	// other redact patterns are other entries
+	if out, _, reads := run(); reads != 2 || !strings.Contains(out, "hunter2") {
+		t.Errorf("run without --redact: %d reads\n%s", reads, out)
+	}
+
+	// a touched file with the same content is hashed, not packed again
+	now := time.Now().Add(-time.Minute)
+	os.Chtimes(filepath.Join(td, "b.go"), now, now)
+	if _, _, reads := run(`hunter\d`); reads != 0 {
+		t.Errorf("touched file: %d reads, want 0", reads)
+	}
+
+	writeFile(t, filepath.Join(td, "b.go"), []byte("package b // edited\n"))
+	if out, _, reads := run(`hunter\d`); reads != 1 || !strings.Contains(out, "edited") {
+		t.Errorf("edited file: %d reads\n%s", reads, out)
+	}
+}
+
+func TestDiskReadCache_SkipsByHead(t *testing.T) {
+	td := t.TempDir()
+	bin := append([]byte{0, 1, 2, 3}, make([]byte, 1<<20)...)
+	writeFile(t, filepath.Join(td, "blob.bin"), bin)
+	writeFile(t, filepath.Join(td, "a.go"), []byte("package a\n"))
+	store, err := cache.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for run := 0; run < 2; run++ {
+		rc, err := NewDiskReadCache(store, td)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg := Config{RootDir: td, OutputFormat: FormatXML, Sections: Sections{Files: true}, BinaryHandling: BinarySkip, Cache: rc}
+		out, rep, err := Pack(context.Background(), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(out), "package a") || strings.Contains(string(out), `path="blob.bin"`) || len(rep.Skipped) != 1 || rep.Skipped[0].Reason != RuleBinary {
+			t.Errorf("run %d: skipped %+v\n%s", run, rep.Skipped, out)
+		}
+	}
+}
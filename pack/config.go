@@ -1,6 +1,15 @@
 package pack
 
-import "runtime"
+import (
+	"errors"
+	"fmt"
+	"path"
+	"runtime"
+	"strings"
+
+	"github.com/parsabordbar/ctx3/analyzer"
+	"github.com/parsabordbar/ctx3/filetree"
+)
 
 type OutputFormat string
 
@@ -18,6 +27,83 @@ const (
 	BinaryBase64 BinaryStrategy = "base64"
 )
 
+type LockfileMode string
+
+const (
+	LockfilesFull      LockfileMode = "full"
+	LockfilesSummarize LockfileMode = "summarize"
+	LockfilesSkip      LockfileMode = "skip"
+)
+
+// LargeFileMode says what happens to a file over Config.MaxFileBytes.
+type LargeFileMode string
+
+const (
+	LargeFilesSkip LargeFileMode = "skip"
+	// LargeFilesTruncate keeps the first and last lines of the file.
+	LargeFilesTruncate LargeFileMode = "truncate"
+	// LargeFilesSample keeps the keys or header and the first records of
+	// JSON, YAML and CSV files, and truncates the others.
+	LargeFilesSample LargeFileMode = "sample"
+)
+
+// Defaults for the excerpts of large files.
+const (
+	DefaultHeadLines     = 100
+	DefaultTailLines     = 20
+	DefaultSampleRecords = 5
+)
+
+// GeneratedPolicy says what happens to generated, minified and vendored
+// files (see analyzer.DetectOrigin). Lockfiles follow LockfileMode instead.
+type GeneratedPolicy string
+
+const (
+	GeneratedInclude GeneratedPolicy = "include"
+	GeneratedSkip    GeneratedPolicy = "skip"
+	// GeneratedStub keeps a one-line placeholder saying why the file was
+	// left out.
+	GeneratedStub GeneratedPolicy = "stub"
+)
+
+// EncodingFallback says how text is read when its encoding can't be
+// detected.
+type EncodingFallback string
+
+const (
+	// EncodingFallbackNone packs the bytes as they are.
+	EncodingFallbackNone EncodingFallback = "none"
+	// EncodingFallbackLatin1 reads them as ISO-8859-1, where every byte is
+	// a character.
+	EncodingFallbackLatin1 EncodingFallback = "latin1"
+)
+
+// SymlinkPolicy says what happens to symbolic links.
+type SymlinkPolicy string
+
+const (
+	// SymlinksSkip leaves links out of the pack and the tree.
+	SymlinksSkip SymlinkPolicy = "skip"
+	// SymlinksRecord lists links in the tree as "name -> target" without
+	// packing what they point to.
+	SymlinksRecord SymlinkPolicy = "record"
+	// SymlinksFollowWithinRoot packs what a link points to when it is
+	// inside the root, and records the other links.
+	SymlinksFollowWithinRoot SymlinkPolicy = "follow-within-root"
+	// SymlinksFollow packs what every link points to.
+	SymlinksFollow SymlinkPolicy = "follow"
+)
+
+// ErrorPolicy says what happens when a file or directory can't be read.
+type ErrorPolicy string
+
+const (
+	// OnErrorSkip records the error in Report.Errors and packs the rest.
+	OnErrorSkip ErrorPolicy = "skip"
+	// OnErrorFail stops at the first error and returns every error seen.
+	OnErrorFail ErrorPolicy = "fail"
+)
+
 type Sections struct {
 	Structure bool
 	Files     bool
@@ -32,14 +118,58 @@ type Config struct {
 	IgnoreGlobs      []string
 	MaxFileBytes     int64
 	MaxTotalBytes    int64
+	MaxTotalTokens   int // estimated LLM tokens of packed content; 0 => unlimited
 	BinaryHandling   BinaryStrategy
 	SortByExt        bool // false = sort by path
 	Sections         Sections
 	RedactPatterns   []string
-	Concurrency      int // 0 or <0 => auto
+	Concurrency      int          // 0 or <0 => auto
+	Lockfiles        LockfileMode // "" => full
+	OnError          ErrorPolicy  // "" => skip
+
+	// LargeFiles says what happens to files over MaxFileBytes; they are
+	// skipped by default. Excerpts fit in MaxFileBytes.
+	LargeFiles    LargeFileMode // "" => skip
+	HeadLines     int           // lines kept from the start of a truncated file; 0 => DefaultHeadLines
+	TailLines     int           // lines kept from its end; 0 => DefaultTailLines
+	SampleRecords int           // records kept by LargeFilesSample; 0 => DefaultSampleRecords
+
+	// Generated says what happens to generated, minified and vendored
+	// files. Attributes holds the linguist-generated and linguist-vendored
+	// attributes; nil loads RootDir/.gitattributes.
+	Generated  GeneratedPolicy // "" => include
+	Attributes *analyzer.Attributes
+
+	// Text files are transcoded to UTF-8 from the encoding detected by
+	// their byte order mark or content; EncodingFallback applies when
+	// none is.
+	EncodingFallback EncodingFallback // "" => none
+
+	// Convert lists the extensions (".ipynb") of the files packed as the
+	// text their registered Converter extracts; MaxFileBytes then applies
+	// to that text. nil converts DefaultConvert, an empty list nothing.
+	Convert             []string
+	NotebookOutputLines int // lines of text output kept per notebook cell; 0 drops outputs
+
+	// Symlinks says which symbolic links are followed. Devices, named
+	// pipes and sockets are never read.
+	Symlinks SymlinkPolicy // "" => follow-within-root
+
+	// Ignores hides directories and files shared with print, context and
+	// percentage (build outputs, caches, ...). nil loads them from RootDir
+	// with filetree.LoadIgnoreRules.
+	Ignores *filetree.IgnoreRules
+
+	// Subdirs restricts packing to these directories (relative to RootDir,
+	// '/'-separated). Empty means the whole root. Ignore rules still apply.
+	Subdirs []string
 
 	// When true, removes extra blank lines between sections and files.
 	Compact bool
+
+	// Cache, when set, serves the unchanged files of a previous pack
+	// instead of reading them again.
+	Cache *ReadCache
 }
 
 type FileEntry struct {
@@ -47,13 +177,73 @@ type FileEntry struct {
 	Size     int64
 	IsBinary bool
 	Content  []byte // omitted when skipped
+	Summary  string // non-empty when Content replaces the original (e.g. "lockfile")
+	// Truncated is non-empty when Content is an excerpt of a large file:
+	// TruncatedHeadTail or TruncatedSample.
+	Truncated string
+	// Encoding is what a text file was transcoded from (EncodingUTF8,
+	// EncodingUTF16LE, ...). It is empty for binary files and for text in
+	// an undetected encoding, which is packed as it is.
+	Encoding string
 }
 
 type Report struct {
-	FilesIncluded int
-	FilesSkipped  int
-	TotalBytes    int64
-	Warnings      []string
+	FilesIncluded int   `json:"filesIncluded"`
+	FilesSkipped  int   `json:"filesSkipped"`
+	TotalBytes    int64 `json:"totalBytes"`
+	// TotalTokens estimates the LLM tokens of the packed file contents.
+	TotalTokens int `json:"totalTokens"`
+	// Skipped lists the files that passed the ignore rules but were left
+	// out anyway, sorted by path. Files hidden by the ignore rules, --include
+	// or .gitignore are not listed; pack --dry-run shows those.
+	Skipped []SkipRecord `json:"skipped"`
+	// SkippedByReason counts Skipped per reason.
+	SkippedByReason map[Rule]int `json:"skippedByReason"`
+	Warnings        []string     `json:"warnings"`
+	// Errors holds the files and directories that could not be read.
+	Errors []string `json:"errors"`
+	// Truncated lists the included files that were excerpted because they
+	// are over MaxFileBytes, sorted by path.
+	Truncated []string `json:"truncated"`
+	// Stubbed lists the generated and vendored files packed as a
+	// placeholder with --generated stub, sorted by path.
+	Stubbed []string `json:"stubbed"`
+}
+
+// SkipRecord is one file left out of a pack.
+type SkipRecord struct {
+	Path   string `json:"path"`
+	Reason Rule   `json:"reason"`
+	Size   int64  `json:"size"`
+}
+
+func (r *Report) skip(rel string, reason Rule, size int64) {
+	r.Skipped = append(r.Skipped, SkipRecord{Path: rel, Reason: reason, Size: size})
+	if r.SkippedByReason == nil {
+		r.SkippedByReason = map[Rule]int{}
+	}
+	r.SkippedByReason[reason]++
+	r.FilesSkipped++
+}
+
+// excerpts reports whether files over MaxFileBytes are excerpted rather
+// than skipped.
+func (c *Config) excerpts() bool {
+	return c.MaxFileBytes > 0 && (c.LargeFiles == LargeFilesTruncate || c.LargeFiles == LargeFilesSample)
+}
+
+// symlinks returns the policy for symbolic links, with its default.
+func (c *Config) symlinks() SymlinkPolicy {
+	if c.Symlinks == "" {
+		return SymlinksFollowWithinRoot
+	}
+	return c.Symlinks
+}
+
+// judgesOrigin reports whether rel is checked for being generated or
+// vendored.
+func (c *Config) judgesOrigin(rel string) bool {
+	return (c.Generated == GeneratedSkip || c.Generated == GeneratedStub) && !analyzer.IsLockfile(path.Base(rel))
 }
 
 func (c *Config) normalizedConcurrency() int {
@@ -62,3 +252,116 @@ func (c *Config) normalizedConcurrency() int {
 	}
 	return c.Concurrency
 }
+
+// The Parse functions turn the option strings of `ctx3 pack` (and the
+// matching parameters of `ctx3 serve`) into Config values.
+
+func ParseOutputFormat(s string) (OutputFormat, error) {
+	switch f := OutputFormat(strings.ToLower(s)); f {
+	case FormatXML, FormatMD, FormatTXT:
+		return f, nil
+	}
+	return "", fmt.Errorf("invalid --format: %s (expected xml|md|txt)", s)
+}
+
+func ParseBinaryStrategy(s string) (BinaryStrategy, error) {
+	switch b := BinaryStrategy(strings.ToLower(s)); b {
+	case BinarySkip, BinaryHex, BinaryBase64:
+		return b, nil
+	}
+	return "", fmt.Errorf("invalid --binary: %s (expected skip|hex|base64)", s)
+}
+
+func ParseLockfileMode(s string) (LockfileMode, error) {
+	switch m := LockfileMode(strings.ToLower(s)); m {
+	case LockfilesFull, LockfilesSummarize, LockfilesSkip:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid --lockfiles: %s (expected full|summarize|skip)", s)
+}
+
+func ParseLargeFileMode(s string) (LargeFileMode, error) {
+	switch m := LargeFileMode(strings.ToLower(s)); m {
+	case LargeFilesSkip, LargeFilesTruncate, LargeFilesSample:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid --large-files: %s (expected skip|truncate|sample)", s)
+}
+
+func ParseGeneratedPolicy(s string) (GeneratedPolicy, error) {
+	switch p := GeneratedPolicy(strings.ToLower(s)); p {
+	case GeneratedInclude, GeneratedSkip, GeneratedStub:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid --generated: %s (expected skip|stub|include)", s)
+}
+
+func ParseEncodingFallback(s string) (EncodingFallback, error) {
+	switch f := EncodingFallback(strings.ToLower(s)); f {
+	case EncodingFallbackNone, EncodingFallbackLatin1:
+		return f, nil
+	}
+	return "", fmt.Errorf("invalid --encoding-fallback: %s (expected none|latin1)", s)
+}
+
+func ParseSymlinkPolicy(s string) (SymlinkPolicy, error) {
+	switch p := SymlinkPolicy(strings.ToLower(s)); p {
+	case SymlinksSkip, SymlinksRecord, SymlinksFollowWithinRoot, SymlinksFollow:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid --symlinks: %s (expected skip|record|follow-within-root|follow)", s)
+}
+
+func ParseErrorPolicy(s string) (ErrorPolicy, error) {
+	switch p := ErrorPolicy(strings.ToLower(s)); p {
+	case OnErrorSkip, OnErrorFail:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid --on-error: %s (expected skip|fail)", s)
+}
+
+// ParseSort reports whether files are sorted by extension ("ext") rather
+// than by path ("paths").
+func ParseSort(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "paths":
+		return false, nil
+	case "ext":
+		return true, nil
+	}
+	return false, fmt.Errorf("invalid --sort: %s (expected paths|ext)", s)
+}
+
+func ParseSections(s string) (Sections, error) {
+	switch strings.ToLower(s) {
+	case "all":
+		return Sections{Structure: true, Files: true}, nil
+	case "structure":
+		return Sections{Structure: true}, nil
+	case "files":
+		return Sections{Files: true}, nil
+	}
+	return Sections{}, errors.New("invalid --section: expected all|structure|files")
+}
+
+// PackageSubdirs returns the directories to pack for a workspace member
+// (by name or path) of the workspace at root: the member and the local
+// packages it depends on.
+func PackageSubdirs(root, member string) ([]string, error) {
+	ws, err := analyzer.DetectWorkspace(root)
+	if err != nil {
+		return nil, err
+	}
+	if ws == nil {
+		return nil, fmt.Errorf("--package: no go.work, npm/pnpm or Cargo workspace found in %s", root)
+	}
+	members, err := ws.WithLocalDeps(member)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, m := range members {
+		dirs = append(dirs, m.Path)
+	}
+	return dirs, nil
+}
@@ -0,0 +1,156 @@
+package pack
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A Converter packs a file as the text it holds rather than as its raw
+// bytes: a notebook as its cells, a document as its paragraphs. Converters
+// are registered by extension with RegisterConverter and enabled per pack
+// with Config.Convert.
+type Converter interface {
+	// Name labels converted files, as their summary in the pack.
+	Name() string
+	// Convert returns the text of a file; content is UTF-8 for text
+	// formats. An error means content isn't what the converter expects,
+	// and the file is packed like any other.
+	Convert(rel string, content []byte, opts ConvertOptions) ([]byte, error)
+}
+
+// ConvertOptions tunes what converters keep.
+type ConvertOptions struct {
+	// OutputLines is how many lines of each notebook cell's text output
+	// are kept; 0 drops outputs. Images are always dropped.
+	OutputLines int
+	// Records is how many rows a table preview keeps.
+	Records int
+	// MaxBytes, when > 0, lets a converter stop once its text is longer:
+	// the file is left out anyway.
+	MaxBytes int64
+}
+
+// DefaultConvert lists the extensions converted when Config.Convert is nil:
+// the formats that are useless to a model as they are stored.
+var DefaultConvert = []string{".ipynb", ".docx", ".odt", ".pdf"}
+
+var (
+	convertersMu sync.RWMutex
+	converters   = map[string]Converter{}
+)
+
+// RegisterConverter makes c the converter of files with extension ext
+// (".ipynb"), replacing any other.
+func RegisterConverter(ext string, c Converter) {
+	convertersMu.Lock()
+	defer convertersMu.Unlock()
+	converters[normalizeExt(ext)] = c
+}
+
+// ConverterFor returns the converter registered for ext, or nil.
+func ConverterFor(ext string) Converter {
+	convertersMu.RLock()
+	defer convertersMu.RUnlock()
+	return converters[normalizeExt(ext)]
+}
+
+// ConverterExts returns the extensions with a registered converter, sorted.
+func ConverterExts() []string {
+	convertersMu.RLock()
+	defer convertersMu.RUnlock()
+	exts := make([]string, 0, len(converters))
+	for ext := range converters {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	return exts
+}
+
+func normalizeExt(ext string) string {
+	return "." + strings.TrimPrefix(strings.ToLower(ext), ".")
+}
+
+func init() {
+	RegisterConverter(".ipynb", notebookConverter{})
+	RegisterConverter(".docx", docxConverter{})
+	RegisterConverter(".odt", odtConverter{})
+	RegisterConverter(".pdf", pdfConverter{})
+	RegisterConverter(".csv", csvConverter{comma: ','})
+	RegisterConverter(".tsv", csvConverter{comma: '\t'})
+}
+
+// maxConvertBytes bounds the files handed to a converter, which reads
+// them whole.
+const maxConvertBytes = 256 << 20
+
+// converter returns the converter rel is packed with, if any.
+func (c *Config) converter(rel string, size int64) (Converter, bool) {
+	if size > maxConvertBytes {
+		return nil, false
+	}
+	ext := strings.ToLower(path.Ext(rel))
+	exts := c.Convert
+	if exts == nil {
+		exts = DefaultConvert
+	}
+	if ext == "" || !slices.Contains(exts, ext) {
+		return nil, false
+	}
+	conv := ConverterFor(ext)
+	return conv, conv != nil
+}
+
+func (c *Config) convertOptions() ConvertOptions {
+	records := c.SampleRecords
+	if records <= 0 {
+		records = DefaultSampleRecords
+	}
+	opts := ConvertOptions{OutputLines: c.NotebookOutputLines, Records: records}
+	if !c.excerpts() {
+		// excerpts need the end of the text too
+		opts.MaxBytes = c.MaxFileBytes
+	}
+	return opts
+}
+
+// ParseConvert turns the values of --convert (extensions with or without
+// the dot, or "none") into Config.Convert.
+func ParseConvert(values []string) ([]string, error) {
+	exts := []string{}
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		if strings.EqualFold(v, "none") {
+			return []string{}, nil
+		}
+		ext := normalizeExt(v)
+		if ConverterFor(ext) == nil {
+			return nil, fmt.Errorf("invalid --convert: %s (expected none or one of %s)", v, strings.Join(ConverterExts(), ", "))
+		}
+		if !slices.Contains(exts, ext) {
+			exts = append(exts, ext)
+		}
+	}
+	return exts, nil
+}
+
+// csvConverter previews a CSV or TSV table: the header and the first rows.
+type csvConverter struct {
+	comma rune
+}
+
+func (csvConverter) Name() string { return "csv" }
+
+func (c csvConverter) Convert(rel string, content []byte, opts ConvertOptions) ([]byte, error) {
+	out, ok := sampleCSV(content, opts.Records, c.comma)
+	if !ok {
+		return nil, errors.New("not a table")
+	}
+	return out, nil
+}
@@ -0,0 +1,156 @@
+package pack
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// notebookConverter turns a Jupyter notebook (nbformat 4) into its cells
+// in order: markdown as it is, code in fenced blocks, and optionally the
+// text the cells printed. Images and other rich outputs are dropped.
+type notebookConverter struct{}
+
+func (notebookConverter) Name() string { return "notebook" }
+
+type notebook struct {
+	NBFormat int `json:"nbformat"`
+	Metadata struct {
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+		KernelSpec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+	} `json:"metadata"`
+	Cells []struct {
+		CellType string          `json:"cell_type"`
+		Source   multilineString `json:"source"`
+		Outputs  []struct {
+			OutputType string                     `json:"output_type"`
+			Text       multilineString            `json:"text"`
+			Data       map[string]json.RawMessage `json:"data"`
+			EName      string                     `json:"ename"`
+			EValue     string                     `json:"evalue"`
+			Traceback  []string                   `json:"traceback"`
+		} `json:"outputs"`
+	} `json:"cells"`
+}
+
+// multilineString is a notebook string, stored either whole or as a list
+// of lines.
+type multilineString string
+
+func (s *multilineString) UnmarshalJSON(data []byte) error {
+	var whole string
+	if err := json.Unmarshal(data, &whole); err == nil {
+		*s = multilineString(whole)
+		return nil
+	}
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err != nil {
+		return err
+	}
+	*s = multilineString(strings.Join(lines, ""))
+	return nil
+}
+
+// ansiEscape matches the color codes of tracebacks.
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+func (notebookConverter) Convert(rel string, content []byte, opts ConvertOptions) ([]byte, error) {
+	var nb notebook
+	if err := json.Unmarshal(content, &nb); err != nil {
+		return nil, err
+	}
+	if nb.NBFormat != 4 {
+		return nil, fmt.Errorf("unsupported nbformat %d", nb.NBFormat)
+	}
+	if len(nb.Cells) == 0 {
+		return nil, errors.New("no cells")
+	}
+	lang := nb.Metadata.LanguageInfo.Name
+	if lang == "" {
+		lang = nb.Metadata.KernelSpec.Language
+	}
+
+	var b strings.Builder
+	for i, cell := range nb.Cells {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		fmt.Fprintf(&b, "<!-- cell %d: %s -->\n", i+1, cell.CellType)
+		src := strings.TrimRight(string(cell.Source), "\n")
+		switch cell.CellType {
+		case "code":
+			writeFenced(&b, lang, src)
+		default:
+			if src != "" {
+				b.WriteString(src + "\n")
+			}
+		}
+		if cell.CellType != "code" || opts.OutputLines <= 0 {
+			continue
+		}
+
+		var text []string
+		for _, out := range cell.Outputs {
+			switch out.OutputType {
+			case "stream":
+				text = append(text, string(out.Text))
+			case "execute_result", "display_data":
+				var plain multilineString
+				if raw, ok := out.Data["text/plain"]; ok && json.Unmarshal(raw, &plain) == nil {
+					text = append(text, string(plain))
+				}
+				var dropped []string
+				for mime := range out.Data {
+					if mime != "text/plain" {
+						dropped = append(dropped, mime)
+					}
+				}
+				if len(dropped) > 0 {
+					sort.Strings(dropped)
+					text = append(text, fmt.Sprintf("[%s output omitted]", strings.Join(dropped, ", ")))
+				}
+			case "error":
+				if len(out.Traceback) > 0 {
+					text = append(text, ansiEscape.ReplaceAllString(strings.Join(out.Traceback, "\n"), ""))
+				} else {
+					text = append(text, out.EName+": "+out.EValue)
+				}
+			}
+		}
+		if len(text) == 0 {
+			continue
+		}
+		var all strings.Builder
+		for _, t := range text {
+			all.WriteString(t)
+			if !strings.HasSuffix(t, "\n") {
+				all.WriteByte('\n')
+			}
+		}
+		lines := strings.Split(strings.TrimRight(all.String(), "\n"), "\n")
+		if n := len(lines) - opts.OutputLines; n > 0 {
+			lines = append(lines[:opts.OutputLines], fmt.Sprintf("… [truncated %s lines] …", groupDigits(n)))
+		}
+		b.WriteString("<!-- output -->\n")
+		writeFenced(&b, "text", strings.Join(lines, "\n"))
+	}
+	return []byte(b.String()), nil
+}
+
+// writeFenced writes body as a fenced code block, with a fence longer than
+// any backtick run inside.
+func writeFenced(b *strings.Builder, info, body string) {
+	fence := fenceFor([]byte(body))
+	b.WriteString(fence + info + "\n")
+	if body != "" {
+		b.WriteString(body + "\n")
+	}
+	b.WriteString(fence + "\n")
+}
@@ -0,0 +1,231 @@
+package pack
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxDocumentXML bounds how much of a document's XML is read, against zip
+// bombs.
+const maxDocumentXML = 64 << 20
+
+// docxConverter extracts the paragraphs of a Word document from
+// word/document.xml, with headings marked as in Markdown.
+type docxConverter struct{}
+
+func (docxConverter) Name() string { return "docx" }
+
+func (docxConverter) Convert(rel string, content []byte, opts ConvertOptions) ([]byte, error) {
+	dec, err := zipXML(content, "word/document.xml")
+	if err != nil {
+		return nil, err
+	}
+	var w docWriter
+	var para strings.Builder
+	heading := 0
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				para.Reset()
+				heading = 0
+			case "pStyle":
+				// Heading1, heading 2, Title, ...
+				style := strings.ToLower(strings.ReplaceAll(attr(t, "val"), " ", ""))
+				if n, err := strconv.Atoi(strings.TrimPrefix(style, "heading")); err == nil && strings.HasPrefix(style, "heading") {
+					heading = min(max(n, 1), 6)
+				} else if style == "title" {
+					heading = 1
+				}
+			case "t":
+				var text string
+				if err := dec.DecodeElement(&text, &t); err != nil {
+					return nil, err
+				}
+				para.WriteString(text)
+			case "tab":
+				para.WriteByte('\t')
+			case "br", "cr":
+				para.WriteByte('\n')
+			case "tc":
+				w.startCell()
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "p":
+				w.paragraph(para.String(), heading)
+			case "tc":
+				w.endCell()
+			case "tr":
+				w.endRow()
+			}
+		}
+	}
+	return w.text()
+}
+
+// odtConverter extracts the paragraphs and headings of an OpenDocument
+// text from content.xml.
+type odtConverter struct{}
+
+func (odtConverter) Name() string { return "odt" }
+
+func (odtConverter) Convert(rel string, content []byte, opts ConvertOptions) ([]byte, error) {
+	dec, err := zipXML(content, "content.xml")
+	if err != nil {
+		return nil, err
+	}
+	var w docWriter
+	var para strings.Builder
+	heading, depth := 0, 0 // depth counts the open text:p and text:h
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p", "h":
+				if depth == 0 {
+					para.Reset()
+					heading = 0
+					if t.Name.Local == "h" {
+						heading = 1
+						if n, err := strconv.Atoi(attr(t, "outline-level")); err == nil {
+							heading = min(max(n, 1), 6)
+						}
+					}
+				}
+				depth++
+			case "s":
+				n, err := strconv.Atoi(attr(t, "c"))
+				if err != nil {
+					n = 1
+				}
+				para.WriteString(strings.Repeat(" ", min(max(n, 1), 80)))
+			case "tab":
+				para.WriteByte('\t')
+			case "line-break":
+				para.WriteByte('\n')
+			case "table-cell":
+				w.startCell()
+			}
+		case xml.CharData:
+			if depth > 0 {
+				para.Write(t)
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "p", "h":
+				if depth--; depth == 0 {
+					w.paragraph(para.String(), heading)
+				}
+			case "table-cell":
+				w.endCell()
+			case "table-row":
+				w.endRow()
+			}
+		}
+	}
+	return w.text()
+}
+
+// zipXML opens name inside the zip archive content for decoding.
+func zipXML(content []byte, name string) (*xml.Decoder, error) {
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, err
+	}
+	f, err := zr.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("no %s: %w", name, err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxDocumentXML))
+	if err != nil {
+		return nil, err
+	}
+	return xml.NewDecoder(bytes.NewReader(data)), nil
+}
+
+func attr(e xml.StartElement, local string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// docWriter collects the text of a document: a paragraph per line,
+// headings with their Markdown prefix and table rows as "| a | b |".
+type docWriter struct {
+	out  strings.Builder
+	row  []string         // cells of the table row being read
+	cell *strings.Builder // nil outside a table cell
+}
+
+// paragraph adds a paragraph; empty ones are left out.
+func (w *docWriter) paragraph(text string, heading int) {
+	var lines []string
+	for _, l := range strings.Split(text, "\n") {
+		if l = strings.Join(strings.Fields(l), " "); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	text = strings.Join(lines, "\n")
+	switch {
+	case text == "":
+	case w.cell != nil:
+		if w.cell.Len() > 0 {
+			w.cell.WriteByte(' ')
+		}
+		w.cell.WriteString(text)
+	default:
+		if heading > 0 {
+			w.out.WriteString(strings.Repeat("#", heading) + " ")
+		}
+		w.out.WriteString(text + "\n")
+	}
+}
+
+func (w *docWriter) startCell() { w.cell = &strings.Builder{} }
+
+func (w *docWriter) endCell() {
+	if w.cell != nil {
+		w.row = append(w.row, strings.ReplaceAll(w.cell.String(), "|", "\\|"))
+		w.cell = nil
+	}
+}
+
+func (w *docWriter) endRow() {
+	if len(w.row) > 0 {
+		w.out.WriteString("| " + strings.Join(w.row, " | ") + " |\n")
+	}
+	w.row = nil
+}
+
+func (w *docWriter) text() ([]byte, error) {
+	if w.out.Len() == 0 {
+		return nil, errors.New("no text")
+	}
+	return []byte(w.out.String()), nil
+}
@@ -0,0 +1,350 @@
+package pack
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// pdfConverter extracts the text a PDF draws, on a best-effort basis: the
+// strings shown by the text operators of its uncompressed and
+// Flate-compressed content streams. Text in fonts with custom encodings
+// (most CID fonts) comes out as nothing, and a PDF without any extractable
+// text, e.g. a scan, is packed like any other binary file.
+type pdfConverter struct{}
+
+func (pdfConverter) Name() string { return "pdf" }
+
+// Bounds on what one PDF may cost, as a small file can inflate to
+// gigabytes: the inflated size of one stream and of all of them, and the
+// size of the extracted text. Conversion stops at the first one reached.
+const (
+	maxPDFStream   = 16 << 20
+	maxPDFInflated = 64 << 20
+	maxPDFText     = 8 << 20
+)
+
+var (
+	pdfStream  = regexp.MustCompile(`stream\r?\n`)
+	pdfFilters = regexp.MustCompile(`/Filter\s*(\[[^\]]*\]|/\w+)`)
+)
+
+func (pdfConverter) Convert(rel string, content []byte, opts ConvertOptions) ([]byte, error) {
+	if !bytes.HasPrefix(content, []byte("%PDF-")) {
+		return nil, errors.New("not a PDF")
+	}
+	limit := maxPDFText
+	if opts.MaxBytes > 0 && opts.MaxBytes < int64(limit) {
+		limit = int(opts.MaxBytes)
+	}
+	var out strings.Builder
+	inflate := int64(maxPDFInflated)
+	rest := content
+	for out.Len() <= limit && inflate > 0 {
+		loc := pdfStream.FindIndex(rest)
+		if loc == nil {
+			break
+		}
+		// the stream dictionary ends right before the keyword
+		dict := rest[:loc[0]]
+		if i := bytes.LastIndex(dict, []byte("obj")); i >= 0 {
+			dict = dict[i:]
+		}
+		body := rest[loc[1]:]
+		end := bytes.Index(body, []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		rest = body[end+len("endstream"):]
+		if data, ok := pdfStreamData(dict, body[:end], &inflate); ok {
+			showText(&out, data, limit)
+		}
+	}
+	text := strings.TrimSpace(out.String())
+	if text == "" {
+		return nil, errors.New("no extractable text")
+	}
+	return []byte(text + "\n"), nil
+}
+
+// pdfStreamData returns the decoded data of a stream that may hold page
+// content: neither an image, a font nor an object or cross-reference
+// stream, and either unfiltered or Flate-compressed. The inflated size is
+// taken from budget, which caps it.
+func pdfStreamData(dict, raw []byte, budget *int64) ([]byte, bool) {
+	for _, skip := range []string{"/Image", "/FontFile", "/ObjStm", "/XRef", "/Metadata"} {
+		if bytes.Contains(dict, []byte(skip)) {
+			return nil, false
+		}
+	}
+	m := pdfFilters.FindSubmatch(dict)
+	if m == nil {
+		return raw, true
+	}
+	filters := strings.Fields(strings.Trim(string(m[1]), "[]"))
+	if len(filters) != 1 || filters[0] != "/FlateDecode" {
+		return nil, false
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, false
+	}
+	defer zr.Close()
+	// a truncated stream still gives the text before the damage
+	data, _ := io.ReadAll(io.LimitReader(zr, min(maxPDFStream, *budget)))
+	*budget -= int64(len(data))
+	return data, len(data) > 0
+}
+
+// showText appends the strings shown by the text operators of a content
+// stream to out, breaking lines where the text moves down. It stops once
+// out is longer than limit.
+func showText(out *strings.Builder, data []byte, limit int) {
+	lex := pdfLexer{data: data}
+	var operands []pdfToken
+	newline := func() {
+		if s := out.String(); s != "" && !strings.HasSuffix(s, "\n") {
+			out.WriteByte('\n')
+		}
+	}
+	space := func() {
+		if s := out.String(); s != "" && !strings.HasSuffix(s, "\n") && !strings.HasSuffix(s, " ") {
+			out.WriteByte(' ')
+		}
+	}
+	for out.Len() <= limit {
+		tok, ok := lex.next()
+		if !ok {
+			return
+		}
+		if tok.kind != pdfOperator {
+			operands = append(operands, tok)
+			continue
+		}
+		switch tok.text {
+		case "Tj":
+			writeOperandStrings(out, operands)
+		case "'", "\"":
+			newline()
+			writeOperandStrings(out, operands)
+		case "TJ":
+			for _, op := range operands {
+				switch op.kind {
+				case pdfString:
+					out.WriteString(op.text)
+				case pdfNumber:
+					// a wide negative adjustment is a word gap
+					if n, err := strconv.ParseFloat(op.text, 64); err == nil && n < -200 {
+						space()
+					}
+				}
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 && operands[len(operands)-1].text != "0" {
+				newline()
+			} else {
+				space()
+			}
+		case "T*", "ET":
+			newline()
+		case "Tm":
+			space()
+		}
+		operands = operands[:0]
+	}
+}
+
+func writeOperandStrings(out *strings.Builder, operands []pdfToken) {
+	for _, op := range operands {
+		if op.kind == pdfString {
+			out.WriteString(op.text)
+		}
+	}
+}
+
+type pdfTokenKind int
+
+const (
+	pdfOperator pdfTokenKind = iota
+	pdfString
+	pdfNumber
+	pdfOther // names, array and dictionary delimiters, ...
+)
+
+type pdfToken struct {
+	kind pdfTokenKind
+	text string // decoded for strings
+}
+
+// pdfLexer splits a content stream into tokens. Arrays are not nested in
+// the result: their elements are operands of their own.
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func (l *pdfLexer) next() (pdfToken, bool) {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		switch {
+		case isPDFSpace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		case c == '(':
+			return pdfToken{kind: pdfString, text: pdfText(l.literal())}, true
+		case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<', c == '>' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '>':
+			l.pos += 2
+			return pdfToken{kind: pdfOther}, true
+		case c == '<':
+			end := bytes.IndexByte(l.data[l.pos:], '>')
+			if end < 0 {
+				l.pos = len(l.data)
+				return pdfToken{}, false
+			}
+			digits := strings.Join(strings.Fields(string(l.data[l.pos+1:l.pos+end])), "")
+			l.pos += end + 1
+			if len(digits)%2 == 1 {
+				digits += "0"
+			}
+			b, _ := hex.DecodeString(digits)
+			return pdfToken{kind: pdfString, text: pdfHexText(b)}, true
+		case c == '[', c == ']', c == '{', c == '}', c == ')', c == '>':
+			l.pos++
+			return pdfToken{kind: pdfOther}, true
+		default:
+			start := l.pos
+			l.pos++
+			for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+				l.pos++
+			}
+			word := string(l.data[start:l.pos])
+			switch {
+			case c == '/':
+				return pdfToken{kind: pdfOther, text: word}, true
+			case c == '-' || c == '+' || c == '.' || ('0' <= c && c <= '9'):
+				return pdfToken{kind: pdfNumber, text: word}, true
+			}
+			return pdfToken{kind: pdfOperator, text: word}, true
+		}
+	}
+	return pdfToken{}, false
+}
+
+// literal reads a (string) with balanced parentheses and escapes.
+func (l *pdfLexer) literal() []byte {
+	var out []byte
+	depth := 0
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			if depth > 0 {
+				out = append(out, c)
+			}
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return out
+			}
+			out = append(out, c)
+		case '\\':
+			if l.pos >= len(l.data) {
+				return out
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b', 'f':
+			case '\r':
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+			case '\n':
+			default:
+				if '0' <= e && e <= '7' {
+					n := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && '0' <= l.data[l.pos] && l.data[l.pos] <= '7'; i++ {
+						n = n*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					out = append(out, byte(n))
+				} else {
+					out = append(out, e)
+				}
+			}
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// pdfText decodes a PDF text string: UTF-16BE with a byte order mark, or
+// else close enough to Latin-1. Control bytes are dropped.
+func pdfText(b []byte) string {
+	if bytes.HasPrefix(b, []byte{0xFE, 0xFF}) {
+		return string(decodeText(b[2:], EncodingUTF16BE))
+	}
+	var out []byte
+	for _, c := range b {
+		if c >= 0x20 || c == '\t' || c == '\n' {
+			out = utf8.AppendRune(out, rune(c))
+		}
+	}
+	return string(out)
+}
+
+// pdfHexText decodes a <hex> string when it reads as text, and drops it
+// when it holds glyph ids of a font with a custom encoding.
+func pdfHexText(b []byte) string {
+	if bytes.HasPrefix(b, []byte{0xFE, 0xFF}) {
+		return pdfText(b)
+	}
+	printable := true
+	for _, c := range b {
+		if c < 0x20 || c > 0x7E {
+			printable = false
+			break
+		}
+	}
+	if printable {
+		return string(b)
+	}
+	// two-byte codes that are plain Unicode, as some producers write
+	if len(b)%2 == 0 {
+		units := make([]uint16, len(b)/2)
+		for i := range units {
+			units[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+			if b[2*i] != 0 || b[2*i+1] < 0x20 {
+				return ""
+			}
+		}
+		return string(utf16.Decode(units))
+	}
+	return ""
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
@@ -0,0 +1,314 @@
+package pack
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testNotebook = `{
+ "nbformat": 4,
+ "nbformat_minor": 5,
+ "metadata": {"language_info": {"name": "python"}},
+ "cells": [
+  {"cell_type": "markdown", "metadata": {}, "source": ["# Exploration\n", "Load the data."]},
+  {"cell_type": "code", "metadata": {}, "execution_count": 1, "source": "import pandas as pd\ndf = pd.read_csv('a.csv')\ndf.head()",
+   "outputs": [
+    {"output_type": "stream", "name": "stdout", "text": ["line 1\n", "line 2\n", "line 3\n"]},
+    {"output_type": "display_data", "metadata": {}, "data": {"image/png": "iVBORw0KGgo=", "text/plain": ["<Figure size 640x480>"]}}
+   ]},
+  {"cell_type": "code", "metadata": {}, "execution_count": 2, "source": ["1/0"],
+   "outputs": [{"output_type": "error", "ename": "ZeroDivisionError", "evalue": "division by zero", "traceback": ["\u001b[0;31mZeroDivisionError\u001b[0m: division by zero"]}]}
+ ]
+}`
+
+func TestNotebookConverter(t *testing.T) {
+	out, err := notebookConverter{}.Convert("eda.ipynb", []byte(testNotebook), ConvertOptions{})
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	want := "<!-- cell 1: markdown -->\n# Exploration\nLoad the data.\n\n" +
+		"<!-- cell 2: code -->\n```python\nimport pandas as pd\ndf = pd.read_csv('a.csv')\ndf.head()\n```\n\n" +
+		"<!-- cell 3: code -->\n```python\n1/0\n```\n"
+	if string(out) != want {
+		t.Fatalf("without outputs:\n%s\nwant:\n%s", out, want)
+	}
+
+	out, err = notebookConverter{}.Convert("eda.ipynb", []byte(testNotebook), ConvertOptions{OutputLines: 2})
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	for _, s := range []string{
+		"<!-- output -->\n```text\nline 1\nline 2\n… [truncated 3 lines] …\n```\n",
+		"<!-- output -->\n```text\nZeroDivisionError: division by zero\n```\n",
+	} {
+		if !strings.Contains(string(out), s) {
+			t.Errorf("with outputs: missing %q in:\n%s", s, out)
+		}
+	}
+	if strings.Contains(string(out), "iVBOR") {
+		t.Errorf("image kept:\n%s", out)
+	}
+
+	if _, err := (notebookConverter{}).Convert("x.ipynb", []byte(`{"nbformat": 3, "worksheets": []}`), ConvertOptions{}); err == nil {
+		t.Error("nbformat 3: expected an error")
+	}
+}
+
+func zipOf(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func testDocx(t *testing.T) []byte {
+	return zipOf(t, map[string]string{"word/document.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>Design</w:t></w:r></w:p>
+<w:p><w:r><w:t xml:space="preserve">The cache is </w:t></w:r><w:r><w:rPr><w:b/></w:rPr><w:t>content-addressed</w:t></w:r><w:r><w:t>.</w:t></w:r></w:p>
+<w:tbl><w:tr><w:tc><w:p><w:r><w:t>Key</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>Value</w:t></w:r></w:p></w:tc></w:tr>
+<w:tr><w:tc><w:p><w:r><w:t>a|b</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>1</w:t></w:r></w:p></w:tc></w:tr></w:tbl>
+</w:body></w:document>`})
+}
+
+func TestDocxConverter(t *testing.T) {
+	out, err := docxConverter{}.Convert("design.docx", testDocx(t), ConvertOptions{})
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	want := "# Design\nThe cache is content-addressed.\n| Key | Value |\n| a\\|b | 1 |\n"
+	if string(out) != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", out, want)
+	}
+	if _, err := (docxConverter{}).Convert("x.docx", []byte("not a zip"), ConvertOptions{}); err == nil {
+		t.Error("expected an error for a broken file")
+	}
+}
+
+func TestOdtConverter(t *testing.T) {
+	doc := zipOf(t, map[string]string{"content.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0"><office:body><office:text>
+<text:h text:outline-level="2">Notes</text:h>
+<text:p>two<text:s text:c="2"/>spaces and a <text:span>span</text:span><text:line-break/>next line</text:p>
+</office:text></office:body></office:document-content>`})
+	out, err := odtConverter{}.Convert("notes.odt", doc, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	if want := "## Notes\ntwo spaces and a span\nnext line\n"; string(out) != want {
+		t.Fatalf("got:\n%q\nwant:\n%q", out, want)
+	}
+}
+
+func testPDF(t *testing.T) []byte {
+	t.Helper()
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write([]byte("BT /F1 12 Tf 72 700 Td [(Second) -250 (page)] TJ 0 -14 Td (\\(escaped\\) \\101) Tj ET"))
+	zw.Close()
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n")
+	pdf.WriteString("4 0 obj\n<< /Length 44 >>\nstream\nBT /F1 12 Tf 72 712 Td (Hello, world) Tj ET\nendstream\nendobj\n")
+	fmt.Fprintf(&pdf, "5 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", z.Len())
+	pdf.Write(z.Bytes())
+	pdf.WriteString("\nendstream\nendobj\n")
+	pdf.WriteString("6 0 obj\n<< /Subtype /Image /Length 5 >>\nstream\n(no) Tj\nendstream\nendobj\n%%EOF\n")
+	return pdf.Bytes()
+}
+
+func TestPDFConverter(t *testing.T) {
+	out, err := pdfConverter{}.Convert("a.pdf", testPDF(t), ConvertOptions{})
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	if want := "Hello, world\nSecond page\n(escaped) A\n"; string(out) != want {
+		t.Fatalf("got %q, want %q", out, want)
+	}
+	if _, err := (pdfConverter{}).Convert("scan.pdf", []byte("%PDF-1.4\n%%EOF\n"), ConvertOptions{}); err == nil {
+		t.Error("expected an error for a PDF without text")
+	}
+}
+
+func TestPDFConverter_Bounded(t *testing.T) {
+	// every stream inflates to 4MB of text from a few kilobytes
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	line := []byte("BT (" + strings.Repeat("a", 1000) + ") Tj ET\n")
+	for i := 0; i < 4<<20/len(line); i++ {
+		zw.Write(line)
+	}
+	zw.Close()
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n")
+	for i := 0; i < 40; i++ {
+		fmt.Fprintf(&pdf, "%d 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", i+1, z.Len())
+		pdf.Write(z.Bytes())
+		pdf.WriteString("\nendstream\nendobj\n")
+	}
+
+	out, err := pdfConverter{}.Convert("bomb.pdf", pdf.Bytes(), ConvertOptions{})
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	if len(out) > maxPDFText+2000 {
+		t.Errorf("extracted %d bytes, want at most about %d", len(out), maxPDFText)
+	}
+	out, err = pdfConverter{}.Convert("bomb.pdf", pdf.Bytes(), ConvertOptions{MaxBytes: 5000})
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	if len(out) <= 5000 || len(out) > 7000 {
+		t.Errorf("extracted %d bytes with MaxBytes 5000", len(out))
+	}
+}
+
+func TestCSVConverter(t *testing.T) {
+	out, err := csvConverter{comma: ','}.Convert("a.csv", []byte("id,name\n1,a\n2,b\n3,c\n"), ConvertOptions{Records: 2})
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	if !strings.HasPrefix(string(out), "id,name\n1,a\n2,b\n") || strings.Contains(string(out), "3,c") {
+		t.Fatalf("got:\n%s", out)
+	}
+}
+
+func TestParseConvert(t *testing.T) {
+	got, err := ParseConvert([]string{"ipynb", ".PDF", "ipynb"})
+	if err != nil || strings.Join(got, ",") != ".ipynb,.pdf" {
+		t.Fatalf("got %v, %v", got, err)
+	}
+	if got, err := ParseConvert([]string{"none"}); err != nil || got == nil || len(got) != 0 {
+		t.Fatalf("none: got %#v, %v", got, err)
+	}
+	if _, err := ParseConvert([]string{"xlsx"}); err == nil {
+		t.Fatal("xlsx: expected an error")
+	}
+}
+
+type upperConverter struct{}
+
+func (upperConverter) Name() string { return "upper" }
+
+func (upperConverter) Convert(rel string, content []byte, opts ConvertOptions) ([]byte, error) {
+	if len(content) == 0 {
+		return nil, errors.New("empty")
+	}
+	return bytes.ToUpper(content), nil
+}
+
+func TestWalk_Convert(t *testing.T) {
+	RegisterConverter("shout", upperConverter{})
+	t.Cleanup(func() {
+		convertersMu.Lock()
+		delete(converters, ".shout")
+		convertersMu.Unlock()
+	})
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	td := t.TempDir()
+	writeFile(t, filepath.Join(td, "docs", "design.docx"), testDocx(t))
+	writeFile(t, filepath.Join(td, "docs", "broken.docx"), []byte{0x50, 0x4b, 0x03, 0x04, 0x00, 0x00, 0xff})
+	writeFile(t, filepath.Join(td, "eda.ipynb"), []byte(testNotebook))
+	writeFile(t, filepath.Join(td, "note.shout"), []byte("hi\n"))
+	writeFile(t, filepath.Join(td, "empty.shout"), nil)
+
+	// --binary skip applies to the docx only when it can't be converted
+	files, _, rep, err := WalkAndCollect(context.Background(), Config{RootDir: td, BinaryHandling: BinarySkip})
+	if err != nil {
+		t.Fatalf("WalkAndCollect error: %v", err)
+	}
+	if got := strings.Join(relPaths(files), ","); got != "docs/design.docx,eda.ipynb,empty.shout,note.shout" || rep.SkippedByReason[RuleBinary] != 1 {
+		t.Fatalf("default: got %s, skipped %+v", got, rep.Skipped)
+	}
+	for _, f := range files {
+		if f.RelPath == "docs/design.docx" && (f.Summary != "docx" || f.IsBinary || !strings.HasPrefix(string(f.Content), "# Design\n")) {
+			t.Errorf("docx: got %+v", f)
+		}
+		if f.RelPath == "note.shout" && string(f.Content) != "hi\n" {
+			t.Errorf("not enabled: got %q", f.Content)
+		}
+	}
+
+	files, _, _, err = WalkAndCollect(context.Background(), Config{RootDir: td, Convert: []string{".shout"}})
+	if err != nil {
+		t.Fatalf("WalkAndCollect error: %v", err)
+	}
+	for _, f := range files {
+		switch f.RelPath {
+		case "note.shout":
+			if string(f.Content) != "HI\n" || f.Summary != "upper" {
+				t.Errorf("custom: got %+v", f)
+			}
+		case "eda.ipynb":
+			if f.Summary != "" {
+				t.Errorf("notebook converted without being listed: %+v", f)
+			}
+		case "empty.shout":
+			if f.Summary != "" || len(f.Content) != 0 {
+				t.Errorf("failed conversion: got %+v", f)
+			}
+		}
+	}
+
+	// the cap applies to the converted text, not to the zip
+	docx := testDocx(t)
+	cfg := Config{RootDir: td, MaxFileBytes: int64(len(docx)) - 1, Convert: []string{".docx"}}
+	files, _, rep, err = WalkAndCollect(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("WalkAndCollect error: %v", err)
+	}
+	if !contains(relPaths(files), "docs/design.docx") {
+		t.Fatalf("capped: got %v, skipped %+v", relPaths(files), rep.Skipped)
+	}
+	cfg.MaxFileBytes = 10
+	files, _, rep, err = WalkAndCollect(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("WalkAndCollect error: %v", err)
+	}
+	if contains(relPaths(files), "docs/design.docx") || rep.SkippedByReason[RuleMaxFileBytes] == 0 {
+		t.Fatalf("over the cap: got %v, skipped %+v", relPaths(files), rep.Skipped)
+	}
+	cfg.LargeFiles = LargeFilesTruncate
+	cfg.MaxFileBytes = 40
+	files, _, _, err = WalkAndCollect(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("WalkAndCollect error: %v", err)
+	}
+	for _, f := range files {
+		if f.RelPath == "docs/design.docx" && (f.Truncated == "" || strings.Contains(string(f.Content), "content-addressed")) {
+			t.Errorf("excerpt: got %+v (%q)", f, f.Content)
+		}
+	}
+}
+
+func TestExplain_Convert(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	td := t.TempDir()
+	writeFile(t, filepath.Join(td, "design.docx"), testDocx(t))
+	d, err := Explain(context.Background(), Config{RootDir: td, MaxFileBytes: 10}, "design.docx")
+	if err != nil {
+		t.Fatalf("Explain: %v", err)
+	}
+	if !d.Included || d.Reason().Source != "--convert" {
+		t.Fatalf("decision %+v", d)
+	}
+}
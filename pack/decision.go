@@ -0,0 +1,439 @@
+package pack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/parsabordbar/ctx3/analyzer"
+	"github.com/parsabordbar/ctx3/filetree"
+	ignore "github.com/sabhiram/go-gitignore"
+)
+
+// Rule names one link of the chain that decides whether a path is packed.
+type Rule string
+
+const (
+	RuleHardExclude    Rule = "hard-exclude"     // .git and node_modules
+	RuleIgnoreList     Rule = "ignore-list"      // filetree ignore rules (defaults, ignore.json, .ctx3.yaml, .ctx3ignore)
+	RulePackage        Rule = "package"          // --package / Config.Subdirs
+	RuleInclude        Rule = "include"          // --include
+	RuleGitignore      Rule = "gitignore"        // root .gitignore
+	RuleIgnoreGlob     Rule = "ignore"           // --ignore
+	RuleMaxFileBytes   Rule = "max-file-bytes"   // --max-file-bytes
+	RuleBinary         Rule = "binary"           // binary detection with --binary skip
+	RuleLockfile       Rule = "lockfile"         // --lockfiles skip
+	RuleGenerated      Rule = "generated"        // --generated skip: generated, minified or vendored
+	RuleSymlink        Rule = "symlink"          // --symlinks: a link that isn't followed
+	RuleSpecial        Rule = "special"          // devices, named pipes and sockets, never read
+	RuleMaxTotalBytes  Rule = "max-total-bytes"  // --max-total-bytes
+	RuleMaxTotalTokens Rule = "max-total-tokens" // Config.MaxTotalTokens; needs content, so not in DryRun
+	RuleReadError      Rule = "read-error"       // the file could not be read; only in Report.Skipped
+)
+
+// Effects of a Step.
+const (
+	EffectPass    = "pass"    // the rule didn't apply
+	EffectInclude = "include" // the rule kept the path and later ignores are not consulted
+	EffectExclude = "exclude" // the rule dropped the path; the chain stops here
+)
+
+// Step is one rule evaluated for a path.
+type Step struct {
+	Rule    Rule   `json:"rule" toon:"rule"`
+	Effect  string `json:"effect" toon:"effect"`
+	Pattern string `json:"pattern,omitempty" toon:"pattern"`
+	Source  string `json:"source,omitempty" toon:"source"` // config file (and line), flag or "built-in defaults"
+	Detail  string `json:"detail,omitempty" toon:"detail"`
+}
+
+// Decision is the outcome of the rule chain for one path.
+type Decision struct {
+	Path     string `json:"path" toon:"path"`
+	IsDir    bool   `json:"isDir,omitempty" toon:"is_dir"`
+	Size     int64  `json:"size" toon:"size"`
+	Included bool   `json:"included" toon:"included"`
+	Steps    []Step `json:"steps" toon:"steps"`
+}
+
+// Reason returns the step that excluded the path, or the last step
+// evaluated for an included one.
+func (d Decision) Reason() Step {
+	if len(d.Steps) == 0 {
+		return Step{}
+	}
+	return d.Steps[len(d.Steps)-1]
+}
+
+func (d *Decision) add(s Step) {
+	d.Steps = append(d.Steps, s)
+	if s.Effect == EffectExclude {
+		d.Included = false
+	}
+}
+
+// filter holds the path-level part of the rule chain, the part that needs
+// no file content. WalkAndCollect, DryRun and Explain all go through it.
+type filter struct {
+	cfg           Config
+	rules         *filetree.IgnoreRules
+	gitIg         *ignore.GitIgnore
+	gitignorePath string
+}
+
+func newFilter(cfg Config, rootAbs string) (*filter, error) {
+	f := &filter{cfg: cfg, rules: cfg.Ignores}
+	if f.rules == nil {
+		var err error
+		if f.rules, err = filetree.LoadIgnoreRules(rootAbs, false); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Generated != "" && cfg.Generated != GeneratedInclude && cfg.Attributes == nil {
+		var err error
+		if f.cfg.Attributes, err = analyzer.LoadAttributes(rootAbs); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.RespectGitignore {
+		f.gitIg = filetree.LoadGitignore(rootAbs)
+		f.gitignorePath = ".gitignore"
+	}
+	return f, nil
+}
+
+// dir decides whether a directory is descended into.
+func (f *filter) dir(rel string) Decision {
+	d := Decision{Path: rel, IsDir: true, Included: true}
+	if isHardExcludedDir(rel) {
+		d.add(Step{Rule: RuleHardExclude, Effect: EffectExclude, Pattern: path.Base(rel), Source: "built-in"})
+		return d
+	}
+	if rule, ok := f.rules.Match(rel, true); ok {
+		d.add(Step{Rule: RuleIgnoreList, Effect: EffectExclude, Pattern: rule.Pattern, Source: rule.Source})
+		return d
+	}
+	if !inSubdirs(rel, f.cfg.Subdirs, true) {
+		d.add(Step{Rule: RulePackage, Effect: EffectExclude, Detail: "outside " + strings.Join(f.cfg.Subdirs, ", ")})
+	}
+	return d
+}
+
+// file runs the path-level rules for a file of the given size.
+func (f *filter) file(rel string, size int64) Decision {
+	d := Decision{Path: rel, Size: size, Included: true}
+
+	if rule, ok := f.rules.Match(rel, false); ok {
+		d.add(Step{Rule: RuleIgnoreList, Effect: EffectExclude, Pattern: rule.Pattern, Source: rule.Source})
+		return d
+	}
+	d.add(Step{Rule: RuleIgnoreList, Effect: EffectPass})
+
+	if !inSubdirs(rel, f.cfg.Subdirs, false) {
+		d.add(Step{Rule: RulePackage, Effect: EffectExclude, Detail: "outside " + strings.Join(f.cfg.Subdirs, ", ")})
+		return d
+	}
+
+	// includes (highest precedence)
+	if len(f.cfg.IncludeGlobs) > 0 {
+		g, ok := filetree.MatchingGlob(rel, f.cfg.IncludeGlobs)
+		if !ok {
+			d.add(Step{Rule: RuleInclude, Effect: EffectExclude, Source: "--include", Detail: "no include glob matched"})
+			return d
+		}
+		d.add(Step{Rule: RuleInclude, Effect: EffectInclude, Pattern: g, Source: "--include", Detail: ".gitignore and --ignore are not consulted"})
+	} else {
+		// if not explicitly included, apply ignores
+		if f.gitIg != nil {
+			if ok, how := f.gitIg.MatchesPathHow(rel); ok {
+				d.add(Step{Rule: RuleGitignore, Effect: EffectExclude, Pattern: how.Line, Source: fmt.Sprintf("%s:%d", f.gitignorePath, how.LineNo)})
+				return d
+			}
+			d.add(Step{Rule: RuleGitignore, Effect: EffectPass})
+		}
+		if g, ok := filetree.MatchingGlob(rel, f.cfg.IgnoreGlobs); ok {
+			d.add(Step{Rule: RuleIgnoreGlob, Effect: EffectExclude, Pattern: g, Source: "--ignore"})
+			return d
+		}
+		if len(f.cfg.IgnoreGlobs) > 0 {
+			d.add(Step{Rule: RuleIgnoreGlob, Effect: EffectPass})
+		}
+	}
+
+	// a stub is small whatever the size of the file
+	if f.cfg.judgesOrigin(rel) {
+		if o := analyzer.DetectOriginByPath(rel, f.cfg.Attributes); o.Kind() != "" {
+			d.add(originStep(o, f.cfg.Generated))
+			return d
+		}
+	}
+
+	// per-file cap
+	if f.cfg.MaxFileBytes > 0 {
+		_, convertible := f.cfg.converter(rel, size)
+		if size > f.cfg.MaxFileBytes && convertible {
+			d.add(Step{Rule: RuleMaxFileBytes, Effect: EffectPass, Source: "--convert", Detail: fmt.Sprintf("%d > %d bytes, the cap applies to the converted text", size, f.cfg.MaxFileBytes)})
+		} else if size > f.cfg.MaxFileBytes && f.cfg.excerpts() {
+			d.add(Step{Rule: RuleMaxFileBytes, Effect: EffectPass, Source: "--large-files " + string(f.cfg.LargeFiles), Detail: fmt.Sprintf("%d > %d bytes, excerpted", size, f.cfg.MaxFileBytes)})
+		} else if size > f.cfg.MaxFileBytes {
+			d.add(Step{Rule: RuleMaxFileBytes, Effect: EffectExclude, Source: "--max-file-bytes", Detail: fmt.Sprintf("%d > %d bytes", size, f.cfg.MaxFileBytes)})
+			return d
+		} else {
+			d.add(Step{Rule: RuleMaxFileBytes, Effect: EffectPass, Detail: fmt.Sprintf("%d <= %d bytes", size, f.cfg.MaxFileBytes)})
+		}
+	}
+
+	if f.cfg.Lockfiles == LockfilesSkip && analyzer.IsLockfile(path.Base(rel)) {
+		d.add(Step{Rule: RuleLockfile, Effect: EffectExclude, Source: "--lockfiles skip"})
+	}
+	return d
+}
+
+// DryRun evaluates the rule chain for every path under cfg.RootDir
+// without reading any file content. Directories that are not descended
+// into appear once, as a directory decision. The binary check needs
+// content, so it is not applied; the total cap is estimated from file
+// sizes in pack order.
+func DryRun(ctx context.Context, cfg Config) ([]Decision, error) {
+	if cfg.RootDir == "" {
+		return nil, errors.New("empty RootDir")
+	}
+	rootAbs, err := filepath.Abs(cfg.RootDir)
+	if err != nil {
+		return nil, err
+	}
+	flt, err := newFilter(cfg, rootAbs)
+	if err != nil {
+		return nil, err
+	}
+
+	var decisions []Decision
+	err = walkRoot(rootAbs, cfg.symlinks(), func(p walkPath, werr error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if werr != nil {
+			return nil
+		}
+		rel := p.rel
+		if p.d.IsDir() {
+			if dec := flt.dir(rel); !dec.Included {
+				decisions = append(decisions, dec)
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if p.skip != "" {
+			dec := flt.file(rel, 0)
+			if dec.Included {
+				dec.add(symlinkStep(p, cfg.symlinks()))
+			}
+			decisions = append(decisions, dec)
+			return nil
+		}
+		var size int64
+		if info, ierr := os.Stat(p.abs); ierr == nil {
+			size = info.Size()
+		}
+		decisions = append(decisions, flt.file(rel, size))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the total cap applies in pack order
+	if cfg.MaxTotalBytes > 0 {
+		byPath := map[string]int{}
+		var order []string
+		for i, d := range decisions {
+			if d.Included {
+				byPath[d.Path] = i
+				order = append(order, d.Path)
+			}
+		}
+		sortPackOrder(order, cfg.SortByExt)
+		var total int64
+		for _, rel := range order {
+			d := &decisions[byPath[rel]]
+			if total+d.Size > cfg.MaxTotalBytes {
+				d.add(Step{Rule: RuleMaxTotalBytes, Effect: EffectExclude, Source: "--max-total-bytes", Detail: fmt.Sprintf("estimated total %d + %d > %d bytes", total, d.Size, cfg.MaxTotalBytes)})
+				continue
+			}
+			total += d.Size
+		}
+	}
+
+	sort.Slice(decisions, func(i, j int) bool { return decisions[i].Path < decisions[j].Path })
+	return decisions, nil
+}
+
+// Explain returns the decision chain for one path, relative to
+// cfg.RootDir. Unlike DryRun it sniffs the file to apply the binary check
+// and to spot generated code by its header or minified code.
+func Explain(ctx context.Context, cfg Config, rel string) (Decision, error) {
+	rel = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(rel)), "./")
+	if rel == "." || rel == "" || strings.HasPrefix(rel, "../") {
+		return Decision{}, fmt.Errorf("%s is not inside %s", rel, cfg.RootDir)
+	}
+	rootAbs, err := filepath.Abs(cfg.RootDir)
+	if err != nil {
+		return Decision{}, err
+	}
+	abs := filepath.Join(rootAbs, filepath.FromSlash(rel))
+	info, err := os.Lstat(abs)
+	if err != nil {
+		return Decision{}, err
+	}
+	flt, err := newFilter(cfg, rootAbs)
+	if err != nil {
+		return Decision{}, err
+	}
+
+	// a link that isn't followed, or a special file, is never read
+	var left *walkPath
+	target := ""
+	if info.Mode()&fs.ModeSymlink != 0 {
+		target, _ = os.Readlink(abs)
+		realRoot := rootAbs
+		if r, err := filepath.EvalSymlinks(rootAbs); err == nil {
+			realRoot = r
+		}
+		switch _, tinfo, why := followLink(cfg.symlinks(), realRoot, abs); why {
+		case "":
+			info = tinfo
+		case "broken":
+			_, err := os.Stat(abs)
+			return Decision{}, err
+		default:
+			left = &walkPath{target: target, skip: RuleSymlink, detail: why}
+		}
+	}
+	if left == nil && !info.IsDir() && !info.Mode().IsRegular() {
+		left = &walkPath{skip: RuleSpecial, detail: specialKind(info.Mode())}
+	}
+
+	// a directory above the path may already cut it off
+	parts := strings.Split(rel, "/")
+	last := len(parts)
+	if !info.IsDir() {
+		last--
+	}
+	for i := 1; i <= last; i++ {
+		dir := strings.Join(parts[:i], "/")
+		if dd := flt.dir(dir); !dd.Included {
+			if dir == rel {
+				return dd, nil
+			}
+			step := dd.Reason()
+			step.Detail = strings.TrimSpace("in excluded directory " + dir + "/ " + step.Detail)
+			return Decision{Path: rel, IsDir: info.IsDir(), Size: info.Size(), Steps: []Step{step}}, nil
+		}
+	}
+	if info.IsDir() {
+		return Decision{Path: rel, IsDir: true, Included: true}, nil
+	}
+
+	if left != nil {
+		d := flt.file(rel, 0)
+		if d.Included {
+			d.add(symlinkStep(*left, cfg.symlinks()))
+		}
+		return d, nil
+	}
+
+	d := flt.file(rel, info.Size())
+	if target != "" {
+		// the link is followed before any other rule applies
+		d.Steps = append([]Step{{Rule: RuleSymlink, Effect: EffectPass, Source: "--symlinks " + string(cfg.symlinks()), Detail: "-> " + target}}, d.Steps...)
+	}
+	if !d.Included {
+		return d, nil
+	}
+
+	head, herr := readHead(abs)
+	if herr == nil && d.Reason().Rule != RuleGenerated && flt.cfg.judgesOrigin(rel) {
+		text := head
+		if enc, isBin := readEncoding(head, cfg); !isBin {
+			text = decodeText(head, enc)
+		}
+		if o := analyzer.DetectOrigin(rel, text, flt.cfg.Attributes); o.Kind() != "" {
+			d.add(originStep(o, cfg.Generated))
+			if !d.Included {
+				return d, nil
+			}
+		}
+	}
+	if conv, ok := cfg.converter(rel, info.Size()); ok && d.Reason().Rule != RuleGenerated {
+		d.add(Step{Rule: RuleBinary, Effect: EffectPass, Source: "--convert", Detail: "packed as the text the " + conv.Name() + " converter extracts"})
+	} else if herr == nil && isBinary(head) && d.Reason().Rule != RuleGenerated {
+		if cfg.excerpts() && info.Size() > cfg.MaxFileBytes {
+			d.add(Step{Rule: RuleMaxFileBytes, Effect: EffectExclude, Source: "--max-file-bytes", Detail: "binary files can't be excerpted"})
+			return d, nil
+		}
+		switch cfg.BinaryHandling {
+		case BinarySkip:
+			d.add(Step{Rule: RuleBinary, Effect: EffectExclude, Source: "--binary skip"})
+			return d, nil
+		default:
+			d.add(Step{Rule: RuleBinary, Effect: EffectPass, Source: "--binary " + string(cfg.BinaryHandling), Detail: "binary content is encoded"})
+		}
+	}
+
+	if cfg.MaxTotalBytes > 0 {
+		all, err := DryRun(ctx, cfg)
+		if err != nil {
+			return d, err
+		}
+		for _, other := range all {
+			if other.Path == rel {
+				if r := other.Reason(); r.Rule == RuleMaxTotalBytes {
+					d.add(r)
+				}
+				break
+			}
+		}
+	}
+	return d, nil
+}
+
+// sniffLen is how much of a file isBinary looks at.
+const sniffLen = 8192
+
+func readHead(abs string) ([]byte, error) {
+	f, err := os.Open(abs)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return head[:n], nil
+}
+
+// sortPackOrder sorts paths the way files appear in a pack: by path, or
+// by extension then path.
+func sortPackOrder(paths []string, byExt bool) {
+	if !byExt {
+		sort.Strings(paths)
+		return
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		exti := strings.ToLower(filepath.Ext(paths[i]))
+		extj := strings.ToLower(filepath.Ext(paths[j]))
+		if exti == extj {
+			return paths[i] < paths[j]
+		}
+		return exti < extj
+	})
+}
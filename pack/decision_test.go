@@ -0,0 +1,137 @@
+package pack
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func decisionFor(t *testing.T, ds []Decision, rel string) Decision {
+	t.Helper()
+	for _, d := range ds {
+		if d.Path == rel {
+			return d
+		}
+	}
+	t.Fatalf("no decision for %s in %+v", rel, ds)
+	return Decision{}
+}
+
+func TestDryRun_ReportsRuleAndSource(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	td := t.TempDir()
+	writeFile(t, filepath.Join(td, ".gitignore"), []byte("# comment\n*.log\n"))
+	writeFile(t, filepath.Join(td, "main.go"), []byte("package main\n"))
+	writeFile(t, filepath.Join(td, "debug.log"), []byte("x\n"))
+	writeFile(t, filepath.Join(td, "notes.md"), []byte("# notes\n"))
+	writeFile(t, filepath.Join(td, "big.txt"), make([]byte, 100))
+	writeFile(t, filepath.Join(td, "dist", "app.js"), []byte("x\n"))
+
+	ds, err := DryRun(context.Background(), Config{
+		RootDir:          td,
+		RespectGitignore: true,
+		IgnoreGlobs:      []string{"*.md"},
+		MaxFileBytes:     50,
+	})
+	if err != nil {
+		t.Fatalf("DryRun: %v", err)
+	}
+
+	if d := decisionFor(t, ds, "main.go"); !d.Included {
+		t.Errorf("main.go should be included: %+v", d)
+	}
+	if r := decisionFor(t, ds, "debug.log").Reason(); r.Rule != RuleGitignore || r.Pattern != "*.log" || r.Source != ".gitignore:2" {
+		t.Errorf("debug.log reason = %+v", r)
+	}
+	if r := decisionFor(t, ds, "notes.md").Reason(); r.Rule != RuleIgnoreGlob || r.Pattern != "*.md" || r.Source != "--ignore" {
+		t.Errorf("notes.md reason = %+v", r)
+	}
+	if r := decisionFor(t, ds, "big.txt").Reason(); r.Rule != RuleMaxFileBytes {
+		t.Errorf("big.txt reason = %+v", r)
+	}
+	d := decisionFor(t, ds, "dist")
+	if r := d.Reason(); !d.IsDir || r.Rule != RuleIgnoreList || r.Pattern != "dist" {
+		t.Errorf("dist decision = %+v", d)
+	}
+	for _, d := range ds {
+		if d.Path == "dist/app.js" {
+			t.Errorf("files below a pruned directory should not be listed")
+		}
+	}
+}
+
+func TestDryRun_IncludeOverridesIgnores(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	td := t.TempDir()
+	writeFile(t, filepath.Join(td, ".gitignore"), []byte("*.md\n"))
+	writeFile(t, filepath.Join(td, "README.md"), []byte("# r\n"))
+	writeFile(t, filepath.Join(td, "main.go"), []byte("package main\n"))
+
+	ds, err := DryRun(context.Background(), Config{
+		RootDir:          td,
+		RespectGitignore: true,
+		IncludeGlobs:     []string{"*.md"},
+	})
+	if err != nil {
+		t.Fatalf("DryRun: %v", err)
+	}
+	d := decisionFor(t, ds, "README.md")
+	if !d.Included || d.Reason().Rule != RuleInclude || d.Reason().Pattern != "*.md" {
+		t.Errorf("README.md decision = %+v", d)
+	}
+	if r := decisionFor(t, ds, "main.go").Reason(); r.Rule != RuleInclude || r.Effect != EffectExclude {
+		t.Errorf("main.go reason = %+v", r)
+	}
+}
+
+func TestDryRun_EstimatesTotalCap(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	td := t.TempDir()
+	writeFile(t, filepath.Join(td, "a.txt"), make([]byte, 40))
+	writeFile(t, filepath.Join(td, "b.txt"), make([]byte, 40))
+	writeFile(t, filepath.Join(td, "c.txt"), make([]byte, 10))
+
+	ds, err := DryRun(context.Background(), Config{RootDir: td, MaxTotalBytes: 60})
+	if err != nil {
+		t.Fatalf("DryRun: %v", err)
+	}
+	if !decisionFor(t, ds, "a.txt").Included || !decisionFor(t, ds, "c.txt").Included {
+		t.Errorf("a.txt and c.txt fit under the cap: %+v", ds)
+	}
+	if r := decisionFor(t, ds, "b.txt").Reason(); r.Rule != RuleMaxTotalBytes {
+		t.Errorf("b.txt reason = %+v", r)
+	}
+}
+
+func TestExplain_BinaryAndExcludedDir(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	td := t.TempDir()
+	writeFile(t, filepath.Join(td, "img.bin"), []byte{0x00, 0x01, 0x02})
+	writeFile(t, filepath.Join(td, "node_modules", "x", "index.js"), []byte("x\n"))
+
+	cfg := Config{RootDir: td, BinaryHandling: BinarySkip}
+	d, err := Explain(context.Background(), cfg, "img.bin")
+	if err != nil {
+		t.Fatalf("Explain: %v", err)
+	}
+	if d.Included || d.Reason().Rule != RuleBinary {
+		t.Errorf("img.bin decision = %+v", d)
+	}
+
+	cfg.BinaryHandling = BinaryBase64
+	if d, _ := Explain(context.Background(), cfg, "img.bin"); !d.Included {
+		t.Errorf("img.bin should be included with --binary base64: %+v", d)
+	}
+
+	d, err = Explain(context.Background(), cfg, "node_modules/x/index.js")
+	if err != nil {
+		t.Fatalf("Explain: %v", err)
+	}
+	if d.Included || d.Reason().Rule != RuleHardExclude {
+		t.Errorf("node_modules file decision = %+v", d)
+	}
+
+	if _, err := Explain(context.Background(), cfg, "../outside"); err == nil {
+		t.Errorf("expected an error for a path outside the root")
+	}
+}
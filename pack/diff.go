@@ -0,0 +1,292 @@
+package pack
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/parsabordbar/ctx3/analyzer"
+)
+
+// ChangeKind says how a file differs between two packs.
+type ChangeKind string
+
+const (
+	ChangeAdded    ChangeKind = "added"
+	ChangeRemoved  ChangeKind = "removed"
+	ChangeModified ChangeKind = "modified"
+)
+
+// FileDiff is one file that differs between two packs.
+type FileDiff struct {
+	Path      string     `json:"path"`
+	Change    ChangeKind `json:"change"`
+	OldTokens int        `json:"oldTokens"`
+	NewTokens int        `json:"newTokens"`
+	// TokenDelta is NewTokens - OldTokens.
+	TokenDelta int `json:"tokenDelta"`
+	// Unified is the change as a unified diff with "a/" and "b/" paths.
+	Unified string `json:"unified"`
+}
+
+// DefaultDiffContext is the number of unchanged lines around each change
+// in a unified diff, as in diff -u.
+const DefaultDiffContext = 3
+
+// maxDiffEdits bounds the work spent on one file: past this many changed
+// lines, the file is shown as entirely removed and re-added.
+const maxDiffEdits = 4000
+
+// Diff compares the files of two packs, e.g. the Files of two parsed
+// Documents, and returns the added, removed and modified ones sorted by
+// path, with unified diffs carrying context lines of context. A file whose
+// summary changed counts as modified even if its content didn't.
+func Diff(old, new []FileEntry, context int) []FileDiff {
+	if context < 0 {
+		context = DefaultDiffContext
+	}
+	before := make(map[string]FileEntry, len(old))
+	for _, f := range old {
+		before[f.RelPath] = f
+	}
+	after := make(map[string]FileEntry, len(new))
+	for _, f := range new {
+		after[f.RelPath] = f
+	}
+
+	var diffs []FileDiff
+	for rel, o := range before {
+		n, ok := after[rel]
+		switch {
+		case !ok:
+			diffs = append(diffs, fileDiff(rel, ChangeRemoved, &o, nil, context))
+		case !bytes.Equal(o.Content, n.Content) || o.Summary != n.Summary:
+			diffs = append(diffs, fileDiff(rel, ChangeModified, &o, &n, context))
+		}
+	}
+	for rel, n := range after {
+		if _, ok := before[rel]; !ok {
+			diffs = append(diffs, fileDiff(rel, ChangeAdded, nil, &n, context))
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Path < diffs[j].Path })
+	return diffs
+}
+
+func fileDiff(rel string, change ChangeKind, old, new *FileEntry, context int) FileDiff {
+	d := FileDiff{Path: rel, Change: change}
+	from, to := "/dev/null", "/dev/null"
+	var a, b []string
+	if old != nil {
+		d.OldTokens = analyzer.EstimateTokens(old.Content)
+		from, a = "a/"+rel, splitLines(old.Content)
+	}
+	if new != nil {
+		d.NewTokens = analyzer.EstimateTokens(new.Content)
+		to, b = "b/"+rel, splitLines(new.Content)
+	}
+	d.TokenDelta = d.NewTokens - d.OldTokens
+	d.Unified = unified(from, to, a, b, context)
+	return d
+}
+
+// splitLines splits content into lines that keep their newline, so a
+// missing newline at the end of a file shows up as a change.
+func splitLines(content []byte) []string {
+	var lines []string
+	for len(content) > 0 {
+		i := bytes.IndexByte(content, '\n')
+		if i < 0 {
+			lines = append(lines, string(content))
+			break
+		}
+		lines = append(lines, string(content[:i+1]))
+		content = content[i+1:]
+	}
+	return lines
+}
+
+// edit is one line of a diff: ' ' kept, '-' removed or '+' added.
+type edit struct {
+	op   byte
+	line string
+}
+
+// unified renders the diff of a and b as a unified diff, or "" when they
+// are equal.
+func unified(from, to string, a, b []string, context int) string {
+	edits := diffLines(a, b)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", from, to)
+	changed := false
+
+	// aLine and bLine count the lines of a and b before edits[i]
+	aLine, bLine := 0, 0
+	for i := 0; i < len(edits); {
+		if edits[i].op == ' ' {
+			aLine, bLine, i = aLine+1, bLine+1, i+1
+			continue
+		}
+		changed = true
+		// a hunk starts context lines before the change and runs until a
+		// gap of more than 2*context unchanged lines
+		start := max(0, i-context)
+		for j := start; j < i; j++ {
+			aLine, bLine = aLine-1, bLine-1
+		}
+		end := i
+		for end < len(edits) {
+			if edits[end].op != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(edits) && edits[run].op == ' ' {
+				run++
+			}
+			if run == len(edits) || run-end > 2*context {
+				end = min(end+context, len(edits))
+				break
+			}
+			end = run
+		}
+
+		var aCount, bCount int
+		for _, e := range edits[start:end] {
+			if e.op != '+' {
+				aCount++
+			}
+			if e.op != '-' {
+				bCount++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(aLine, aCount), hunkRange(bLine, bCount))
+		for _, e := range edits[start:end] {
+			sb.WriteByte(e.op)
+			sb.WriteString(e.line)
+			if !strings.HasSuffix(e.line, "\n") {
+				sb.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		aLine, bLine, i = aLine+aCount, bLine+bCount, end
+	}
+	if !changed {
+		return ""
+	}
+	return sb.String()
+}
+
+// hunkRange formats the "start,count" of a hunk side; before is the number
+// of lines ahead of the hunk.
+func hunkRange(before, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", before+1)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
+
+// diffLines returns a shortest edit script turning a into b (Myers' O(ND)
+// algorithm), with the unchanged lines kept as ' ' edits.
+func diffLines(a, b []string) []edit {
+	// common prefix and suffix need no search
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+
+	edits := make([]edit, 0, len(a)+len(b))
+	for _, l := range a[:pre] {
+		edits = append(edits, edit{' ', l})
+	}
+	edits = append(edits, myers(a[pre:len(a)-suf], b[pre:len(b)-suf])...)
+	for _, l := range a[len(a)-suf:] {
+		edits = append(edits, edit{' ', l})
+	}
+	return edits
+}
+
+func myers(a, b []string) []edit {
+	n, m := len(a), len(b)
+	replace := func() []edit {
+		edits := make([]edit, 0, n+m)
+		for _, l := range a {
+			edits = append(edits, edit{'-', l})
+		}
+		for _, l := range b {
+			edits = append(edits, edit{'+', l})
+		}
+		return edits
+	}
+	if n == 0 || m == 0 {
+		return replace()
+	}
+
+	// v[off+k] is the furthest x reached on diagonal k; trace[d] keeps
+	// v[-d-1..d+1] as it was before step d, for the walk back
+	off := n + m + 1
+	v := make([]int, 2*off+1)
+	var trace [][]int
+	found := -1
+	for d := 0; d <= min(n+m, maxDiffEdits) && found < 0; d++ {
+		trace = append(trace, append([]int(nil), v[off-d-1:off+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				found = d
+				break
+			}
+		}
+	}
+	if found < 0 {
+		return replace()
+	}
+
+	var rev []edit
+	x, y := n, m
+	for d := found; d >= 0; d-- {
+		prev := func(k int) int { return trace[d][k+d+1] }
+		k := x - y
+		var pk int
+		if k == -d || (k != d && prev(k-1) < prev(k+1)) {
+			pk = k + 1
+		} else {
+			pk = k - 1
+		}
+		px := prev(pk)
+		py := px - pk
+		for x > px && y > py {
+			rev = append(rev, edit{' ', a[x-1]})
+			x, y = x-1, y-1
+		}
+		if d > 0 {
+			if x == px {
+				rev = append(rev, edit{'+', b[y-1]})
+			} else {
+				rev = append(rev, edit{'-', a[x-1]})
+			}
+			x, y = px, py
+		}
+	}
+	for i, j := 0, len(rev)-1; i < j; i, j = i+1, j-1 {
+		rev[i], rev[j] = rev[j], rev[i]
+	}
+	return rev
+}
@@ -0,0 +1,69 @@
+package pack
+
+import "testing"
+
+func TestDiff(t *testing.T) {
+	old := []FileEntry{
+		{RelPath: "a.go", Content: []byte("1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n")},
+		{RelPath: "gone.txt", Content: []byte("bye\n")},
+		{RelPath: "same.txt", Content: []byte("same\n")},
+	}
+	new := []FileEntry{
+		{RelPath: "a.go", Content: []byte("1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13")},
+		{RelPath: "new.txt", Content: []byte("hello world\n")},
+		{RelPath: "same.txt", Content: []byte("same\n")},
+	}
+	diffs := Diff(old, new, DefaultDiffContext)
+	if len(diffs) != 3 {
+		t.Fatalf("got %d diffs, want 3: %+v", len(diffs), diffs)
+	}
+
+	a := diffs[0]
+	if a.Path != "a.go" || a.Change != ChangeModified {
+		t.Fatalf("diffs[0] = %s %s", a.Path, a.Change)
+	}
+	want := `--- a/a.go
++++ b/a.go
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -10,3 +10,4 @@
+ 10
+ 11
+ 12
++13
+\ No newline at end of file
+`
+	if a.Unified != want {
+		t.Errorf("unified diff:\n%s\nwant:\n%s", a.Unified, want)
+	}
+	if a.TokenDelta != a.NewTokens-a.OldTokens || a.TokenDelta <= 0 {
+		t.Errorf("token delta %d (old %d, new %d)", a.TokenDelta, a.OldTokens, a.NewTokens)
+	}
+
+	if g := diffs[1]; g.Path != "gone.txt" || g.Change != ChangeRemoved || g.Unified != "--- a/gone.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-bye\n" || g.TokenDelta >= 0 {
+		t.Errorf("removed file: %+v", g)
+	}
+	if n := diffs[2]; n.Path != "new.txt" || n.Change != ChangeAdded || n.Unified != "--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1 @@\n+hello world\n" || n.OldTokens != 0 {
+		t.Errorf("added file: %+v", n)
+	}
+}
+
+func TestDiffLines_Shortest(t *testing.T) {
+	a := splitLines([]byte("a\nb\nc\na\nb\nb\na\n"))
+	b := splitLines([]byte("c\nb\na\nb\na\nc\n"))
+	changes := 0
+	for _, e := range diffLines(a, b) {
+		if e.op != ' ' {
+			changes++
+		}
+	}
+	if changes != 5 {
+		t.Fatalf("got %d changed lines, want 5", changes)
+	}
+}
@@ -0,0 +1,220 @@
+package pack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Values of FileEntry.Encoding: the encoding a text file was read in.
+// Packed content is always UTF-8.
+const (
+	EncodingUTF8        = "utf-8"
+	EncodingUTF16LE     = "utf-16le"
+	EncodingUTF16BE     = "utf-16be"
+	EncodingUTF32LE     = "utf-32le"
+	EncodingUTF32BE     = "utf-32be"
+	EncodingWindows1252 = "windows-1252"
+	EncodingLatin1      = "iso-8859-1"
+)
+
+// transcoded reports whether f was read in another encoding than UTF-8,
+// which renderers point out.
+func transcoded(f FileEntry) bool {
+	return f.Encoding != "" && f.Encoding != EncodingUTF8
+}
+
+// sniffEncoding classifies the first bytes of a file as binary, or as text
+// in an encoding: by its byte order mark, by the NUL bytes of UTF-16 and
+// UTF-32, as UTF-8, or as Windows-1252 when its high bytes read like
+// accented letters and punctuation. A text file whose encoding can't be
+// told apart returns "".
+func sniffEncoding(head []byte) (enc string, isBinary bool) {
+	switch {
+	case len(head) == 0:
+		return EncodingUTF8, false
+	// UTF-32LE first: its mark starts with the UTF-16LE one
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE, 0x00, 0x00}):
+		return EncodingUTF32LE, false
+	case bytes.HasPrefix(head, []byte{0x00, 0x00, 0xFE, 0xFF}):
+		return EncodingUTF32BE, false
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE}):
+		return EncodingUTF16LE, false
+	case bytes.HasPrefix(head, []byte{0xFE, 0xFF}):
+		return EncodingUTF16BE, false
+	case bytes.HasPrefix(head, []byte{0xEF, 0xBB, 0xBF}):
+		return EncodingUTF8, false
+	}
+
+	if bytes.IndexByte(head, 0x00) >= 0 {
+		// without a mark, only text made mostly of ASCII is recognized
+		for _, enc := range []string{EncodingUTF32LE, EncodingUTF32BE, EncodingUTF16LE, EncodingUTF16BE} {
+			if text := decodeText(head, enc); looksLikeText(text) && asciiShare(text) >= 0.7 {
+				return enc, false
+			}
+		}
+		return "", true
+	}
+	if utf8.Valid(trimPartialRune(head)) {
+		return EncodingUTF8, false
+	}
+	nng := 0
+	for _, b := range head {
+		if b < 0x09 || (b > 0x0D && b < 0x20) {
+			nng++
+		}
+	}
+	if float64(nng)/float64(len(head)) > 0.3 {
+		return "", true
+	}
+	if looksWindows1252(head) {
+		return EncodingWindows1252, false
+	}
+	return "", false
+}
+
+// trimPartialRune drops an incomplete UTF-8 sequence at the end of b, as
+// left where a head was cut.
+func trimPartialRune(b []byte) []byte {
+	for i := 1; i <= utf8.UTFMax-1 && i <= len(b); i++ {
+		c := b[len(b)-i]
+		if c < utf8.RuneSelf {
+			break
+		}
+		if utf8.RuneStart(c) {
+			if !utf8.FullRune(b[len(b)-i:]) {
+				return b[:len(b)-i]
+			}
+			break
+		}
+	}
+	return b
+}
+
+// looksLikeText reports whether decoded text has hardly any control
+// characters, invalid sequences or unpaired surrogates.
+func looksLikeText(s []byte) bool {
+	runes, bad := 0, 0
+	for len(s) > 0 {
+		r, n := utf8.DecodeRune(s)
+		s = s[n:]
+		runes++
+		if r == utf8.RuneError || (unicode.IsControl(r) && r != '\t' && r != '\n' && r != '\r' && r != '\f' && r != '\v') {
+			bad++
+		}
+	}
+	return runes > 0 && bad*100 <= runes
+}
+
+// asciiShare is the share of the runes of s below U+0080.
+func asciiShare(s []byte) float64 {
+	ascii := 0
+	for _, c := range s {
+		if c < utf8.RuneSelf {
+			ascii++
+		}
+	}
+	return float64(ascii) / float64(max(utf8.RuneCount(s), 1))
+}
+
+// looksWindows1252 reports whether the bytes over 0x7F of b read as
+// Windows-1252: letters next to other letters, and symbols next to ASCII.
+// Legacy multi-byte encodings (Shift JIS, GBK, ...) put high bytes in
+// runs that fail this.
+func looksWindows1252(b []byte) bool {
+	high, plausible := 0, 0
+	at := func(i int) byte {
+		if i < 0 || i >= len(b) {
+			return ' '
+		}
+		return b[i]
+	}
+	isLetter := func(c byte) bool {
+		return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || (c >= 0xC0 && c != 0xD7 && c != 0xF7)
+	}
+	for i, c := range b {
+		if c < 0x80 {
+			continue
+		}
+		high++
+		if c < 0xA0 && windows1252[c-0x80] == utf8.RuneError {
+			return false // undefined in Windows-1252
+		}
+		prev, next := at(i-1), at(i+1)
+		switch {
+		case isLetter(c) && (isLetter(prev) || isLetter(next)):
+			plausible++
+		case !isLetter(c) && (prev < 0x80 || next < 0x80):
+			plausible++
+		}
+	}
+	return high*100 <= len(b)*30 && plausible*10 >= high*9
+}
+
+// windows1252 maps the bytes 0x80-0x9F of Windows-1252; the others are
+// the same as in ISO-8859-1. RuneError marks the five undefined bytes.
+var windows1252 = [32]rune{
+	'€', utf8.RuneError, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', utf8.RuneError, 'Ž', utf8.RuneError,
+	utf8.RuneError, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', utf8.RuneError, 'ž', 'Ÿ',
+}
+
+// decodeText transcodes b from enc to UTF-8, dropping a byte order mark.
+// Invalid sequences become U+FFFD; UTF-8 and unknown encodings are
+// returned as they are.
+func decodeText(b []byte, enc string) []byte {
+	switch enc {
+	case EncodingUTF8:
+		return bytes.TrimPrefix(b, []byte{0xEF, 0xBB, 0xBF})
+	case EncodingUTF16LE, EncodingUTF16BE:
+		order := byteOrder(enc)
+		units := make([]uint16, 0, len(b)/2)
+		for i := 0; i+1 < len(b); i += 2 {
+			units = append(units, order.Uint16(b[i:]))
+		}
+		if len(units) > 0 && units[0] == 0xFEFF {
+			units = units[1:]
+		}
+		return appendRunes(nil, utf16.Decode(units))
+	case EncodingUTF32LE, EncodingUTF32BE:
+		order := byteOrder(enc)
+		runes := make([]rune, 0, len(b)/4)
+		for i := 0; i+3 < len(b); i += 4 {
+			r := rune(order.Uint32(b[i:]))
+			if i == 0 && r == 0xFEFF {
+				continue
+			}
+			if !utf8.ValidRune(r) {
+				r = utf8.RuneError
+			}
+			runes = append(runes, r)
+		}
+		return appendRunes(nil, runes)
+	case EncodingWindows1252, EncodingLatin1:
+		out := make([]byte, 0, len(b)+len(b)/4)
+		for _, c := range b {
+			r := rune(c)
+			if enc == EncodingWindows1252 && c >= 0x80 && c < 0xA0 {
+				r = windows1252[c-0x80]
+			}
+			out = utf8.AppendRune(out, r)
+		}
+		return out
+	}
+	return b
+}
+
+func byteOrder(enc string) binary.ByteOrder {
+	if enc == EncodingUTF16BE || enc == EncodingUTF32BE {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+func appendRunes(dst []byte, runes []rune) []byte {
+	for _, r := range runes {
+		dst = utf8.AppendRune(dst, r)
+	}
+	return dst
+}
@@ -0,0 +1,134 @@
+package pack
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func utf16Bytes(s string, order binary.AppendByteOrder, bom bool) []byte {
+	var out []byte
+	if bom {
+		out = order.AppendUint16(out, 0xFEFF)
+	}
+	for _, u := range utf16.Encode([]rune(s)) {
+		out = order.AppendUint16(out, u)
+	}
+	return out
+}
+
+func TestSniffEncoding(t *testing.T) {
+	text := "héllo, wörld\r\nsecond line\r\n"
+	var utf32 []byte
+	for _, r := range text {
+		utf32 = binary.LittleEndian.AppendUint32(utf32, uint32(r))
+	}
+	cases := []struct {
+		name   string
+		data   []byte
+		enc    string
+		binary bool
+	}{
+		{"empty", nil, EncodingUTF8, false},
+		{"ascii", []byte("plain\n"), EncodingUTF8, false},
+		{"utf-8 bom", append([]byte{0xEF, 0xBB, 0xBF}, text...), EncodingUTF8, false},
+		{"utf-8 cut mid-rune", []byte(text)[:2], EncodingUTF8, false},
+		{"utf-16le bom", utf16Bytes(text, binary.LittleEndian, true), EncodingUTF16LE, false},
+		{"utf-16be bom", utf16Bytes(text, binary.BigEndian, true), EncodingUTF16BE, false},
+		{"utf-16le", utf16Bytes(text, binary.LittleEndian, false), EncodingUTF16LE, false},
+		{"utf-16be", utf16Bytes(text, binary.BigEndian, false), EncodingUTF16BE, false},
+		{"utf-32le", utf32, EncodingUTF32LE, false},
+		{"windows-1252", []byte("caf\xe9 \x93cr\xe8me br\xfbl\xe9e\x94 \x80 5\n"), EncodingWindows1252, false},
+		{"shift_jis", []byte("\x82\xb1\x82\xf1\x82\xc9\x82\xbf\x82\xcd\n"), "", false},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x01\x00"), "", true},
+		{"control bytes", bytes.Repeat([]byte{0x01, 0x02, 0x03, 0xff}, 8), "", true},
+	}
+	for _, c := range cases {
+		enc, bin := sniffEncoding(c.data)
+		if enc != c.enc || bin != c.binary {
+			t.Errorf("%s: got %q binary=%v, want %q binary=%v", c.name, enc, bin, c.enc, c.binary)
+		}
+		if !bin && enc != "" && enc != EncodingWindows1252 && strings.HasPrefix(c.name, "utf") && len(c.data) > 2 {
+			if got := string(decodeText(c.data, enc)); got != text {
+				t.Errorf("%s: decoded %q", c.name, got)
+			}
+		}
+	}
+	if got := string(decodeText([]byte("caf\xe9 \x93x\x94"), EncodingWindows1252)); got != "café “x”" {
+		t.Errorf("windows-1252: decoded %q", got)
+	}
+	if got := string(decodeText([]byte("caf\xe9 \x93"), EncodingLatin1)); got != "café \u0093" {
+		t.Errorf("latin1: decoded %q", got)
+	}
+}
+
+func TestWalk_Encodings(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	td := t.TempDir()
+	writeFile(t, filepath.Join(td, "res", "app.rc"), utf16Bytes("STRINGTABLE\r\nBEGIN\r\n  1 \"Grüße\"\r\nEND\r\n", binary.LittleEndian, true))
+	writeFile(t, filepath.Join(td, "legacy.txt"), []byte("na\xefve caf\xe9\n"))
+	writeFile(t, filepath.Join(td, "sjis.txt"), []byte("\x82\xb1\x82\xf1\n"))
+	writeFile(t, filepath.Join(td, "img.bin"), []byte{0x89, 0x50, 0x00, 0x01})
+
+	cfg := Config{RootDir: td, BinaryHandling: BinarySkip}
+	files, _, rep, err := WalkAndCollect(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("WalkAndCollect error: %v", err)
+	}
+	if rep.SkippedByReason[RuleBinary] != 1 || len(files) != 3 {
+		t.Fatalf("got %v, skipped %+v", relPaths(files), rep.Skipped)
+	}
+	byPath := map[string]FileEntry{}
+	for _, f := range files {
+		byPath[f.RelPath] = f
+	}
+	if f := byPath["res/app.rc"]; f.IsBinary || f.Encoding != EncodingUTF16LE || !strings.Contains(string(f.Content), "\"Grüße\"") {
+		t.Errorf("app.rc: %+v (%q)", f, f.Content)
+	}
+	if f := byPath["legacy.txt"]; f.Encoding != EncodingWindows1252 || string(f.Content) != "naïve café\n" {
+		t.Errorf("legacy.txt: %+v (%q)", f, f.Content)
+	}
+	if f := byPath["sjis.txt"]; f.Encoding != "" || string(f.Content) != "\x82\xb1\x82\xf1\n" {
+		t.Errorf("sjis.txt: %+v (%q)", f, f.Content)
+	}
+
+	for _, format := range []OutputFormat{FormatXML, FormatMD} {
+		pcfg := cfg
+		pcfg.OutputFormat, pcfg.Sections = format, Sections{Files: true}
+		out, _, err := Pack(context.Background(), pcfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if format == FormatXML && !strings.Contains(string(out), `<file path="res/app.rc" encoding="utf-16le">`) {
+			t.Fatalf("encoding not rendered:\n%s", out)
+		}
+		doc, err := Parse(out)
+		if err != nil {
+			t.Fatalf("%s: Parse: %v", format, err)
+		}
+		for _, f := range doc.Files {
+			want := ""
+			if transcoded(byPath[f.RelPath]) {
+				want = byPath[f.RelPath].Encoding
+			}
+			if f.Encoding != want {
+				t.Errorf("%s: %s parsed with encoding %q", format, f.RelPath, f.Encoding)
+			}
+		}
+	}
+
+	cfg.EncodingFallback = EncodingFallbackLatin1
+	files, _, _, err = WalkAndCollect(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("WalkAndCollect error: %v", err)
+	}
+	for _, f := range files {
+		if f.RelPath == "sjis.txt" && (f.Encoding != EncodingLatin1 || string(f.Content) != "\u0082±\u0082ñ\n") {
+			t.Errorf("sjis.txt with latin1 fallback: %+v (%q)", f, f.Content)
+		}
+	}
+}
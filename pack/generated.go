@@ -0,0 +1,30 @@
+package pack
+
+import (
+	"fmt"
+
+	"github.com/parsabordbar/ctx3/analyzer"
+)
+
+// originStep is the step of the rule chain for a generated or vendored
+// file.
+func originStep(o analyzer.Origin, policy GeneratedPolicy) Step {
+	s := Step{Rule: RuleGenerated, Effect: EffectExclude, Source: "--generated " + string(policy), Detail: o.Kind() + ": " + o.Reason()}
+	if policy == GeneratedStub {
+		s.Effect = EffectPass
+		s.Detail += ", packed as a stub"
+	}
+	return s
+}
+
+// stub is the placeholder packed for a generated or vendored file with
+// --generated stub.
+func stub(rel string, size int64, o analyzer.Origin) FileEntry {
+	content := fmt.Sprintf("… [%s file omitted, %s bytes: %s] …\n", o.Kind(), groupDigits(int(size)), o.Reason())
+	return FileEntry{RelPath: rel, Size: size, Content: []byte(content), Summary: o.Kind()}
+}
+
+// isStub reports whether e is the stub of a generated or vendored file.
+func isStub(e FileEntry) bool {
+	return e.Summary == "generated" || e.Summary == "vendored"
+}
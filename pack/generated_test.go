@@ -0,0 +1,130 @@
+package pack
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/parsabordbar/ctx3/cache"
+)
+
+func writeGeneratedTree(t *testing.T) string {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	td := t.TempDir()
+	writeFile(t, filepath.Join(td, "main.go"), []byte("package main\n"))
+	writeFile(t, filepath.Join(td, "api", "api.pb.go"), []byte("package api\n"))
+	writeFile(t, filepath.Join(td, "api", "types.go"), []byte("// Code generated by stringer. DO NOT EDIT.\n\npackage api\n"))
+	writeFile(t, filepath.Join(td, "static", "app.js"), bytes.Repeat([]byte("var a=function(b){return b+1};"), 200))
+	writeFile(t, filepath.Join(td, "third_party", "lib.c"), []byte("int x;\n"))
+	writeFile(t, filepath.Join(td, "schema", "openapi.json"), []byte("{}\n"))
+	writeFile(t, filepath.Join(td, "go.sum"), []byte("github.com/a/b v1.0.0 h1:abc=\n"))
+	writeFile(t, filepath.Join(td, ".gitattributes"), []byte("schema/*.json linguist-generated\nthird_party/** -linguist-vendored\n"))
+	return td
+}
+
+func TestWalk_GeneratedPolicies(t *testing.T) {
+	td := writeGeneratedTree(t)
+	generated := []string{"api/api.pb.go", "api/types.go", "schema/openapi.json", "static/app.js"}
+
+	files, _, rep, err := WalkAndCollect(context.Background(), Config{RootDir: td})
+	if err != nil {
+		t.Fatalf("WalkAndCollect error: %v", err)
+	}
+	if len(files) != 8 || len(rep.Stubbed) != 0 {
+		t.Fatalf("include: got %v, stubbed %v", relPaths(files), rep.Stubbed)
+	}
+
+	files, _, rep, err = WalkAndCollect(context.Background(), Config{RootDir: td, Generated: GeneratedSkip})
+	if err != nil {
+		t.Fatalf("WalkAndCollect error: %v", err)
+	}
+	for _, rel := range generated {
+		if contains(relPaths(files), rel) {
+			t.Errorf("skip: %s packed", rel)
+		}
+	}
+	for _, rel := range []string{"main.go", "third_party/lib.c", "go.sum"} {
+		if !contains(relPaths(files), rel) {
+			t.Errorf("skip: %s left out", rel)
+		}
+	}
+	if rep.SkippedByReason[RuleGenerated] != len(generated) {
+		t.Errorf("skip: report %+v", rep.Skipped)
+	}
+
+	files, tree, rep, err := WalkAndCollect(context.Background(), Config{RootDir: td, Generated: GeneratedStub})
+	if err != nil {
+		t.Fatalf("WalkAndCollect error: %v", err)
+	}
+	if strings.Join(rep.Stubbed, ",") != strings.Join(generated, ",") {
+		t.Fatalf("stub: stubbed %v", rep.Stubbed)
+	}
+	for _, f := range files {
+		if f.RelPath != "api/types.go" {
+			continue
+		}
+		want := "… [generated file omitted, 57 bytes: header // Code generated by stringer. DO NOT EDIT.] …\n"
+		if f.Summary != "generated" || string(f.Content) != want {
+			t.Fatalf("stub: got %+v (%q)", f, f.Content)
+		}
+	}
+	var buf bytes.Buffer
+	renderXMLStructure(&buf, tree, Config{})
+	if !strings.Contains(buf.String(), "api.pb.go (generated)") {
+		t.Fatalf("stub: tree not annotated:\n%s", buf.String())
+	}
+}
+
+func TestWalk_GeneratedDiskCache(t *testing.T) {
+	td := writeGeneratedTree(t)
+	store, err := cache.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, policy := range []GeneratedPolicy{GeneratedStub, GeneratedStub, GeneratedInclude} {
+		rc, err := NewDiskReadCache(store, td)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files, _, rep, err := WalkAndCollect(context.Background(), Config{RootDir: td, Generated: policy, Cache: rc})
+		if err != nil {
+			t.Fatalf("WalkAndCollect error: %v", err)
+		}
+		if stubbed := policy == GeneratedStub; (len(rep.Stubbed) == 4) != stubbed || len(files) != 8 {
+			t.Fatalf("run %d (%s): stubbed %v", i, policy, rep.Stubbed)
+		}
+		if i == 1 && rc.Reads() != 0 {
+			t.Fatalf("second run read %d files", rc.Reads())
+		}
+	}
+}
+
+func TestExplain_Generated(t *testing.T) {
+	td := writeGeneratedTree(t)
+	cfg := Config{RootDir: td, Generated: GeneratedSkip}
+	cases := map[string]string{
+		"api/api.pb.go":       "generated: path **/*.pb.go",
+		"api/types.go":        "generated: header // Code generated by stringer. DO NOT EDIT.",
+		"schema/openapi.json": "generated: .gitattributes:1 linguist-generated",
+	}
+	for rel, detail := range cases {
+		d, err := Explain(context.Background(), cfg, rel)
+		if err != nil {
+			t.Fatalf("Explain: %v", err)
+		}
+		if r := d.Reason(); d.Included || r.Rule != RuleGenerated || r.Detail != detail {
+			t.Errorf("%s: decision %+v", rel, d)
+		}
+	}
+
+	cfg.Generated = GeneratedStub
+	if d, _ := Explain(context.Background(), cfg, "static/app.js"); !d.Included || d.Reason().Rule != RuleGenerated {
+		t.Errorf("static/app.js: decision %+v", d)
+	}
+	if d, _ := Explain(context.Background(), cfg, "third_party/lib.c"); !d.Included || d.Reason().Rule == RuleGenerated {
+		t.Errorf("third_party/lib.c: decision %+v", d)
+	}
+}
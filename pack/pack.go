@@ -1,31 +1,58 @@
 package pack
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 )
 
+// writer is what the renderers write to: a bytes.Buffer or a bufio.Writer.
+type writer interface {
+	io.Writer
+	io.StringWriter
+	io.ByteWriter
+}
+
 // Pack walks the repository and renders the output into a single buffer.
-// Currently supports XML (sample-style). MD/TXT can be added later.
+// Supports XML (sample-style) and Markdown. TXT can be added later.
 func Pack(ctx context.Context, cfg Config) ([]byte, Report, error) {
-	files, tree, rep, err := WalkAndCollect(ctx, cfg)
+	var buf bytes.Buffer
+	rep, err := PackTo(ctx, cfg, &buf)
 	if err != nil {
 		return nil, rep, err
 	}
+	return buf.Bytes(), rep, nil
+}
 
-	var buf bytes.Buffer
+// PackTo is Pack writing the output to w as it is rendered. Nothing is
+// written when collecting the files fails.
+func PackTo(ctx context.Context, cfg Config, w io.Writer) (Report, error) {
+	if cfg.OutputFormat != FormatXML && cfg.OutputFormat != FormatMD {
+		return Report{}, fmt.Errorf("unsupported format: %s (only xml and md are implemented)", cfg.OutputFormat)
+	}
+	files, tree, rep, err := WalkAndCollect(ctx, cfg)
+	if err != nil {
+		return rep, err
+	}
+
+	bw := bufio.NewWriter(w)
 	switch cfg.OutputFormat {
 	case FormatXML:
 		if cfg.Sections.Structure {
-			renderXMLStructure(&buf, tree, cfg)
+			renderXMLStructure(bw, tree, cfg)
 		}
 		if cfg.Sections.Files {
-			renderXMLFiles(&buf, files, cfg)
+			renderXMLFiles(bw, files, cfg)
+		}
+	case FormatMD:
+		if cfg.Sections.Structure {
+			renderMDStructure(bw, tree, cfg)
+		}
+		if cfg.Sections.Files {
+			renderMDFiles(bw, files, cfg)
 		}
-	default:
-		return nil, rep, fmt.Errorf("unsupported format: %s (only xml is implemented)", cfg.OutputFormat)
 	}
-
-	return buf.Bytes(), rep, nil
+	return rep, bw.Flush()
 }
@@ -118,7 +118,7 @@ func TestPack_UnsupportedFormat(t *testing.T) {
 	mustWrite(t, filepath.Join(td, "f.txt"), []byte("z"))
 	cfg := Config{
 		RootDir:      td,
-		OutputFormat: FormatMD, // TODO: not implemented yet
+		OutputFormat: FormatTXT, // TODO: not implemented yet
 		Sections:     Sections{Structure: true, Files: true},
 	}
 	_, _, err := Pack(context.Background(), cfg)
@@ -0,0 +1,269 @@
+package pack
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Document is a pack read back from its rendered form.
+type Document struct {
+	Format OutputFormat
+	// Structure is the body of the directory structure section, "" when
+	// the pack has none.
+	Structure string
+	// Files are in the order the pack lists them. Content is as rendered:
+	// it ends with a newline unless the file is empty, and binary files hold
+	// their hex or base64 text. Size is the length of Content.
+	Files []FileEntry
+}
+
+// ErrNotAPack is returned by Parse for input that isn't an XML or Markdown
+// pack.
+var ErrNotAPack = errors.New("not a ctx3 pack")
+
+// Parse reads a pack written by Pack, in XML or Markdown, compact or not.
+// The format is detected from the first section heading.
+//
+// XML packs don't escape file content, so a file that itself contains a
+// line "</file>" followed by a "<file path=" line can't be told apart from
+// the end of its block; Parse ends the block there. Markdown fences are
+// always longer than any backtick run inside, so they are unambiguous.
+func Parse(data []byte) (Document, error) {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("<directory_structure>")), bytes.HasPrefix(trimmed, []byte("<files>")):
+		return parseXML(trimmed)
+	case bytes.HasPrefix(trimmed, []byte("# Directory Structure")), bytes.HasPrefix(trimmed, []byte("# Files")):
+		return parseMD(trimmed)
+	}
+	return Document{}, ErrNotAPack
+}
+
+func parseXML(data []byte) (Document, error) {
+	doc := Document{Format: FormatXML, Files: []FileEntry{}}
+	p := &lineReader{data: data}
+
+	if p.skipBlank(); p.consume("<directory_structure>") {
+		end := bytes.Index(data[p.pos:], []byte("</directory_structure>\n"))
+		if end < 0 {
+			return doc, fmt.Errorf("%w: unterminated <directory_structure>", ErrNotAPack)
+		}
+		doc.Structure = string(data[p.pos : p.pos+end])
+		p.pos += end + len("</directory_structure>\n")
+	}
+
+	if p.skipBlank(); !p.consume("<files>") {
+		if p.done() {
+			return doc, nil
+		}
+		return doc, fmt.Errorf("%w: expected <files> at line %d", ErrNotAPack, p.lineNo())
+	}
+	for {
+		if p.skipBlank(); p.done() {
+			return doc, fmt.Errorf("%w: unterminated <files>", ErrNotAPack)
+		}
+		if p.consume("</files>") {
+			return doc, nil
+		}
+		line := p.line()
+		if !strings.HasPrefix(line, `<file path="`) {
+			// the section's description sentence
+			continue
+		}
+		f, err := parseXMLFileTag(line)
+		if err != nil {
+			return doc, fmt.Errorf("%w: line %d: %v", ErrNotAPack, p.lineNo()-1, err)
+		}
+		end, next := xmlFileEnd(data, p.pos)
+		if end < 0 {
+			return doc, fmt.Errorf("%w: unterminated <file> for %s", ErrNotAPack, f.RelPath)
+		}
+		f.Content = renderedContent(data[p.pos:end])
+		f.Size = int64(len(f.Content))
+		doc.Files = append(doc.Files, f)
+		p.pos = next
+	}
+}
+
+// parseXMLFileTag parses `<file path="..." summary="..." truncated="..." encoding="...">`.
+func parseXMLFileTag(line string) (FileEntry, error) {
+	rest, ok := strings.CutSuffix(strings.TrimPrefix(line, `<file path="`), `">`)
+	if !ok {
+		return FileEntry{}, fmt.Errorf("malformed file tag %q", line)
+	}
+	var f FileEntry
+	f.RelPath, rest, _ = strings.Cut(rest, `"`)
+	for rest != "" {
+		name, value, ok := strings.Cut(strings.TrimPrefix(rest, " "), `="`)
+		if !ok {
+			return FileEntry{}, fmt.Errorf("malformed file tag %q", line)
+		}
+		value, rest, _ = strings.Cut(value, `"`)
+		switch name {
+		case "summary":
+			f.Summary = value
+		case "truncated":
+			f.Truncated = value
+		case "encoding":
+			f.Encoding = value
+		}
+	}
+	return f, nil
+}
+
+// xmlFileEnd finds the "</file>" line closing the block whose content
+// starts at from: the first one followed, after at most one blank line, by
+// another file, the end of the section or the end of data. It returns where
+// the content ends and where the next block starts.
+func xmlFileEnd(data []byte, from int) (end, next int) {
+	const closing = "</file>\n"
+	for i := from; ; {
+		j := bytes.Index(data[i:], []byte(closing))
+		if j < 0 {
+			return -1, -1
+		}
+		at := i + j
+		i = at + len(closing)
+		if at > from && data[at-1] != '\n' {
+			continue
+		}
+		rest := data[i:]
+		rest = bytes.TrimPrefix(rest, []byte("\n"))
+		if len(rest) == 0 || bytes.HasPrefix(rest, []byte(`<file path="`)) || bytes.HasPrefix(rest, []byte("</files>")) {
+			return at, i
+		}
+	}
+}
+
+func parseMD(data []byte) (Document, error) {
+	doc := Document{Format: FormatMD, Files: []FileEntry{}}
+	p := &lineReader{data: data}
+
+	if p.skipBlank(); p.consume("# Directory Structure") {
+		p.skipBlank()
+		fence := p.line()
+		if !strings.HasPrefix(fence, "```") {
+			return doc, fmt.Errorf("%w: expected a code fence at line %d", ErrNotAPack, p.lineNo()-1)
+		}
+		body, ok := p.untilLine(fence)
+		if !ok {
+			return doc, fmt.Errorf("%w: unterminated directory structure", ErrNotAPack)
+		}
+		doc.Structure = string(body)
+	}
+
+	if p.skipBlank(); !p.consume("# Files") {
+		if p.done() {
+			return doc, nil
+		}
+		return doc, fmt.Errorf("%w: expected # Files at line %d", ErrNotAPack, p.lineNo())
+	}
+	for {
+		if p.skipBlank(); p.done() {
+			return doc, nil
+		}
+		line := p.line()
+		rel, ok := strings.CutPrefix(line, "## File: ")
+		if !ok {
+			return doc, fmt.Errorf("%w: expected ## File: at line %d", ErrNotAPack, p.lineNo()-1)
+		}
+		f := FileEntry{RelPath: rel}
+		for p.skipBlank(); p.peekPrefix("_("); p.skipBlank() {
+			note := strings.TrimSuffix(strings.TrimPrefix(p.line(), "_("), ")_")
+			if t, ok := strings.CutPrefix(note, "truncated: "); ok {
+				f.Truncated = t
+			} else if e, ok := strings.CutPrefix(note, "encoding: "); ok {
+				f.Encoding = e
+			} else {
+				f.Summary = strings.TrimSuffix(note, " summary")
+			}
+		}
+		p.skipBlank()
+		open := p.line()
+		fence := open[:len(open)-len(strings.TrimLeft(open, "`"))]
+		if len(fence) < 3 {
+			return doc, fmt.Errorf("%w: expected a code fence for %s", ErrNotAPack, rel)
+		}
+		body, ok := p.untilLine(fence)
+		if !ok {
+			return doc, fmt.Errorf("%w: unterminated code fence for %s", ErrNotAPack, rel)
+		}
+		f.Content = body
+		if len(f.Content) == 0 {
+			f.Content = nil
+		}
+		f.Size = int64(len(f.Content))
+		doc.Files = append(doc.Files, f)
+	}
+}
+
+// renderedContent maps the content of an XML file block back: the
+// renderer writes a lone newline for an empty file.
+func renderedContent(b []byte) []byte {
+	if len(b) == 0 || (len(b) == 1 && b[0] == '\n') {
+		return nil
+	}
+	return b
+}
+
+// lineReader walks data line by line.
+type lineReader struct {
+	data []byte
+	pos  int
+}
+
+func (p *lineReader) done() bool { return p.pos >= len(p.data) }
+
+func (p *lineReader) lineNo() int { return bytes.Count(p.data[:p.pos], []byte("\n")) + 1 }
+
+// line returns the current line without its newline and moves past it.
+func (p *lineReader) line() string {
+	rest := p.data[p.pos:]
+	i := bytes.IndexByte(rest, '\n')
+	if i < 0 {
+		p.pos = len(p.data)
+		return strings.TrimSuffix(string(rest), "\r")
+	}
+	p.pos += i + 1
+	return strings.TrimSuffix(string(rest[:i]), "\r")
+}
+
+func (p *lineReader) skipBlank() {
+	for !p.done() {
+		rest := p.data[p.pos:]
+		i := bytes.IndexByte(rest, '\n')
+		if i < 0 || len(bytes.TrimSpace(rest[:i])) != 0 {
+			return
+		}
+		p.pos += i + 1
+	}
+}
+
+func (p *lineReader) peekPrefix(prefix string) bool {
+	return bytes.HasPrefix(p.data[p.pos:], []byte(prefix))
+}
+
+// consume moves past the current line if it is exactly s.
+func (p *lineReader) consume(s string) bool {
+	save := p.pos
+	if p.peekPrefix(s) && p.line() == s {
+		return true
+	}
+	p.pos = save
+	return false
+}
+
+// untilLine returns everything up to the next line that is exactly end and
+// moves past that line.
+func (p *lineReader) untilLine(end string) ([]byte, bool) {
+	start := p.pos
+	for !p.done() {
+		at := p.pos
+		if p.line() == end {
+			return p.data[start:at], true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,59 @@
+package pack
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestParse_RoundTrip(t *testing.T) {
+	td := t.TempDir()
+	mustWrite(t, filepath.Join(td, "main.go"), []byte("package main\n\nfunc main() {}\n"))
+	mustWrite(t, filepath.Join(td, "docs", "a.md"), []byte("```go\nx\n```\n</file>\nno newline"))
+	mustWrite(t, filepath.Join(td, "empty.txt"), nil)
+
+	for _, format := range []OutputFormat{FormatXML, FormatMD} {
+		for _, compact := range []bool{false, true} {
+			cfg := Config{
+				RootDir:      td,
+				OutputFormat: format,
+				Compact:      compact,
+				Sections:     Sections{Structure: true, Files: true},
+			}
+			out, _, err := Pack(context.Background(), cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc, err := Parse(out)
+			if err != nil {
+				t.Fatalf("%s compact=%v: %v\n%s", format, compact, err, out)
+			}
+			if doc.Format != format || doc.Structure == "" {
+				t.Errorf("%s compact=%v: format %q, structure %q", format, compact, doc.Format, doc.Structure)
+			}
+			want := map[string]string{
+				"docs/a.md": "```go\nx\n```\n</file>\nno newline\n",
+				"empty.txt": "",
+				"main.go":   "package main\n\nfunc main() {}\n",
+			}
+			if len(doc.Files) != len(want) {
+				t.Fatalf("%s compact=%v: got %d files, want %d", format, compact, len(doc.Files), len(want))
+			}
+			for _, f := range doc.Files {
+				if string(f.Content) != want[f.RelPath] {
+					t.Errorf("%s compact=%v: %s = %q, want %q", format, compact, f.RelPath, f.Content, want[f.RelPath])
+				}
+			}
+		}
+	}
+}
+
+func TestParse_NotAPack(t *testing.T) {
+	if _, err := Parse([]byte("hello\n")); !errors.Is(err, ErrNotAPack) {
+		t.Fatalf("got %v, want ErrNotAPack", err)
+	}
+	if _, err := Parse([]byte("<files>\n<file path=\"a\">\nx\n")); !errors.Is(err, ErrNotAPack) {
+		t.Fatalf("truncated pack: got %v, want ErrNotAPack", err)
+	}
+}
@@ -0,0 +1,98 @@
+package pack
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/parsabordbar/ctx3/analyzer"
+	"github.com/parsabordbar/ctx3/filetree"
+)
+
+// renderMDStructure writes the directory tree as a fenced block under a
+// "Directory Structure" heading, using the same layout as the XML section.
+func renderMDStructure(buf writer, tree *filetree.Node, cfg Config) {
+	var inner bytes.Buffer
+	renderXMLStructure(&inner, tree, Config{Compact: true})
+	body := strings.TrimPrefix(inner.String(), "<directory_structure>\n")
+	body = strings.TrimSuffix(body, "</directory_structure>\n")
+
+	buf.WriteString("# Directory Structure\n\n")
+	buf.WriteString("```\n")
+	buf.WriteString(body)
+	buf.WriteString("```\n")
+	if !cfg.Compact {
+		buf.WriteByte('\n')
+	}
+}
+
+// renderMDFiles writes one "## File:" heading per file with its content in
+// a code fence tagged with the language from analyzer's language table.
+func renderMDFiles(buf writer, files []FileEntry, cfg Config) {
+	buf.WriteString("# Files\n")
+	if !cfg.Compact {
+		buf.WriteByte('\n')
+	}
+
+	sorted := make([]FileEntry, len(files))
+	copy(sorted, files)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].RelPath < sorted[j].RelPath })
+
+	for _, f := range sorted {
+		fmt.Fprintf(buf, "## File: %s\n", f.RelPath)
+		if f.Summary != "" {
+			fmt.Fprintf(buf, "_(%s summary)_\n", f.Summary)
+		}
+		if f.Truncated != "" {
+			fmt.Fprintf(buf, "_(truncated: %s)_\n", f.Truncated)
+		}
+		if transcoded(f) {
+			fmt.Fprintf(buf, "_(encoding: %s)_\n", f.Encoding)
+		}
+		if !cfg.Compact {
+			buf.WriteByte('\n')
+		}
+
+		tag := ""
+		if !f.IsBinary && f.Summary == "" {
+			tag = analyzer.DetectLanguage(f.RelPath, headOf(f.Content, 256)).Fence
+		}
+		fence := fenceFor(f.Content)
+		buf.WriteString(fence)
+		buf.WriteString(tag)
+		buf.WriteByte('\n')
+		buf.Write(f.Content)
+		if len(f.Content) > 0 && f.Content[len(f.Content)-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(fence)
+		buf.WriteByte('\n')
+		if !cfg.Compact {
+			buf.WriteByte('\n')
+		}
+	}
+}
+
+// fenceFor returns a backtick fence longer than any run inside content.
+func fenceFor(content []byte) string {
+	longest, run := 0, 0
+	for _, b := range content {
+		if b == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	return strings.Repeat("`", maxInt(3, longest+1))
+}
+
+func headOf(b []byte, n int) []byte {
+	if len(b) > n {
+		return b[:n]
+	}
+	return b
+}
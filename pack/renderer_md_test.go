@@ -0,0 +1,46 @@
+package pack
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/parsabordbar/ctx3/filetree"
+)
+
+func TestRenderMD_FenceTagsFromLanguageTable(t *testing.T) {
+	files := []FileEntry{
+		{RelPath: "main.go", Content: []byte("package main\n")},
+		{RelPath: "deploy/values.yml", Content: []byte("a: 1\n")},
+		{RelPath: "Makefile", Content: []byte("all:\n")},
+		{RelPath: "scripts/run", Content: []byte("#!/usr/bin/env python3\nprint(1)\n")},
+		{RelPath: "README.md", Content: []byte("Use ```go blocks```\n")},
+	}
+	var buf bytes.Buffer
+	renderMDFiles(&buf, files, Config{})
+	out := buf.String()
+
+	for _, want := range []string{
+		"## File: main.go\n\n```go\npackage main\n```\n",
+		"## File: deploy/values.yml\n\n```yaml\n",
+		"## File: Makefile\n\n```makefile\n",
+		"## File: scripts/run\n\n```python\n",
+		"## File: README.md\n\n````markdown\nUse ```go blocks```\n````\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestRenderMD_Structure(t *testing.T) {
+	root := filetree.NewRoot()
+	root.Insert("go.mod", false, 0)
+	root.Insert("cmd/root.go", false, 0)
+	var buf bytes.Buffer
+	renderMDStructure(&buf, root, Config{})
+	want := "# Directory Structure\n\n```\ncmd/\n  root.go\ngo.mod\n```\n\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected structure:\n%q\nwant:\n%q", buf.String(), want)
+	}
+}
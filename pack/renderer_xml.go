@@ -1,10 +1,11 @@
 package pack
 
 import (
-	"bytes"
 	"fmt"
 	"sort"
 	"strings"
+
+	"github.com/parsabordbar/ctx3/filetree"
 )
 
 // renderXMLStructure writes:
@@ -15,24 +16,15 @@ import (
 //
 // ...
 // </directory_structure>
-func renderXMLStructure(buf *bytes.Buffer, tree *dirNode, cfg Config) {
+func renderXMLStructure(buf writer, tree *filetree.Node, cfg Config) {
 	buf.WriteString("<directory_structure>\n")
 	if tree != nil {
 		// render child directories first (sorted), then root files (sorted)
-		children := make([]*dirNode, len(tree.Children))
-		copy(children, tree.Children)
-		sort.Slice(children, func(i, j int) bool {
-			return base(children[i].Name) < base(children[j].Name)
-		})
-		for _, ch := range children {
+		for _, ch := range sortedByName(tree.Dirs()) {
 			renderDirNode(buf, ch, 0)
 		}
-
-		rootFiles := make([]string, len(tree.Files))
-		copy(rootFiles, tree.Files)
-		sort.Slice(rootFiles, func(i, j int) bool { return rootFiles[i] < rootFiles[j] })
-		for _, rf := range rootFiles {
-			buf.WriteString(base(rf))
+		for _, rf := range sortedByName(tree.Files()) {
+			buf.WriteString(annotated(rf))
 			buf.WriteByte('\n')
 		}
 	}
@@ -43,36 +35,53 @@ func renderXMLStructure(buf *bytes.Buffer, tree *dirNode, cfg Config) {
 	}
 }
 
-func renderDirNode(buf *bytes.Buffer, n *dirNode, depth int) {
+func renderDirNode(buf writer, n *filetree.Node, depth int) {
 	indent := strings.Repeat("  ", depth)
 	buf.WriteString(indent)
-	buf.WriteString(base(n.Name))
-	buf.WriteString("/\n")
+	buf.WriteString(n.Name)
+	buf.WriteString("/")
+	if n.Target != "" {
+		buf.WriteString(" -> " + n.Target)
+	}
+	buf.WriteByte('\n')
 
-	// files in this directory (basenames), sorted
-	files := make([]string, len(n.Files))
-	copy(files, n.Files)
-	sort.Slice(files, func(i, j int) bool { return files[i] < files[j] })
-	for _, f := range files {
+	// files in this directory, sorted
+	for _, f := range sortedByName(n.Files()) {
 		buf.WriteString(indent)
 		buf.WriteString("  ")
-		buf.WriteString(base(f))
+		buf.WriteString(annotated(f))
 		buf.WriteByte('\n')
 	}
 
-	// child directories (sorted by basename)
-	children := make([]*dirNode, len(n.Children))
-	copy(children, n.Children)
-	sort.Slice(children, func(i, j int) bool { return base(children[i].Name) < base(children[j].Name) })
-	for _, ch := range children {
+	// child directories, sorted
+	for _, ch := range sortedByName(n.Dirs()) {
 		renderDirNode(buf, ch, depth+1)
 	}
 }
 
+// annotated returns the name of a file with its link target and note,
+// e.g. "dump.sql (truncated)" or "config.yaml -> ../shared/config.yaml".
+func annotated(n *filetree.Node) string {
+	name := n.Name
+	if n.Target != "" {
+		name += " -> " + n.Target
+	}
+	if n.Note == "" {
+		return name
+	}
+	return name + " (" + n.Note + ")"
+}
+
+// sortedByName sorts nodes in place by name and returns them.
+func sortedByName(nodes []*filetree.Node) []*filetree.Node {
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	return nodes
+}
+
 // renderXMLFiles writes.
 // If cfg.Compact is true, it removes the extra blank lines between file blocks
 // and after the section header.
-func renderXMLFiles(buf *bytes.Buffer, files []FileEntry, cfg Config) {
+func renderXMLFiles(buf writer, files []FileEntry, cfg Config) {
 	buf.WriteString("<files>\n")
 	if cfg.Compact {
 		buf.WriteString("This section contains the contents of the repository's files.\n")
@@ -86,7 +95,17 @@ func renderXMLFiles(buf *bytes.Buffer, files []FileEntry, cfg Config) {
 	sort.Slice(sorted, func(i, j int) bool { return sorted[i].RelPath < sorted[j].RelPath })
 
 	for _, f := range sorted {
-		fmt.Fprintf(buf, "<file path=\"%s\">\n", f.RelPath)
+		fmt.Fprintf(buf, "<file path=\"%s\"", f.RelPath)
+		if f.Summary != "" {
+			fmt.Fprintf(buf, " summary=\"%s\"", f.Summary)
+		}
+		if f.Truncated != "" {
+			fmt.Fprintf(buf, " truncated=\"%s\"", f.Truncated)
+		}
+		if transcoded(f) {
+			fmt.Fprintf(buf, " encoding=\"%s\"", f.Encoding)
+		}
+		buf.WriteString(">\n")
 		if len(f.Content) > 0 {
 			buf.Write(f.Content)
 			// ensure exactly one trailing newline before </file>
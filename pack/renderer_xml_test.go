@@ -4,13 +4,15 @@ import (
 	"bytes"
 	"strings"
 	"testing"
+
+	"github.com/parsabordbar/ctx3/filetree"
 )
 
 func TestRenderXML_Structure_OrderAndRootFiles(t *testing.T) {
-	root := &dirNode{Name: ".", Children: nil, Files: []string{".gitignore"}}
-	an := &dirNode{Name: "analyzer", Files: []string{"analyzer/analyzer.go"}}
-	cmd := &dirNode{Name: "cmd", Files: []string{"cmd/root.go"}}
-	root.Children = []*dirNode{cmd, an} // unsorted to test sorting
+	root := filetree.NewRoot()
+	root.Insert("cmd/root.go", false, 0) // unsorted to test sorting
+	root.Insert(".gitignore", false, 0)
+	root.Insert("analyzer/analyzer.go", false, 0)
 
 	var buf bytes.Buffer
 	renderXMLStructure(&buf, root, Config{})
@@ -43,7 +45,8 @@ func TestRenderXML_Compact_RemovesExtraBlankLines(t *testing.T) {
 		{RelPath: "a.txt", Content: []byte("A\n")},
 		{RelPath: "b.txt", Content: []byte("B\n")},
 	}
-	root := &dirNode{Name: ".", Files: []string{"top.txt"}}
+	root := filetree.NewRoot()
+	root.Insert("top.txt", false, 0)
 	var sbuf bytes.Buffer
 	renderXMLStructure(&sbuf, root, Config{Compact: true})
 	structOut := sbuf.String()
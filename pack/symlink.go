@@ -0,0 +1,162 @@
+package pack
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/parsabordbar/ctx3/filetree"
+)
+
+// walkPath is a path met by walkRoot.
+type walkPath struct {
+	rel string      // '/'-separated, relative to the root, through the links followed
+	abs string      // where the path is read from
+	d   fs.DirEntry // for a followed link, that of what it points to
+	// target is what a symbolic link points to, as written in the link.
+	target string
+	// skip is RuleSymlink for a link that isn't followed and RuleSpecial
+	// for a device, named pipe or socket; detail says why.
+	skip   Rule
+	detail string
+}
+
+// walkRoot walks rootAbs like filepath.WalkDir, in lexical order, and
+// follows symbolic links as policy says: a followed link to a directory
+// is walked under the link's path, unless it leads back to a directory
+// above it. Special files are passed to fn with skip set, as they would
+// block a reader or never end.
+func walkRoot(rootAbs string, policy SymlinkPolicy, fn func(p walkPath, err error) error) error {
+	w := rootWalk{policy: policy, realRoot: rootAbs, fn: fn}
+	if real, err := filepath.EvalSymlinks(rootAbs); err == nil {
+		w.realRoot = real
+	}
+	return w.walk(rootAbs, "", nil)
+}
+
+type rootWalk struct {
+	policy   SymlinkPolicy
+	realRoot string
+	fn       func(walkPath, error) error
+}
+
+// walk walks dir, which is at rel ("" for the root). ancestors holds the
+// real directories of the links followed to get there.
+func (w *rootWalk) walk(dir, rel string, ancestors []string) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, werr error) error {
+		sub, _ := filepath.Rel(dir, p)
+		r := rel
+		if sub != "." {
+			r = strings.TrimPrefix(rel+"/"+filepath.ToSlash(sub), "/")
+		}
+		if werr != nil {
+			return w.fn(walkPath{rel: r, abs: p, d: d}, werr)
+		}
+		if sub == "." {
+			// the root, or a link's directory already passed to fn
+			return nil
+		}
+		switch t := d.Type(); {
+		case t&fs.ModeSymlink != 0:
+			return w.link(p, r, d, ancestors)
+		case !t.IsDir() && !t.IsRegular():
+			return w.fn(walkPath{rel: r, abs: p, d: d, skip: RuleSpecial, detail: specialKind(t)}, nil)
+		}
+		return w.fn(walkPath{rel: r, abs: p, d: d}, nil)
+	})
+}
+
+// link passes the link at p to fn, followed or not.
+func (w *rootWalk) link(p, rel string, d fs.DirEntry, ancestors []string) error {
+	target, _ := os.Readlink(p)
+	real, info, why := followLink(w.policy, w.realRoot, p)
+	if why == "" && info.IsDir() {
+		parent, err := filepath.EvalSymlinks(filepath.Dir(p))
+		if err != nil {
+			parent = filepath.Dir(p)
+		}
+		ancestors = append(ancestors[:len(ancestors):len(ancestors)], parent)
+		for _, a := range ancestors {
+			if filetree.Within(real, a) {
+				why = "loop"
+				break
+			}
+		}
+	}
+	switch why {
+	case "":
+	case "broken":
+		// reading it reports the error
+		return w.fn(walkPath{rel: rel, abs: p, d: d, target: target}, nil)
+	default:
+		return w.fn(walkPath{rel: rel, abs: p, d: d, target: target, skip: RuleSymlink, detail: why}, nil)
+	}
+
+	entry := linkedEntry{fs.FileInfoToDirEntry(info), d.Name()}
+	switch {
+	case info.Mode().IsRegular():
+		return w.fn(walkPath{rel: rel, abs: p, d: entry, target: target}, nil)
+	case !info.IsDir():
+		return w.fn(walkPath{rel: rel, abs: p, d: entry, target: target, skip: RuleSpecial, detail: specialKind(info.Mode())}, nil)
+	}
+	if err := w.fn(walkPath{rel: rel, abs: p, d: entry, target: target}, nil); err != nil {
+		if err == fs.SkipDir {
+			return nil
+		}
+		return err
+	}
+	return w.walk(real, rel, ancestors)
+}
+
+// followLink resolves the symbolic link at p when policy allows it, or
+// says why it doesn't: "not followed", "broken" or "outside the root".
+func followLink(policy SymlinkPolicy, realRoot, p string) (real string, info fs.FileInfo, why string) {
+	if policy == SymlinksSkip || policy == SymlinksRecord {
+		return "", nil, "not followed"
+	}
+	real, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return "", nil, "broken"
+	}
+	if policy != SymlinksFollow && !filetree.Within(realRoot, real) {
+		return "", nil, "outside the root"
+	}
+	if info, err = os.Stat(real); err != nil {
+		return "", nil, "broken"
+	}
+	return real, info, ""
+}
+
+// linkedEntry is what a followed link points to, under the link's name.
+type linkedEntry struct {
+	fs.DirEntry
+	name string
+}
+
+func (e linkedEntry) Name() string { return e.name }
+
+// specialKind names the kind of a file that is neither a directory nor a
+// regular file.
+func specialKind(m fs.FileMode) string {
+	switch {
+	case m&fs.ModeNamedPipe != 0:
+		return "named pipe"
+	case m&fs.ModeSocket != 0:
+		return "socket"
+	case m&fs.ModeCharDevice != 0:
+		return "character device"
+	case m&fs.ModeDevice != 0:
+		return "device"
+	}
+	return "irregular file"
+}
+
+// symlinkStep is the decision step of a link or special file walkRoot
+// leaves out.
+func symlinkStep(p walkPath, policy SymlinkPolicy) Step {
+	if p.skip == RuleSpecial {
+		return Step{Rule: RuleSpecial, Effect: EffectExclude, Source: "built-in", Detail: p.detail}
+	}
+	return Step{Rule: RuleSymlink, Effect: EffectExclude, Source: "--symlinks " + string(policy), Detail: "-> " + p.target + ": " + p.detail}
+}
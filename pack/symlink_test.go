@@ -0,0 +1,123 @@
+package pack
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeLinkTree makes a root with links inside it, out of it and back up
+// to it, next to a directory outside the root.
+func writeLinkTree(t *testing.T) string {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	outside := t.TempDir()
+	writeFile(t, filepath.Join(outside, "secret.txt"), []byte("secret\n"))
+	td := t.TempDir()
+	writeFile(t, filepath.Join(td, "a.txt"), []byte("a\n"))
+	writeFile(t, filepath.Join(td, "sub", "b.txt"), []byte("b\n"))
+	for link, target := range map[string]string{
+		"link.txt":   "a.txt",
+		"dirlink":    "sub",
+		"sub/up":     "..",
+		"secret.txt": filepath.Join(outside, "secret.txt"),
+		"elsewhere":  outside,
+	} {
+		if err := os.Symlink(target, filepath.Join(td, filepath.FromSlash(link))); err != nil {
+			t.Skipf("symlink: %v", err)
+		}
+	}
+	return td
+}
+
+func TestWalk_SymlinkPolicies(t *testing.T) {
+	td := writeLinkTree(t)
+	cases := []struct {
+		policy  SymlinkPolicy
+		files   string
+		skipped string // links left out
+	}{
+		{SymlinksSkip, "a.txt,sub/b.txt", "dirlink,elsewhere,link.txt,secret.txt,sub/up"},
+		{SymlinksRecord, "a.txt,sub/b.txt", "dirlink,elsewhere,link.txt,secret.txt,sub/up"},
+		{"", "a.txt,dirlink/b.txt,link.txt,sub/b.txt", "dirlink/up,elsewhere,secret.txt,sub/up"},
+		{SymlinksFollow, "a.txt,dirlink/b.txt,elsewhere/secret.txt,link.txt,secret.txt,sub/b.txt", "dirlink/up,sub/up"},
+	}
+	for _, c := range cases {
+		files, tree, rep, err := WalkAndCollect(context.Background(), Config{RootDir: td, Symlinks: c.policy})
+		if err != nil {
+			t.Fatalf("%s: WalkAndCollect error: %v", c.policy, err)
+		}
+		if got := strings.Join(relPaths(files), ","); got != c.files {
+			t.Errorf("%s: files %s, want %s", c.policy, got, c.files)
+		}
+		var skipped []string
+		for _, s := range rep.Skipped {
+			if s.Reason == RuleSymlink {
+				skipped = append(skipped, s.Path)
+			}
+		}
+		if got := strings.Join(skipped, ","); got != c.skipped {
+			t.Errorf("%s: skipped %s, want %s", c.policy, got, c.skipped)
+		}
+
+		var buf bytes.Buffer
+		renderXMLStructure(&buf, tree, Config{})
+		listed := strings.Contains(buf.String(), "link.txt -> a.txt")
+		if listed == (c.policy == SymlinksSkip) {
+			t.Errorf("%s: tree:\n%s", c.policy, buf.String())
+		}
+	}
+}
+
+func TestWalk_SymlinkLoop(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	td := t.TempDir()
+	writeFile(t, filepath.Join(td, "a", "x.txt"), []byte("x\n"))
+	writeFile(t, filepath.Join(td, "b", "y.txt"), []byte("y\n"))
+	// a/tob -> b and b/toa -> a: a/tob/toa is a again
+	if err := os.Symlink(filepath.Join("..", "b"), filepath.Join(td, "a", "tob")); err != nil {
+		t.Skipf("symlink: %v", err)
+	}
+	if err := os.Symlink(filepath.Join("..", "a"), filepath.Join(td, "b", "toa")); err != nil {
+		t.Fatal(err)
+	}
+	files, _, rep, err := WalkAndCollect(context.Background(), Config{RootDir: td, Symlinks: SymlinksFollow})
+	if err != nil {
+		t.Fatalf("WalkAndCollect error: %v", err)
+	}
+	if got := strings.Join(relPaths(files), ","); got != "a/tob/y.txt,a/x.txt,b/toa/x.txt,b/y.txt" {
+		t.Errorf("files %s", got)
+	}
+	if rep.SkippedByReason[RuleSymlink] != 2 {
+		t.Errorf("skipped %+v", rep.Skipped)
+	}
+}
+
+func TestExplain_Symlinks(t *testing.T) {
+	td := writeLinkTree(t)
+	d, err := Explain(context.Background(), Config{RootDir: td}, "secret.txt")
+	if err != nil {
+		t.Fatalf("Explain: %v", err)
+	}
+	if r := d.Reason(); d.Included || r.Rule != RuleSymlink || !strings.HasSuffix(r.Detail, "outside the root") {
+		t.Errorf("secret.txt: decision %+v", d)
+	}
+	d, err = Explain(context.Background(), Config{RootDir: td}, "link.txt")
+	if err != nil {
+		t.Fatalf("Explain: %v", err)
+	}
+	if !d.Included || d.Steps[0].Rule != RuleSymlink || d.Steps[0].Detail != "-> a.txt" {
+		t.Errorf("link.txt: decision %+v", d)
+	}
+
+	ds, err := DryRun(context.Background(), Config{RootDir: td, Symlinks: SymlinksRecord})
+	if err != nil {
+		t.Fatalf("DryRun: %v", err)
+	}
+	if r := decisionFor(t, ds, "dirlink").Reason(); r.Rule != RuleSymlink || r.Detail != "-> sub: not followed" {
+		t.Errorf("dirlink: %+v", r)
+	}
+}
@@ -0,0 +1,39 @@
+//go:build unix
+
+package pack
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestWalk_SpecialFiles(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	td := t.TempDir()
+	writeFile(t, filepath.Join(td, "a.txt"), []byte("a\n"))
+	// reading a pipe nobody writes to would block forever
+	if err := syscall.Mkfifo(filepath.Join(td, "pipe"), 0o644); err != nil {
+		t.Skipf("mkfifo: %v", err)
+	}
+	files, _, rep, err := WalkAndCollect(context.Background(), Config{RootDir: td, Symlinks: SymlinksFollow})
+	if err != nil {
+		t.Fatalf("WalkAndCollect error: %v", err)
+	}
+	if got := strings.Join(relPaths(files), ","); got != "a.txt" {
+		t.Errorf("files %s", got)
+	}
+	if len(rep.Skipped) != 1 || rep.Skipped[0].Reason != RuleSpecial {
+		t.Errorf("skipped %+v", rep.Skipped)
+	}
+
+	d, err := Explain(context.Background(), Config{RootDir: td}, "pipe")
+	if err != nil {
+		t.Fatalf("Explain: %v", err)
+	}
+	if r := d.Reason(); d.Included || r.Rule != RuleSpecial || r.Detail != "named pipe" {
+		t.Errorf("pipe: decision %+v", d)
+	}
+}
@@ -0,0 +1,354 @@
+package pack
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Values of FileEntry.Truncated.
+const (
+	TruncatedHeadTail = "head-tail"
+	TruncatedSample   = "sample"
+)
+
+// excerpt returns what is packed of a text file over cfg.MaxFileBytes and
+// how it was cut. Schema samples that don't parse, or that still don't
+// fit, fall back to head and tail.
+func excerpt(rel string, content []byte, cfg Config) ([]byte, string) {
+	head, tail, records := cfg.HeadLines, cfg.TailLines, cfg.SampleRecords
+	if head <= 0 {
+		head = DefaultHeadLines
+	}
+	if tail <= 0 {
+		tail = DefaultTailLines
+	}
+	if records <= 0 {
+		records = DefaultSampleRecords
+	}
+	if cfg.LargeFiles == LargeFilesSample {
+		if s, ok := sample(rel, content, records); ok {
+			if int64(len(s)) <= cfg.MaxFileBytes {
+				return s, TruncatedSample
+			}
+			return headTail(s, head, tail, cfg.MaxFileBytes), TruncatedSample
+		}
+	}
+	return headTail(content, head, tail, cfg.MaxFileBytes), TruncatedHeadTail
+}
+
+// headTail keeps the first head and last tail lines of content, within
+// budget bytes split evenly between them, around a marker saying how much
+// was left out. A line longer than its half of the budget is cut.
+func headTail(content []byte, head, tail int, budget int64) []byte {
+	lines := splitLines(content)
+	headBudget, tailBudget := budget/2, budget-budget/2
+
+	var top []string
+	used := int64(0)
+	for len(top) < head && len(top) < len(lines) {
+		l := lines[len(top)]
+		if used+int64(len(l)) > headBudget {
+			if len(top) == 0 {
+				top = append(top, cutEnd(l, headBudget))
+			}
+			break
+		}
+		top = append(top, l)
+		used += int64(len(l))
+	}
+
+	var bottom []string // reversed
+	used = 0
+	for len(bottom) < tail && len(lines)-len(bottom) > len(top) {
+		l := lines[len(lines)-1-len(bottom)]
+		if used+int64(len(l)) > tailBudget {
+			if len(bottom) == 0 {
+				bottom = append(bottom, cutStart(l, tailBudget))
+			}
+			break
+		}
+		bottom = append(bottom, l)
+		used += int64(len(l))
+	}
+
+	var out bytes.Buffer
+	for _, l := range top {
+		out.WriteString(l)
+	}
+	kept := out.Len()
+	for _, l := range bottom {
+		kept += len(l)
+	}
+	if omitted := len(lines) - len(top) - len(bottom); omitted > 0 {
+		fmt.Fprintf(&out, "… [truncated %s lines] …\n", groupDigits(omitted))
+	} else {
+		fmt.Fprintf(&out, "… [truncated %s bytes] …\n", groupDigits(max(len(content)-kept, 0)))
+	}
+	for i := len(bottom) - 1; i >= 0; i-- {
+		out.WriteString(bottom[i])
+	}
+	return out.Bytes()
+}
+
+// cutEnd keeps the start of line within n bytes, ending in "…".
+func cutEnd(line string, n int64) string {
+	n = max(n-int64(len("…\n")), 0)
+	i := int(min(n, int64(len(line))))
+	for i > 0 && !utf8.RuneStart(line[i]) {
+		i--
+	}
+	return line[:i] + "…\n"
+}
+
+// cutStart keeps the end of line within n bytes, starting with "…".
+func cutStart(line string, n int64) string {
+	n = max(n-int64(len("…")), 0)
+	i := len(line) - int(min(n, int64(len(line))))
+	for i < len(line) && !utf8.RuneStart(line[i]) {
+		i++
+	}
+	return "…" + line[i:]
+}
+
+// sample returns a schema sample of a JSON, JSON Lines, YAML or CSV file:
+// every key, or the header, and the first records of every list.
+func sample(rel string, content []byte, records int) ([]byte, bool) {
+	switch strings.ToLower(path.Ext(rel)) {
+	case ".json":
+		return sampleJSON(content, records)
+	case ".jsonl", ".ndjson":
+		return sampleLines(content, records), true
+	case ".yaml", ".yml":
+		return sampleYAML(content, records)
+	case ".csv":
+		return sampleCSV(content, records, ',')
+	case ".tsv":
+		return sampleCSV(content, records, '\t')
+	}
+	return nil, false
+}
+
+// recordsMarker is the marker standing in for n left-out records.
+func recordsMarker(n int) string {
+	return fmt.Sprintf("… [truncated %s records] …", groupDigits(n))
+}
+
+// sampleLines keeps the first records lines, one record each.
+func sampleLines(content []byte, records int) []byte {
+	lines := splitLines(content)
+	keep := min(records, len(lines))
+	var out bytes.Buffer
+	for _, l := range lines[:keep] {
+		out.WriteString(l)
+	}
+	if n := len(lines) - keep; n > 0 {
+		if out.Len() > 0 && !bytes.HasSuffix(out.Bytes(), []byte("\n")) {
+			out.WriteByte('\n')
+		}
+		out.WriteString(recordsMarker(n) + "\n")
+	}
+	return out.Bytes()
+}
+
+// sampleJSON re-indents a JSON document keeping every object key and the
+// first records elements of every array. The left-out elements are
+// replaced by a marker string, so the sample is still valid JSON.
+func sampleJSON(content []byte, records int) ([]byte, bool) {
+	s := &jsonSampler{dec: json.NewDecoder(bytes.NewReader(content)), records: records}
+	s.dec.UseNumber()
+	if err := s.value(""); err != nil {
+		return nil, false
+	}
+	if _, err := s.dec.Token(); err != io.EOF {
+		return nil, false // more than one value
+	}
+	s.out.WriteByte('\n')
+	return s.out.Bytes(), true
+}
+
+type jsonSampler struct {
+	dec     *json.Decoder
+	out     bytes.Buffer
+	records int
+}
+
+func (s *jsonSampler) value(indent string) error {
+	tok, err := s.dec.Token()
+	if err != nil {
+		return err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		b, err := json.Marshal(tok)
+		if err != nil {
+			return err
+		}
+		s.out.Write(b)
+		return nil
+	}
+	inner := indent + "  "
+	switch delim {
+	case '{':
+		s.out.WriteByte('{')
+		n := 0
+		for s.dec.More() {
+			key, err := s.dec.Token()
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				s.out.WriteByte(',')
+			}
+			k, _ := json.Marshal(key)
+			fmt.Fprintf(&s.out, "\n%s%s: ", inner, k)
+			if err := s.value(inner); err != nil {
+				return err
+			}
+			n++
+		}
+		if _, err := s.dec.Token(); err != nil {
+			return err
+		}
+		if n > 0 {
+			s.out.WriteString("\n" + indent)
+		}
+		s.out.WriteByte('}')
+	case '[':
+		s.out.WriteByte('[')
+		n, skipped := 0, 0
+		for s.dec.More() {
+			if n == s.records {
+				var raw json.RawMessage
+				if err := s.dec.Decode(&raw); err != nil {
+					return err
+				}
+				skipped++
+				continue
+			}
+			if n > 0 {
+				s.out.WriteByte(',')
+			}
+			s.out.WriteString("\n" + inner)
+			if err := s.value(inner); err != nil {
+				return err
+			}
+			n++
+		}
+		if _, err := s.dec.Token(); err != nil {
+			return err
+		}
+		if skipped > 0 {
+			m, _ := json.Marshal(recordsMarker(skipped))
+			fmt.Fprintf(&s.out, ",\n%s%s", inner, m)
+		}
+		if n > 0 {
+			s.out.WriteString("\n" + indent)
+		}
+		s.out.WriteByte(']')
+	default:
+		return errors.New("unexpected " + delim.String())
+	}
+	return nil
+}
+
+// sampleYAML keeps every mapping key and the first records items of every
+// sequence, with a marker item for the rest, and the first records
+// documents of a multi-document file.
+func sampleYAML(content []byte, records int) ([]byte, bool) {
+	dec := yaml.NewDecoder(bytes.NewReader(content))
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	docs := 0
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, false
+		}
+		if docs >= records {
+			docs++
+			continue
+		}
+		trimYAML(&doc, records)
+		if err := enc.Encode(&doc); err != nil {
+			return nil, false
+		}
+		docs++
+	}
+	if err := enc.Close(); err != nil {
+		return nil, false
+	}
+	if docs > records {
+		fmt.Fprintf(&out, "# … [truncated %s documents] …\n", groupDigits(docs-records))
+	}
+	return out.Bytes(), true
+}
+
+func trimYAML(n *yaml.Node, records int) {
+	if n.Kind == yaml.SequenceNode && len(n.Content) > records {
+		marker := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: recordsMarker(len(n.Content) - records)}
+		n.Content = append(n.Content[:records:records], marker)
+	}
+	for _, c := range n.Content {
+		trimYAML(c, records)
+	}
+}
+
+// sampleCSV keeps the header row and the first records rows.
+func sampleCSV(content []byte, records int, comma rune) ([]byte, bool) {
+	r := csv.NewReader(bytes.NewReader(content))
+	r.Comma = comma
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	var out bytes.Buffer
+	w := csv.NewWriter(&out)
+	w.Comma = comma
+	rows := 0
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, false
+		}
+		if rows <= records { // the header, then the records
+			w.Write(row)
+		}
+		rows++
+	}
+	w.Flush()
+	if w.Error() != nil || rows == 0 {
+		return nil, false
+	}
+	if n := rows - 1 - records; n > 0 {
+		out.WriteString(recordsMarker(n) + "\n")
+	}
+	return out.Bytes(), true
+}
+
+// groupDigits formats n with thousands separators: 12345 => "12,345".
+func groupDigits(n int) string {
+	s := strconv.Itoa(n)
+	if n < 0 {
+		return "-" + groupDigits(-n)
+	}
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
@@ -0,0 +1,120 @@
+package pack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func numberedLines(n int) []byte {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	return []byte(b.String())
+}
+
+func TestHeadTail(t *testing.T) {
+	got := string(headTail(numberedLines(20000), 2, 1, 1000))
+	want := "line 1\nline 2\n… [truncated 19,997 lines] …\nline 20000\n"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	// one long line is cut within the budget
+	long := []byte(strings.Repeat("é", 5000))
+	got = string(headTail(long, 10, 10, 200))
+	if len(got) > 200+len("… [truncated 9,999 bytes] …\n") || !strings.Contains(got, "… [truncated ") || !strings.HasPrefix(got, "éé") {
+		t.Fatalf("long line: %q", got)
+	}
+}
+
+func TestSample(t *testing.T) {
+	var records []map[string]any
+	for i := 0; i < 1000; i++ {
+		records = append(records, map[string]any{"id": i, "tags": []string{"a", "b", "c"}})
+	}
+	data, _ := json.Marshal(map[string]any{"version": 2, "records": records})
+	out, ok := sample("data.json", data, 2)
+	if !ok {
+		t.Fatal("json sample failed")
+	}
+	var v struct {
+		Version int   `json:"version"`
+		Records []any `json:"records"`
+	}
+	if err := json.Unmarshal(out, &v); err != nil {
+		t.Fatalf("sample is not valid JSON: %v\n%s", err, out)
+	}
+	if v.Version != 2 || len(v.Records) != 3 || v.Records[2] != "… [truncated 998 records] …" {
+		t.Fatalf("json sample:\n%s", out)
+	}
+
+	out, ok = sample("data.csv", []byte("id,name\n1,a\n2,b\n3,c\n4,d\n"), 2)
+	if !ok || string(out) != "id,name\n1,a\n2,b\n… [truncated 2 records] …\n" {
+		t.Fatalf("csv sample: %q", out)
+	}
+
+	out, ok = sample("data.yaml", []byte("name: x\nitems:\n  - 1\n  - 2\n  - 3\n"), 1)
+	var y struct {
+		Name  string   `yaml:"name"`
+		Items []string `yaml:"items"`
+	}
+	if !ok || yaml.Unmarshal(out, &y) != nil || y.Name != "x" || len(y.Items) != 2 || y.Items[1] != "… [truncated 2 records] …" {
+		t.Fatalf("yaml sample: %q", out)
+	}
+
+	if _, ok := sample("broken.json", []byte(`{"a": [1, 2`), 2); ok {
+		t.Fatal("broken JSON should not sample")
+	}
+}
+
+func TestWalk_LargeFiles(t *testing.T) {
+	td := t.TempDir()
+	writeFile(t, filepath.Join(td, "big.log"), numberedLines(5000))
+	writeFile(t, filepath.Join(td, "big.bin"), append([]byte{0}, make([]byte, 5000)...))
+	writeFile(t, filepath.Join(td, "small.txt"), []byte("small\n"))
+
+	cfg := Config{
+		RootDir:      td,
+		OutputFormat: FormatXML,
+		Sections:     Sections{Structure: true, Files: true},
+		MaxFileBytes: 1000,
+		LargeFiles:   LargeFilesTruncate,
+		HeadLines:    3,
+		TailLines:    2,
+	}
+	out, rep, err := Pack(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(out)
+	for _, want := range []string{
+		"big.log (truncated)\n",
+		"<file path=\"big.log\" truncated=\"head-tail\">\nline 1\nline 2\nline 3\n… [truncated 4,995 lines] …\nline 4999\nline 5000\n</file>",
+		"<file path=\"small.txt\">",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("pack lacks %q:\n%s", want, s)
+		}
+	}
+	if strings.Contains(s, `path="big.bin"`) {
+		t.Errorf("large binary file was packed")
+	}
+	if len(rep.Truncated) != 1 || rep.Truncated[0] != "big.log" || rep.SkippedByReason[RuleMaxFileBytes] != 1 {
+		t.Errorf("report: truncated %v, skipped %v", rep.Truncated, rep.Skipped)
+	}
+
+	doc, err := Parse(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Files) != 2 || doc.Files[0].RelPath != "big.log" || doc.Files[0].Truncated != TruncatedHeadTail {
+		t.Errorf("parsed back: %+v", doc.Files)
+	}
+}
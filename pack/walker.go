@@ -1,37 +1,30 @@
 package pack
 
 import (
-	"bytes"
 	"context"
 	"errors"
-	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
-	"unicode/utf8"
+	"sync"
 
-	doublestar "github.com/bmatcuk/doublestar/v4"
-	ignore "github.com/sabhiram/go-gitignore"
+	"github.com/parsabordbar/ctx3/analyzer"
+	"github.com/parsabordbar/ctx3/filetree"
 )
 
-type dirNode struct {
-	Name     string
-	Children []*dirNode
-	Files    []string // relative file paths under this dir
-}
-
 type walkResult struct {
-	rootTree *dirNode
+	rootTree *filetree.Node
 	files    []FileEntry
 	report   Report
 }
 
 // WalkAndCollect walks cfg.RootDir and returns files + a directory tree.
-// Precedence: hard excludes (.git/node_modules) → includes (if any) → ignores/.gitignore
-func WalkAndCollect(ctx context.Context, cfg Config) ([]FileEntry, *dirNode, Report, error) {
+// Precedence: hard excludes (.git/node_modules) and ignore rules → includes (if any) → ignores/.gitignore.
+// The chain is spelled out in filter (decision.go), which Explain and DryRun share.
+func WalkAndCollect(ctx context.Context, cfg Config) ([]FileEntry, *filetree.Node, Report, error) {
 	if cfg.RootDir == "" {
 		return nil, nil, Report{}, errors.New("empty RootDir")
 	}
@@ -39,197 +32,307 @@ func WalkAndCollect(ctx context.Context, cfg Config) ([]FileEntry, *dirNode, Rep
 	if err != nil {
 		return nil, nil, Report{}, err
 	}
-	// Load .gitignore at repo root
-	var gitIg *ignore.GitIgnore
-	if cfg.RespectGitignore {
-		if gi := loadGitignore(rootAbs); gi != nil {
-			gitIg = gi
-		}
+	flt, err := newFilter(cfg, rootAbs)
+	if err != nil {
+		return nil, nil, Report{}, err
 	}
+	cfg.Attributes = flt.cfg.Attributes // loaded once for every read
 
-	tree := &dirNode{Name: ".", Children: nil, Files: nil}
-	byDir := map[string]*dirNode{".": tree}
+	tree := filetree.NewRoot()
 	result := walkResult{rootTree: tree}
+	for _, p := range cfg.RedactPatterns {
+		if _, err := regexp.Compile(p); err != nil {
+			result.report.Warnings = append(result.report.Warnings, "invalid redact regex: "+p)
+		}
+	}
 
 	candidates := []string{}
-	err = filepath.WalkDir(rootAbs, func(p string, d fs.DirEntry, werr error) error {
-		if werr != nil {
-			result.report.Warnings = append(result.report.Warnings, werr.Error())
-			return nil
+	abs := map[string]string{} // where each candidate is read from
+	err = walkRoot(rootAbs, cfg.symlinks(), func(p walkPath, werr error) error {
+		if err := ctx.Err(); err != nil {
+			return err
 		}
-		rel, _ := filepath.Rel(rootAbs, p)
-		if rel == "." {
+		if werr != nil {
+			if cfg.OnError == OnErrorFail {
+				return werr
+			}
+			result.report.Errors = append(result.report.Errors, werr.Error())
 			return nil
 		}
-		rel = filepath.ToSlash(rel)
+		rel := p.rel
 
-		if d.IsDir() {
-			if isHardExcludedDir(rel) {
+		if p.d.IsDir() {
+			if !flt.dir(rel).Included {
 				return fs.SkipDir
 			}
-			ensureDirNode(byDir, rel)
+			if inSubdirs(rel, cfg.Subdirs, false) {
+				tree.Insert(rel, true, 0).Target = p.target
+			}
 			return nil
 		}
 
-		// includes (highest precedence)
-		included := true
-		if len(cfg.IncludeGlobs) > 0 {
-			included = anyGlobMatch(rel, cfg.IncludeGlobs)
-		}
-		if !included {
+		if p.skip != "" {
+			// links and special files are left out where the rules keep them
+			if flt.file(rel, 0).Included {
+				result.report.skip(rel, p.skip, 0)
+				if p.skip == RuleSymlink && cfg.symlinks() != SymlinksSkip {
+					tree.Insert(rel, false, 0).Target = p.target
+				}
+			}
 			return nil
 		}
 
-		// if not explicitly included, apply ignores
-		if len(cfg.IncludeGlobs) == 0 {
-			if gitIg != nil && gitIg.MatchesPath(rel) {
-				return nil
-			}
-			if anyGlobMatch(rel, cfg.IgnoreGlobs) {
-				return nil
-			}
+		var size int64
+		if info, ierr := os.Stat(p.abs); ierr == nil {
+			size = info.Size()
 		}
-
-		// per-file cap
-		if cfg.MaxFileBytes > 0 {
-			if info, ierr := os.Stat(p); ierr == nil && info.Size() > cfg.MaxFileBytes {
-				result.report.FilesSkipped++
+		if dec := flt.file(rel, size); !dec.Included {
+			// lockfiles are dropped after reading so they don't count against --max-total-bytes
+			if r := dec.Reason().Rule; r == RuleMaxFileBytes || r == RuleGenerated {
+				result.report.skip(rel, r, size)
+				return nil
+			} else if r != RuleLockfile {
 				return nil
 			}
 		}
 
 		candidates = append(candidates, rel)
-		parentDir := parent(rel)
-		node := ensureDirNode(byDir, parentDir)
-		node.Files = append(node.Files, rel)
+		abs[rel] = p.abs
+		tree.Insert(rel, false, size).Target = p.target
 		return nil
 	})
 	if err != nil {
 		return nil, nil, result.report, err
 	}
+	tree.Summarize()
 
 	// deterministic order
-	if cfg.SortByExt {
-		sort.Slice(candidates, func(i, j int) bool {
-			exti := strings.ToLower(filepath.Ext(candidates[i]))
-			extj := strings.ToLower(filepath.Ext(candidates[j]))
-			if exti == extj {
-				return candidates[i] < candidates[j]
-			}
-			return exti < extj
-		})
-	} else {
-		sort.Strings(candidates)
+	sortPackOrder(candidates, cfg.SortByExt)
+	cfg.Cache.retain(candidates)
+
+	// Read contents concurrently. With OnErrorFail the first read error
+	// cancels the reads that haven't started; either way every worker exits
+	// before out is closed, so nothing is left blocked.
+	readCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type readItem struct {
+		idx      int
+		rel, pth string
 	}
-
-	// Read contents with total cap + binary handling
-	concurrency := cfg.normalizedConcurrency()
-	type readItem struct{ rel, pth string }
 	items := make(chan readItem, len(candidates))
-	for _, rel := range candidates {
-		items <- readItem{rel: rel, pth: filepath.Join(rootAbs, filepath.FromSlash(rel))}
+	for i, rel := range candidates {
+		items <- readItem{idx: i, rel: rel, pth: abs[rel]}
 	}
 	close(items)
 
 	type outItem struct {
-		entry         FileEntry
-		err           error
-		skipped       bool
-		skippedReason string
-		size          int64
+		idx int
+		readResult
+		readErr error
 	}
 
 	out := make(chan outItem, len(candidates))
-	workers := minInt(concurrency, maxInt(1, len(candidates)))
+	var wg sync.WaitGroup
+	workers := minInt(cfg.normalizedConcurrency(), maxInt(1, len(candidates)))
 	for w := 0; w < workers; w++ {
+		wg.Add(1)
 		go func() {
+			defer wg.Done()
 			for it := range items {
-				select {
-				case <-ctx.Done():
-					out <- outItem{err: ctx.Err()}
+				if readCtx.Err() != nil {
 					return
-				default:
 				}
-				entry, size, skipped, reason, rerr := readOne(it.rel, it.pth, cfg)
-				out <- outItem{entry: entry, err: rerr, size: size, skipped: skipped, skippedReason: reason}
+				res, rerr := cfg.Cache.read(it.rel, it.pth, cfg)
+				out <- outItem{idx: it.idx, readResult: res, readErr: rerr}
 			}
 		}()
 	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	// results are gathered first so the total cap applies in pack order
+	results := make([]*outItem, len(candidates))
+	var errs []error
+	for oi := range out {
+		results[oi.idx] = &oi
+		if oi.readErr != nil {
+			errs = append(errs, oi.readErr)
+			if cfg.OnError == OnErrorFail {
+				cancel()
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, result.report, errors.Join(append(errs, err)...)
+	}
+	if cfg.OnError == OnErrorFail && len(errs) > 0 {
+		return nil, nil, result.report, errors.Join(errs...)
+	}
 
 	var picked []FileEntry
 	var total int64
-	var firstErr error
-	for i := 0; i < len(candidates); i++ {
-		oi := <-out
-		if oi.err != nil && firstErr == nil {
-			firstErr = oi.err
+	var totalTokens int
+	for _, oi := range results {
+		if oi.readErr != nil {
+			result.report.Errors = append(result.report.Errors, oi.readErr.Error())
 		}
-		if oi.skipped {
-			result.report.FilesSkipped++
-			if oi.skippedReason != "" {
-				result.report.Warnings = append(result.report.Warnings, oi.skippedReason)
-			}
+		if oi.skipped != "" {
+			result.report.skip(oi.entry.RelPath, oi.skipped, oi.entry.Size)
 			continue
 		}
 		if cfg.MaxTotalBytes > 0 && total+oi.size > cfg.MaxTotalBytes {
-			result.report.FilesSkipped++
-			result.report.Warnings = append(result.report.Warnings, "max total bytes exceeded; remaining files skipped")
+			result.report.skip(oi.entry.RelPath, RuleMaxTotalBytes, oi.entry.Size)
+			continue
+		}
+		if cfg.MaxTotalTokens > 0 && totalTokens+oi.tokens > cfg.MaxTotalTokens {
+			result.report.skip(oi.entry.RelPath, RuleMaxTotalTokens, oi.entry.Size)
 			continue
 		}
+		if oi.entry.Truncated != "" {
+			result.report.Truncated = append(result.report.Truncated, oi.entry.RelPath)
+			tree.Insert(oi.entry.RelPath, false, 0).Note = "truncated"
+		}
+		if isStub(oi.entry) {
+			result.report.Stubbed = append(result.report.Stubbed, oi.entry.RelPath)
+			tree.Insert(oi.entry.RelPath, false, 0).Note = oi.entry.Summary
+		}
 		picked = append(picked, oi.entry)
 		total += oi.size
+		totalTokens += oi.tokens
 	}
 
+	if err := cfg.Cache.save(); err != nil {
+		result.report.Warnings = append(result.report.Warnings, "cache: "+err.Error())
+	}
+	sort.Slice(result.report.Skipped, func(i, j int) bool {
+		return result.report.Skipped[i].Path < result.report.Skipped[j].Path
+	})
+	sort.Strings(result.report.Truncated)
+	sort.Strings(result.report.Stubbed)
 	result.files = picked
 	result.report.FilesIncluded = len(picked)
 	result.report.TotalBytes = total
-	return result.files, result.rootTree, result.report, firstErr
+	result.report.TotalTokens = totalTokens
+	return result.files, result.rootTree, result.report, nil
 }
 
-func readOne(rel, abs string, cfg Config) (FileEntry, int64, bool, string, error) {
+// readOne reads a candidate file. A non-empty Rule means the file is
+// skipped; the error says why when it could not be read.
+func readOne(rel, abs string, cfg Config) (FileEntry, int64, Rule, error) {
 	info, err := os.Stat(abs)
 	if err != nil {
-		return FileEntry{}, 0, true, "stat error: " + rel, nil
+		return FileEntry{RelPath: rel}, 0, RuleReadError, err
+	}
+	if !info.Mode().IsRegular() {
+		// replaced by a pipe or device since the walk
+		return FileEntry{RelPath: rel}, 0, RuleSpecial, nil
 	}
 	size := info.Size()
 
-	f, err := os.Open(abs)
+	// files left out for what their first bytes show are not read further
+	head, err := readHead(abs)
+	if err != nil {
+		return FileEntry{RelPath: rel, Size: size}, 0, RuleReadError, err
+	}
+	if entry, n, skipped, ok := skipByHead(rel, size, head, cfg); ok {
+		return entry, n, skipped, nil
+	}
+	all, err := os.ReadFile(abs)
 	if err != nil {
-		return FileEntry{}, 0, true, "open error: " + rel, nil
+		return FileEntry{RelPath: rel, Size: size}, 0, RuleReadError, err
 	}
-	defer f.Close()
+	entry, n, skipped := transform(rel, abs, size, all, cfg)
+	return entry, n, skipped, nil
+}
 
-	var sniffCap int64 = 8192
-	head := make([]byte, min64(size, sniffCap))
-	n, _ := io.ReadFull(f, head)
-	head = head[:n]
-	isBin := isBinary(head)
+// skipByHead leaves out binary files with --binary skip or over
+// --max-file-bytes, lockfiles with --lockfiles skip, and generated or
+// vendored files with --generated skip, judging by the first bytes of the
+// file. With --generated stub those are replaced by their stub.
+func skipByHead(rel string, size int64, head []byte, cfg Config) (FileEntry, int64, Rule, bool) {
+	enc, isBin := readEncoding(head, cfg)
+	if cfg.judgesOrigin(rel) {
+		text := head
+		if !isBin {
+			text = decodeText(head, enc)
+		}
+		if o := analyzer.DetectOrigin(rel, text, cfg.Attributes); o.Kind() != "" {
+			if cfg.Generated == GeneratedSkip {
+				return FileEntry{RelPath: rel, Size: size, IsBinary: isBin}, size, RuleGenerated, true
+			}
+			entry := stub(rel, size, o)
+			return entry, int64(len(entry.Content)), "", true
+		}
+	}
+	if _, ok := cfg.converter(rel, size); ok {
+		// judged by the text it converts to, in transform
+		return FileEntry{}, 0, "", false
+	}
+	return skipUnconverted(rel, size, isBin, cfg)
+}
+
+// skipUnconverted applies the size, binary and lockfile rules to a file
+// packed as it is stored.
+func skipUnconverted(rel string, size int64, isBin bool, cfg Config) (FileEntry, int64, Rule, bool) {
+	if cfg.MaxFileBytes > 0 && size > cfg.MaxFileBytes && (isBin || !cfg.excerpts()) {
+		// only text can be excerpted
+		return FileEntry{RelPath: rel, Size: size, IsBinary: isBin}, size, RuleMaxFileBytes, true
+	}
+	if isBin && cfg.BinaryHandling == BinarySkip {
+		return FileEntry{RelPath: rel, Size: size, IsBinary: true}, size, RuleBinary, true
+	}
+	if !isBin && cfg.Lockfiles == LockfilesSkip && analyzer.IsLockfile(filepath.Base(rel)) {
+		return FileEntry{RelPath: rel, Size: size}, size, RuleLockfile, true
+	}
+	return FileEntry{}, 0, "", false
+}
+
+// transform turns the content of a file into what is packed: converted
+// when a converter applies, encoded when binary, transcoded to UTF-8 when
+// text, summarized when a lockfile, excerpted when over --max-file-bytes,
+// redacted.
+func transform(rel, abs string, size int64, all []byte, cfg Config) (FileEntry, int64, Rule) {
+	head := all[:min(len(all), sniffLen)]
+	if entry, n, skipped, ok := skipByHead(rel, size, head, cfg); ok {
+		return entry, n, skipped
+	}
+	enc, isBin := readEncoding(head, cfg)
+	if conv, ok := cfg.converter(rel, size); ok {
+		if entry, n, skipped, ok := convert(rel, size, all, enc, isBin, conv, cfg); ok {
+			return entry, n, skipped
+		}
+		// a file the converter can't read is packed as it is
+		if entry, n, skipped, ok := skipUnconverted(rel, size, isBin, cfg); ok {
+			return entry, n, skipped
+		}
+	}
+	lockfile := !isBin && analyzer.IsLockfile(filepath.Base(rel))
 
 	var content []byte
+	var summary, truncated string
 	switch {
-	case isBin && cfg.BinaryHandling == BinarySkip:
-		return FileEntry{RelPath: rel, Size: size, IsBinary: true}, size, true, "binary skipped: " + rel, nil
 	case isBin && cfg.BinaryHandling == BinaryHex:
-		all, rerr := os.ReadFile(abs)
-		if rerr != nil {
-			return FileEntry{}, 0, true, "read error: " + rel, nil
-		}
-		dst := make([]byte, len(all)*2)
-		hexEncode(dst, all)
-		content = dst
+		content = make([]byte, len(all)*2)
+		hexEncode(content, all)
 	case isBin && cfg.BinaryHandling == BinaryBase64:
-		all, rerr := os.ReadFile(abs)
-		if rerr != nil {
-			return FileEntry{}, 0, true, "read error: " + rel, nil
-		}
 		content = make([]byte, base64EncodedLen(len(all)))
 		base64Encode(content, all)
 	default:
-		all, rerr := os.ReadFile(abs)
-		if rerr != nil {
-			return FileEntry{}, 0, true, "read error: " + rel, nil
+		text := decodeText(all, enc)
+		content = text
+		if lockfile && cfg.Lockfiles == LockfilesSummarize {
+			// fall back to the full content when the lockfile can't be parsed
+			if ls, serr := analyzer.SummarizeLockfile(abs, text); serr == nil {
+				content = ls.Render()
+				summary = "lockfile"
+			}
+		}
+		if summary == "" && cfg.excerpts() && size > cfg.MaxFileBytes {
+			content, truncated = excerpt(rel, text, cfg)
 		}
-		content = all
 	}
 
 	if len(cfg.RedactPatterns) > 0 && len(content) > 0 {
@@ -238,21 +341,41 @@ func readOne(rel, abs string, cfg Config) (FileEntry, int64, bool, string, error
 	}
 
 	return FileEntry{
-		RelPath:  rel,
-		Size:     size,
-		IsBinary: isBin,
-		Content:  content,
-	}, int64(len(content)), false, "", nil
+		RelPath:   rel,
+		Size:      size,
+		IsBinary:  isBin,
+		Content:   content,
+		Summary:   summary,
+		Truncated: truncated,
+		Encoding:  enc,
+	}, int64(len(content)), ""
 }
 
-func loadGitignore(rootAbs string) *ignore.GitIgnore {
-	p := filepath.Join(rootAbs, ".gitignore")
-	if _, err := os.Stat(p); err == nil {
-		if gi, err := ignore.CompileIgnoreFile(p); err == nil {
-			return gi
+// convert packs a file as the text conv extracts from it, which
+// --max-file-bytes then applies to. false means the conversion failed.
+func convert(rel string, size int64, all []byte, enc string, isBin bool, conv Converter, cfg Config) (FileEntry, int64, Rule, bool) {
+	in := all
+	if !isBin {
+		in = decodeText(all, enc)
+	} else {
+		enc = ""
+	}
+	out, err := conv.Convert(rel, in, cfg.convertOptions())
+	if err != nil {
+		return FileEntry{}, 0, "", false
+	}
+	entry := FileEntry{RelPath: rel, Size: size, Content: out, Summary: conv.Name(), Encoding: enc}
+	if cfg.MaxFileBytes > 0 && int64(len(out)) > cfg.MaxFileBytes {
+		if !cfg.excerpts() {
+			return FileEntry{RelPath: rel, Size: size, IsBinary: isBin}, size, RuleMaxFileBytes, true
 		}
+		// converted text has no format of its own to sample
+		entry.Content, entry.Truncated = excerpt("", out, cfg)
 	}
-	return nil
+	if len(cfg.RedactPatterns) > 0 {
+		entry.Content, _ = redactAll(entry.Content, cfg.RedactPatterns)
+	}
+	return entry, int64(len(entry.Content)), "", true
 }
 
 func isHardExcludedDir(rel string) bool {
@@ -261,58 +384,41 @@ func isHardExcludedDir(rel string) bool {
 	return last == ".git" || last == "node_modules"
 }
 
-func anyGlobMatch(rel string, globs []string) bool {
-	name := filepath.FromSlash(rel)
-	for _, g := range globs {
-		pat := filepath.FromSlash(g)
-		if ok, _ := doublestar.PathMatch(pat, name); ok {
+// inSubdirs reports whether rel lies inside one of subdirs. With
+// ancestors set, directories leading down to a subdir also count.
+func inSubdirs(rel string, subdirs []string, ancestors bool) bool {
+	if len(subdirs) == 0 {
+		return true
+	}
+	for _, sd := range subdirs {
+		sd = strings.Trim(filepath.ToSlash(filepath.Clean(sd)), "/")
+		if sd == "." || sd == "" || rel == sd || strings.HasPrefix(rel, sd+"/") {
+			return true
+		}
+		if ancestors && strings.HasPrefix(sd, rel+"/") {
 			return true
 		}
 	}
 	return false
 }
 
-func parent(rel string) string {
-	if i := strings.LastIndex(rel, "/"); i >= 0 {
-		return rel[:i]
-	}
-	return "."
-}
-
-func ensureDirNode(index map[string]*dirNode, rel string) *dirNode {
-	if rel == "" {
-		rel = "."
-	}
-	if n, ok := index[rel]; ok {
-		return n
-	}
-	par := parent(rel)
-	parentNode := ensureDirNode(index, par)
-	n := &dirNode{Name: rel, Children: nil, Files: nil}
-	parentNode.Children = append(parentNode.Children, n)
-	index[rel] = n
-	return n
-}
-
 // --- helpers ---
 
+// isBinary reports whether buf, the first bytes of a file, is binary
+// data rather than text in any encoding sniffEncoding knows.
 func isBinary(buf []byte) bool {
-	if len(buf) == 0 {
-		return false
-	}
-	if bytes.IndexByte(buf, 0x00) >= 0 {
-		return true
-	}
-	if utf8.Valid(buf) {
-		return false
-	}
-	nng := 0
-	for _, b := range buf {
-		if b < 0x09 || (b > 0x0D && b < 0x20) {
-			nng++
-		}
+	_, bin := sniffEncoding(buf)
+	return bin
+}
+
+// readEncoding is sniffEncoding with cfg.EncodingFallback applied to text
+// in an encoding it couldn't tell.
+func readEncoding(head []byte, cfg Config) (string, bool) {
+	enc, isBin := sniffEncoding(head)
+	if !isBin && enc == "" && cfg.EncodingFallback == EncodingFallbackLatin1 {
+		enc = EncodingLatin1
 	}
-	return float64(nng)/float64(len(buf)) > 0.3
+	return enc, isBin
 }
 
 func minInt(a, b int) int {
@@ -327,12 +433,6 @@ func maxInt(a, b int) int {
 	}
 	return b
 }
-func min64(a, b int64) int64 {
-	if a < b {
-		return a
-	}
-	return b
-}
 
 const hexdigits = "0123456789abcdef"
 
@@ -2,11 +2,17 @@ package pack
 
 import (
 	"context"
+	"errors"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/parsabordbar/ctx3/filetree"
 )
 
 func writeFile(t *testing.T, path string, data []byte) {
@@ -242,3 +248,162 @@ func isBase64String(s string) bool {
 	}
 	return n%4 == 0
 }
+
+func TestWalk_LockfileModes(t *testing.T) {
+	td := t.TempDir()
+	writeFile(t, filepath.Join(td, "go.mod"), []byte("module x\n\nrequire github.com/a/b v1.0.0\n"))
+	writeFile(t, filepath.Join(td, "go.sum"), []byte("github.com/a/b v1.0.0 h1:abc=\ngithub.com/a/b v1.0.0/go.mod h1:def=\n"))
+
+	files, _, _, err := WalkAndCollect(context.Background(), Config{RootDir: td, Lockfiles: LockfilesSummarize})
+	if err != nil {
+		t.Fatalf("WalkAndCollect error: %v", err)
+	}
+	var sum FileEntry
+	for _, f := range files {
+		if f.RelPath == "go.sum" {
+			sum = f
+		}
+	}
+	if sum.Summary != "lockfile" || !strings.Contains(string(sum.Content), "github.com/a/b") || strings.Contains(string(sum.Content), "h1:abc=") {
+		t.Fatalf("expected go.sum to be summarised, got %+v (%q)", sum, sum.Content)
+	}
+
+	files, _, rep, err := WalkAndCollect(context.Background(), Config{RootDir: td, Lockfiles: LockfilesSkip})
+	if err != nil {
+		t.Fatalf("WalkAndCollect error: %v", err)
+	}
+	if contains(relPaths(files), "go.sum") || rep.FilesSkipped != 1 {
+		t.Fatalf("expected go.sum skipped; got %v (skipped=%d)", relPaths(files), rep.FilesSkipped)
+	}
+}
+
+func TestWalk_SubdirsRestrictTree(t *testing.T) {
+	td := t.TempDir()
+	writeFile(t, filepath.Join(td, "apps", "api", "main.go"), []byte("package main\n"))
+	writeFile(t, filepath.Join(td, "apps", "web", "index.js"), []byte("//\n"))
+	writeFile(t, filepath.Join(td, "libs", "db", "db.go"), []byte("package db\n"))
+	writeFile(t, filepath.Join(td, "root.txt"), []byte("x\n"))
+
+	cfg := Config{RootDir: td, Subdirs: []string{"apps/api", "libs/db"}}
+	files, tree, _, err := WalkAndCollect(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("WalkAndCollect error: %v", err)
+	}
+	got := strings.Join(relPaths(files), ",")
+	if got != "apps/api/main.go,libs/db/db.go" {
+		t.Fatalf("unexpected files: %s", got)
+	}
+	if files := tree.Files(); len(files) != 0 {
+		t.Fatalf("expected no root files in tree, got %v", files)
+	}
+}
+
+func TestWalk_DefaultIgnoreRules(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	td := t.TempDir()
+	writeFile(t, filepath.Join(td, "src", "main.go"), []byte("package main\n"))
+	writeFile(t, filepath.Join(td, "dist", "bundle.js"), []byte("x\n"))
+	writeFile(t, filepath.Join(td, ".DS_Store"), []byte("x"))
+	writeFile(t, filepath.Join(td, "fixtures", "a.txt"), []byte("a\n"))
+	writeFile(t, filepath.Join(td, ".ctx3.yaml"), []byte("ignore:\n  dirs: [fixtures]\n"))
+
+	files, _, _, err := WalkAndCollect(context.Background(), Config{RootDir: td})
+	if err != nil {
+		t.Fatalf("WalkAndCollect error: %v", err)
+	}
+	if got := strings.Join(relPaths(files), ","); got != ".ctx3.yaml,src/main.go" {
+		t.Fatalf("unexpected files with default rules: %s", got)
+	}
+
+	files, _, _, err = WalkAndCollect(context.Background(), Config{RootDir: td, Ignores: &filetree.IgnoreRules{}})
+	if err != nil {
+		t.Fatalf("WalkAndCollect error: %v", err)
+	}
+	if got := strings.Join(relPaths(files), ","); got != ".DS_Store,.ctx3.yaml,dist/bundle.js,fixtures/a.txt,src/main.go" {
+		t.Fatalf("unexpected files without rules: %s", got)
+	}
+}
+
+func TestWalk_SkipRecords(t *testing.T) {
+	td := t.TempDir()
+	writeFile(t, filepath.Join(td, "big.txt"), bytesOfSize(10_000))
+	writeFile(t, filepath.Join(td, "img.bin"), []byte{0x00, 0x01, 0x02})
+	writeFile(t, filepath.Join(td, "small.txt"), []byte("hello\n"))
+
+	cfg := Config{
+		RootDir:        td,
+		BinaryHandling: BinarySkip,
+		MaxFileBytes:   5_000,
+		RedactPatterns: []string{"("},
+	}
+	_, _, rep, err := WalkAndCollect(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("WalkAndCollect error: %v", err)
+	}
+	want := []SkipRecord{
+		{Path: "big.txt", Reason: RuleMaxFileBytes, Size: 10_000},
+		{Path: "img.bin", Reason: RuleBinary, Size: 3},
+	}
+	if len(rep.Skipped) != len(want) {
+		t.Fatalf("Skipped = %+v, want %+v", rep.Skipped, want)
+	}
+	for i := range want {
+		if rep.Skipped[i] != want[i] {
+			t.Errorf("Skipped[%d] = %+v, want %+v", i, rep.Skipped[i], want[i])
+		}
+	}
+	if rep.FilesSkipped != 2 || rep.SkippedByReason[RuleBinary] != 1 || rep.SkippedByReason[RuleMaxFileBytes] != 1 {
+		t.Errorf("counters: skipped=%d by reason=%v", rep.FilesSkipped, rep.SkippedByReason)
+	}
+	if len(rep.Warnings) != 1 || !strings.Contains(rep.Warnings[0], "invalid redact regex") || len(rep.Errors) != 0 {
+		t.Errorf("warnings=%v errors=%v", rep.Warnings, rep.Errors)
+	}
+}
+
+func TestWalk_CancelledContext(t *testing.T) {
+	td := t.TempDir()
+	for i := 0; i < 50; i++ {
+		writeFile(t, filepath.Join(td, "f"+strconv.Itoa(i)+".txt"), []byte("x\n"))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, _, err := WalkAndCollect(ctx, Config{RootDir: td, Concurrency: 2})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("WalkAndCollect did not return after cancellation")
+	}
+}
+
+func TestWalk_OnErrorPolicy(t *testing.T) {
+	td := t.TempDir()
+	writeFile(t, filepath.Join(td, "a.txt"), []byte("a\n"))
+	// a dangling symlink passes the walk but fails to read
+	if err := os.Symlink(filepath.Join(td, "missing"), filepath.Join(td, "broken.txt")); err != nil {
+		t.Skipf("symlink: %v", err)
+	}
+
+	files, _, rep, err := WalkAndCollect(context.Background(), Config{RootDir: td, OnError: OnErrorSkip})
+	if err != nil {
+		t.Fatalf("skip policy returned error: %v", err)
+	}
+	if got := strings.Join(relPaths(files), ","); got != "a.txt" {
+		t.Errorf("files = %s", got)
+	}
+	if len(rep.Errors) != 1 || rep.SkippedByReason[RuleReadError] != 1 {
+		t.Errorf("errors=%v skipped=%v", rep.Errors, rep.Skipped)
+	}
+
+	_, _, _, err = WalkAndCollect(context.Background(), Config{RootDir: td, OnError: OnErrorFail})
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("fail policy: expected a not-exist error, got %v", err)
+	}
+}
@@ -0,0 +1,393 @@
+package serve
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/parsabordbar/ctx3/analyzer"
+	"github.com/parsabordbar/ctx3/filetree"
+	"github.com/parsabordbar/ctx3/functions"
+	"github.com/parsabordbar/ctx3/pack"
+)
+
+func (s *Server) handleRoots(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.roots)
+}
+
+var treeContentTypes = map[string]string{
+	filetree.FormatJSON:    "application/json",
+	filetree.FormatHTML:    "text/html; charset=utf-8",
+	filetree.FormatMD:      "text/markdown; charset=utf-8",
+	filetree.FormatText:    "text/plain; charset=utf-8",
+	filetree.FormatMermaid: "text/plain; charset=utf-8",
+	filetree.FormatDOT:     "text/vnd.graphviz; charset=utf-8",
+}
+
+// handleTree answers like `ctx3 print`, in JSON unless ?format= says
+// otherwise.
+func (s *Server) handleTree(w http.ResponseWriter, r *http.Request) {
+	q := newQuery(r)
+	root, dir, err := s.dir(q)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	format := q.str("format", filetree.FormatJSON)
+	contentType, ok := treeContentTypes[format]
+	if !ok {
+		q.fail(fmt.Errorf("invalid format: %s (expected text|json|md|mermaid|dot|html)", format))
+	}
+	showHidden := q.bool("show_hidden_defaults", false)
+	opts := filetree.Options{
+		IncludeGlobs:     q.list("include"),
+		IgnoreGlobs:      q.list("ignore"),
+		RespectGitignore: q.bool("respect_gitignore", true),
+	}
+	render := filetree.RenderOptions{
+		Depth:     q.int("depth", 0),
+		DirsFirst: q.bool("dirs_first", false),
+		Human:     q.bool("human", false),
+		DirsOnly:  q.bool("dirs_only", false),
+		FilesOnly: q.bool("files_only", false),
+		Collapse:  q.bool("collapse", false),
+	}
+	if render.FilesOnly && render.DirsOnly {
+		q.fail(fmt.Errorf("files_only and dirs_only are mutually exclusive"))
+	}
+	if render.FilesOnly && format != filetree.FormatText {
+		q.fail(fmt.Errorf("files_only only applies to format text"))
+	}
+	if q.err != nil {
+		writeError(w, q.err)
+		return
+	}
+
+	out, err := s.run(r, func(ctx context.Context) (any, error) {
+		rules, err := filetree.LoadIgnoreRules(root.Path, showHidden)
+		if err != nil {
+			return nil, err
+		}
+		opts.Rules = rules
+		tree, err := filetree.Build(ctx, dir, opts)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		err = filetree.Render(&buf, tree, format, render)
+		return buf.Bytes(), err
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(out.([]byte))
+}
+
+// handleContext answers with the JSON of `ctx3 context -j`.
+func (s *Server) handleContext(w http.ResponseWriter, r *http.Request) {
+	q := newQuery(r)
+	root, dir, err := s.dir(q)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	showHidden := q.bool("show_hidden_defaults", false)
+	respectGit := q.bool("respect_gitignore", false)
+	if q.err != nil {
+		writeError(w, q.err)
+		return
+	}
+	out, err := s.run(r, func(ctx context.Context) (any, error) {
+		rules, err := filetree.LoadIgnoreRules(root.Path, showHidden)
+		if err != nil {
+			return nil, err
+		}
+		return analyzer.AnalyzeProjectWithOptions(ctx, dir, analyzer.AnalyzeOptions{RespectGitignore: respectGit, Ignores: rules})
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, out)
+}
+
+// packConfig reads the parameters of `ctx3 pack`, flag names with
+// underscores (max_file_bytes, on_error, ...).
+func (s *Server) packConfig(q *query, root Root, dir string) pack.Config {
+	cfg := pack.Config{
+		RootDir:          dir,
+		RespectGitignore: q.bool("respect_gitignore", true),
+		IncludeGlobs:     q.list("include"),
+		IgnoreGlobs:      q.list("ignore"),
+		MaxFileBytes:     q.int64("max_file_bytes", 0),
+		MaxTotalBytes:    q.int64("max_total_bytes", 0),
+		MaxTotalTokens:   q.int("max_total_tokens", 0),
+		RedactPatterns:   q.list("redact"),
+		Compact:          q.bool("compact", false),
+	}
+	// a ReadCache is tied to one root: a subdirectory gets its own
+	if dir == root.Path {
+		cfg.Cache = s.readCaches[root.Name]
+	} else if s.cache != nil {
+		cfg.Cache, _ = pack.NewDiskReadCache(s.cache, dir)
+	}
+	var err error
+	if cfg.OutputFormat, err = pack.ParseOutputFormat(q.str("format", "xml")); q.check(err) && cfg.OutputFormat == pack.FormatTXT {
+		q.fail(fmt.Errorf("invalid format: txt is not implemented (expected xml|md)"))
+	}
+	cfg.BinaryHandling, err = pack.ParseBinaryStrategy(q.str("binary", "skip"))
+	q.check(err)
+	cfg.SortByExt, err = pack.ParseSort(q.str("sort", "paths"))
+	q.check(err)
+	cfg.Sections, err = pack.ParseSections(q.str("section", "all"))
+	q.check(err)
+	cfg.Lockfiles, err = pack.ParseLockfileMode(q.str("lockfiles", "full"))
+	q.check(err)
+	cfg.OnError, err = pack.ParseErrorPolicy(q.str("on_error", "skip"))
+	q.check(err)
+	cfg.LargeFiles, err = pack.ParseLargeFileMode(q.str("large_files", "skip"))
+	if q.check(err) && cfg.LargeFiles != pack.LargeFilesSkip && cfg.MaxFileBytes <= 0 {
+		q.fail(fmt.Errorf("invalid large_files: %s requires max_file_bytes", cfg.LargeFiles))
+	}
+	cfg.HeadLines = q.int("head_lines", pack.DefaultHeadLines)
+	cfg.TailLines = q.int("tail_lines", pack.DefaultTailLines)
+	cfg.SampleRecords = q.int("sample_records", pack.DefaultSampleRecords)
+	cfg.Generated, err = pack.ParseGeneratedPolicy(q.str("generated", "include"))
+	q.check(err)
+	cfg.EncodingFallback, err = pack.ParseEncodingFallback(q.str("encoding_fallback", "none"))
+	q.check(err)
+	if exts := q.list("convert"); exts != nil {
+		cfg.Convert, err = pack.ParseConvert(exts)
+		q.check(err)
+	}
+	cfg.NotebookOutputLines = q.int("notebook_output_lines", 0)
+	// links out of the root would expose what the server doesn't serve
+	if cfg.Symlinks, err = pack.ParseSymlinkPolicy(q.str("symlinks", "follow-within-root")); q.check(err) && cfg.Symlinks == pack.SymlinksFollow {
+		q.fail(fmt.Errorf("invalid symlinks: follow is not allowed (expected skip|record|follow-within-root)"))
+	}
+
+	// never more readers than the server allows
+	cfg.Concurrency = min(q.int("concurrency", 0), s.concurrency)
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = s.concurrency
+	}
+	if member := q.str("package", ""); member != "" && q.err == nil {
+		cfg.Subdirs, err = pack.PackageSubdirs(dir, member)
+		q.check(err)
+	}
+	if q.err == nil {
+		cfg.Ignores, err = filetree.LoadIgnoreRules(root.Path, q.bool("show_hidden_defaults", false))
+		if err != nil {
+			q.err = err
+		}
+	}
+	return cfg
+}
+
+var packContentTypes = map[pack.OutputFormat]string{
+	pack.FormatXML: "application/xml; charset=utf-8",
+	pack.FormatMD:  "text/markdown; charset=utf-8",
+}
+
+// Trailers sent after a streamed pack.
+const (
+	trailerIncluded = "X-Ctx3-Files-Included"
+	trailerSkipped  = "X-Ctx3-Files-Skipped"
+	trailerBytes    = "X-Ctx3-Total-Bytes"
+	trailerTokens   = "X-Ctx3-Total-Tokens"
+)
+
+// handlePack streams the packed document as it is rendered; the report
+// follows in trailers. With ?dry_run it answers with the decisions of
+// `ctx3 pack --dry-run` as JSON.
+func (s *Server) handlePack(w http.ResponseWriter, r *http.Request) {
+	q := newQuery(r)
+	root, dir, err := s.dir(q)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	dryRun := q.bool("dry_run", false)
+	cfg := s.packConfig(q, root, dir)
+	if q.err != nil {
+		writeError(w, q.err)
+		return
+	}
+	release, err := s.acquire(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer release()
+
+	if dryRun {
+		decisions, err := pack.DryRun(r.Context(), cfg)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if decisions == nil {
+			decisions = []pack.Decision{}
+		}
+		writeJSON(w, decisions)
+		return
+	}
+
+	w.Header().Set("Trailer", trailerIncluded+", "+trailerSkipped+", "+trailerBytes+", "+trailerTokens)
+	sw := &startWriter{w: w, contentType: packContentTypes[cfg.OutputFormat]}
+	report, err := pack.PackTo(r.Context(), cfg, sw)
+	if err != nil {
+		if !sw.started {
+			writeError(w, err)
+		}
+		// otherwise the client is gone or the stream broke mid-way; the
+		// missing trailers tell a client the output is incomplete
+		return
+	}
+	sw.start()
+	w.Header().Set(trailerIncluded, strconv.Itoa(report.FilesIncluded))
+	w.Header().Set(trailerSkipped, strconv.Itoa(report.FilesSkipped))
+	w.Header().Set(trailerBytes, strconv.FormatInt(report.TotalBytes, 10))
+	w.Header().Set(trailerTokens, strconv.Itoa(report.TotalTokens))
+}
+
+// startWriter sends the success headers on the first write, so an error
+// found before any output can still be answered with an error status.
+type startWriter struct {
+	w           http.ResponseWriter
+	contentType string
+	started     bool
+}
+
+func (sw *startWriter) start() {
+	if !sw.started {
+		sw.started = true
+		sw.w.Header().Set("Content-Type", sw.contentType)
+		sw.w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (sw *startWriter) Write(p []byte) (int, error) {
+	sw.start()
+	n, err := sw.w.Write(p)
+	if f, ok := sw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return n, err
+}
+
+type percentageOutput struct {
+	By     analyzer.ShareMetric `json:"by"`
+	Total  int64                `json:"total"`
+	Shares []analyzer.Share     `json:"shares"`
+}
+
+type historyOutput struct {
+	By     analyzer.ShareMetric    `json:"by"`
+	Points []analyzer.HistoryPoint `json:"points"`
+}
+
+// handlePercentage answers with the JSON of `ctx3 percentage -j`, or of
+// `--history` with ?history.
+func (s *Server) handlePercentage(w http.ResponseWriter, r *http.Request) {
+	q := newQuery(r)
+	root, dir, err := s.dir(q)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	by, err := analyzer.ParseShareMetric(q.str("by", "bytes"))
+	q.check(err)
+	share := analyzer.ShareOptions{
+		By:               by,
+		Top:              q.int("top", 0),
+		IncludeVendored:  q.bool("include_vendored", false),
+		IncludeGenerated: q.bool("include_generated", false),
+	}
+	showHidden := q.bool("show_hidden_defaults", false)
+	respectGit := q.bool("respect_gitignore", true)
+	history := q.bool("history", false)
+	every, err := analyzer.ParseEvery(q.str("every", "1w"))
+	q.check(err)
+	rev := q.str("rev", "HEAD")
+	if strings.HasPrefix(rev, "-") {
+		// git would read it as an option
+		q.fail(fmt.Errorf("invalid rev: %s", rev))
+	}
+	limit := q.int("limit", 0)
+	if q.err != nil {
+		writeError(w, q.err)
+		return
+	}
+
+	out, err := s.run(r, func(ctx context.Context) (any, error) {
+		rules, err := filetree.LoadIgnoreRules(root.Path, showHidden)
+		if err != nil {
+			return nil, err
+		}
+		if history {
+			points, err := analyzer.LanguageHistory(ctx, dir, analyzer.HistoryOptions{
+				Rev: rev, Every: every, Limit: limit, Share: share, Ignores: rules,
+			})
+			if err != nil {
+				return nil, badRequest(err)
+			}
+			return historyOutput{By: by, Points: points}, nil
+		}
+		pc, err := analyzer.AnalyzeProjectWithOptions(ctx, dir, analyzer.AnalyzeOptions{RespectGitignore: respectGit, Ignores: rules})
+		if err != nil {
+			return nil, err
+		}
+		res := percentageOutput{By: by, Shares: analyzer.LanguageShares(pc.Files, share)}
+		for _, sh := range res.Shares {
+			res.Total += sh.Value
+		}
+		return res, nil
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, out)
+}
+
+// handleFunctions lists functions below ?path=, optionally those whose
+// name contains ?name= (case-sensitive, as in the mcp list_functions tool).
+func (s *Server) handleFunctions(w http.ResponseWriter, r *http.Request) {
+	q := newQuery(r)
+	root, dir, err := s.dir(q)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	name := q.str("name", "")
+	showHidden := q.bool("show_hidden_defaults", false)
+	if q.err != nil {
+		writeError(w, q.err)
+		return
+	}
+	out, err := s.run(r, func(ctx context.Context) (any, error) {
+		rules, err := filetree.LoadIgnoreRules(root.Path, showHidden)
+		if err != nil {
+			return nil, err
+		}
+		// analyze from the root so paths read the same whatever ?path= is
+		rel, _ := filepath.Rel(root.Path, dir)
+		fc, err := functions.AnalyzeFunctionsWithOptions(ctx, root.Path, functions.Options{Rules: rules, Cache: s.cache})
+		if err != nil {
+			return nil, err
+		}
+		return fc.Filter(filepath.ToSlash(rel), name), nil
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, out)
+}
@@ -0,0 +1,286 @@
+// Package serve exposes ctx3 over a local HTTP API: GET endpoints taking
+// the same parameters as the CLI commands and answering in JSON, except
+// /pack, which streams the packed document.
+package serve
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/parsabordbar/ctx3/cache"
+	"github.com/parsabordbar/ctx3/filetree"
+	"github.com/parsabordbar/ctx3/pack"
+)
+
+// Root is a directory requests may read. Requests pick one by Name.
+type Root struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+type Options struct {
+	// Roots is the allowlist of directories that can be served. A request
+	// names one with ?root= (default: the first) and can't leave it.
+	Roots []string
+	// Concurrency caps the file reads of one /pack request (0 = number of
+	// CPUs). A request may ask for fewer with ?concurrency=.
+	Concurrency int
+	// MaxInFlight caps the requests doing work at the same time (0 = number
+	// of CPUs). Others wait for a slot or for their client to give up.
+	MaxInFlight int
+	// Cache, when set, keeps packed files and function lists across
+	// requests and runs.
+	Cache *cache.Store
+}
+
+type Server struct {
+	roots       []Root
+	cache       *cache.Store
+	readCaches  map[string]*pack.ReadCache // by root name
+	concurrency int
+	slots       chan struct{}
+	mux         *http.ServeMux
+}
+
+// New checks the roots and returns a server for them.
+func New(opts Options) (*Server, error) {
+	if len(opts.Roots) == 0 {
+		return nil, errors.New("no roots to serve")
+	}
+	s := &Server{concurrency: opts.Concurrency, cache: opts.Cache, readCaches: map[string]*pack.ReadCache{}}
+	if s.concurrency <= 0 {
+		s.concurrency = runtime.NumCPU()
+	}
+	inFlight := opts.MaxInFlight
+	if inFlight <= 0 {
+		inFlight = runtime.NumCPU()
+	}
+	s.slots = make(chan struct{}, inFlight)
+
+	used := map[string]int{}
+	for _, r := range opts.Roots {
+		abs, err := filepath.Abs(r)
+		if err != nil {
+			return nil, err
+		}
+		if abs, err = filepath.EvalSymlinks(abs); err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(abs)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("%s is not a directory", r)
+		}
+		name := filepath.Base(abs)
+		if used[name]++; used[name] > 1 {
+			name = fmt.Sprintf("%s-%d", name, used[name])
+		}
+		s.roots = append(s.roots, Root{Name: name, Path: abs})
+		if opts.Cache != nil {
+			if s.readCaches[name], err = pack.NewDiskReadCache(opts.Cache, abs); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc("GET /roots", s.handleRoots)
+	s.mux.HandleFunc("GET /tree", s.handleTree)
+	s.mux.HandleFunc("GET /context", s.handleContext)
+	s.mux.HandleFunc("GET /pack", s.handlePack)
+	s.mux.HandleFunc("GET /percentage", s.handlePercentage)
+	s.mux.HandleFunc("GET /functions", s.handleFunctions)
+	return s, nil
+}
+
+// Roots returns the served roots with their request names.
+func (s *Server) Roots() []Root { return s.roots }
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// httpError carries the status an error is answered with.
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e *httpError) Error() string { return e.err.Error() }
+func (e *httpError) Unwrap() error { return e.err }
+
+func badRequest(err error) error { return &httpError{http.StatusBadRequest, err} }
+
+// writeError answers with {"error": "..."} and a status derived from err.
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var he *httpError
+	switch {
+	case errors.As(err, &he):
+		status = he.status
+	case errors.Is(err, filetree.ErrOutsideRoot):
+		status = http.StatusForbidden
+	case errors.Is(err, fs.ErrNotExist):
+		status = http.StatusNotFound
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// dir resolves the ?root= and ?path= of a request to a directory inside
+// an allowed root. It returns the root too, for loading ignore rules.
+func (s *Server) dir(q *query) (Root, string, error) {
+	name := q.str("root", "")
+	root := s.roots[0]
+	if name != "" {
+		found := false
+		for _, r := range s.roots {
+			if r.Name == name {
+				root, found = r, true
+				break
+			}
+		}
+		if !found {
+			return Root{}, "", &httpError{http.StatusNotFound, fmt.Errorf("unknown root: %s", name)}
+		}
+	}
+	abs, _, err := filetree.Resolve(root.Path, q.str("path", "."))
+	if err != nil {
+		return Root{}, "", err
+	}
+	return root, abs, nil
+}
+
+// acquire waits for a work slot; the returned func releases it. It fails
+// when the client goes away first.
+func (s *Server) acquire(r *http.Request) (func(), error) {
+	select {
+	case s.slots <- struct{}{}:
+		return func() { <-s.slots }, nil
+	case <-r.Context().Done():
+		return nil, r.Context().Err()
+	}
+}
+
+// run calls fn with a work slot held and the request's context, and
+// returns its result, or returns early when the request is cancelled. fn
+// keeps its slot until it returns, so it must stop soon after ctx is
+// done.
+func (s *Server) run(r *http.Request, fn func(ctx context.Context) (any, error)) (any, error) {
+	release, err := s.acquire(r)
+	if err != nil {
+		return nil, err
+	}
+	type result struct {
+		v   any
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer release()
+		v, err := fn(r.Context())
+		done <- result{v, err}
+	}()
+	select {
+	case res := <-done:
+		return res.v, res.err
+	case <-r.Context().Done():
+		return nil, r.Context().Err()
+	}
+}
+
+// query reads typed parameters, keeping the first malformed one.
+type query struct {
+	v   map[string][]string
+	err error
+}
+
+func newQuery(r *http.Request) *query { return &query{v: r.URL.Query()} }
+
+func (q *query) str(name, def string) string {
+	if vs, ok := q.v[name]; ok && len(vs) > 0 {
+		return vs[0]
+	}
+	return def
+}
+
+func (q *query) int(name string, def int) int {
+	return int(q.int64(name, int64(def)))
+}
+
+func (q *query) int64(name string, def int64) int64 {
+	s := q.str(name, "")
+	if s == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		q.fail(fmt.Errorf("invalid %s: %s (expected an integer >= 0)", name, s))
+		return def
+	}
+	return n
+}
+
+func (q *query) bool(name string, def bool) bool {
+	vs, ok := q.v[name]
+	if !ok {
+		return def
+	}
+	if len(vs) == 0 || vs[0] == "" {
+		return true // ?flag alone
+	}
+	b, err := strconv.ParseBool(vs[0])
+	if err != nil {
+		q.fail(fmt.Errorf("invalid %s: %s (expected true|false)", name, vs[0]))
+		return def
+	}
+	return b
+}
+
+// list accepts repeated and comma-separated values, like the CLI's slice
+// flags.
+func (q *query) list(name string) []string {
+	var out []string
+	for _, v := range q.v[name] {
+		for _, piece := range strings.Split(v, ",") {
+			if p := strings.TrimSpace(piece); p != "" {
+				out = append(out, p)
+			}
+		}
+	}
+	return out
+}
+
+func (q *query) fail(err error) {
+	if q.err == nil {
+		q.err = badRequest(err)
+	}
+}
+
+// check records err as a bad parameter and reports whether it was nil.
+func (q *query) check(err error) bool {
+	if err != nil {
+		q.fail(err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,310 @@
+package serve
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTestFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
+
+func testProject(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "go.mod"), "module example.com/demo\n\ngo 1.22\n")
+	writeTestFile(t, filepath.Join(root, "main.go"), "package main\n\nfunc main() {}\n")
+	writeTestFile(t, filepath.Join(root, "greet", "greet.go"), "package greet\n\nfunc Hello(name string) string {\n\treturn \"hello \" + name\n}\n")
+	writeTestFile(t, filepath.Join(root, "README.md"), "# demo\n")
+	return root
+}
+
+func newTestServer(t *testing.T, roots ...string) *httptest.Server {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	s, err := New(Options{Roots: roots, Concurrency: 2, MaxInFlight: 2})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func get(t *testing.T, ts *httptest.Server, url string) (*http.Response, string) {
+	t.Helper()
+	resp, err := http.Get(ts.URL + url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	return resp, string(body)
+}
+
+func TestServer_Roots(t *testing.T) {
+	a, b := testProject(t), testProject(t)
+	if _, err := New(Options{Roots: []string{filepath.Join(a, "main.go")}}); err == nil {
+		t.Errorf("expected an error for a file root")
+	}
+	ts := newTestServer(t, a, b)
+
+	_, body := get(t, ts, "/roots")
+	var roots []Root
+	if err := json.Unmarshal([]byte(body), &roots); err != nil {
+		t.Fatalf("%v\n%s", err, body)
+	}
+	if len(roots) != 2 || roots[0].Name == roots[1].Name {
+		t.Errorf("roots = %+v", roots)
+	}
+
+	if resp, body := get(t, ts, "/tree?root="+roots[1].Name+"&path=greet"); resp.StatusCode != http.StatusOK || !strings.Contains(body, "greet.go") {
+		t.Errorf("tree of second root: %d\n%s", resp.StatusCode, body)
+	}
+	if resp, _ := get(t, ts, "/tree?root=nope"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown root: status %d", resp.StatusCode)
+	}
+}
+
+func TestServer_PathsStayInRoot(t *testing.T) {
+	root := testProject(t)
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	ts := newTestServer(t, root)
+
+	for _, path := range []string{"..", "../../etc", outside, "escape", "greet/../../x"} {
+		for _, ep := range []string{"/tree", "/context", "/pack", "/functions", "/percentage"} {
+			if resp, body := get(t, ts, ep+"?path="+path); resp.StatusCode != http.StatusForbidden {
+				t.Errorf("%s?path=%s: status %d\n%s", ep, path, resp.StatusCode, body)
+			}
+		}
+	}
+	if resp, _ := get(t, ts, "/tree?path=missing"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("missing path: status %d", resp.StatusCode)
+	}
+}
+
+func TestServer_BadParams(t *testing.T) {
+	ts := newTestServer(t, testProject(t))
+	for _, url := range []string{
+		"/tree?depth=-1",
+		"/tree?format=svg",
+		"/tree?files_only&dirs_only",
+		"/pack?format=txt",
+		"/pack?max_file_bytes=lots",
+		"/pack?binary=nope",
+		"/pack?compact=maybe",
+		"/pack?symlinks=follow",
+		"/percentage?by=weight",
+	} {
+		resp, body := get(t, ts, url)
+		var e struct{ Error string }
+		if resp.StatusCode != http.StatusBadRequest || json.Unmarshal([]byte(body), &e) != nil || e.Error == "" {
+			t.Errorf("%s: status %d\n%s", url, resp.StatusCode, body)
+		}
+	}
+}
+
+func TestServer_Pack(t *testing.T) {
+	ts := newTestServer(t, testProject(t))
+
+	resp, body := get(t, ts, "/pack?include=**/*.go")
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/xml") {
+		t.Fatalf("status %d, content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	if !strings.Contains(body, `<file path="greet/greet.go">`) || strings.Contains(body, "README.md") {
+		t.Errorf("pack:\n%s", body)
+	}
+	if got := resp.Trailer.Get(trailerIncluded); got != "2" {
+		t.Errorf("%s = %q, want 2", trailerIncluded, got)
+	}
+	if resp.Trailer.Get(trailerBytes) == "" || resp.Trailer.Get(trailerTokens) == "" {
+		t.Errorf("missing trailers: %v", resp.Trailer)
+	}
+
+	if _, md := get(t, ts, "/pack?format=md&path=greet"); !strings.Contains(md, "greet.go") || strings.Contains(md, "main.go") {
+		t.Errorf("pack md of greet:\n%s", md)
+	}
+
+	_, body = get(t, ts, "/pack?dry_run&ignore=*.md")
+	var decisions []struct {
+		Path     string
+		Included bool
+	}
+	if err := json.Unmarshal([]byte(body), &decisions); err != nil {
+		t.Fatalf("%v\n%s", err, body)
+	}
+	for _, d := range decisions {
+		if d.Path == "README.md" && d.Included {
+			t.Errorf("README.md should be excluded: %s", body)
+		}
+	}
+}
+
+func TestServer_Endpoints(t *testing.T) {
+	ts := newTestServer(t, testProject(t))
+
+	_, body := get(t, ts, "/context")
+	var pc struct {
+		Files []struct{ Path string }
+	}
+	if err := json.Unmarshal([]byte(body), &pc); err != nil || len(pc.Files) == 0 {
+		t.Errorf("context: %v\n%s", err, body)
+	}
+
+	_, body = get(t, ts, "/percentage?by=files")
+	var pct percentageOutput
+	if err := json.Unmarshal([]byte(body), &pct); err != nil || pct.By != "files" || pct.Total == 0 {
+		t.Errorf("percentage: %v\n%s", err, body)
+	}
+
+	_, body = get(t, ts, "/functions?path=greet&name=Hel")
+	var fns struct {
+		Functions []struct{ Name, Path string }
+	}
+	if err := json.Unmarshal([]byte(body), &fns); err != nil || len(fns.Functions) != 1 || fns.Functions[0].Path != "greet/greet.go" {
+		t.Errorf("functions: %v\n%s", err, body)
+	}
+
+	resp, body := get(t, ts, "/tree?format=text")
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") || !strings.Contains(body, "main.go") {
+		t.Errorf("tree text: %q\n%s", resp.Header.Get("Content-Type"), body)
+	}
+	if resp, _ := get(t, ts, "/tree"); resp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("tree default content type %q", resp.Header.Get("Content-Type"))
+	}
+}
+
+func TestServer_CancelledRequest(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	s, err := New(Options{Roots: []string{testProject(t)}, MaxInFlight: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// hold the only slot, so a request can only wait for it
+	s.slots <- struct{}{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, ep := range []string{"/tree", "/pack"} {
+		req := httptest.NewRequest(http.MethodGet, ep, nil).WithContext(ctx)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), context.Canceled.Error()) {
+			t.Errorf("%s: status %d\n%s", ep, rec.Code, rec.Body)
+		}
+	}
+	if _, err := s.run(httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx), nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("run = %v, want context.Canceled", err)
+	}
+}
+
+func TestServer_CancelFreesSlot(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	s, err := New(Options{Roots: []string{testProject(t)}, MaxInFlight: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// freeSlot takes and gives back the only slot, failing if the work of
+	// a cancelled request still holds it
+	freeSlot := func(what string) {
+		t.Helper()
+		select {
+		case s.slots <- struct{}{}:
+			<-s.slots
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: slot still held after cancellation", what)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.run(httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx), func(ctx context.Context) (any, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+		done <- err
+	}()
+	<-started
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("run = %v, want context.Canceled", err)
+	}
+	freeSlot("run")
+
+	// the work behind each endpoint gives up once the request is gone
+	for _, ep := range []string{"/tree", "/context", "/percentage", "/functions", "/pack"} {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, ep, nil).WithContext(ctx))
+		if rec.Code == http.StatusOK {
+			t.Errorf("%s: cancelled request answered\n%s", ep, rec.Body)
+		}
+		freeSlot(ep)
+	}
+}
+
+func TestServer_HistoryRevIsNotAnOption(t *testing.T) {
+	root := testProject(t)
+	if _, err := exec.LookPath("git"); err == nil {
+		exec.Command("git", "init", "-q", root).Run()
+	}
+	ts := newTestServer(t, root)
+	probe := filepath.Join(t.TempDir(), "pwned")
+	for _, rev := range []string{"--output=" + probe, "-o" + probe} {
+		if resp, body := get(t, ts, "/percentage?history&rev="+url.QueryEscape(rev)); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("rev %s: status %d\n%s", rev, resp.StatusCode, body)
+		}
+	}
+	if _, err := os.Stat(probe); err == nil {
+		t.Fatal("rev was passed to git as an option")
+	}
+}
+
+func TestServer_SymlinksStayInRoot(t *testing.T) {
+	root := testProject(t)
+	outside := t.TempDir()
+	writeTestFile(t, filepath.Join(outside, "s.go"), "package s\n\nfunc SecretFunc(password string) {}\n")
+	writeTestFile(t, filepath.Join(outside, "notes.md"), "TOP SECRET readme\n")
+	if err := os.Symlink(filepath.Join(outside, "s.go"), filepath.Join(root, "s.go")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+	if err := os.Remove(filepath.Join(root, "README.md")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "notes.md"), filepath.Join(root, "README.md")); err != nil {
+		t.Fatal(err)
+	}
+	ts := newTestServer(t, root)
+
+	for _, ep := range []string{"/functions", "/context"} {
+		resp, body := get(t, ts, ep)
+		if resp.StatusCode != http.StatusOK || strings.Contains(body, "SecretFunc") || strings.Contains(body, "TOP SECRET") {
+			t.Errorf("%s: status %d, read outside the root\n%s", ep, resp.StatusCode, body)
+		}
+	}
+}
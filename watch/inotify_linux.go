@@ -0,0 +1,171 @@
+//go:build linux
+
+package watch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_ATTRIB |
+	syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF | syscall.IN_ONLYDIR
+
+// inotify holds one watch per directory. New directories get theirs from
+// IN_CREATE and from the rescan after each batch.
+type inotify struct {
+	root string
+	skip func(rel string) bool
+	fd   int // kept apart: File.Fd would make f blocking again
+	f    *os.File
+
+	mu    sync.Mutex
+	dirs  map[int32]string // watch descriptor -> rel dir
+	byRel map[string]int32
+}
+
+func newInotify(root string, skip func(rel string) bool) (backend, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	// non-blocking, so reads go through the runtime poller and Close
+	// interrupts them
+	in := &inotify{
+		root:  root,
+		skip:  skip,
+		fd:    fd,
+		f:     os.NewFile(uintptr(fd), "inotify"),
+		dirs:  map[int32]string{},
+		byRel: map[string]int32{},
+	}
+	if err := in.addTree(); err != nil {
+		in.f.Close()
+		return nil, err
+	}
+	return in, nil
+}
+
+func (in *inotify) name() string { return "inotify" }
+
+// addTree watches every directory not watched yet. Running out of
+// watches (ENOSPC) is an error, so New can fall back to polling.
+func (in *inotify) addTree() error {
+	var firstErr error
+	walkDirs(in.root, in.skip, func(rel, abs string) {
+		if firstErr == nil {
+			firstErr = in.add(rel, abs)
+		}
+	})
+	return firstErr
+}
+
+func (in *inotify) add(rel, abs string) error {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if _, ok := in.byRel[rel]; ok {
+		return nil
+	}
+	wd, err := syscall.InotifyAddWatch(in.fd, abs, inotifyMask)
+	if err != nil {
+		if errors.Is(err, syscall.ENOENT) || errors.Is(err, syscall.ENOTDIR) {
+			return nil // gone already
+		}
+		return err
+	}
+	in.dirs[int32(wd)] = rel
+	in.byRel[rel] = int32(wd)
+	return nil
+}
+
+func (in *inotify) rescan() { in.addTree() }
+
+func (in *inotify) run(ctx context.Context, changes chan<- string) error {
+	go func() {
+		<-ctx.Done()
+		in.f.Close()
+	}()
+
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := in.f.Read(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			name := ""
+			if ev.Len > 0 {
+				raw := buf[off+syscall.SizeofInotifyEvent : off+syscall.SizeofInotifyEvent+int(ev.Len)]
+				for i, b := range raw {
+					if b == 0 {
+						raw = raw[:i]
+						break
+					}
+				}
+				name = string(raw)
+			}
+			off += syscall.SizeofInotifyEvent + int(ev.Len)
+
+			for _, rel := range in.handle(ev.Wd, ev.Mask, name) {
+				if !send(ctx, changes, rel) {
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// handle updates the watches for one event and returns the changed paths.
+func (in *inotify) handle(wd int32, mask uint32, name string) []string {
+	if mask&syscall.IN_Q_OVERFLOW != 0 {
+		return []string{"."}
+	}
+	in.mu.Lock()
+	dir, ok := in.dirs[wd]
+	if mask&syscall.IN_IGNORED != 0 {
+		delete(in.dirs, wd)
+		if in.byRel[dir] == wd {
+			delete(in.byRel, dir)
+		}
+	}
+	in.mu.Unlock()
+	if !ok || mask&syscall.IN_IGNORED != 0 {
+		return nil
+	}
+	if name == "" {
+		return []string{dir} // the directory itself was deleted or moved
+	}
+	rel := join(dir, name)
+	if mask&syscall.IN_ISDIR != 0 && mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 && !in.skip(rel) {
+		// watch the new directory and report what is already in it: files
+		// written before the watch was added send no events
+		out := []string{rel}
+		walkDirs(filepath.Join(in.root, filepath.FromSlash(rel)), func(sub string) bool {
+			return in.skip(join(rel, sub))
+		}, func(sub, abs string) {
+			r := rel
+			if sub != "." {
+				r = join(rel, sub)
+				out = append(out, r)
+			}
+			in.add(r, abs)
+			entries, _ := os.ReadDir(abs)
+			for _, e := range entries {
+				if !e.IsDir() {
+					out = append(out, join(r, e.Name()))
+				}
+			}
+		})
+		return out
+	}
+	return []string{rel}
+}
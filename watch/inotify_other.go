@@ -0,0 +1,9 @@
+//go:build !linux
+
+package watch
+
+import "errors"
+
+func newInotify(root string, skip func(rel string) bool) (backend, error) {
+	return nil, errors.New("inotify is only available on Linux")
+}
@@ -0,0 +1,96 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// poller compares snapshots of the tree every interval.
+type poller struct {
+	root     string
+	interval time.Duration
+	skip     func(rel string) bool
+	last     map[string]stamp
+}
+
+type stamp struct {
+	size    int64
+	modTime time.Time
+	isDir   bool
+}
+
+func newPoller(root string, interval time.Duration, skip func(rel string) bool) *poller {
+	p := &poller{root: root, interval: interval, skip: skip}
+	p.last = p.snapshot()
+	return p
+}
+
+func (p *poller) name() string { return "poll" }
+
+// rescan is a no-op: every poll walks the tree with the current SkipDir.
+func (p *poller) rescan() {}
+
+func (p *poller) run(ctx context.Context, changes chan<- string) error {
+	t := time.NewTicker(p.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+		cur := p.snapshot()
+		prev := p.last
+		p.last = cur
+		for rel, s := range cur {
+			if old, ok := prev[rel]; !ok || (!s.isDir && (old.size != s.size || !old.modTime.Equal(s.modTime))) {
+				if !send(ctx, changes, rel) {
+					return nil
+				}
+			}
+		}
+		for rel := range prev {
+			if _, ok := cur[rel]; !ok {
+				if !send(ctx, changes, rel) {
+					return nil
+				}
+			}
+		}
+	}
+}
+
+func (p *poller) snapshot() map[string]stamp {
+	snap := map[string]stamp{}
+	walkDirs(p.root, p.skip, func(rel, abs string) {
+		entries, err := os.ReadDir(abs)
+		if err != nil {
+			return
+		}
+		for _, e := range entries {
+			info, err := e.Info()
+			if err != nil {
+				continue
+			}
+			child := join(rel, e.Name())
+			if e.IsDir() {
+				if p.skip(child) {
+					continue
+				}
+				snap[child] = stamp{isDir: true}
+				continue
+			}
+			snap[child] = stamp{size: info.Size(), modTime: info.ModTime()}
+		}
+	})
+	return snap
+}
+
+func send(ctx context.Context, changes chan<- string, rel string) bool {
+	select {
+	case changes <- rel:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,157 @@
+// Package watch reports changes below a directory in debounced batches.
+// It uses inotify on Linux and falls back to polling elsewhere, or when
+// inotify is unavailable or out of watches.
+package watch
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+type Options struct {
+	// Debounce is how long the tree must stay quiet before a batch is
+	// delivered (0 = 200ms).
+	Debounce time.Duration
+	// Interval is how often the polling backend looks for changes
+	// (0 = 1s).
+	Interval time.Duration
+	// Poll forces the polling backend.
+	Poll bool
+	// SkipDir reports directories not to watch, by '/'-separated path
+	// relative to the root. It is asked again after every batch, so it may
+	// follow ignore files that change. .git is never watched.
+	SkipDir func(rel string) bool
+	// Ignore drops changes to these paths, e.g. the file being written by
+	// the caller.
+	Ignore func(rel string) bool
+}
+
+// backend feeds raw changes ('/'-separated, relative to the root) to the
+// channel until ctx is done.
+type backend interface {
+	run(ctx context.Context, changes chan<- string) error
+	// rescan picks up directories created or no longer skipped.
+	rescan()
+	name() string
+}
+
+// Watcher watches a directory tree. Run delivers its changes.
+type Watcher struct {
+	root string
+	opts Options
+	b    backend
+}
+
+// New prepares a watcher for root: inotify on Linux unless opts.Poll is
+// set or inotify fails to start, polling otherwise.
+func New(root string, opts Options) (*Watcher, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(abs); err != nil {
+		return nil, err
+	}
+	if opts.Debounce <= 0 {
+		opts.Debounce = 200 * time.Millisecond
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+	w := &Watcher{root: abs, opts: opts}
+	if !opts.Poll {
+		if b, err := newInotify(abs, w.skipDir); err == nil {
+			w.b = b
+		}
+	}
+	if w.b == nil {
+		w.b = newPoller(abs, opts.Interval, w.skipDir)
+	}
+	return w, nil
+}
+
+// Backend names the mechanism in use: "inotify" or "poll".
+func (w *Watcher) Backend() string { return w.b.name() }
+
+// Run calls fn with every batch of changed paths, sorted, until ctx is
+// done or fn fails. A batch of ["."] means changes were lost and anything
+// may have changed. Run returns nil when ctx is done.
+func (w *Watcher) Run(ctx context.Context, fn func(changed []string) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	changes := make(chan string, 256)
+	errc := make(chan error, 1)
+	go func() { errc <- w.b.run(ctx, changes) }()
+
+	pending := map[string]bool{}
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			<-errc
+			return nil
+		case err := <-errc:
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		case rel := <-changes:
+			if w.opts.Ignore != nil && w.opts.Ignore(rel) {
+				continue
+			}
+			pending[rel] = true
+			timer.Reset(w.opts.Debounce)
+		case <-timer.C:
+			batch := make([]string, 0, len(pending))
+			for rel := range pending {
+				batch = append(batch, rel)
+			}
+			sort.Strings(batch)
+			pending = map[string]bool{}
+			if err := fn(batch); err != nil {
+				cancel()
+				<-errc
+				return err
+			}
+			w.b.rescan()
+		}
+	}
+}
+
+func (w *Watcher) skipDir(rel string) bool {
+	if rel == ".git" || filepath.Base(rel) == ".git" {
+		return true
+	}
+	return w.opts.SkipDir != nil && w.opts.SkipDir(rel)
+}
+
+// walkDirs calls fn for root (as ".") and every directory below it that
+// skip lets through.
+func walkDirs(root string, skip func(rel string) bool, fn func(rel, abs string)) {
+	filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, p)
+		rel = filepath.ToSlash(rel)
+		if rel != "." && skip(rel) {
+			return fs.SkipDir
+		}
+		fn(rel, p)
+		return nil
+	})
+}
+
+// join is path.Join for a '/'-separated dir relative to the root.
+func join(dir, name string) string {
+	if dir == "." || dir == "" {
+		return name
+	}
+	return dir + "/" + name
+}
@@ -0,0 +1,144 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+)
+
+func writeTestFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
+
+// start runs w and returns the channel its batches arrive on.
+func start(t *testing.T, w *Watcher) <-chan []string {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	batches := make(chan []string, 16)
+	done := make(chan error, 1)
+	go func() {
+		done <- w.Run(ctx, func(changed []string) error {
+			batches <- changed
+			return nil
+		})
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Run: %v", err)
+		}
+	})
+	return batches
+}
+
+// waitFor collects batches until every path in want was seen.
+func waitFor(t *testing.T, batches <-chan []string, want ...string) []string {
+	t.Helper()
+	var seen []string
+	deadline := time.After(5 * time.Second)
+	for {
+		missing := false
+		for _, p := range want {
+			if !slices.Contains(seen, p) {
+				missing = true
+			}
+		}
+		if !missing {
+			return seen
+		}
+		select {
+		case b := <-batches:
+			seen = append(seen, b...)
+		case <-deadline:
+			t.Fatalf("saw %v, want %v", seen, want)
+		}
+	}
+}
+
+func testBackends(t *testing.T, fn func(t *testing.T, poll bool)) {
+	t.Run("poll", func(t *testing.T) { fn(t, true) })
+	if runtime.GOOS == "linux" {
+		t.Run("inotify", func(t *testing.T) { fn(t, false) })
+	}
+}
+
+func TestWatcher_Changes(t *testing.T) {
+	testBackends(t, func(t *testing.T, poll bool) {
+		root := t.TempDir()
+		writeTestFile(t, filepath.Join(root, "a.go"), "package a\n")
+		writeTestFile(t, filepath.Join(root, "skipped", "x.go"), "package x\n")
+		writeTestFile(t, filepath.Join(root, ".git", "HEAD"), "ref: refs/heads/main\n")
+
+		w, err := New(root, Options{
+			Debounce: 50 * time.Millisecond,
+			Interval: 50 * time.Millisecond,
+			Poll:     poll,
+			SkipDir:  func(rel string) bool { return rel == "skipped" },
+			Ignore:   func(rel string) bool { return rel == "out.xml" },
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := map[bool]string{true: "poll", false: "inotify"}[poll]; w.Backend() != want {
+			t.Fatalf("backend = %s, want %s", w.Backend(), want)
+		}
+		batches := start(t, w)
+
+		writeTestFile(t, filepath.Join(root, "a.go"), "package a\n\nfunc A() {}\n")
+		writeTestFile(t, filepath.Join(root, "out.xml"), "<ignored/>")
+		writeTestFile(t, filepath.Join(root, "skipped", "x.go"), "package x // changed\n")
+		writeTestFile(t, filepath.Join(root, ".git", "HEAD"), "ref: refs/heads/other\n")
+		seen := waitFor(t, batches, "a.go")
+
+		// a new directory is watched, and what lands in it is reported
+		writeTestFile(t, filepath.Join(root, "pkg", "sub", "b.go"), "package sub\n")
+		seen = append(seen, waitFor(t, batches, "pkg/sub/b.go")...)
+		time.Sleep(100 * time.Millisecond)
+		writeTestFile(t, filepath.Join(root, "pkg", "sub", "b.go"), "package sub // edited\n")
+		seen = append(seen, waitFor(t, batches, "pkg/sub/b.go")...)
+
+		if err := os.Remove(filepath.Join(root, "a.go")); err != nil {
+			t.Fatal(err)
+		}
+		seen = append(seen, waitFor(t, batches, "a.go")...)
+
+		for _, p := range seen {
+			if p == "out.xml" || p == ".git/HEAD" || p == "skipped/x.go" {
+				t.Errorf("unexpected change %s in %v", p, seen)
+			}
+		}
+	})
+}
+
+func TestWatcher_CallbackError(t *testing.T) {
+	root := t.TempDir()
+	w, err := New(root, Options{Debounce: 20 * time.Millisecond, Interval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := os.ErrClosed
+	done := make(chan error, 1)
+	go func() {
+		done <- w.Run(context.Background(), func([]string) error { return stop })
+	}()
+	time.Sleep(50 * time.Millisecond)
+	writeTestFile(t, filepath.Join(root, "a.txt"), "a")
+	select {
+	case err := <-done:
+		if err != stop {
+			t.Errorf("Run = %v, want %v", err, stop)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the callback failed")
+	}
+}